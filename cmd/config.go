@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/config"
+)
+
+const configUsage = "usage: usafi-hub-cleaning-service [flags] config print"
+
+// RunConfig implements the config subcommand. print shows the effective configuration with
+// secrets redacted, then reports whether it would pass validation at startup.
+func RunConfig(config config.Config, args []string) {
+	if len(args) != 1 || args[0] != "print" {
+		fmt.Println(configUsage)
+		os.Exit(2)
+	}
+
+	if err := config.Print(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := config.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		os.Exit(1)
+	}
+}
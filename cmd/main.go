@@ -1,31 +1,383 @@
 package cmd
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	_ "time/tzdata"
+
 	"github.com/AntonyIS/usafi-hub-cleaning-service/config"
 	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/adapter/app"
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/adapter/auth"
 	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/adapter/logger"
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/adapter/metrics"
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/adapter/moderation"
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/adapter/payment"
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/adapter/pdf"
 	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/adapter/repository"
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/adapter/tracing"
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/ports"
 	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/services"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
-func RunService() {
-	logger, err := logger.NewDefaultLogger()
+const usage = "usage: usafi-hub-cleaning-service [flags] [migrate [up | down [steps] | status] | config print]"
+
+// Execute runs the command args name, serving the API when there is none. Flags overriding
+// settings come before the command, e.g. --config app.yaml migrate up.
+func Execute(args []string) {
+	console, err := logger.NewStructuredLogger(logger.Options{})
 	if err != nil {
 		panic(err)
 	}
+	cfg, args, err := config.NewConfig(args, console)
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Println(usage)
+		os.Exit(0)
+	}
+	if err != nil {
+		console.Error(err.Error())
+		os.Exit(2)
+	}
+
+	command := ""
+	if len(args) > 0 {
+		command = args[0]
+	}
+	if command == "config" {
+		RunConfig(*cfg, args[1:])
+		return
+	}
+
+	if err := cfg.Validate(); err != nil {
+		console.Error("invalid configuration:\n" + err.Error())
+		os.Exit(2)
+	}
+	switch command {
+	case "":
+		RunService(*cfg)
+	case "migrate":
+		RunMigrations(*cfg, args[1:])
+	default:
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+}
 
-	config, err := config.NewConfig(logger)
+// RunService serves the API until the process is asked to stop, then shuts it down gracefully
+func RunService(config config.Config) {
+	logger, err := newLogger(config)
 	if err != nil {
 		panic(err)
 	}
 
-	serviceRepo, _ := repository.NewServicePostgresClient(*config)
-	requestRepo, _ := repository.NewRequestPostgresClient(*config)
-	reviewRepo, _ := repository.NewReviewPostgresClient(*config)
+	if err := runService(config, logger); err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+}
+
+// runService fails fast when a dependency can't be set up. Once serving, SIGINT or SIGTERM stops
+// new connections, drains in-flight requests and background workers, then closes the database
+// pools and flushes the remaining spans.
+func runService(config config.Config, logger ports.LoggerService) error {
+	signals, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+
+	location := config.Location("TIMEZONE")
+	shutdownTimeout := config.Duration("SHUTDOWN_TIMEOUT")
+
+	tracer, err := newTracer(config, logger)
+	if err != nil {
+		return err
+	}
+	if tracer != nil {
+		services.SetTracer(tracer)
+		repository.SetTracer(tracer)
+		// Deferred first so it runs last, after everything that starts spans has stopped
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			defer cancel()
+			if err := tracer.Shutdown(ctx); err != nil {
+				logger.Warning(fmt.Sprintf("flushing spans: %v", err))
+			}
+		}()
+	}
+
+	var (
+		serviceRepo      ports.ServiceRepository
+		requestRepo      ports.RequestRepository
+		reviewRepo       ports.ReviewRepository
+		availabilityRepo ports.AvailabilityRepository
+		cleanerRepo      ports.CleanerProfileRepository
+		invoiceRepo      ports.InvoiceRepository
+		paymentRepo      ports.PaymentRepository
+		recurringRepo    ports.RecurringBookingRepository
+		unitOfWork       ports.UnitOfWork
+		pool             *sql.DB
+	)
+
+	switch config.STORAGE_BACKEND {
+	case "memory":
+		memoryRepo := repository.NewMemoryClient()
+		serviceRepo, requestRepo, reviewRepo, availabilityRepo, cleanerRepo, invoiceRepo, paymentRepo, recurringRepo = memoryRepo, memoryRepo, memoryRepo, memoryRepo, memoryRepo, memoryRepo, memoryRepo, memoryRepo
+		unitOfWork = memoryRepo
+	default:
+		pool, err = repository.NewPostgresDB(config)
+		if err != nil {
+			return err
+		}
+		// Closed once the drain below is done, after the last query has finished
+		defer pool.Close()
+
+		if err := checkSchema(pool, config); err != nil {
+			return err
+		}
+		postgresRepo := repository.NewPostgresClient(pool, config)
+		serviceRepo, requestRepo, reviewRepo, availabilityRepo, cleanerRepo, invoiceRepo, paymentRepo, recurringRepo = postgresRepo, postgresRepo, postgresRepo, postgresRepo, postgresRepo, postgresRepo, postgresRepo, postgresRepo
+		unitOfWork = postgresRepo
+	}
 
 	serviceService := services.NewServiceServiceManagement(serviceRepo, logger)
-	requestService := services.NewRequestServiceManagement(requestRepo, logger)
-	reviewService := services.NewReviewServiceManagement(reviewRepo, logger)
+	availabilityService := services.NewAvailabilityServiceManagement(availabilityRepo, requestRepo, serviceRepo, location, logger)
+	pricingService := services.NewPricingServiceManagement(serviceRepo, pricingRules(config), location, logger)
+	invoiceRenderer := pdf.NewInvoiceRenderer(config.COMPANY_NAME, location)
+	invoiceService := services.NewInvoiceServiceManagement(invoiceRepo, requestRepo, pricingService, invoiceRenderer, config.Percent("TAX_RATE_PERCENT"), logger)
+	var requestService ports.RequestService = services.NewRequestServiceManagement(requestRepo, serviceRepo, unitOfWork, availabilityService, pricingService, invoiceService, logger)
+	paymentProvider, err := newPaymentProvider(config)
+	if err != nil {
+		return err
+	}
+	paymentService := services.NewPaymentServiceManagement(paymentRepo, requestRepo, invoiceRepo, unitOfWork, paymentProvider, logger)
+	contentFilter, err := moderation.LoadWordlist(config.MODERATION_WORDLIST_FILE)
+	if err != nil {
+		return fmt.Errorf("MODERATION_WORDLIST_FILE: %w", err)
+	}
+	var reviewService ports.ReviewService = services.NewReviewServiceManagement(reviewRepo, requestRepo, unitOfWork, contentFilter, config.Duration("REVIEW_WINDOW"), logger)
+	var matchingService ports.MatchingService = services.NewMatchingServiceManagement(cleanerRepo, requestRepo, reviewRepo, requestService, availabilityService, services.NewWeightedMatchingStrategy(), logger)
+
+	recorder := metrics.NewMetrics()
+	var checks []ports.HealthCheck
+	if pool != nil {
+		recorder.AddPool("postgres", pool)
+		migrator, err := repository.NewMigrator(pool, config)
+		if err != nil {
+			return err
+		}
+		checks = append(checks, repository.NewPingCheck("postgres", pool), repository.NewMigrationCheck(migrator))
+	}
+	if check, ok := paymentProvider.(ports.HealthCheck); ok {
+		checks = append(checks, check)
+	}
+	requestService = metrics.InstrumentRequests(requestService, recorder)
+	matchingService = metrics.InstrumentMatching(matchingService, recorder)
+	reviewService = metrics.InstrumentReviews(reviewService, recorder)
+	// Occurrences are booked through the instrumented request service, so they are counted too
+	recurringService := services.NewRecurringBookingServiceManagement(recurringRepo, requestRepo, serviceRepo, requestService, unitOfWork, config.Duration("RECURRING_HORIZON"), location, logger)
+
+	verifier, err := newTokenVerifier(config, logger)
+	if err != nil {
+		return err
+	}
+
+	router := app.InitGinRoutes(serviceService, requestService, reviewService, availabilityService, matchingService, pricingService, invoiceService, paymentService, recurringService, checks, verifier, recorder, tracer, config, logger)
+	server := newServer(config, router)
+	// Listen before starting anything else, so a port that is taken fails startup
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return err
+	}
+
+	// Workers get their own context, they are stopped only after the last request has been served
+	workers, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	var running sync.WaitGroup
+
+	// The auto assign worker only runs when an interval is configured
+	if interval := config.Duration("AUTO_ASSIGN_INTERVAL"); interval > 0 {
+		worker := services.NewAutoAssignWorker(matchingService, requestRepo, interval, logger)
+		running.Add(1)
+		go func() {
+			defer running.Done()
+			worker.Run(workers)
+		}()
+	}
+
+	// Likewise the recurring booking generator, series booked through the API get their first
+	// occurrences right away either way
+	if interval := config.Duration("RECURRING_GENERATE_INTERVAL"); interval > 0 {
+		worker := services.NewRecurringBookingWorker(recurringService, interval, logger)
+		running.Add(1)
+		go func() {
+			defer running.Done()
+			worker.Run(workers)
+		}()
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(listener)
+	}()
+	logger.Info(fmt.Sprintf("Server running on port 0.0.0.0:%s", config.SERVER_PORT))
+
+	select {
+	case err := <-served:
+		stopWorkers()
+		running.Wait()
+		return fmt.Errorf("serving HTTP: %w", err)
+	case <-signals.Done():
+	}
+	// A second signal kills the process instead of waiting for the drain
+	stopSignals()
+	logger.Info("Shutting down, draining in-flight requests")
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		// The workers still have to stop before the deferred closes pull the pool from under them,
+		// the drain having used up ctx they get a timeout of their own
+		workersCtx, cancelWorkers := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancelWorkers()
+		return errors.Join(fmt.Errorf("draining HTTP requests: %w", err), drainWorkers(workersCtx, stopWorkers, &running))
+	}
+	if err := drainWorkers(ctx, stopWorkers, &running); err != nil {
+		return err
+	}
+
+	logger.Info("Server stopped")
+	return nil
+}
+
+// drainWorkers stops the background workers and waits for them to return until ctx is done
+func drainWorkers(ctx context.Context, stop context.CancelFunc, running *sync.WaitGroup) error {
+	stop()
+	stopped := make(chan struct{})
+	go func() {
+		running.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("stopping background workers: %w", ctx.Err())
+	}
+}
+
+// newServer sets timeouts so slow or idle clients can't hold connections open indefinitely
+func newServer(config config.Config, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              fmt.Sprintf(":%s", config.SERVER_PORT),
+		Handler:           handler,
+		ReadTimeout:       config.Duration("SERVER_READ_TIMEOUT"),
+		ReadHeaderTimeout: config.Duration("SERVER_READ_TIMEOUT"),
+		WriteTimeout:      config.Duration("SERVER_WRITE_TIMEOUT"),
+		IdleTimeout:       config.Duration("SERVER_IDLE_TIMEOUT"),
+	}
+}
+
+// newLogger builds the logger the configuration describes
+func newLogger(cfg config.Config) (ports.LoggerService, error) {
+	level, err := logger.ParseLevel(cfg.LOG_LEVEL)
+	if err != nil {
+		return nil, fmt.Errorf("LOG_LEVEL: %w", err)
+	}
 
-	app.InitGinRoutes(serviceService, requestService, reviewService, *config, logger)
+	structured, err := logger.NewStructuredLogger(logger.Options{
+		Level:      level,
+		FilePath:   cfg.LOG_FILE,
+		MaxSizeMB:  cfg.Int("LOG_MAX_SIZE_MB"),
+		MaxAge:     cfg.Duration("LOG_MAX_AGE"),
+		MaxBackups: cfg.Int("LOG_MAX_BACKUPS"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return structured.With(map[string]interface{}{"env": cfg.ENV}), nil
+}
+
+func pricingRules(config config.Config) domain.PricingRules {
+	return domain.PricingRules{
+		WeekendSurchargeBps:    config.Percent("WEEKEND_SURCHARGE_PERCENT"),
+		AfterHoursSurchargeBps: config.Percent("AFTER_HOURS_SURCHARGE_PERCENT"),
+		BusinessHoursStart:     config.BUSINESS_HOURS_START,
+		BusinessHoursEnd:       config.BUSINESS_HOURS_END,
+		Discounts:              config.Discounts("DISCOUNT_CODES"),
+		Cancellation: domain.CancellationPolicy{
+			FreeCancellationWindow: config.Duration("FREE_CANCELLATION_WINDOW"),
+			LateCancellationFeeBps: config.Percent("LATE_CANCELLATION_FEE_PERCENT"),
+			FreeRescheduleWindow:   config.Duration("FREE_RESCHEDULE_WINDOW"),
+			LateRescheduleFeeBps:   config.Percent("LATE_RESCHEDULE_FEE_PERCENT"),
+		},
+	}
+}
+
+// newTokenVerifier accepts tokens signed with SECRET_KEY, with the keys published at JWKS_URL, or both
+func newTokenVerifier(config config.Config, logger ports.LoggerService) (ports.TokenVerifier, error) {
+	return auth.NewTokenVerifier(auth.VerifierConfig{
+		HMACSecret:  config.SECRET_KEY,
+		JWKSSource:  config.JWKS_URL,
+		JWKSRefresh: config.Duration("JWKS_REFRESH_INTERVAL"),
+		Issuer:      config.JWT_ISSUER,
+		Audience:    config.JWT_AUDIENCE,
+		ClockSkew:   config.Duration("JWT_CLOCK_SKEW"),
+	}, logger)
+}
+
+// newTracer returns nil when TRACING_EXPORTER is none, leaving tracing off
+func newTracer(config config.Config, logger ports.LoggerService) (*tracing.Tracer, error) {
+	var (
+		exporter sdktrace.SpanExporter
+		err      error
+	)
+	switch config.TRACING_EXPORTER {
+	case "none":
+		return nil, nil
+	case "stdout":
+		exporter, err = tracing.NewWriterExporter(os.Stdout)
+	case "otlp":
+		exporter, err = tracing.NewOTLPExporter(config.OTEL_EXPORTER_OTLP_ENDPOINT)
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", config.TRACING_EXPORTER)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tracing.NewTracer(exporter, config.OTEL_SERVICE_NAME, logger), nil
+}
+
+func newPaymentProvider(config config.Config) (ports.PaymentProvider, error) {
+	switch config.PAYMENT_PROVIDER {
+	case "simulator":
+		return payment.NewSimulator(), nil
+	case "mpesa":
+		return payment.NewMpesaClient(payment.MpesaConfig{
+			BaseURL:        config.MPESA_BASE_URL,
+			ConsumerKey:    config.MPESA_CONSUMER_KEY,
+			ConsumerSecret: config.MPESA_CONSUMER_SECRET,
+			ShortCode:      config.MPESA_SHORTCODE,
+			PassKey:        config.MPESA_PASSKEY,
+			CallbackURL:    config.MPESA_CALLBACK_URL,
+		})
+	default:
+		return nil, fmt.Errorf("unknown payment provider %q", config.PAYMENT_PROVIDER)
+	}
+}
+
+// checkSchema refuses to start the service against a database that still has migrations to apply
+func checkSchema(db *sql.DB, config config.Config) error {
+	migrator, err := repository.NewMigrator(db, config)
+	if err != nil {
+		return err
+	}
+	return migrator.EnsureCurrent(context.Background())
 }
@@ -1,116 +1,241 @@
 package config
 
 import (
-	"os"
-
 	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/ports"
-	"github.com/joho/godotenv"
 )
 
+// Config holds every setting of the service. Each field tagged config is a setting, named SERVER_PORT
+// in the environment, server_port in config files and --server-port on the command line. The tag
+// lists the rules Validate checks the setting against.
 type Config struct {
-	ENV               string
-	SECRET_KEY        string
-	SERVER_PORT       string
-	POSTGRES_DB       string
-	POSTGRES_HOST     string
-	POSTGRES_PORT     string
-	POSTGRES_USER     string
-	POSTGRES_PASSWORD string
-	SERVICE_TABLE     string
-	REVIEWS_TABLE     string
-	REQUEST_TABLE     string
-	DEBUG             bool
-	TEST              bool
+	ENV                           string
+	SECRET_KEY                    string `config:"secret"`
+	SERVER_PORT                   string `config:"required,port"`
+	SERVER_READ_TIMEOUT           string `config:"required,duration"`
+	SERVER_WRITE_TIMEOUT          string `config:"required,duration"`
+	SERVER_IDLE_TIMEOUT           string `config:"required,duration"`
+	SHUTDOWN_TIMEOUT              string `config:"required,duration"`
+	CORS_ALLOWED_ORIGINS          string `config:"required"`
+	POSTGRES_DB                   string `config:""`
+	POSTGRES_HOST                 string `config:""`
+	POSTGRES_PORT                 string `config:"port"`
+	POSTGRES_USER                 string `config:""`
+	POSTGRES_PASSWORD             string `config:"secret"`
+	POSTGRES_SSLMODE              string `config:"oneof=disable|allow|prefer|require|verify-ca|verify-full"`
+	POSTGRES_DSN                  string `config:"secret"`
+	POSTGRES_MAX_OPEN_CONNS       string `config:"required,int"`
+	POSTGRES_MAX_IDLE_CONNS       string `config:"required,int"`
+	POSTGRES_CONN_MAX_LIFETIME    string `config:"required,duration"`
+	POSTGRES_CONN_MAX_IDLE_TIME   string `config:"required,duration"`
+	SERVICE_TABLE                 string `config:""`
+	REVIEWS_TABLE                 string `config:""`
+	REQUEST_TABLE                 string `config:""`
+	MIGRATIONS_TABLE              string
+	TABLE_PREFIX                  string `config:""`
+	WORKING_HOURS_TABLE           string
+	AVAILABILITY_EXCEPTIONS_TABLE string
+	TIME_OFF_TABLE                string
+	CLEANER_PROFILES_TABLE        string
+	INVOICES_TABLE                string
+	PAYMENTS_TABLE                string
+	RATING_AGGREGATES_TABLE       string
+	RECURRING_BOOKINGS_TABLE      string
+	TIMEZONE                      string `config:"required,timezone"`
+	STORAGE_BACKEND               string `config:"required,oneof=postgres|memory"`
+	AUTO_ASSIGN_INTERVAL          string `config:"duration"`
+	RECURRING_HORIZON             string `config:"required,duration"`
+	RECURRING_GENERATE_INTERVAL   string `config:"duration"`
+	WEEKEND_SURCHARGE_PERCENT     string `config:"required,percent"`
+	AFTER_HOURS_SURCHARGE_PERCENT string `config:"required,percent"`
+	BUSINESS_HOURS_START          string `config:"required,clock"`
+	BUSINESS_HOURS_END            string `config:"required,clock"`
+	DISCOUNT_CODES                string `config:"discounts"`
+	TAX_RATE_PERCENT              string `config:"required,percent"`
+	FREE_CANCELLATION_WINDOW      string `config:"required,duration"`
+	LATE_CANCELLATION_FEE_PERCENT string `config:"required,percent"`
+	FREE_RESCHEDULE_WINDOW        string `config:"required,duration"`
+	LATE_RESCHEDULE_FEE_PERCENT   string `config:"required,percent"`
+	REVIEW_WINDOW                 string `config:"required,duration"`
+	MODERATION_WORDLIST_FILE      string `config:""`
+	COMPANY_NAME                  string `config:"required"`
+	PAYMENT_PROVIDER              string `config:"required,oneof=simulator|mpesa"`
+	PAYMENT_CALLBACK_TOKEN        string `config:"required,secret"`
+	MPESA_BASE_URL                string `config:"url"`
+	MPESA_CONSUMER_KEY            string `config:"secret"`
+	MPESA_CONSUMER_SECRET         string `config:"secret"`
+	MPESA_SHORTCODE               string `config:""`
+	MPESA_PASSKEY                 string `config:"secret"`
+	MPESA_CALLBACK_URL            string `config:"url"`
+	JWKS_URL                      string `config:""`
+	JWKS_REFRESH_INTERVAL         string `config:"required,duration"`
+	JWT_ISSUER                    string `config:""`
+	JWT_AUDIENCE                  string `config:""`
+	JWT_CLOCK_SKEW                string `config:"required,duration"`
+	QUERY_TIMEOUT                 string `config:"required,duration"`
+	LOG_LEVEL                     string `config:"required,oneof=debug|info|warn|warning|error"`
+	LOG_FILE                      string `config:""`
+	LOG_MAX_SIZE_MB               string `config:"required,int"`
+	LOG_MAX_AGE                   string `config:"required,duration"`
+	LOG_MAX_BACKUPS               string `config:"required,int"`
+	TRACING_EXPORTER              string `config:"required,oneof=none|stdout|otlp"`
+	OTEL_EXPORTER_OTLP_ENDPOINT   string `config:"url"`
+	OTEL_SERVICE_NAME             string `config:"required"`
+	DEBUG                         bool   `config:""`
+	TEST                          bool   `config:""`
 }
 
-func NewConfig(logger ports.LoggerService) (*Config, error) {
-	ENV := os.Getenv("ENV")
+// NewConfig layers the settings: the defaults of the environment named by --env or ENV, then the
+// file named by --config or CONFIG_FILE, then environment variables, then the other flags. args
+// are the command line arguments, whatever follows the flags is returned. The result still needs
+// to be checked with Validate.
+func NewConfig(args []string, logger ports.LoggerService) (*Config, []string, error) {
+	flags, err := parseFlags(args)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	switch ENV {
-	case "development":
-		err := godotenv.Load(".env")
-		if err != nil {
+	config := defaults(flags.env)
+	if config.ENV == "development" {
+		if err := loadDotEnv(); err != nil {
 			logger.Error(err.Error())
-			return nil, err
+			return nil, nil, err
+		}
+	}
+
+	if flags.file != "" {
+		if err := config.loadFile(flags.file); err != nil {
+			return nil, nil, err
 		}
+	}
+	if err := config.loadEnv(); err != nil {
+		return nil, nil, err
+	}
+	for _, setting := range settings() {
+		if value, ok := flags.values[setting.name]; ok {
+			if err := config.set(setting, value); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
 
+	// Debug lines are only wanted outside production unless asked for
+	if config.LOG_LEVEL == "" && config.DEBUG {
+		config.LOG_LEVEL = "debug"
 	}
+	if config.LOG_LEVEL == "" {
+		config.LOG_LEVEL = "info"
+	}
+
+	config.MIGRATIONS_TABLE = config.TABLE_PREFIX + "schema_migrations"
+	config.WORKING_HOURS_TABLE = config.TABLE_PREFIX + "working_hours"
+	config.AVAILABILITY_EXCEPTIONS_TABLE = config.TABLE_PREFIX + "availability_exceptions"
+	config.TIME_OFF_TABLE = config.TABLE_PREFIX + "time_off"
+	config.CLEANER_PROFILES_TABLE = config.TABLE_PREFIX + "cleaner_profiles"
+	config.INVOICES_TABLE = config.TABLE_PREFIX + "invoices"
+	config.PAYMENTS_TABLE = config.TABLE_PREFIX + "payments"
+	config.RATING_AGGREGATES_TABLE = config.TABLE_PREFIX + "rating_aggregates"
+	config.RECURRING_BOOKINGS_TABLE = config.TABLE_PREFIX + "recurring_bookings"
 
-	var (
-		SECRET_KEY        = os.Getenv("SECRET_KEY")
-		SERVER_PORT       = "5001"
-		POSTGRES_DB       = "usafihub-cleaner-service"
-		POSTGRES_HOST     = "postgres"
-		POSTGRES_PORT     = "5432"
-		POSTGRES_USER     = "postgres"
-		POSTGRES_PASSWORD = os.Getenv("POSTGRES_PASSWORD")
-		SERVICE_TABLE     = ""
-		REVIEWS_TABLE     = ""
-		REQUEST_TABLE     = ""
-		DEBUG             = false
-		TEST              = false
-	)
+	return &config, flags.args, nil
+}
+
+// defaults are the settings of env before anything else is applied
+func defaults(env string) Config {
+	config := Config{
+		ENV:                           env,
+		SERVER_PORT:                   "5001",
+		SERVER_READ_TIMEOUT:           "15s",
+		SERVER_WRITE_TIMEOUT:          "30s",
+		SERVER_IDLE_TIMEOUT:           "60s",
+		SHUTDOWN_TIMEOUT:              "30s",
+		CORS_ALLOWED_ORIGINS:          "*",
+		POSTGRES_DB:                   "usafihub-cleaner-service",
+		POSTGRES_HOST:                 "postgres",
+		POSTGRES_PORT:                 "5432",
+		POSTGRES_USER:                 "postgres",
+		POSTGRES_SSLMODE:              "disable",
+		POSTGRES_MAX_OPEN_CONNS:       "20",
+		POSTGRES_MAX_IDLE_CONNS:       "10",
+		POSTGRES_CONN_MAX_LIFETIME:    "30m",
+		POSTGRES_CONN_MAX_IDLE_TIME:   "5m",
+		TIMEZONE:                      "Africa/Nairobi",
+		STORAGE_BACKEND:               "postgres",
+		RECURRING_HORIZON:             "672h",
+		RECURRING_GENERATE_INTERVAL:   "1h",
+		WEEKEND_SURCHARGE_PERCENT:     "20",
+		AFTER_HOURS_SURCHARGE_PERCENT: "25",
+		BUSINESS_HOURS_START:          "08:00",
+		BUSINESS_HOURS_END:            "18:00",
+		TAX_RATE_PERCENT:              "16",
+		FREE_CANCELLATION_WINDOW:      "24h",
+		LATE_CANCELLATION_FEE_PERCENT: "50",
+		FREE_RESCHEDULE_WINDOW:        "24h",
+		LATE_RESCHEDULE_FEE_PERCENT:   "10",
+		REVIEW_WINDOW:                 "336h",
+		COMPANY_NAME:                  "Usafi Hub",
+		PAYMENT_PROVIDER:              "simulator",
+		JWKS_REFRESH_INTERVAL:         "15m",
+		JWT_CLOCK_SKEW:                "30s",
+		QUERY_TIMEOUT:                 "5s",
+		LOG_FILE:                      "logs/logs.log",
+		LOG_MAX_SIZE_MB:               "100",
+		LOG_MAX_AGE:                   "24h",
+		LOG_MAX_BACKUPS:               "7",
+		TRACING_EXPORTER:              "none",
+		OTEL_EXPORTER_OTLP_ENDPOINT:   "http://localhost:4318",
+		OTEL_SERVICE_NAME:             "usafi-hub-cleaning-service",
+	}
 
-	switch ENV {
+	switch env {
 	case "production":
-		TEST = false
-		DEBUG = false
+		config.TEST = false
+		config.DEBUG = false
+		config.PAYMENT_PROVIDER = "mpesa"
 
 	case "production_test":
-		TEST = true
-		DEBUG = true
-		SERVICE_TABLE = "Prod_Test_Service"
-		REVIEWS_TABLE = "Prod_Test_Review"
-		REQUEST_TABLE = "Prod_Test_request"
+		config.TEST = true
+		config.DEBUG = true
+		config.SERVICE_TABLE = "Prod_Test_Service"
+		config.REVIEWS_TABLE = "Prod_Test_Review"
+		config.REQUEST_TABLE = "Prod_Test_request"
+		config.TABLE_PREFIX = "Prod_Test_"
 
 	case "development":
-		TEST = true
-		DEBUG = true
-		POSTGRES_HOST = "localhost"
-		SERVICE_TABLE = "Dev_Service"
-		REVIEWS_TABLE = "Dev_Review"
-		REQUEST_TABLE = "Dev_request"
+		config.TEST = true
+		config.DEBUG = true
+		config.POSTGRES_HOST = "localhost"
+		config.SERVICE_TABLE = "Dev_Service"
+		config.REVIEWS_TABLE = "Dev_Review"
+		config.REQUEST_TABLE = "Dev_request"
+		config.TABLE_PREFIX = "Dev_"
 
 	case "development_test":
-		TEST = true
-		DEBUG = true
-		SECRET_KEY = "testsecret"
-		POSTGRES_PASSWORD = "pass1234"
-		POSTGRES_HOST = "localhost"
-		SERVICE_TABLE = "Test_Dev_Service"
-		REVIEWS_TABLE = "Test_Dev_Review"
-		REQUEST_TABLE = "Test_Dev_request"
+		config.TEST = true
+		config.DEBUG = true
+		config.SECRET_KEY = "testsecret"
+		config.PAYMENT_CALLBACK_TOKEN = "testcallbacktoken"
+		config.POSTGRES_PASSWORD = "pass1234"
+		config.POSTGRES_HOST = "localhost"
+		config.SERVICE_TABLE = "Test_Dev_Service"
+		config.REVIEWS_TABLE = "Test_Dev_Review"
+		config.REQUEST_TABLE = "Test_Dev_request"
+		config.TABLE_PREFIX = "Test_Dev_"
 
 	case "docker":
-		TEST = true
-		DEBUG = true
-		SERVICE_TABLE = "Docker_Service"
-		REVIEWS_TABLE = "Docker_Review"
-		REQUEST_TABLE = "Docker_request"
+		config.TEST = true
+		config.DEBUG = true
+		config.SERVICE_TABLE = "Docker_Service"
+		config.REVIEWS_TABLE = "Docker_Review"
+		config.REQUEST_TABLE = "Docker_request"
+		config.TABLE_PREFIX = "Docker_"
 
 	case "docker_test":
-		TEST = true
-		DEBUG = true
-		SERVICE_TABLE = "Test_Docker_Service"
-		REVIEWS_TABLE = "Test_Docker_Review"
-		REQUEST_TABLE = "Test_Docker_request"
+		config.TEST = true
+		config.DEBUG = true
+		config.SERVICE_TABLE = "Test_Docker_Service"
+		config.REVIEWS_TABLE = "Test_Docker_Review"
+		config.REQUEST_TABLE = "Test_Docker_request"
+		config.TABLE_PREFIX = "Test_Docker_"
 	}
-
-	config := Config{
-		ENV:               ENV,
-		SECRET_KEY:        SECRET_KEY,
-		SERVER_PORT:       SERVER_PORT,
-		POSTGRES_DB:       POSTGRES_DB,
-		POSTGRES_HOST:     POSTGRES_HOST,
-		POSTGRES_PORT:     POSTGRES_PORT,
-		POSTGRES_USER:     POSTGRES_USER,
-		POSTGRES_PASSWORD: POSTGRES_PASSWORD,
-		SERVICE_TABLE:     SERVICE_TABLE,
-		REVIEWS_TABLE:     REVIEWS_TABLE,
-		REQUEST_TABLE:     REQUEST_TABLE,
-		DEBUG:             DEBUG,
-		TEST:              TEST,
-	}
-
-	return &config, nil
+	return config
 }
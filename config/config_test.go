@@ -0,0 +1,372 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/ports"
+	"gopkg.in/yaml.v3"
+)
+
+type testLogger struct{}
+
+func (testLogger) Debug(message string)   {}
+func (testLogger) Info(message string)    {}
+func (testLogger) Warning(message string) {}
+func (testLogger) Error(message string)   {}
+
+func (l testLogger) With(fields map[string]interface{}) ports.LoggerService { return l }
+func (l testLogger) WithContext(ctx context.Context) ports.LoggerService    { return l }
+
+// clearEnv blanks every variable NewConfig reads, so the environment running the tests can't leak
+// into them. Empty variables are ignored by loadEnv.
+func clearEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv("ENV", "production")
+	t.Setenv("CONFIG_FILE", "")
+	for _, s := range settings() {
+		t.Setenv(s.name, "")
+	}
+}
+
+// writeFile writes content to a file called name in a temporary directory and returns its path
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// valueOf reads the field called name from c, settings or not
+func valueOf(c Config, name string) string {
+	return c.get(setting{name: name})
+}
+
+func TestNewConfigLayers(t *testing.T) {
+	tests := []struct {
+		name string
+		// file is written to a temporary file called fileName and passed in CONFIG_FILE
+		fileName string
+		file     string
+		env      map[string]string
+		args     []string
+		want     map[string]string
+		rest     []string
+	}{
+		{
+			name: "defaults of the environment",
+			want: map[string]string{"SERVER_PORT": "5001", "STORAGE_BACKEND": "postgres", "DEBUG": "false", "LOG_LEVEL": "info"},
+		},
+		{
+			name: "defaults of another environment",
+			env:  map[string]string{"ENV": "docker"},
+			want: map[string]string{"DEBUG": "true", "LOG_LEVEL": "debug", "TABLE_PREFIX": "Docker_", "PAYMENTS_TABLE": "Docker_payments"},
+		},
+		{
+			name:     "yaml file over the defaults",
+			fileName: "app.yaml",
+			file:     "server_port: 6000\ndebug: true\npostgres_max_open_conns: 30\ntable_prefix: Staging_\n",
+			want:     map[string]string{"SERVER_PORT": "6000", "DEBUG": "true", "POSTGRES_MAX_OPEN_CONNS": "30", "SHUTDOWN_TIMEOUT": "30s", "INVOICES_TABLE": "Staging_invoices"},
+		},
+		{
+			name:     "toml file over the defaults",
+			fileName: "app.toml",
+			file:     "server_port = 6001\nstorage_backend = \"memory\"\n",
+			want:     map[string]string{"SERVER_PORT": "6001", "STORAGE_BACKEND": "memory"},
+		},
+		{
+			name:     "yaml lists are joined with commas",
+			fileName: "app.yml",
+			file:     "cors_allowed_origins:\n  - https://usafihub.co.ke\n  - https://admin.usafihub.co.ke\n",
+			want:     map[string]string{"CORS_ALLOWED_ORIGINS": "https://usafihub.co.ke,https://admin.usafihub.co.ke"},
+		},
+		{
+			name:     "toml lists are joined with commas",
+			fileName: "app.toml",
+			file:     "discount_codes = [\"WELCOME=10%\", \"LOYAL=500\"]\n",
+			want:     map[string]string{"DISCOUNT_CODES": "WELCOME=10%,LOYAL=500"},
+		},
+		{
+			name:     "environment over the file",
+			fileName: "app.yaml",
+			file:     "server_port: 6000\nlog_level: warn\n",
+			env:      map[string]string{"SERVER_PORT": "7000"},
+			want:     map[string]string{"SERVER_PORT": "7000", "LOG_LEVEL": "warn"},
+		},
+		{
+			name:     "flags over the environment",
+			fileName: "app.yaml",
+			file:     "server_port: 6000\n",
+			env:      map[string]string{"SERVER_PORT": "7000", "TIMEZONE": "UTC"},
+			args:     []string{"--server-port", "8000", "--debug=true"},
+			want:     map[string]string{"SERVER_PORT": "8000", "TIMEZONE": "UTC", "DEBUG": "true", "LOG_LEVEL": "debug"},
+		},
+		{
+			name: "env flag over ENV",
+			env:  map[string]string{"ENV": "docker"},
+			args: []string{"--env", "docker_test"},
+			want: map[string]string{"ENV": "docker_test", "TABLE_PREFIX": "Test_Docker_"},
+		},
+		{
+			name: "arguments after the flags are returned",
+			args: []string{"--server-port", "8000", "migrate", "down", "2"},
+			want: map[string]string{"SERVER_PORT": "8000"},
+			rest: []string{"migrate", "down", "2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			if tt.file != "" {
+				t.Setenv("CONFIG_FILE", writeFile(t, tt.fileName, tt.file))
+			}
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			config, rest, err := NewConfig(tt.args, testLogger{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, want := range tt.want {
+				if got := valueOf(*config, name); got != want {
+					t.Errorf("expected %s to be %q, got %q", name, want, got)
+				}
+			}
+			if strings.Join(rest, " ") != strings.Join(tt.rest, " ") {
+				t.Errorf("expected the arguments %v to be left, got %v", tt.rest, rest)
+			}
+		})
+	}
+}
+
+func TestNewConfigRefusesBadSettings(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		file     string
+		env      map[string]string
+		args     []string
+		err      string
+	}{
+		{name: "unknown file key", fileName: "app.yaml", file: "server_prot: 6000\n", err: `unknown setting "server_prot"`},
+		{name: "settings are named in lower case", fileName: "app.toml", file: "SERVER_PORT = 6000\n", err: `unknown setting "SERVER_PORT"`},
+		{name: "nested file value", fileName: "app.yaml", file: "server_port:\n  value: 6000\n", err: "server_port must be a value or a list of values"},
+		{name: "unsupported file type", fileName: "app.json", file: "{}", err: "use a .yaml, .yml or .toml file"},
+		{name: "malformed file", fileName: "app.toml", file: "server_port = \n", err: "app.toml"},
+		{name: "missing file", args: []string{"--config", filepath.Join(os.TempDir(), "missing-usafi-hub.yaml")}, err: "missing-usafi-hub.yaml"},
+		{name: "bad boolean in the environment", env: map[string]string{"DEBUG": "maybe"}, err: `DEBUG: "maybe" is not true or false`},
+		{name: "bad boolean flag", args: []string{"--test=maybe"}, err: `TEST: "maybe" is not true or false`},
+		{name: "unknown flag", args: []string{"--server-prot", "6000"}, err: "server-prot"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			if tt.fileName != "" {
+				t.Setenv("CONFIG_FILE", writeFile(t, tt.fileName, tt.file))
+			}
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			_, _, err := NewConfig(tt.args, testLogger{})
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("expected an error mentioning %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+// validConfig is the production defaults with what NewConfig and the deployment add to them
+func validConfig() Config {
+	config := defaults("production")
+	config.LOG_LEVEL = "info"
+	config.SECRET_KEY = "secret"
+	config.SERVICE_TABLE = "Prod_Service"
+	config.REVIEWS_TABLE = "Prod_Review"
+	config.REQUEST_TABLE = "Prod_request"
+	config.TABLE_PREFIX = "Prod_"
+	config.PAYMENT_CALLBACK_TOKEN = "callback-secret"
+	config.MPESA_BASE_URL = "https://api.safaricom.co.ke"
+	config.MPESA_CONSUMER_KEY = "key"
+	config.MPESA_CONSUMER_SECRET = "secret"
+	config.MPESA_SHORTCODE = "174379"
+	config.MPESA_PASSKEY = "passkey"
+	config.MPESA_CALLBACK_URL = "https://api.usafihub.co.ke/payments/callback?token=callback-secret"
+	return config
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(c *Config)
+		// errs are the problems expected in the report, in order, none means the config is valid
+		errs []string
+	}{
+		{name: "defaults with a key", change: func(c *Config) {}},
+		{name: "keys from a JWKS", change: func(c *Config) { c.SECRET_KEY, c.JWKS_URL = "", "https://id.usafihub.co.ke/jwks.json" }},
+		{name: "no way to verify tokens", change: func(c *Config) { c.SECRET_KEY = "" }, errs: []string{"SECRET_KEY or JWKS_URL must be set"}},
+		{name: "required setting", change: func(c *Config) { c.SHUTDOWN_TIMEOUT = "" }, errs: []string{"SHUTDOWN_TIMEOUT: required"}},
+		{name: "duration", change: func(c *Config) { c.QUERY_TIMEOUT = "two weeks" }, errs: []string{`QUERY_TIMEOUT: "two weeks" is not a duration`}},
+		{name: "negative duration", change: func(c *Config) { c.QUERY_TIMEOUT = "-5s" }, errs: []string{`QUERY_TIMEOUT: "-5s" is not a duration`}},
+		{name: "port", change: func(c *Config) { c.SERVER_PORT = "70000" }, errs: []string{`SERVER_PORT: "70000" is not a port number`}},
+		{name: "whole number", change: func(c *Config) { c.LOG_MAX_BACKUPS = "7.5" }, errs: []string{`LOG_MAX_BACKUPS: "7.5" is not a whole number`}},
+		{name: "one of", change: func(c *Config) { c.STORAGE_BACKEND = "mysql" }, errs: []string{`STORAGE_BACKEND: "mysql" is not one of postgres, memory`}},
+		{name: "url", change: func(c *Config) { c.MPESA_BASE_URL = "sandbox.safaricom.co.ke" }, errs: []string{`MPESA_BASE_URL: "sandbox.safaricom.co.ke" is not an http(s) URL`}},
+		{name: "time zone", change: func(c *Config) { c.TIMEZONE = "Africa/Atlantis" }, errs: []string{`TIMEZONE: "Africa/Atlantis" is not a time zone`}},
+		{name: "time of day", change: func(c *Config) { c.BUSINESS_HOURS_END = "6pm" }, errs: []string{`BUSINESS_HOURS_END: "6pm" is not a time of day`}},
+		{name: "percent", change: func(c *Config) { c.TAX_RATE_PERCENT = "sixteen" }, errs: []string{"TAX_RATE_PERCENT:"}},
+		{name: "discounts", change: func(c *Config) { c.DISCOUNT_CODES = "WELCOME" }, errs: []string{"DISCOUNT_CODES:"}},
+		{
+			name:   "postgres without connection settings",
+			change: func(c *Config) { c.POSTGRES_HOST, c.POSTGRES_USER = "", "" },
+			errs:   []string{"POSTGRES_HOST: required unless POSTGRES_DSN is set", "POSTGRES_USER: required unless POSTGRES_DSN is set"},
+		},
+		{name: "postgres from a DSN", change: func(c *Config) { c.POSTGRES_HOST, c.POSTGRES_DSN = "", "postgres://localhost/usafi" }},
+		{
+			name:   "postgres without table names",
+			change: func(c *Config) { c.REQUEST_TABLE, c.TABLE_PREFIX = "", "" },
+			errs:   []string{"REQUEST_TABLE: required when STORAGE_BACKEND is postgres", "TABLE_PREFIX: required when STORAGE_BACKEND is postgres"},
+		},
+		{name: "memory needs no postgres", change: func(c *Config) { c.STORAGE_BACKEND, c.POSTGRES_HOST, c.TABLE_PREFIX = "memory", "", "" }},
+		{
+			name:   "mpesa without credentials",
+			change: func(c *Config) { c.MPESA_SHORTCODE, c.MPESA_PASSKEY, c.MPESA_CALLBACK_URL = "", "", "" },
+			errs:   []string{"MPESA_CALLBACK_URL: required when PAYMENT_PROVIDER is mpesa", "MPESA_PASSKEY: required when PAYMENT_PROVIDER is mpesa", "MPESA_SHORTCODE: required when PAYMENT_PROVIDER is mpesa"},
+		},
+		{name: "no callback token", change: func(c *Config) { c.PAYMENT_CALLBACK_TOKEN = "" }, errs: []string{"PAYMENT_CALLBACK_TOKEN: required"}},
+		{name: "simulator in production", change: func(c *Config) { c.PAYMENT_PROVIDER = "simulator" }, errs: []string{"PAYMENT_PROVIDER: the simulator cannot take payments in production"}},
+		{name: "simulator elsewhere", change: func(c *Config) { c.ENV, c.PAYMENT_PROVIDER = "docker", "simulator" }},
+		{name: "business hours out of order", change: func(c *Config) { c.BUSINESS_HOURS_START = "18:00" }, errs: []string{"BUSINESS_HOURS_START must be before BUSINESS_HOURS_END"}},
+		{name: "more idle than open connections", change: func(c *Config) { c.POSTGRES_MAX_IDLE_CONNS = "30" }, errs: []string{"POSTGRES_MAX_IDLE_CONNS must not be above POSTGRES_MAX_OPEN_CONNS"}},
+		{name: "unlimited open connections", change: func(c *Config) { c.POSTGRES_MAX_OPEN_CONNS = "0" }},
+		{
+			name:   "every problem at once",
+			change: func(c *Config) { c.SERVER_PORT, c.LOG_LEVEL, c.SECRET_KEY = "", "loud", "" },
+			errs:   []string{`LOG_LEVEL: "loud" is not one of`, "SECRET_KEY or JWKS_URL must be set", "SERVER_PORT: required"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := validConfig()
+			tt.change(&config)
+
+			err := config.Validate()
+			if len(tt.errs) == 0 {
+				if err != nil {
+					t.Fatalf("expected the config to be valid, got %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected %v, got a valid config", tt.errs)
+			}
+			problems := strings.Split(err.Error(), "\n")
+			if len(problems) != len(tt.errs) {
+				t.Fatalf("expected %d problems, got %q", len(tt.errs), problems)
+			}
+			for i, want := range tt.errs {
+				if !strings.HasPrefix(problems[i], want) {
+					t.Errorf("expected problem %d to start with %q, got %q", i, want, problems[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPrintRedactsSecrets(t *testing.T) {
+	config := validConfig()
+	config.SECRET_KEY = "jwt-signing-secret"
+	config.POSTGRES_PASSWORD = "database-password"
+	config.CORS_ALLOWED_ORIGINS = "https://usafihub.co.ke"
+
+	var out bytes.Buffer
+	if err := config.Print(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"jwt-signing-secret", "database-password"} {
+		if strings.Contains(out.String(), secret) {
+			t.Errorf("expected %q to be redacted from\n%s", secret, out.String())
+		}
+	}
+
+	var printed map[string]interface{}
+	if err := yaml.Unmarshal(out.Bytes(), &printed); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"secret_key":           redacted,
+		"postgres_password":    redacted,
+		"postgres_dsn":         "",
+		"cors_allowed_origins": "https://usafihub.co.ke",
+		"server_port":          "5001",
+		"debug":                false,
+	}
+	for key, value := range want {
+		if printed[key] != value {
+			t.Errorf("expected %s to be printed as %#v, got %#v", key, value, printed[key])
+		}
+	}
+
+	// The printed settings load back as a config file
+	clearEnv(t)
+	path := writeFile(t, "printed.yaml", out.String())
+	loaded, _, err := NewConfig([]string{"--config", path}, testLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.CORS_ALLOWED_ORIGINS != config.CORS_ALLOWED_ORIGINS || loaded.SERVER_PORT != config.SERVER_PORT {
+		t.Errorf("expected the printed settings to load back, got %+v", loaded)
+	}
+}
+
+func TestAccessors(t *testing.T) {
+	config := validConfig()
+	config.AUTO_ASSIGN_INTERVAL = ""
+	config.DISCOUNT_CODES = "WELCOME=10%"
+
+	if got := config.Duration("SHUTDOWN_TIMEOUT"); got != 30*time.Second {
+		t.Errorf("expected SHUTDOWN_TIMEOUT to read as 30s, got %v", got)
+	}
+	if got := config.Duration("AUTO_ASSIGN_INTERVAL"); got != 0 {
+		t.Errorf("expected an empty duration to read as zero, got %v", got)
+	}
+	if got := config.Int("POSTGRES_MAX_OPEN_CONNS"); got != 20 {
+		t.Errorf("expected POSTGRES_MAX_OPEN_CONNS to read as 20, got %d", got)
+	}
+	if got := config.Percent("TAX_RATE_PERCENT"); got != 1600 {
+		t.Errorf("expected TAX_RATE_PERCENT to read as 1600 basis points, got %d", got)
+	}
+	if got := config.Discounts("DISCOUNT_CODES"); got["WELCOME"].PercentBps != 1000 {
+		t.Errorf("expected the WELCOME discount to read as 10%%, got %+v", got)
+	}
+	if got := config.Location("TIMEZONE"); got.String() != "Africa/Nairobi" {
+		t.Errorf("expected TIMEZONE to read as Africa/Nairobi, got %v", got)
+	}
+
+	misuses := map[string]func(){
+		"a setting read as another type": func() { config.Int("SHUTDOWN_TIMEOUT") },
+		"an unknown setting":             func() { config.Duration("SHUTDOWN_TIMOUT") },
+		"a value Validate refuses":       func() { config.SERVER_READ_TIMEOUT = "soon"; config.Duration("SERVER_READ_TIMEOUT") },
+	}
+	for name, misuse := range misuses {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected %s to panic", name)
+				}
+			}()
+			misuse()
+		}()
+	}
+}
@@ -0,0 +1,207 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// setting describes one tagged field of Config
+type setting struct {
+	name  string
+	key   string
+	flag  string
+	rules []string
+}
+
+func (s setting) has(rule string) bool {
+	for _, r := range s.rules {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+func settings() []setting {
+	fields := reflect.TypeOf(Config{})
+	var all []setting
+	for i := 0; i < fields.NumField(); i++ {
+		field := fields.Field(i)
+		tag, ok := field.Tag.Lookup("config")
+		if !ok {
+			continue
+		}
+		s := setting{
+			name: field.Name,
+			key:  strings.ToLower(field.Name),
+			flag: strings.ReplaceAll(strings.ToLower(field.Name), "_", "-"),
+		}
+		if tag != "" {
+			s.rules = strings.Split(tag, ",")
+		}
+		all = append(all, s)
+	}
+	return all
+}
+
+func (c *Config) set(s setting, value string) error {
+	field := reflect.ValueOf(c).Elem().FieldByName(s.name)
+	if field.Kind() == reflect.Bool {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not true or false", s.name, value)
+		}
+		field.SetBool(parsed)
+		return nil
+	}
+	field.SetString(value)
+	return nil
+}
+
+func (c Config) get(s setting) string {
+	field := reflect.ValueOf(c).FieldByName(s.name)
+	if field.Kind() == reflect.Bool {
+		return strconv.FormatBool(field.Bool())
+	}
+	return field.String()
+}
+
+type flags struct {
+	env    string
+	file   string
+	values map[string]string
+	args   []string
+}
+
+// parseFlags reads --env, --config and a flag for every setting, stopping at the first argument
+// that is not a flag
+func parseFlags(args []string) (flags, error) {
+	parsed := flags{
+		env:    os.Getenv("ENV"),
+		file:   os.Getenv("CONFIG_FILE"),
+		values: map[string]string{},
+	}
+
+	set := flag.NewFlagSet("usafi-hub-cleaning-service", flag.ContinueOnError)
+	set.StringVar(&parsed.env, "env", parsed.env, "environment whose defaults to start from, overrides ENV")
+	set.StringVar(&parsed.file, "config", parsed.file, "YAML or TOML config file, overrides CONFIG_FILE")
+	for _, s := range settings() {
+		s := s
+		set.Func(s.flag, "overrides "+s.name, func(value string) error {
+			parsed.values[s.name] = value
+			return nil
+		})
+	}
+
+	if err := set.Parse(args); err != nil {
+		return flags{}, err
+	}
+	parsed.args = set.Args()
+	return parsed, nil
+}
+
+// loadDotEnv adds the variables of .env to the environment, without replacing those already set
+func loadDotEnv() error {
+	return godotenv.Load(".env")
+}
+
+// loadFile applies a YAML or TOML config file, picked by its extension. Keys are setting names in
+// lower case. Lists, such as CORS origins, are joined with commas.
+func (c *Config) loadFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	values := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.NewDecoder(bytes.NewReader(content)).Decode(&values)
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+	case ".toml":
+		err = toml.Unmarshal(content, &values)
+	default:
+		return fmt.Errorf("config file %s: use a .yaml, .yml or .toml file", path)
+	}
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	byKey := map[string]setting{}
+	for _, s := range settings() {
+		byKey[s.key] = s
+	}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var problems []error
+	for _, key := range keys {
+		s, ok := byKey[key]
+		if !ok {
+			problems = append(problems, fmt.Errorf("config file %s: unknown setting %q", path, key))
+			continue
+		}
+		value, err := fileValue(values[key])
+		if err != nil {
+			problems = append(problems, fmt.Errorf("config file %s: %s %w", path, key, err))
+			continue
+		}
+		if err := c.set(s, value); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	return errors.Join(problems...)
+}
+
+func fileValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool, int, int64, uint64, float64:
+		return fmt.Sprint(v), nil
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			value, err := fileValue(item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = value
+		}
+		return strings.Join(items, ","), nil
+	}
+	return "", fmt.Errorf("must be a value or a list of values, not %T", value)
+}
+
+// loadEnv applies the environment variables named after settings. Empty variables are ignored, as
+// they always have been.
+func (c *Config) loadEnv() error {
+	for _, s := range settings() {
+		if value := os.Getenv(s.name); value != "" {
+			if err := c.set(s, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+	"gopkg.in/yaml.v3"
+)
+
+// Validate checks every setting against the rules in its tag, then the settings that depend on
+// each other, reporting all problems at once
+func (c Config) Validate() error {
+	var problems []error
+	for _, s := range settings() {
+		if err := checkRules(s, c.get(s)); err != nil {
+			problems = append(problems, fmt.Errorf("%s: %w", s.name, err))
+		}
+	}
+
+	if c.SECRET_KEY == "" && c.JWKS_URL == "" {
+		problems = append(problems, errors.New("SECRET_KEY or JWKS_URL must be set to verify access tokens"))
+	}
+	// Production has no table names of its own, the deployment names them
+	if c.STORAGE_BACKEND == "postgres" {
+		for name, value := range map[string]string{"SERVICE_TABLE": c.SERVICE_TABLE, "REVIEWS_TABLE": c.REVIEWS_TABLE, "REQUEST_TABLE": c.REQUEST_TABLE, "TABLE_PREFIX": c.TABLE_PREFIX} {
+			if value == "" {
+				problems = append(problems, fmt.Errorf("%s: required when STORAGE_BACKEND is postgres", name))
+			}
+		}
+	}
+	if c.STORAGE_BACKEND == "postgres" && c.POSTGRES_DSN == "" {
+		for name, value := range map[string]string{"POSTGRES_HOST": c.POSTGRES_HOST, "POSTGRES_PORT": c.POSTGRES_PORT, "POSTGRES_DB": c.POSTGRES_DB, "POSTGRES_USER": c.POSTGRES_USER} {
+			if value == "" {
+				problems = append(problems, fmt.Errorf("%s: required unless POSTGRES_DSN is set", name))
+			}
+		}
+	}
+	// The simulator settles whatever it is told to, real money goes through a real provider
+	if c.ENV == "production" && c.PAYMENT_PROVIDER == "simulator" {
+		problems = append(problems, errors.New("PAYMENT_PROVIDER: the simulator cannot take payments in production"))
+	}
+	if c.PAYMENT_PROVIDER == "mpesa" {
+		for name, value := range map[string]string{"MPESA_BASE_URL": c.MPESA_BASE_URL, "MPESA_CONSUMER_KEY": c.MPESA_CONSUMER_KEY, "MPESA_CONSUMER_SECRET": c.MPESA_CONSUMER_SECRET, "MPESA_SHORTCODE": c.MPESA_SHORTCODE, "MPESA_PASSKEY": c.MPESA_PASSKEY, "MPESA_CALLBACK_URL": c.MPESA_CALLBACK_URL} {
+			if value == "" {
+				problems = append(problems, fmt.Errorf("%s: required when PAYMENT_PROVIDER is mpesa", name))
+			}
+		}
+	}
+	start, startErr := domain.ParseClock(c.BUSINESS_HOURS_START)
+	end, endErr := domain.ParseClock(c.BUSINESS_HOURS_END)
+	if startErr == nil && endErr == nil && start >= end {
+		problems = append(problems, errors.New("BUSINESS_HOURS_START must be before BUSINESS_HOURS_END"))
+	}
+	maxOpen, openErr := strconv.Atoi(c.POSTGRES_MAX_OPEN_CONNS)
+	maxIdle, idleErr := strconv.Atoi(c.POSTGRES_MAX_IDLE_CONNS)
+	if openErr == nil && idleErr == nil && maxOpen > 0 && maxIdle > maxOpen {
+		problems = append(problems, errors.New("POSTGRES_MAX_IDLE_CONNS must not be above POSTGRES_MAX_OPEN_CONNS"))
+	}
+
+	// The maps above are unordered, keep the report stable
+	sort.Slice(problems, func(i, j int) bool { return problems[i].Error() < problems[j].Error() })
+	return errors.Join(problems...)
+}
+
+func checkRules(s setting, value string) error {
+	if value == "" {
+		if s.has("required") {
+			return errors.New("required")
+		}
+		return nil
+	}
+
+	for _, rule := range s.rules {
+		switch {
+		case rule == "duration":
+			duration, err := time.ParseDuration(value)
+			if err != nil || duration < 0 {
+				return fmt.Errorf("%q is not a duration such as 30s or 5m", value)
+			}
+		case rule == "int":
+			number, err := strconv.Atoi(value)
+			if err != nil || number < 0 {
+				return fmt.Errorf("%q is not a whole number", value)
+			}
+		case rule == "port":
+			port, err := strconv.Atoi(value)
+			if err != nil || port < 1 || port > 65535 {
+				return fmt.Errorf("%q is not a port number", value)
+			}
+		case rule == "url":
+			parsed, err := url.Parse(value)
+			if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+				return fmt.Errorf("%q is not an http(s) URL", value)
+			}
+		case rule == "timezone":
+			if _, err := time.LoadLocation(value); err != nil {
+				return fmt.Errorf("%q is not a time zone", value)
+			}
+		case rule == "percent":
+			if _, err := domain.ParsePercent(value); err != nil {
+				return err
+			}
+		case rule == "discounts":
+			if _, err := domain.ParseDiscounts(value); err != nil {
+				return err
+			}
+		case rule == "clock":
+			if _, err := domain.ParseClock(value); err != nil {
+				return fmt.Errorf("%q is not a time of day such as 08:00", value)
+			}
+		case strings.HasPrefix(rule, "oneof="):
+			allowed := strings.Split(strings.TrimPrefix(rule, "oneof="), "|")
+			if !contains(allowed, value) {
+				return fmt.Errorf("%q is not one of %s", value, strings.Join(allowed, ", "))
+			}
+		}
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+const redacted = "[redacted]"
+
+// Print writes the effective settings as YAML, usable as a config file, with secrets redacted
+func (c Config) Print(w io.Writer) error {
+	document := &yaml.Node{Kind: yaml.MappingNode}
+	for _, s := range settings() {
+		value := c.get(s)
+		tag := "!!str"
+		if s.name == "DEBUG" || s.name == "TEST" {
+			tag = "!!bool"
+		}
+		if s.has("secret") && value != "" {
+			value = redacted
+		}
+		document.Content = append(document.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: s.key},
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value},
+		)
+	}
+
+	fmt.Fprintf(w, "# effective configuration for ENV=%q\n", c.ENV)
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(document); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+)
+
+// The accessors below read a setting as the type its rule describes. Validate has already checked
+// the value, so they return no error; an empty setting reads as the zero value. Asking for a setting
+// as a type its tag doesn't promise, or reading one Validate would refuse, is a bug and panics.
+
+// Duration reads a setting tagged duration
+func (c Config) Duration(name string) time.Duration {
+	value := c.value(name, "duration")
+	if value == "" {
+		return 0
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		panic(unvalidated(name, err))
+	}
+	return duration
+}
+
+// Int reads a setting tagged int
+func (c Config) Int(name string) int {
+	value := c.value(name, "int")
+	if value == "" {
+		return 0
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		panic(unvalidated(name, err))
+	}
+	return number
+}
+
+// Percent reads a setting tagged percent in basis points
+func (c Config) Percent(name string) int64 {
+	value := c.value(name, "percent")
+	if value == "" {
+		return 0
+	}
+	bps, err := domain.ParsePercent(value)
+	if err != nil {
+		panic(unvalidated(name, err))
+	}
+	return bps
+}
+
+// Discounts reads a setting tagged discounts, by discount code
+func (c Config) Discounts(name string) map[string]domain.Discount {
+	discounts, err := domain.ParseDiscounts(c.value(name, "discounts"))
+	if err != nil {
+		panic(unvalidated(name, err))
+	}
+	return discounts
+}
+
+// Location reads a setting tagged timezone, an empty one is UTC
+func (c Config) Location(name string) *time.Location {
+	location, err := time.LoadLocation(c.value(name, "timezone"))
+	if err != nil {
+		panic(unvalidated(name, err))
+	}
+	return location
+}
+
+// value returns the setting called name, which has to be tagged with rule
+func (c Config) value(name, rule string) string {
+	for _, s := range settings() {
+		if s.name != name {
+			continue
+		}
+		if !s.has(rule) {
+			panic(fmt.Sprintf("config: %s is not a %s setting", name, rule))
+		}
+		return c.get(s)
+	}
+	panic(fmt.Sprintf("config: unknown setting %s", name))
+}
+
+func unvalidated(name string, err error) string {
+	return fmt.Sprintf("config: %s read before Validate: %v", name, err)
+}
@@ -0,0 +1,108 @@
+package app
+
+import (
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+	"github.com/gin-gonic/gin"
+)
+
+const principalKey = "principal"
+
+// principalFrom returns the caller AuthorizeToken put on the context, or a caller without any
+// roles on routes that are not authorized
+func principalFrom(ctx *gin.Context) domain.Principal {
+	if principal, ok := ctx.Get(principalKey); ok {
+		return principal.(domain.Principal)
+	}
+	return domain.Principal{}
+}
+
+func forbid(ctx *gin.Context) {
+	ctx.Error(domain.ErrForbidden)
+}
+
+// actingAsClient guards routes scoped to the :client_id in their path
+func actingAsClient(ctx *gin.Context) {
+	if !principalFrom(ctx).CanActAsClient(ctx.Param("client_id")) {
+		forbid(ctx)
+		ctx.Abort()
+		return
+	}
+	ctx.Next()
+}
+
+// actingAsCleaner guards routes scoped to the :cleaner_id in their path
+func actingAsCleaner(ctx *gin.Context) {
+	if !principalFrom(ctx).CanActAsCleaner(ctx.Param("cleaner_id")) {
+		forbid(ctx)
+		ctx.Abort()
+		return
+	}
+	ctx.Next()
+}
+
+// authorizeRequest loads the request and checks the caller against it with allowed. When the caller
+// may not go on the error has been recorded on the context and nil is returned.
+func (h handler) authorizeRequest(ctx *gin.Context, requestId string, allowed func(domain.Principal, domain.Request) bool) *domain.Request {
+	request, err := h.requestService.GetRequestById(ctx.Request.Context(), requestId)
+	if err != nil {
+		ctx.Error(err)
+		return nil
+	}
+	if !allowed(principalFrom(ctx), *request) {
+		forbid(ctx)
+		return nil
+	}
+	return request
+}
+
+// authorizeReview is authorizeRequest for reviews
+func (h handler) authorizeReview(ctx *gin.Context, reviewId string, allowed func(domain.Principal, domain.Reviews) bool) *domain.Reviews {
+	review, err := h.reviewService.GetReviewById(ctx.Request.Context(), reviewId)
+	if err != nil {
+		ctx.Error(err)
+		return nil
+	}
+	if !allowed(principalFrom(ctx), *review) {
+		forbid(ctx)
+		return nil
+	}
+	return review
+}
+
+// Policies shared by several routes
+func canViewRequest(principal domain.Principal, request domain.Request) bool {
+	return principal.CanViewRequest(request)
+}
+
+func canManageBooking(principal domain.Principal, request domain.Request) bool {
+	return principal.CanActAsClient(request.ClientId)
+}
+
+func canWorkRequest(principal domain.Principal, request domain.Request) bool {
+	return principal.CanActAsCleaner(request.CleanerId)
+}
+
+// canChangeBooking lets the request's cleaner cancel too, which hands the job back to dispatch
+func canChangeBooking(principal domain.Principal, request domain.Request) bool {
+	return canManageBooking(principal, request) || (request.CleanerId != "" && canWorkRequest(principal, request))
+}
+
+// changeInitiator is who the cancellation policy treats the caller as
+func changeInitiator(principal domain.Principal, request domain.Request) domain.ChangeInitiator {
+	switch {
+	case principal.IsStaff():
+		return domain.InitiatorStaff
+	case principal.CanActAsClient(request.ClientId):
+		return domain.InitiatorClient
+	default:
+		return domain.InitiatorCleaner
+	}
+}
+
+func canViewReview(principal domain.Principal, review domain.Reviews) bool {
+	return principal.CanViewReview(review)
+}
+
+func canEditReview(principal domain.Principal, review domain.Reviews) bool {
+	return principal.CanEditReview(review)
+}
@@ -0,0 +1,212 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+	"github.com/gin-gonic/gin"
+)
+
+// defaultCalendarWindow is how far ahead exceptions and time off are listed when no range is given
+const defaultCalendarWindow = 90 * 24 * time.Hour
+
+func (h handler) SetWorkingHours(ctx *gin.Context) {
+	cleanerId := ctx.Param("cleaner_id")
+
+	var hours []domain.WorkingHours
+	if !bindJSON(ctx, &hours) {
+		return
+	}
+
+	dbHours, err := h.availabilityService.SetWorkingHours(ctx.Request.Context(), cleanerId, hours)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"responseMessage": "Working hours updated successfully",
+		"responseCode":    http.StatusOK,
+		"data":            dbHours,
+	})
+}
+
+func (h handler) GetWorkingHours(ctx *gin.Context) {
+	cleanerId := ctx.Param("cleaner_id")
+
+	hours, err := h.availabilityService.GetWorkingHours(ctx.Request.Context(), cleanerId)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"responseMessage": "Working hours found",
+		"responseCode":    http.StatusOK,
+		"data":            hours,
+	})
+}
+
+func (h handler) CreateAvailabilityException(ctx *gin.Context) {
+	var exception domain.AvailabilityException
+	if !bindJSON(ctx, &exception) {
+		return
+	}
+	exception.CleanerId = ctx.Param("cleaner_id")
+
+	dbException, err := h.availabilityService.CreateException(ctx.Request.Context(), exception)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{
+		"responseMessage": "Availability exception created successfully",
+		"responseCode":    http.StatusCreated,
+		"data":            dbException,
+	})
+}
+
+func (h handler) GetAvailabilityExceptions(ctx *gin.Context) {
+	cleanerId := ctx.Param("cleaner_id")
+
+	from, to, err := parseCalendarRange(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	exceptions, err := h.availabilityService.GetExceptions(ctx.Request.Context(), cleanerId, from, to)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"responseMessage": "Availability exceptions found",
+		"responseCode":    http.StatusOK,
+		"data":            exceptions,
+	})
+}
+
+func (h handler) DeleteAvailabilityException(ctx *gin.Context) {
+	cleanerId := ctx.Param("cleaner_id")
+	exceptionId := ctx.Param("exception_id")
+
+	err := h.availabilityService.DeleteException(ctx.Request.Context(), cleanerId, exceptionId)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"responseMessage": "Availability exception deleted successfully",
+		"responseCode":    http.StatusOK,
+	})
+}
+
+func (h handler) CreateTimeOff(ctx *gin.Context) {
+	var timeOff domain.TimeOff
+	if !bindJSON(ctx, &timeOff) {
+		return
+	}
+	timeOff.CleanerId = ctx.Param("cleaner_id")
+
+	dbTimeOff, err := h.availabilityService.CreateTimeOff(ctx.Request.Context(), timeOff)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{
+		"responseMessage": "Time off created successfully",
+		"responseCode":    http.StatusCreated,
+		"data":            dbTimeOff,
+	})
+}
+
+func (h handler) GetTimeOff(ctx *gin.Context) {
+	cleanerId := ctx.Param("cleaner_id")
+
+	from, to, err := parseCalendarRange(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	timeOff, err := h.availabilityService.GetTimeOff(ctx.Request.Context(), cleanerId, from, to)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"responseMessage": "Time off found",
+		"responseCode":    http.StatusOK,
+		"data":            timeOff,
+	})
+}
+
+func (h handler) DeleteTimeOff(ctx *gin.Context) {
+	cleanerId := ctx.Param("cleaner_id")
+	timeOffId := ctx.Param("time_off_id")
+
+	err := h.availabilityService.DeleteTimeOff(ctx.Request.Context(), cleanerId, timeOffId)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"responseMessage": "Time off deleted successfully",
+		"responseCode":    http.StatusOK,
+	})
+}
+
+func (h handler) GetCleanerSlots(ctx *gin.Context) {
+	cleanerId := ctx.Param("cleaner_id")
+
+	date, err := time.Parse(domain.DateLayout, ctx.Query("date"))
+	if err != nil {
+		ctx.Error(fmt.Errorf("%w: date query parameter must be formatted as YYYY-MM-DD", domain.ErrInvalidInput))
+		return
+	}
+
+	slots, err := h.availabilityService.GetSlots(ctx.Request.Context(), cleanerId, date, ctx.Query("service_id"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"responseMessage": "Open slots found",
+		"responseCode":    http.StatusOK,
+		"data":            slots,
+		"responseCount":   len(*slots),
+	})
+}
+
+// parseCalendarRange reads the optional from and to query parameters, defaulting to the coming days
+func parseCalendarRange(ctx *gin.Context) (time.Time, time.Time, error) {
+	from := time.Now()
+	to := from.Add(defaultCalendarWindow)
+
+	parsedFrom, err := parseTimeQuery(ctx, "from")
+	if err != nil {
+		return from, to, err
+	}
+	if parsedFrom != nil {
+		from = *parsedFrom
+	}
+
+	parsedTo, err := parseTimeQuery(ctx, "to")
+	if err != nil {
+		return from, to, err
+	}
+	if parsedTo != nil {
+		to = *parsedTo
+	}
+	return from, to, nil
+}
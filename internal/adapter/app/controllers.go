@@ -2,6 +2,7 @@ package app
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
 	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/ports"
@@ -11,6 +12,8 @@ import (
 type GinHandler interface {
 	Home(ctx *gin.Context)
 	Healthcheck(ctx *gin.Context)
+	Livez(ctx *gin.Context)
+	Readyz(ctx *gin.Context)
 	CreateService(ctx *gin.Context)
 	GetServiceById(ctx *gin.Context)
 	GetServices(ctx *gin.Context)
@@ -22,6 +25,12 @@ type GinHandler interface {
 	UpdateRequest(ctx *gin.Context)
 	DeleteRequest(ctx *gin.Context)
 	AssignCleaner(ctx *gin.Context)
+	MarkEnRoute(ctx *gin.Context)
+	StartRequest(ctx *gin.Context)
+	CompleteRequest(ctx *gin.Context)
+	CancelRequest(ctx *gin.Context)
+	RescheduleRequest(ctx *gin.Context)
+	MarkNoShow(ctx *gin.Context)
 	GetRequestByClient(ctx *gin.Context)
 	GetRequestByCleaner(ctx *gin.Context)
 	CreateReview(ctx *gin.Context)
@@ -30,19 +39,70 @@ type GinHandler interface {
 	DeleteReview(ctx *gin.Context)
 	GetReviewByClient(ctx *gin.Context)
 	GetReviewByCleaner(ctx *gin.Context)
+	GetCleanerRatingSummary(ctx *gin.Context)
+	GetServiceRatingSummary(ctx *gin.Context)
+	GetModerationQueue(ctx *gin.Context)
+	ApproveReview(ctx *gin.Context)
+	RejectReview(ctx *gin.Context)
+	FlagReview(ctx *gin.Context)
+	RespondToReview(ctx *gin.Context)
+	SetWorkingHours(ctx *gin.Context)
+	GetWorkingHours(ctx *gin.Context)
+	CreateAvailabilityException(ctx *gin.Context)
+	GetAvailabilityExceptions(ctx *gin.Context)
+	DeleteAvailabilityException(ctx *gin.Context)
+	CreateTimeOff(ctx *gin.Context)
+	GetTimeOff(ctx *gin.Context)
+	DeleteTimeOff(ctx *gin.Context)
+	GetCleanerSlots(ctx *gin.Context)
+	UpsertCleanerProfile(ctx *gin.Context)
+	GetCleanerProfile(ctx *gin.Context)
+	GetRequestMatches(ctx *gin.Context)
+	AutoAssignCleaner(ctx *gin.Context)
+	QuoteRequest(ctx *gin.Context)
+	GenerateInvoice(ctx *gin.Context)
+	GetInvoiceById(ctx *gin.Context)
+	GetInvoicePDF(ctx *gin.Context)
+	GetInvoiceByRequest(ctx *gin.Context)
+	GetInvoicesByClient(ctx *gin.Context)
+	InitiatePayment(ctx *gin.Context)
+	PaymentCallback(ctx *gin.Context)
+	GetPaymentById(ctx *gin.Context)
+	GetPaymentsByRequest(ctx *gin.Context)
+	CreateRecurringBooking(ctx *gin.Context)
+	GetRecurringBookingById(ctx *gin.Context)
+	GetRecurringBookingsByClient(ctx *gin.Context)
+	GetRecurringSchedule(ctx *gin.Context)
+	SkipOccurrence(ctx *gin.Context)
+	RescheduleOccurrence(ctx *gin.Context)
+	CancelRecurringBooking(ctx *gin.Context)
 }
 
 type handler struct {
-	serviceService ports.ServiceService
-	requestService ports.RequestService
-	reviewService  ports.ReviewService
+	serviceService      ports.ServiceService
+	requestService      ports.RequestService
+	reviewService       ports.ReviewService
+	availabilityService ports.AvailabilityService
+	matchingService     ports.MatchingService
+	pricingService      ports.PricingService
+	invoiceService      ports.InvoiceService
+	paymentService      ports.PaymentService
+	recurringService    ports.RecurringBookingService
+	checks              []ports.HealthCheck
 }
 
-func NewGinHandler(serviceService ports.ServiceService, requestService ports.RequestService, reviewService ports.ReviewService) GinHandler {
+func NewGinHandler(serviceService ports.ServiceService, requestService ports.RequestService, reviewService ports.ReviewService, availabilityService ports.AvailabilityService, matchingService ports.MatchingService, pricingService ports.PricingService, invoiceService ports.InvoiceService, paymentService ports.PaymentService, recurringService ports.RecurringBookingService, checks []ports.HealthCheck) GinHandler {
 	routerHandler := handler{
-		serviceService: serviceService,
-		requestService: requestService,
-		reviewService:  reviewService,
+		serviceService:      serviceService,
+		requestService:      requestService,
+		reviewService:       reviewService,
+		availabilityService: availabilityService,
+		matchingService:     matchingService,
+		pricingService:      pricingService,
+		invoiceService:      invoiceService,
+		paymentService:      paymentService,
+		recurringService:    recurringService,
+		checks:              checks,
 	}
 	return routerHandler
 }
@@ -63,20 +123,13 @@ func (h handler) Healthcheck(ctx *gin.Context) {
 
 func (h handler) CreateService(ctx *gin.Context) {
 	var service domain.Service
-	if err := ctx.ShouldBindJSON(&service); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"responseMessage": err.Error(),
-			"responseCode":    http.StatusBadRequest,
-		})
+	if !bindJSON(ctx, &service) {
 		return
 	}
 
-	dbService, err := h.serviceService.CreateService(service)
+	dbService, err := h.serviceService.CreateService(ctx.Request.Context(), service)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"responseMessage": err.Error(),
-			"responseCode":    http.StatusInternalServerError,
-		})
+		ctx.Error(err)
 		return
 	}
 
@@ -90,13 +143,10 @@ func (h handler) CreateService(ctx *gin.Context) {
 func (h handler) GetServiceById(ctx *gin.Context) {
 	serviceId := ctx.Param("service_id")
 
-	service, err := h.serviceService.GetServiceById(serviceId)
+	service, err := h.serviceService.GetServiceById(ctx.Request.Context(), serviceId)
 	if err != nil {
 
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"responseMessage": err.Error(),
-			"responseCode":    http.StatusInternalServerError,
-		})
+		ctx.Error(err)
 		return
 	}
 
@@ -108,39 +158,38 @@ func (h handler) GetServiceById(ctx *gin.Context) {
 }
 
 func (h handler) GetServices(ctx *gin.Context) {
-	services, err := h.serviceService.GetServices()
+	filter, err := parseServiceFilter(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"responseMessage": err.Error(),
-			"responseCode":    http.StatusInternalServerError,
-		})
+		ctx.Error(err)
+		return
+	}
+
+	page, err := h.serviceService.GetServices(ctx.Request.Context(), filter)
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, gin.H{
 		"responseMessage": "Services found",
 		"responseCode":    http.StatusOK,
-		"data":            services,
-		"responseCount":   len(*services),
+		"data":            page.Items,
+		"responseCount":   len(page.Items),
+		"next_cursor":     page.NextCursor,
+		"total":           page.Total,
 	})
 }
 
 func (h handler) UpdateService(ctx *gin.Context) {
 	var service domain.Service
-	if err := ctx.ShouldBindJSON(&service); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"responseMessage": err.Error(),
-			"responseCode":    http.StatusBadRequest,
-		})
+	if !bindJSON(ctx, &service) {
 		return
 	}
+	service.ServiceId = ctx.Param("service_id")
 
-	updatedService, err := h.serviceService.UpdateService(service)
+	updatedService, err := h.serviceService.UpdateService(ctx.Request.Context(), service)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"responseMessage": err.Error(),
-			"responseCode":    http.StatusInternalServerError,
-		})
+		ctx.Error(err)
 		return
 	}
 
@@ -154,12 +203,9 @@ func (h handler) UpdateService(ctx *gin.Context) {
 func (h handler) DeleteService(ctx *gin.Context) {
 	serviceId := ctx.Param("service_id")
 
-	err := h.serviceService.DeleteService(serviceId)
+	err := h.serviceService.DeleteService(ctx.Request.Context(), serviceId)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"responseMessage": err.Error(),
-			"responseCode":    http.StatusInternalServerError,
-		})
+		ctx.Error(err)
 		return
 	}
 
@@ -171,20 +217,22 @@ func (h handler) DeleteService(ctx *gin.Context) {
 
 func (h handler) CreateRequest(ctx *gin.Context) {
 	var request domain.Request
-	if err := ctx.ShouldBindJSON(&request); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"responseMessage": err.Error(),
-			"responseCode":    http.StatusBadRequest,
-		})
+	if !bindJSON(ctx, &request) {
 		return
 	}
 
-	dbRequest, err := h.requestService.CreateRequest(request)
+	principal := principalFrom(ctx)
+	if request.ClientId == "" && principal.HasRole(domain.RoleClient) {
+		request.ClientId = principal.Subject
+	}
+	if !principal.CanActAsClient(request.ClientId) {
+		forbid(ctx)
+		return
+	}
+
+	dbRequest, err := h.requestService.CreateRequest(ctx.Request.Context(), request)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"responseMessage": err.Error(),
-			"responseCode":    http.StatusInternalServerError,
-		})
+		ctx.Error(err)
 		return
 	}
 
@@ -195,15 +243,30 @@ func (h handler) CreateRequest(ctx *gin.Context) {
 	})
 }
 
+func (h handler) QuoteRequest(ctx *gin.Context) {
+	var request domain.Request
+	if !bindJSON(ctx, &request) {
+		return
+	}
+
+	quote, err := h.pricingService.QuoteRequest(ctx.Request.Context(), request)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"responseMessage": "Request quoted successfully",
+		"responseCode":    http.StatusOK,
+		"data":            quote,
+	})
+}
+
 func (h handler) GetRequestById(ctx *gin.Context) {
 	requestId := ctx.Param("request_id")
 
-	request, err := h.requestService.GetRequestById(requestId)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"responseMessage": err.Error(),
-			"responseCode":    http.StatusInternalServerError,
-		})
+	request := h.authorizeRequest(ctx, requestId, canViewRequest)
+	if request == nil {
 		return
 	}
 
@@ -215,38 +278,50 @@ func (h handler) GetRequestById(ctx *gin.Context) {
 }
 
 func (h handler) GetRequests(ctx *gin.Context) {
-	requests, err := h.requestService.GetRequests()
+	filter, err := parseRequestFilter(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	page, err := h.requestService.GetRequests(ctx.Request.Context(), filter)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"responseMessage": err.Error(),
-			"responseCode":    http.StatusInternalServerError,
-		})
+		ctx.Error(err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, gin.H{
 		"responseMessage": "Requests found",
 		"responseCode":    http.StatusOK,
-		"data":            requests,
+		"data":            page.Items,
+		"responseCount":   len(page.Items),
+		"next_cursor":     page.NextCursor,
+		"total":           page.Total,
 	})
 }
 
 func (h handler) UpdateRequest(ctx *gin.Context) {
 	var request domain.Request
-	if err := ctx.ShouldBindJSON(&request); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"responseMessage": err.Error(),
-			"responseCode":    http.StatusBadRequest,
-		})
+	if !bindJSON(ctx, &request) {
+		return
+	}
+	// The path names the request, whatever id the body carries can't point the update elsewhere
+	request.RequestId = ctx.Param("request_id")
+
+	dbRequest := h.authorizeRequest(ctx, request.RequestId, canManageBooking)
+	if dbRequest == nil {
 		return
 	}
+	// Clients change what they booked, who does the job and its progress are not theirs to set.
+	if !principalFrom(ctx).IsStaff() {
+		request.ClientId = dbRequest.ClientId
+		request.CleanerId = dbRequest.CleanerId
+		request.Status = dbRequest.Status
+	}
 
-	updatedRequest, err := h.requestService.UpdateRequest(request)
+	updatedRequest, err := h.requestService.UpdateRequest(ctx.Request.Context(), request)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"responseMessage": err.Error(),
-			"responseCode":    http.StatusInternalServerError,
-		})
+		ctx.Error(err)
 		return
 	}
 
@@ -257,161 +332,263 @@ func (h handler) UpdateRequest(ctx *gin.Context) {
 	})
 }
 
+// DeleteRequest cancels the request like CancelRequest does, requests are kept for their history
 func (h handler) DeleteRequest(ctx *gin.Context) {
+	h.CancelRequest(ctx)
+}
+
+func (h handler) AssignCleaner(ctx *gin.Context) {
 	requestId := ctx.Param("request_id")
+	cleanerId := ctx.Param("cleaner_id")
 
-	err := h.requestService.DeleteRequest(requestId)
+	err := h.requestService.AssignCleaner(ctx.Request.Context(), requestId, cleanerId)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"responseMessage": err.Error(),
-			"responseCode":    http.StatusInternalServerError,
-		})
+		ctx.Error(err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, gin.H{
-		"responseMessage": "Request deleted successfully",
+		"responseMessage": "Cleaner assigned successfully",
 		"responseCode":    http.StatusOK,
 	})
 }
 
-func (h handler) AssignCleaner(ctx *gin.Context) {
+func (h handler) MarkEnRoute(ctx *gin.Context) {
+	h.transitionRequest(ctx, domain.RequestEnRoute, canWorkRequest, "Cleaner is en route")
+}
+
+func (h handler) StartRequest(ctx *gin.Context) {
+	h.transitionRequest(ctx, domain.RequestInProgress, canWorkRequest, "Request started")
+}
+
+func (h handler) CompleteRequest(ctx *gin.Context) {
+	h.transitionRequest(ctx, domain.RequestCompleted, canWorkRequest, "Request completed")
+}
+
+type changeBody struct {
+	Reason   string `json:"reason"`
+	WaiveFee bool   `json:"waive_fee"`
+}
+
+type rescheduleBody struct {
+	changeBody
+	RequestedDate time.Time `json:"requested_date" binding:"required"`
+}
+
+func (b changeBody) change(principal domain.Principal, request domain.Request) domain.BookingChange {
+	return domain.BookingChange{
+		Initiator: changeInitiator(principal, request),
+		By:        principal.Subject,
+		Reason:    b.Reason,
+		FeeWaived: b.WaiveFee,
+	}
+}
+
+// CancelRequest cancels a booking for its client or staff, or takes its cleaner off the job when
+// the cleaner cancels
+func (h handler) CancelRequest(ctx *gin.Context) {
 	requestId := ctx.Param("request_id")
-	cleanerId := ctx.Param("cleaner_id")
+	var body changeBody
+	if ctx.Request.ContentLength != 0 && !bindJSON(ctx, &body) {
+		return
+	}
+	request := h.authorizeRequest(ctx, requestId, canChangeBooking)
+	if request == nil {
+		return
+	}
 
-	err := h.requestService.AssignCleaner(requestId, cleanerId)
+	request, err := h.requestService.CancelRequest(ctx.Request.Context(), requestId, body.change(principalFrom(ctx), *request))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"responseMessage": err.Error(),
-			"responseCode":    http.StatusInternalServerError,
-		})
+		ctx.Error(err)
 		return
 	}
 
+	message := "Request cancelled"
+	if request.Status != domain.RequestCancelled {
+		message = "Request released for another cleaner"
+	}
 	ctx.JSON(http.StatusOK, gin.H{
-		"responseMessage": "Cleaner assigned successfully",
+		"responseMessage": message,
+		"responseCode":    http.StatusOK,
+		"data":            request,
+	})
+}
+
+func (h handler) RescheduleRequest(ctx *gin.Context) {
+	requestId := ctx.Param("request_id")
+	var body rescheduleBody
+	if !bindJSON(ctx, &body) {
+		return
+	}
+	request := h.authorizeRequest(ctx, requestId, canManageBooking)
+	if request == nil {
+		return
+	}
+
+	request, err := h.requestService.RescheduleRequest(ctx.Request.Context(), requestId, body.RequestedDate, body.change(principalFrom(ctx), *request))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"responseMessage": "Request rescheduled",
 		"responseCode":    http.StatusOK,
+		"data":            request,
+	})
+}
+
+func (h handler) MarkNoShow(ctx *gin.Context) {
+	h.transitionRequest(ctx, domain.RequestNoShow, canWorkRequest, "Request marked as no show")
+}
+
+func (h handler) transitionRequest(ctx *gin.Context, status domain.RequestStatus, allowed func(domain.Principal, domain.Request) bool, message string) {
+	requestId := ctx.Param("request_id")
+	if h.authorizeRequest(ctx, requestId, allowed) == nil {
+		return
+	}
+
+	request, err := h.requestService.TransitionRequest(ctx.Request.Context(), requestId, status)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"responseMessage": message,
+		"responseCode":    http.StatusOK,
+		"data":            request,
 	})
 }
 
 func (h handler) GetRequestByClient(ctx *gin.Context) {
 	clientId := ctx.Param("client_id")
+	filter, err := parseRequestFilter(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
 
-	requests, err := h.requestService.GetRequestById(clientId)
+	page, err := h.requestService.GetRequestByClient(ctx.Request.Context(), clientId, filter)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"responseMessage": err.Error(),
-			"responseCode":    http.StatusInternalServerError,
-		})
+		ctx.Error(err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, gin.H{
 		"responseMessage": "Requests found for client",
 		"responseCode":    http.StatusOK,
-		"data":            requests,
+		"data":            page.Items,
+		"responseCount":   len(page.Items),
+		"next_cursor":     page.NextCursor,
+		"total":           page.Total,
 	})
 }
 
 func (h handler) GetRequestByCleaner(ctx *gin.Context) {
 	cleanerId := ctx.Param("cleaner_id")
+	filter, err := parseRequestFilter(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
 
-	requests, err := h.requestService.GetRequestByCleaner(cleanerId)
+	page, err := h.requestService.GetRequestByCleaner(ctx.Request.Context(), cleanerId, filter)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"responseMessage": err.Error(),
-			"responseCode":    http.StatusInternalServerError,
-		})
+		ctx.Error(err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, gin.H{
 		"responseMessage": "Requests found for cleaner",
 		"responseCode":    http.StatusOK,
-		"data":            requests,
+		"data":            page.Items,
+		"responseCount":   len(page.Items),
+		"next_cursor":     page.NextCursor,
+		"total":           page.Total,
 	})
 }
 
 func (h handler) CreateReview(ctx *gin.Context) {
 	var review domain.Reviews
-	if err := ctx.ShouldBindJSON(&review); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"responseMessage": err.Error(),
-			"responseCode":    http.StatusBadRequest,
-		})
+	if !bindJSON(ctx, &review) {
 		return
 	}
 
-	dbReview, err := h.reviewService.CreateReview(review)
+	// Clients review as themselves, admins name the client they review for. Either way the
+	// service checks the client against the request.
+	principal := principalFrom(ctx)
+	if principal.HasRole(domain.RoleClient) && !principal.HasRole(domain.RoleAdmin) {
+		review.ClientId = principal.Subject
+	}
+	if !principal.CanEditReview(review) {
+		forbid(ctx)
+		return
+	}
+
+	dbReview, err := h.reviewService.CreateReview(ctx.Request.Context(), review)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"responseMessage": err.Error(),
-			"responseCode":    http.StatusInternalServerError,
-		})
+		ctx.Error(err)
 		return
 	}
 
 	ctx.JSON(http.StatusCreated, gin.H{
 		"responseMessage": "Review created successfully",
 		"responseCode":    http.StatusCreated,
-		"data":            dbReview,
+		"data":            reviewView(principal, *dbReview),
 	})
 }
 
 func (h handler) GetReviewById(ctx *gin.Context) {
 	reviewId := ctx.Param("review_id")
 
-	review, err := h.reviewService.GetReviewById(reviewId)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"responseMessage": err.Error(),
-			"responseCode":    http.StatusInternalServerError,
-		})
+	review := h.authorizeReview(ctx, reviewId, canViewReview)
+	if review == nil {
 		return
 	}
 
 	ctx.JSON(http.StatusOK, gin.H{
 		"responseMessage": "Review found",
 		"responseCode":    http.StatusOK,
-		"data":            review,
+		"data":            reviewView(principalFrom(ctx), *review),
 	})
 }
 
 func (h handler) UpdateReview(ctx *gin.Context) {
 	var review domain.Reviews
-	if err := ctx.ShouldBindJSON(&review); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"responseMessage": err.Error(),
-			"responseCode":    http.StatusBadRequest,
-		})
+	if !bindJSON(ctx, &review) {
+		return
+	}
+	review.ReviewId = ctx.Param("review_id")
+
+	dbReview := h.authorizeReview(ctx, review.ReviewId, canEditReview)
+	if dbReview == nil {
 		return
 	}
+	review.ClientId = dbReview.ClientId
 
-	updatedReview, err := h.reviewService.UpdateReview(review)
+	updatedReview, err := h.reviewService.UpdateReview(ctx.Request.Context(), review)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"responseMessage": err.Error(),
-			"responseCode":    http.StatusInternalServerError,
-		})
+		ctx.Error(err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, gin.H{
 		"responseMessage": "Review updated successfully",
 		"responseCode":    http.StatusOK,
-		"data":            updatedReview,
+		"data":            reviewView(principalFrom(ctx), *updatedReview),
 	})
 }
 
 func (h handler) DeleteReview(ctx *gin.Context) {
 	reviewId := ctx.Param("review_id")
+	if h.authorizeReview(ctx, reviewId, canEditReview) == nil {
+		return
+	}
 
-	err := h.reviewService.DeleteReview(reviewId)
+	err := h.reviewService.DeleteReview(ctx.Request.Context(), reviewId)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"responseMessage": err.Error(),
-			"responseCode":    http.StatusInternalServerError,
-		})
+		ctx.Error(err)
 		return
 	}
 
@@ -423,38 +600,80 @@ func (h handler) DeleteReview(ctx *gin.Context) {
 
 func (h handler) GetReviewByClient(ctx *gin.Context) {
 	clientId := ctx.Param("client_id")
+	filter, err := parseReviewFilter(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
 
-	reviews, err := h.reviewService.GetReviewByClient(clientId)
+	page, err := h.reviewService.GetReviewByClient(ctx.Request.Context(), clientId, filter)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"responseMessage": err.Error(),
-			"responseCode":    http.StatusInternalServerError,
-		})
+		ctx.Error(err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, gin.H{
 		"responseMessage": "Reviews found for client",
 		"responseCode":    http.StatusOK,
-		"data":            reviews,
+		"data":            reviewViews(principalFrom(ctx), page.Items),
+		"responseCount":   len(page.Items),
+		"next_cursor":     page.NextCursor,
+		"total":           page.Total,
 	})
 }
 
 func (h handler) GetReviewByCleaner(ctx *gin.Context) {
 	cleanerId := ctx.Param("cleaner_id")
+	filter, err := parseReviewFilter(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	// Reviews about a cleaner that are not live are for staff only
+	if !principalFrom(ctx).IsStaff() {
+		filter.Statuses = domain.LiveReviewStatuses
+	}
 
-	reviews, err := h.reviewService.GetReviewByCleaner(cleanerId)
+	page, err := h.reviewService.GetReviewByCleaner(ctx.Request.Context(), cleanerId, filter)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"responseMessage": err.Error(),
-			"responseCode":    http.StatusInternalServerError,
-		})
+		ctx.Error(err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, gin.H{
 		"responseMessage": "Reviews found for cleaner",
 		"responseCode":    http.StatusOK,
-		"data":            reviews,
+		"data":            reviewViews(principalFrom(ctx), page.Items),
+		"responseCount":   len(page.Items),
+		"next_cursor":     page.NextCursor,
+		"total":           page.Total,
+	})
+}
+
+func (h handler) GetCleanerRatingSummary(ctx *gin.Context) {
+	summary, err := h.reviewService.GetCleanerRatingSummary(ctx.Request.Context(), ctx.Param("cleaner_id"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"responseMessage": "Rating summary found for cleaner",
+		"responseCode":    http.StatusOK,
+		"data":            summary,
+	})
+}
+
+func (h handler) GetServiceRatingSummary(ctx *gin.Context) {
+	summary, err := h.reviewService.GetServiceRatingSummary(ctx.Request.Context(), ctx.Param("service_id"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"responseMessage": "Rating summary found for service",
+		"responseCode":    http.StatusOK,
+		"data":            summary,
 	})
 }
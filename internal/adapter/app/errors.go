@@ -0,0 +1,70 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+	"github.com/gin-gonic/gin"
+)
+
+// statusByKind is the HTTP status each kind of domain error is reported with
+var statusByKind = map[domain.ErrorKind]int{
+	domain.KindNotFound:    http.StatusNotFound,
+	domain.KindConflict:    http.StatusConflict,
+	domain.KindValidation:  http.StatusUnprocessableEntity,
+	domain.KindForbidden:   http.StatusForbidden,
+	domain.KindUnavailable: http.StatusServiceUnavailable,
+}
+
+// describeError returns the status, error code and client facing message for err. Only domain
+// errors are described to clients; anything else may carry SQL or driver details and is reported
+// as a bare internal error.
+func describeError(err error) (int, string, string) {
+	domainErr, ok := domain.AsError(err)
+	if !ok {
+		return http.StatusInternalServerError, "internal_error", "internal server error"
+	}
+	status, ok := statusByKind[domainErr.Kind]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+	// An unavailable dependency's own error says nothing useful to clients
+	if domainErr.Kind == domain.KindUnavailable {
+		return status, domainErr.Code, domainErr.Message
+	}
+	return status, domainErr.Code, err.Error()
+}
+
+// bindJSON decodes the request body into value, recording a validation error when it does not fit
+func bindJSON(ctx *gin.Context, value interface{}) bool {
+	if err := ctx.ShouldBindJSON(value); err != nil {
+		ctx.Error(fmt.Errorf("%w: %v", domain.ErrInvalidInput, err))
+		return false
+	}
+	return true
+}
+
+// RenderErrors answers with the last error a handler recorded through ctx.Error, unless the handler
+// already wrote a response of its own. Server side errors are logged in full.
+func (m middleware) RenderErrors(ctx *gin.Context) {
+	ctx.Next()
+
+	if len(ctx.Errors) == 0 {
+		return
+	}
+	err := ctx.Errors.Last().Err
+	status, code, message := describeError(err)
+	if status >= http.StatusInternalServerError {
+		m.logger.WithContext(ctx.Request.Context()).Error(fmt.Sprintf("%s %s failed: %v", ctx.Request.Method, ctx.Request.URL.Path, err))
+	}
+	if ctx.Writer.Written() {
+		return
+	}
+
+	ctx.JSON(status, gin.H{
+		"responseMessage": message,
+		"responseCode":    status,
+		"errorCode":       code,
+	})
+}
@@ -0,0 +1,118 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/ports"
+	"github.com/gin-gonic/gin"
+)
+
+// errorLogger keeps the errors logged through it
+type errorLogger struct {
+	testLogger
+	mu     *sync.Mutex
+	errors *[]string
+}
+
+func newErrorLogger() errorLogger {
+	return errorLogger{mu: &sync.Mutex{}, errors: &[]string{}}
+}
+
+func (l errorLogger) Error(message string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	*l.errors = append(*l.errors, message)
+}
+
+func (l errorLogger) With(fields map[string]interface{}) ports.LoggerService { return l }
+func (l errorLogger) WithContext(ctx context.Context) ports.LoggerService    { return l }
+
+func TestRenderErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		status  int
+		code    string
+		message string
+		logged  bool
+	}{
+		{"not found", fmt.Errorf("%w: request 42", domain.ErrNotFound), http.StatusNotFound, "not_found", "record not found: request 42", false},
+		{"already exists", domain.ErrAlreadyExists, http.StatusConflict, "already_exists", "record already exists", false},
+		{"invalid transition", domain.ErrInvalidTransition, http.StatusConflict, "invalid_transition", "invalid request status transition", false},
+		{"cleaner unavailable", domain.ErrCleanerUnavailable, http.StatusConflict, "cleaner_unavailable", "cleaner is not available", false},
+		{"no eligible cleaner", domain.ErrNoEligibleCleaner, http.StatusConflict, "no_eligible_cleaner", "no eligible cleaner", false},
+		{"not reviewable", domain.ErrNotReviewable, http.StatusConflict, "not_reviewable", "request cannot be reviewed", false},
+		{"currency mismatch", domain.ErrCurrencyMismatch, http.StatusConflict, "currency_mismatch", "amounts are in different currencies", false},
+		{"payment settled", domain.ErrPaymentSettled, http.StatusConflict, "payment_settled", "payment is already settled", false},
+		{"invalid input", fmt.Errorf("%w: rating must be between 1 and 5", domain.ErrInvalidInput), http.StatusUnprocessableEntity, "invalid_input", "invalid input: rating must be between 1 and 5", false},
+		{"invalid list options", domain.ErrInvalidListOptions, http.StatusUnprocessableEntity, "invalid_list_options", "invalid list options", false},
+		{"forbidden", domain.ErrForbidden, http.StatusForbidden, "forbidden", "forbidden", false},
+		// The dependency's own error is logged but not shown to clients
+		{"unavailable", fmt.Errorf("%w: mpesa: dial tcp 196.201.214.200:443: i/o timeout", domain.ErrUnavailable), http.StatusServiceUnavailable, "service_unavailable", "service temporarily unavailable", true},
+		// Anything that isn't a domain error may carry SQL or driver details
+		{"internal", errors.New(`pq: relation "Prod_request" does not exist`), http.StatusInternalServerError, "internal_error", "internal server error", true},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := newErrorLogger()
+			router := gin.New()
+			router.Use(NewMiddleware(logger, testPrincipals).RenderErrors)
+			router.GET("/fail", func(ctx *gin.Context) { ctx.Error(tt.err) })
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/fail", nil))
+
+			var body struct {
+				ResponseMessage string `json:"responseMessage"`
+				ResponseCode    int    `json:"responseCode"`
+				ErrorCode       string `json:"errorCode"`
+			}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if recorder.Code != tt.status || body.ResponseCode != tt.status || body.ErrorCode != tt.code || body.ResponseMessage != tt.message {
+				t.Errorf("expected %d %s %q, got %d %+v", tt.status, tt.code, tt.message, recorder.Code, body)
+			}
+			if logged := len(*logger.errors) == 1 && strings.Contains((*logger.errors)[0], tt.err.Error()); logged != tt.logged {
+				t.Errorf("expected the error logged in full: %v, got %q", tt.logged, *logger.errors)
+			}
+		})
+	}
+}
+
+func TestRenderErrorsLeavesWrittenResponses(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(NewMiddleware(newErrorLogger(), testPrincipals).RenderErrors)
+	router.GET("/written", func(ctx *gin.Context) {
+		ctx.Error(domain.ErrNotFound)
+		ctx.String(http.StatusAccepted, "already answered")
+	})
+	router.GET("/last", func(ctx *gin.Context) {
+		ctx.Error(domain.ErrNotFound)
+		ctx.Error(domain.ErrForbidden)
+	})
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/written", nil))
+	if recorder.Code != http.StatusAccepted || recorder.Body.String() != "already answered" {
+		t.Errorf("expected the handler's own response, got %d %q", recorder.Code, recorder.Body.String())
+	}
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/last", nil))
+	if recorder.Code != http.StatusForbidden {
+		t.Errorf("expected the last error recorded to be reported, got %d", recorder.Code)
+	}
+}
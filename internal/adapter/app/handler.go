@@ -1,21 +1,28 @@
 package app
 
 import (
-	"fmt"
-	"log"
+	"net/http"
+	"strings"
 
 	"github.com/AntonyIS/usafi-hub-cleaning-service/config"
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/adapter/metrics"
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/adapter/tracing"
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
 	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/ports"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
-func InitGinRoutes(serviceService ports.ServiceService, requestService ports.RequestService, reviewService ports.ReviewService, config config.Config, logger ports.LoggerService) {
+// InitGinRoutes builds the router serving the API, cmd runs it
+func InitGinRoutes(serviceService ports.ServiceService, requestService ports.RequestService, reviewService ports.ReviewService, availabilityService ports.AvailabilityService, matchingService ports.MatchingService, pricingService ports.PricingService, invoiceService ports.InvoiceService, paymentService ports.PaymentService, recurringService ports.RecurringBookingService, checks []ports.HealthCheck, verifier ports.TokenVerifier, recorder *metrics.Metrics, tracer *tracing.Tracer, config config.Config, logger ports.LoggerService) http.Handler {
 	gin.SetMode(gin.DebugMode)
 
-	router := gin.Default()
+	middleware := NewMiddleware(logger, verifier)
+
+	router := gin.New()
+	router.Use(middleware.CorrelateRequest, traceRequests(tracer), observeRequests(recorder), gin.Recovery(), middleware.RenderErrors)
 	router.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"*"},
+		AllowOrigins:     allowedOrigins(config.CORS_ALLOWED_ORIGINS),
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE"},
 		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type", "Authorization"},
 		ExposeHeaders:    []string{"Content-Length"},
@@ -26,6 +33,13 @@ func InitGinRoutes(serviceService ports.ServiceService, requestService ports.Req
 		serviceService,
 		requestService,
 		reviewService,
+		availabilityService,
+		matchingService,
+		pricingService,
+		invoiceService,
+		paymentService,
+		recurringService,
+		checks,
 	)
 
 	// Define routes
@@ -33,43 +47,123 @@ func InitGinRoutes(serviceService ports.ServiceService, requestService ports.Req
 	servicesRoutes := router.Group("/services/v1")
 	requestsRoutes := router.Group("/requests/v1")
 	reviewsRoutes := router.Group("/reviews/v1")
-
-	middleware := NewMiddleware(logger, config.SECRET_KEY)
+	cleanersRoutes := router.Group("/cleaners/v1")
+	invoicesRoutes := router.Group("/invoices/v1")
+	paymentsRoutes := router.Group("/payments/v1")
+	recurringRoutes := router.Group("/recurring/v1")
 
 	// servicesRoutes.Use(middleware.AuthorizeToken)
 	requestsRoutes.Use(middleware.AuthorizeToken)
 	reviewsRoutes.Use(middleware.AuthorizeToken)
+	cleanersRoutes.Use(middleware.AuthorizeToken)
+	invoicesRoutes.Use(middleware.AuthorizeToken)
+	recurringRoutes.Use(middleware.AuthorizeToken)
 	homeRoutes.Use(middleware.AuthorizeToken)
 
 	// Home routes
 	homeRoutes.GET("/", handler.Home)
 	homeRoutes.GET("/health-check", handler.Healthcheck)
 
-	// Services routes
-	servicesRoutes.POST("/", handler.CreateService)
+	// Probes for the orchestrator, which has no access token
+	router.GET("/livez", handler.Livez)
+	router.GET("/readyz", handler.Readyz)
+
+	// Prometheus scrapes without an access token, keep the port off the public network
+	router.GET("/metrics", gin.WrapH(recorder.Handler()))
+
+	// Route policies. Ownership of individual requests, reviews, invoices and payments is checked
+	// by the handlers once the record is loaded.
+	adminOnly := middleware.RequireRoles(domain.RoleAdmin)
+	staffOnly := middleware.RequireRoles(domain.RoleAdmin, domain.RoleDispatcher)
+
+	// Services routes, the catalog is public but only admins manage it
+	servicesRoutes.POST("/", middleware.AuthorizeToken, adminOnly, handler.CreateService)
 	servicesRoutes.GET("/:service_id", handler.GetServiceById)
 	servicesRoutes.GET("/", handler.GetServices)
-	servicesRoutes.PUT("/:service_id", handler.UpdateService)
-	servicesRoutes.DELETE("/:service_id", handler.DeleteService)
+	servicesRoutes.PUT("/:service_id", middleware.AuthorizeToken, adminOnly, handler.UpdateService)
+	servicesRoutes.DELETE("/:service_id", middleware.AuthorizeToken, adminOnly, handler.DeleteService)
 
 	// Requests routes
 	requestsRoutes.POST("/", handler.CreateRequest)
+	requestsRoutes.POST("/quote", handler.QuoteRequest)
 	requestsRoutes.GET("/:request_id", handler.GetRequestById)
-	requestsRoutes.GET("/", handler.GetRequests)
+	requestsRoutes.GET("/", staffOnly, handler.GetRequests)
 	requestsRoutes.PUT("/:request_id", handler.UpdateRequest)
 	requestsRoutes.DELETE("/:request_id", handler.DeleteRequest)
-	requestsRoutes.POST("/:request_id/assign-cleaner/:cleaner_id", handler.AssignCleaner)
-	requestsRoutes.GET("/client/:client_id", handler.GetRequestByClient)
-	requestsRoutes.GET("/cleaner/:cleaner_id", handler.GetRequestByCleaner)
+	requestsRoutes.POST("/:request_id/assign-cleaner/:cleaner_id", staffOnly, handler.AssignCleaner)
+	requestsRoutes.GET("/:request_id/matches", staffOnly, handler.GetRequestMatches)
+	requestsRoutes.POST("/:request_id/auto-assign", staffOnly, handler.AutoAssignCleaner)
+	requestsRoutes.POST("/:request_id/en-route", handler.MarkEnRoute)
+	requestsRoutes.POST("/:request_id/start", handler.StartRequest)
+	requestsRoutes.POST("/:request_id/complete", handler.CompleteRequest)
+	requestsRoutes.POST("/:request_id/cancel", handler.CancelRequest)
+	requestsRoutes.POST("/:request_id/reschedule", handler.RescheduleRequest)
+	requestsRoutes.POST("/:request_id/no-show", handler.MarkNoShow)
+	requestsRoutes.GET("/client/:client_id", actingAsClient, handler.GetRequestByClient)
+	requestsRoutes.GET("/cleaner/:cleaner_id", actingAsCleaner, handler.GetRequestByCleaner)
 
 	// Reviews routes
 	reviewsRoutes.POST("/", handler.CreateReview)
 	reviewsRoutes.GET("/:review_id", handler.GetReviewById)
 	reviewsRoutes.PUT("/:review_id", handler.UpdateReview)
 	reviewsRoutes.DELETE("/:review_id", handler.DeleteReview)
-	reviewsRoutes.GET("/client/:client_id", handler.GetReviewByClient)
-	reviewsRoutes.GET("/cleaner/:cleaner_id", handler.GetReviewByCleaner)
+	reviewsRoutes.GET("/moderation", adminOnly, handler.GetModerationQueue)
+	reviewsRoutes.POST("/:review_id/approve", adminOnly, handler.ApproveReview)
+	reviewsRoutes.POST("/:review_id/reject", adminOnly, handler.RejectReview)
+	reviewsRoutes.POST("/:review_id/flag", handler.FlagReview)
+	reviewsRoutes.PUT("/:review_id/response", handler.RespondToReview)
+	reviewsRoutes.GET("/client/:client_id", actingAsClient, handler.GetReviewByClient)
+	reviewsRoutes.GET("/cleaner/:cleaner_id", actingAsCleaner, handler.GetReviewByCleaner)
+	// Rating summaries hold nothing personal, anyone signed in may read them
+	reviewsRoutes.GET("/cleaner/:cleaner_id/summary", handler.GetCleanerRatingSummary)
+	reviewsRoutes.GET("/service/:service_id/summary", handler.GetServiceRatingSummary)
+
+	// Cleaners routes, anyone signed in can see when a cleaner is free but only the cleaner
+	// and staff manage their calendar and profile
+	cleanersRoutes.PUT("/:cleaner_id/working-hours", actingAsCleaner, handler.SetWorkingHours)
+	cleanersRoutes.GET("/:cleaner_id/working-hours", handler.GetWorkingHours)
+	cleanersRoutes.POST("/:cleaner_id/exceptions", actingAsCleaner, handler.CreateAvailabilityException)
+	cleanersRoutes.GET("/:cleaner_id/exceptions", actingAsCleaner, handler.GetAvailabilityExceptions)
+	cleanersRoutes.DELETE("/:cleaner_id/exceptions/:exception_id", actingAsCleaner, handler.DeleteAvailabilityException)
+	cleanersRoutes.POST("/:cleaner_id/time-off", actingAsCleaner, handler.CreateTimeOff)
+	cleanersRoutes.GET("/:cleaner_id/time-off", actingAsCleaner, handler.GetTimeOff)
+	cleanersRoutes.DELETE("/:cleaner_id/time-off/:time_off_id", actingAsCleaner, handler.DeleteTimeOff)
+	cleanersRoutes.GET("/:cleaner_id/slots", handler.GetCleanerSlots)
+	cleanersRoutes.PUT("/:cleaner_id/profile", actingAsCleaner, handler.UpsertCleanerProfile)
+	cleanersRoutes.GET("/:cleaner_id/profile", handler.GetCleanerProfile)
+
+	// Invoices routes
+	invoicesRoutes.GET("/:invoice_id", handler.GetInvoiceById)
+	invoicesRoutes.GET("/:invoice_id/pdf", handler.GetInvoicePDF)
+	invoicesRoutes.GET("/request/:request_id", handler.GetInvoiceByRequest)
+	invoicesRoutes.POST("/request/:request_id", staffOnly, handler.GenerateInvoice)
+	invoicesRoutes.GET("/client/:client_id", actingAsClient, handler.GetInvoicesByClient)
+
+	// Recurring booking routes, only the booking's client and staff manage a series
+	recurringRoutes.POST("/", handler.CreateRecurringBooking)
+	recurringRoutes.GET("/:booking_id", handler.GetRecurringBookingById)
+	recurringRoutes.GET("/:booking_id/occurrences", handler.GetRecurringSchedule)
+	recurringRoutes.POST("/:booking_id/occurrences/skip", handler.SkipOccurrence)
+	recurringRoutes.POST("/:booking_id/occurrences/reschedule", handler.RescheduleOccurrence)
+	recurringRoutes.POST("/:booking_id/cancel", handler.CancelRecurringBooking)
+	recurringRoutes.GET("/client/:client_id", actingAsClient, handler.GetRecurringBookingsByClient)
+
+	// Payments routes, the provider callback is authorized by its token instead of an access token
+	paymentsRoutes.POST("/callback", verifyCallbackToken(config.PAYMENT_CALLBACK_TOKEN), handler.PaymentCallback)
+	paymentsRoutes.POST("/request/:request_id", middleware.AuthorizeToken, handler.InitiatePayment)
+	paymentsRoutes.GET("/request/:request_id", middleware.AuthorizeToken, handler.GetPaymentsByRequest)
+	paymentsRoutes.GET("/:payment_id", middleware.AuthorizeToken, handler.GetPaymentById)
+
+	return router
+}
 
-	log.Printf("Server running on port 0.0.0.0:%s", config.SERVER_PORT)
-	router.Run(fmt.Sprintf(":%s", config.SERVER_PORT))
+// allowedOrigins splits the comma separated CORS_ALLOWED_ORIGINS
+func allowedOrigins(value string) []string {
+	var origins []string
+	for _, origin := range strings.Split(value, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
 }
@@ -0,0 +1,215 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/config"
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/adapter/metrics"
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/adapter/moderation"
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/adapter/payment"
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/adapter/pdf"
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/adapter/repository"
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/ports"
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/services"
+)
+
+type testLogger struct{}
+
+func (testLogger) Debug(message string)   {}
+func (testLogger) Info(message string)    {}
+func (testLogger) Warning(message string) {}
+func (testLogger) Error(message string)   {}
+
+func (l testLogger) With(fields map[string]interface{}) ports.LoggerService { return l }
+func (l testLogger) WithContext(ctx context.Context) ports.LoggerService    { return l }
+
+// testVerifier accepts the access tokens it was built with and refuses anything else
+type testVerifier map[string]domain.Principal
+
+func (v testVerifier) Verify(token string) (*domain.Principal, error) {
+	principal, ok := v[token]
+	if !ok {
+		return nil, errors.New("unknown token")
+	}
+	return &principal, nil
+}
+
+var testPrincipals = testVerifier{
+	"admin":    {Subject: "admin-1", Roles: []domain.Role{domain.RoleAdmin}},
+	"client-1": {Subject: "client-1", Roles: []domain.Role{domain.RoleClient}},
+	"client-2": {Subject: "client-2", Roles: []domain.Role{domain.RoleClient}},
+}
+
+// newTestRouter serves the API over the memory backend, wired the way cmd wires it
+func newTestRouter(t *testing.T) http.Handler {
+	t.Helper()
+	repo := repository.NewMemoryClient()
+	logger := testLogger{}
+
+	serviceService := services.NewServiceServiceManagement(repo, logger)
+	availabilityService := services.NewAvailabilityServiceManagement(repo, repo, repo, time.UTC, logger)
+	pricingService := services.NewPricingServiceManagement(repo, domain.PricingRules{}, time.UTC, logger)
+	invoiceService := services.NewInvoiceServiceManagement(repo, repo, pricingService, pdf.NewInvoiceRenderer("Usafi Hub", time.UTC), 1600, logger)
+	requestService := services.NewRequestServiceManagement(repo, repo, repo, availabilityService, pricingService, invoiceService, logger)
+	paymentService := services.NewPaymentServiceManagement(repo, repo, repo, repo, payment.NewSimulator(), logger)
+	reviewService := services.NewReviewServiceManagement(repo, repo, repo, moderation.NewWordlistFilter(nil), 14*24*time.Hour, logger)
+	matchingService := services.NewMatchingServiceManagement(repo, repo, repo, requestService, availabilityService, services.NewWeightedMatchingStrategy(), logger)
+	recurringService := services.NewRecurringBookingServiceManagement(repo, repo, repo, requestService, repo, 28*24*time.Hour, time.UTC, logger)
+
+	return InitGinRoutes(serviceService, requestService, reviewService, availabilityService, matchingService, pricingService, invoiceService, paymentService, recurringService, nil, testPrincipals, metrics.NewMetrics(), nil, config.Config{CORS_ALLOWED_ORIGINS: "http://localhost:3000"}, logger)
+}
+
+// testResponse is the envelope every handler answers with
+type testResponse struct {
+	ResponseCode    int             `json:"responseCode"`
+	ResponseMessage string          `json:"responseMessage"`
+	ErrorCode       string          `json:"errorCode"`
+	Data            json.RawMessage `json:"data"`
+}
+
+// serve sends body as JSON to the router with token as the access token, an empty token sends none
+func serve(t *testing.T, router http.Handler, method, path, token string, body interface{}) (int, testResponse) {
+	t.Helper()
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &payload)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("access_token", token)
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	var response testResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("%s %s: decoding %q: %v", method, path, recorder.Body.String(), err)
+	}
+	if response.ResponseCode != recorder.Code {
+		t.Errorf("%s %s: expected responseCode %d to match the status, got %d", method, path, recorder.Code, response.ResponseCode)
+	}
+	return recorder.Code, response
+}
+
+// createTestService has the admin add a service to the catalog through the API
+func createTestService(t *testing.T, router http.Handler, name string) domain.Service {
+	t.Helper()
+	var service domain.Service
+	catalog := domain.Service{Name: name, HourlyRate: domain.NewMoney(150000, "KES"), DurationMinutes: 120}
+	decode(t, serveExpecting(t, router, http.MethodPost, "/services/v1/", "admin", catalog, http.StatusCreated), &service)
+	return service
+}
+
+// bookTestRequest has the client token belongs to book serviceId through the API
+func bookTestRequest(t *testing.T, router http.Handler, token, serviceId string) domain.Request {
+	t.Helper()
+	var request domain.Request
+	booking := domain.Request{ServiceId: serviceId, RequestedDate: time.Now().Add(72 * time.Hour).UTC().Truncate(time.Hour)}
+	decode(t, serveExpecting(t, router, http.MethodPost, "/requests/v1/", token, booking, http.StatusCreated), &request)
+	return request
+}
+
+// serveExpecting is serve for requests that have to succeed with status
+func serveExpecting(t *testing.T, router http.Handler, method, path, token string, body interface{}, status int) testResponse {
+	t.Helper()
+	got, response := serve(t, router, method, path, token, body)
+	if got != status {
+		t.Fatalf("%s %s: expected %d, got %d %s", method, path, status, got, response.ResponseMessage)
+	}
+	return response
+}
+
+func decode(t *testing.T, response testResponse, value interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(response.Data, value); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRequestHandlers(t *testing.T) {
+	router := newTestRouter(t)
+	request := bookTestRequest(t, router, "client-1", createTestService(t, router, "Deep clean").ServiceId)
+	if request.ClientId != "client-1" || request.Status != domain.RequestPending {
+		t.Fatalf("expected a pending request booked for the caller, got %+v", request)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		status int
+		code   string
+	}{
+		{"owner reads the request", http.MethodGet, "/requests/v1/" + request.RequestId, "client-1", http.StatusOK, ""},
+		{"admin reads the request", http.MethodGet, "/requests/v1/" + request.RequestId, "admin", http.StatusOK, ""},
+		{"another client is refused", http.MethodGet, "/requests/v1/" + request.RequestId, "client-2", http.StatusForbidden, "forbidden"},
+		{"missing request", http.MethodGet, "/requests/v1/missing", "admin", http.StatusNotFound, "not_found"},
+		{"no access token", http.MethodGet, "/requests/v1/" + request.RequestId, "", http.StatusUnauthorized, "unauthorized"},
+		{"unknown access token", http.MethodGet, "/requests/v1/" + request.RequestId, "forged", http.StatusUnauthorized, "unauthorized"},
+		{"clients do not list every request", http.MethodGet, "/requests/v1/", "client-1", http.StatusForbidden, "forbidden"},
+		{"clients do not manage the catalog", http.MethodPost, "/services/v1/", "client-1", http.StatusForbidden, "forbidden"},
+		{"the catalog is public", http.MethodGet, "/services/v1/" + request.ServiceId, "", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		status, response := serve(t, router, tt.method, tt.path, tt.token, nil)
+		if status != tt.status || response.ErrorCode != tt.code {
+			t.Errorf("%s: expected %d %q, got %d %q", tt.name, tt.status, tt.code, status, response.ErrorCode)
+		}
+	}
+}
+
+// The path names the record an update applies to, an id in the body can't redirect it
+func TestUpdatesApplyToThePathRecord(t *testing.T) {
+	router := newTestRouter(t)
+	deepClean := createTestService(t, router, "Deep clean")
+	laundry := createTestService(t, router, "Laundry")
+	theirs := bookTestRequest(t, router, "client-1", deepClean.ServiceId)
+	mine := bookTestRequest(t, router, "client-2", deepClean.ServiceId)
+
+	change := mine
+	change.RequestId = theirs.RequestId
+	change.Latitude, change.Longitude = -1.2921, 36.8219
+	var updated domain.Request
+	decode(t, serveExpecting(t, router, http.MethodPut, "/requests/v1/"+mine.RequestId, "client-2", change, http.StatusOK), &updated)
+	if updated.RequestId != mine.RequestId || updated.Latitude != change.Latitude {
+		t.Errorf("expected the update to apply to %s, got %+v", mine.RequestId, updated)
+	}
+	var untouched domain.Request
+	decode(t, serveExpecting(t, router, http.MethodGet, "/requests/v1/"+theirs.RequestId, "client-1", nil, http.StatusOK), &untouched)
+	if untouched.Latitude != 0 {
+		t.Errorf("expected another client's request to be left alone, got %+v", untouched)
+	}
+
+	change.RequestId = mine.RequestId
+	if status, response := serve(t, router, http.MethodPut, "/requests/v1/"+theirs.RequestId, "client-2", change); status != http.StatusForbidden {
+		t.Errorf("expected an update of another client's request to be refused, got %d %s", status, response.ResponseMessage)
+	}
+
+	rename := laundry
+	rename.ServiceId = deepClean.ServiceId
+	rename.Name = "Laundry and ironing"
+	var renamed domain.Service
+	decode(t, serveExpecting(t, router, http.MethodPut, "/services/v1/"+laundry.ServiceId, "admin", rename, http.StatusOK), &renamed)
+	if renamed.ServiceId != laundry.ServiceId {
+		t.Errorf("expected the update to apply to %s, got %+v", laundry.ServiceId, renamed)
+	}
+	var unchanged domain.Service
+	decode(t, serveExpecting(t, router, http.MethodGet, "/services/v1/"+deepClean.ServiceId, "", nil, http.StatusOK), &unchanged)
+	if unchanged.Name != "Deep clean" {
+		t.Errorf("expected the service the body named to be left alone, got %+v", unchanged)
+	}
+}
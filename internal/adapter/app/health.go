@@ -0,0 +1,88 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/ports"
+	"github.com/gin-gonic/gin"
+)
+
+// readinessTimeout bounds each readiness check, well inside the usual probe timeout
+const readinessTimeout = 2 * time.Second
+
+type componentStatus struct {
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latency_ms"`
+}
+
+// Livez answers as long as the process can serve HTTP at all
+func (h handler) Livez(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{
+		"responseMessage": "Service is alive",
+		"responseCode":    http.StatusOK,
+	})
+}
+
+// Readyz runs every health check concurrently and reports each component. The service is ready
+// only when all of them pass. Failure details are logged rather than returned, since the probe
+// needs no access token.
+func (h handler) Readyz(ctx *gin.Context) {
+	checkCtx, cancel := context.WithTimeout(ctx.Request.Context(), readinessTimeout)
+	defer cancel()
+
+	components := make([]componentStatus, len(h.checks))
+	failures := make([]error, len(h.checks))
+	var wg sync.WaitGroup
+	for i, check := range h.checks {
+		wg.Add(1)
+		go func(i int, check ports.HealthCheck) {
+			defer wg.Done()
+			started := time.Now()
+			err := runCheck(checkCtx, check)
+
+			components[i] = componentStatus{Name: check.Name(), Status: "up", LatencyMs: time.Since(started).Milliseconds()}
+			if err != nil {
+				components[i].Status = "down"
+				failures[i] = fmt.Errorf("readiness check %s failed: %w", check.Name(), err)
+			}
+		}(i, check)
+	}
+	wg.Wait()
+
+	if err := errors.Join(failures...); err != nil {
+		ctx.Error(err)
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{
+			"responseMessage": "Service is not ready",
+			"responseCode":    http.StatusServiceUnavailable,
+			"data":            components,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"responseMessage": "Service is ready",
+		"responseCode":    http.StatusOK,
+		"data":            components,
+	})
+}
+
+// runCheck gives up on a check once ctx is done, even if the check itself ignores ctx
+func runCheck(ctx context.Context, check ports.HealthCheck) error {
+	result := make(chan error, 1)
+	go func() {
+		result <- check.Check(ctx)
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,120 @@
+package app
+
+import (
+	"net/http"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+	"github.com/gin-gonic/gin"
+)
+
+func (h handler) GenerateInvoice(ctx *gin.Context) {
+	requestId := ctx.Param("request_id")
+
+	invoice, err := h.invoiceService.GenerateInvoice(ctx.Request.Context(), requestId)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"responseMessage": "Invoice generated successfully",
+		"responseCode":    http.StatusOK,
+		"data":            invoice,
+	})
+}
+
+// GetInvoiceById responds with JSON, or with the PDF when ?format=pdf is given
+func (h handler) GetInvoiceById(ctx *gin.Context) {
+	invoiceId := ctx.Param("invoice_id")
+
+	if ctx.Query("format") == "pdf" {
+		h.GetInvoicePDF(ctx)
+		return
+	}
+
+	invoice := h.authorizeInvoice(ctx, invoiceId)
+	if invoice == nil {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"responseMessage": "Invoice found",
+		"responseCode":    http.StatusOK,
+		"data":            invoice,
+	})
+}
+
+func (h handler) GetInvoicePDF(ctx *gin.Context) {
+	invoiceId := ctx.Param("invoice_id")
+	if h.authorizeInvoice(ctx, invoiceId) == nil {
+		return
+	}
+
+	document, err := h.invoiceService.RenderInvoicePDF(ctx.Request.Context(), invoiceId)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.Header("Content-Disposition", `inline; filename="invoice-`+invoiceId+`.pdf"`)
+	ctx.Data(http.StatusOK, "application/pdf", document)
+}
+
+func (h handler) GetInvoiceByRequest(ctx *gin.Context) {
+	requestId := ctx.Param("request_id")
+
+	invoice, err := h.invoiceService.GetInvoiceByRequest(ctx.Request.Context(), requestId)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	if !principalFrom(ctx).CanActAsClient(invoice.ClientId) {
+		forbid(ctx)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"responseMessage": "Invoice found",
+		"responseCode":    http.StatusOK,
+		"data":            invoice,
+	})
+}
+
+func (h handler) GetInvoicesByClient(ctx *gin.Context) {
+	clientId := ctx.Param("client_id")
+	filter, err := parseInvoiceFilter(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	page, err := h.invoiceService.GetInvoicesByClient(ctx.Request.Context(), clientId, filter)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"responseMessage": "Invoices found for client",
+		"responseCode":    http.StatusOK,
+		"data":            page.Items,
+		"responseCount":   len(page.Items),
+		"next_cursor":     page.NextCursor,
+		"total":           page.Total,
+	})
+}
+
+// authorizeInvoice loads the invoice when the caller is its client or staff, otherwise it responds
+// and returns nil
+func (h handler) authorizeInvoice(ctx *gin.Context, invoiceId string) *domain.Invoice {
+	invoice, err := h.invoiceService.GetInvoiceById(ctx.Request.Context(), invoiceId)
+	if err != nil {
+		ctx.Error(err)
+		return nil
+	}
+	if !principalFrom(ctx).CanActAsClient(invoice.ClientId) {
+		forbid(ctx)
+		return nil
+	}
+	return invoice
+}
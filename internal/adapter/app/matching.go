@@ -0,0 +1,77 @@
+package app
+
+import (
+	"net/http"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+	"github.com/gin-gonic/gin"
+)
+
+func (h handler) UpsertCleanerProfile(ctx *gin.Context) {
+	var profile domain.CleanerProfile
+	if !bindJSON(ctx, &profile) {
+		return
+	}
+	profile.CleanerId = ctx.Param("cleaner_id")
+
+	dbProfile, err := h.matchingService.UpsertCleanerProfile(ctx.Request.Context(), profile)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"responseMessage": "Cleaner profile saved successfully",
+		"responseCode":    http.StatusOK,
+		"data":            dbProfile,
+	})
+}
+
+func (h handler) GetCleanerProfile(ctx *gin.Context) {
+	cleanerId := ctx.Param("cleaner_id")
+
+	profile, err := h.matchingService.GetCleanerProfile(ctx.Request.Context(), cleanerId)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"responseMessage": "Cleaner profile found",
+		"responseCode":    http.StatusOK,
+		"data":            profile,
+	})
+}
+
+func (h handler) GetRequestMatches(ctx *gin.Context) {
+	requestId := ctx.Param("request_id")
+
+	matches, err := h.matchingService.RankCleaners(ctx.Request.Context(), requestId)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"responseMessage": "Cleaner matches found",
+		"responseCode":    http.StatusOK,
+		"responseCount":   len(*matches),
+		"data":            matches,
+	})
+}
+
+func (h handler) AutoAssignCleaner(ctx *gin.Context) {
+	requestId := ctx.Param("request_id")
+
+	request, err := h.matchingService.AutoAssign(ctx.Request.Context(), requestId)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"responseMessage": "Cleaner assigned successfully",
+		"responseCode":    http.StatusOK,
+		"data":            request,
+	})
+}
@@ -5,72 +5,142 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/adapter/logger"
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/adapter/metrics"
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/adapter/tracing"
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
 	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/ports"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
 )
 
+const requestIdHeader = "X-Request-ID"
+
 type middleware struct {
-	logger    ports.LoggerService
-	secretKey string
+	logger   ports.LoggerService
+	verifier ports.TokenVerifier
 }
 
-func NewMiddleware(logger ports.LoggerService, secretKey string) *middleware {
+func NewMiddleware(logger ports.LoggerService, verifier ports.TokenVerifier) *middleware {
 	return &middleware{
-		logger:    logger,
-		secretKey: secretKey,
+		logger:   logger,
+		verifier: verifier,
 	}
 }
 
-func (m middleware) AuthorizeToken(ctx *gin.Context) {
-	tokenString := ctx.GetHeader("access_token")
+// CorrelateRequest tags the request with the caller's X-Request-ID, or a new one, and echoes it back.
+// The id and route are attached to every line logged for the request, which ends with an access line.
+func (m middleware) CorrelateRequest(ctx *gin.Context) {
+	requestId := ctx.GetHeader(requestIdHeader)
+	if requestId == "" || len(requestId) > 128 {
+		requestId = uuid.New().String()
+	}
+	ctx.Header(requestIdHeader, requestId)
 
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			m.logger.Error(fmt.Sprintf("unexpected signing method: %v", token.Header["sub"]))
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["sub"])
-		}
-		return []byte(m.secretKey), nil
+	ctx.Request = ctx.Request.WithContext(logger.ContextWithFields(ctx.Request.Context(), map[string]interface{}{
+		"request_id": requestId,
+		"method":     ctx.Request.Method,
+		"route":      routeOf(ctx),
+	}))
+
+	started := time.Now()
+	ctx.Next()
+
+	access := m.logger.WithContext(ctx.Request.Context()).With(map[string]interface{}{
+		"status":      ctx.Writer.Status(),
+		"duration_ms": time.Since(started).Milliseconds(),
+		"path":        ctx.Request.URL.Path,
 	})
+	if ctx.Writer.Status() >= http.StatusInternalServerError {
+		access.Warning("request served")
+		return
+	}
+	access.Info("request served")
+}
 
-	if err != nil {
-		if err != nil {
-			m.logger.Error(fmt.Sprintf("Failed to verify token string : %v", err))
-			ctx.JSON(http.StatusUnauthorized, gin.H{
-				"responseCode":    http.StatusUnauthorized,
-				"responseMessage": "Failed to verify token string",
-			})
-			ctx.Abort()
+// observeRequests records the status and latency of every request against its route pattern
+func observeRequests(recorder *metrics.Metrics) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		started := time.Now()
+		ctx.Next()
+
+		recorder.ObserveHTTP(routeOf(ctx), ctx.Request.Method, ctx.Writer.Status(), time.Since(started))
+	}
+}
+
+// traceRequests starts the server span of every request, continuing the caller's trace when a
+// traceparent header is sent. Without a tracer requests are not traced.
+func traceRequests(tracer *tracing.Tracer) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if tracer == nil {
+			ctx.Next()
 			return
 		}
 
+		spanCtx, span := tracer.StartServer(ctx.Request.Context(), ctx.Request.Method+" "+routeOf(ctx), ctx.Request.Header)
+		span.SetAttribute("http.method", ctx.Request.Method)
+		span.SetAttribute("http.route", routeOf(ctx))
+		span.SetAttribute("http.target", ctx.Request.URL.Path)
+		ctx.Request = ctx.Request.WithContext(logger.ContextWithFields(spanCtx, map[string]interface{}{
+			"trace_id": span.TraceId(),
+		}))
+
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		span.SetAttribute("http.status_code", status)
+		if status >= http.StatusInternalServerError {
+			if last := ctx.Errors.Last(); last != nil {
+				span.RecordError(last.Err)
+			} else {
+				span.RecordError(fmt.Errorf("%s", http.StatusText(status)))
+			}
+		}
+		span.End()
+	}
+}
+
+// routeOf is the route pattern that matched the request, which keeps ids out of metric labels
+func routeOf(ctx *gin.Context) string {
+	if route := ctx.FullPath(); route != "" {
+		return route
+	}
+	return "unmatched"
+}
+
+func (m middleware) AuthorizeToken(ctx *gin.Context) {
+	tokenString := ctx.GetHeader("access_token")
+
+	principal, err := m.verifier.Verify(tokenString)
+	if err != nil {
+		m.logger.WithContext(ctx.Request.Context()).Warning(fmt.Sprintf("Failed to verify token string : %v", err))
 		ctx.JSON(http.StatusUnauthorized, gin.H{
 			"responseCode":    http.StatusUnauthorized,
-			"responseMessage": "request not authorized",
+			"responseMessage": "Failed to verify token string",
+			"errorCode":       "unauthorized",
 		})
 		ctx.Abort()
 		return
 	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		if float64(time.Now().Unix()) > claims["exp"].(float64) {
-			ctx.JSON(http.StatusUnauthorized, gin.H{
-				"responseCode":    http.StatusUnauthorized,
-				"responseMessage": "Failed to verify token string",
-			})
+	ctx.Set(principalKey, *principal)
+	ctx.Request = ctx.Request.WithContext(logger.ContextWithFields(ctx.Request.Context(), map[string]interface{}{
+		"user_id": principal.Subject,
+	}))
+	ctx.Next()
+}
+
+// RequireRoles lets the request through only when the caller holds one of roles. It runs after
+// AuthorizeToken, which puts the caller on the context.
+func (m middleware) RequireRoles(roles ...domain.Role) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		principal := principalFrom(ctx)
+		if !principal.HasRole(roles...) {
+			m.logger.WithContext(ctx.Request.Context()).Warning(fmt.Sprintf("%s %s refused for subject %q with roles %v", ctx.Request.Method, ctx.FullPath(), principal.Subject, principal.Roles))
+			ctx.Error(fmt.Errorf("%w: request not allowed for your role", domain.ErrForbidden))
 			ctx.Abort()
 			return
 		}
 		ctx.Next()
-	} else {
-		m.logger.Error("request not authorized")
-
-		ctx.JSON(http.StatusUnauthorized, gin.H{
-			"responseCode":    http.StatusUnauthorized,
-			"responseMessage": "Failed to verify token string",
-		})
-		ctx.Abort()
-
-		return
 	}
-}
\ No newline at end of file
+}
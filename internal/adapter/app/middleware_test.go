@@ -0,0 +1,80 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/adapter/metrics"
+	"github.com/gin-gonic/gin"
+)
+
+// scrape returns what Prometheus would read from recorder
+func scrape(t *testing.T, recorder *metrics.Metrics) string {
+	t.Helper()
+	response := httptest.NewRecorder()
+	recorder.Handler().ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	return response.Body.String()
+}
+
+func TestObserveRequestsLabelsTheRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := metrics.NewMetrics()
+	router := gin.New()
+	router.Use(observeRequests(recorder))
+	router.GET("/requests/v1/:request_id", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+	router.POST("/requests/v1/:request_id/cancel", func(ctx *gin.Context) { ctx.Status(http.StatusConflict) })
+
+	for _, request := range []struct{ method, path string }{
+		{http.MethodGet, "/requests/v1/7f9c2e4a"},
+		{http.MethodGet, "/requests/v1/0b1d8f36"},
+		{http.MethodPost, "/requests/v1/7f9c2e4a/cancel"},
+		{http.MethodGet, "/wp-login.php"},
+		{http.MethodGet, "/requests/v1/7f9c2e4a/../../admin"},
+	} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(request.method, request.path, nil))
+	}
+
+	exposed := scrape(t, recorder)
+	for _, want := range []string{
+		`usafi_http_requests_total{method="GET",route="/requests/v1/:request_id",status="200"} 2`,
+		`usafi_http_requests_total{method="POST",route="/requests/v1/:request_id/cancel",status="409"} 1`,
+		`usafi_http_requests_total{method="GET",route="unmatched",status="404"} 2`,
+		`usafi_http_request_duration_seconds_count{method="GET",route="/requests/v1/:request_id"} 2`,
+	} {
+		if !strings.Contains(exposed, want) {
+			t.Errorf("expected %s in\n%s", want, exposed)
+		}
+	}
+	for _, path := range []string{"7f9c2e4a", "0b1d8f36", "wp-login", "admin"} {
+		if strings.Contains(exposed, path) {
+			t.Errorf("expected the raw path %q kept out of the labels", path)
+		}
+	}
+}
+
+func TestVerifyCallbackToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name, configured, path string
+		status                 int
+	}{
+		{"matching token", "callback-secret", "/callback?token=callback-secret", http.StatusOK},
+		{"wrong token", "callback-secret", "/callback?token=guess", http.StatusUnauthorized},
+		{"no token", "callback-secret", "/callback", http.StatusUnauthorized},
+		// Nothing to compare against must not mean anything goes
+		{"none configured", "", "/callback", http.StatusUnauthorized},
+		{"none configured with a token", "", "/callback?token=", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		router := gin.New()
+		router.POST("/callback", verifyCallbackToken(tt.configured), func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(http.MethodPost, tt.path, nil))
+		if response.Code != tt.status {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.status, response.Code)
+		}
+	}
+}
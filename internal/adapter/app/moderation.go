@@ -0,0 +1,158 @@
+package app
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+	"github.com/gin-gonic/gin"
+)
+
+type moderationBody struct {
+	Note string `json:"note"`
+}
+
+type flagBody struct {
+	Reason string `json:"reason"`
+}
+
+type responseBody struct {
+	Body string `json:"body" binding:"required"`
+}
+
+// reviewView hides moderation details from everyone but admins
+func reviewView(principal domain.Principal, review domain.Reviews) domain.Reviews {
+	if principal.HasRole(domain.RoleAdmin) {
+		return review
+	}
+	return review.PublicView()
+}
+
+func reviewViews(principal domain.Principal, reviews []domain.Reviews) []domain.Reviews {
+	views := make([]domain.Reviews, len(reviews))
+	for i, review := range reviews {
+		views[i] = reviewView(principal, review)
+	}
+	return views
+}
+
+// GetModerationQueue lists the reviews waiting for a moderator, those held by the content filter
+// and those flagged by users unless ?status= asks for others
+func (h handler) GetModerationQueue(ctx *gin.Context) {
+	filter, err := parseReviewFilter(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	filter.Statuses = []domain.ReviewStatus{domain.ReviewPending, domain.ReviewFlagged}
+	if value := ctx.Query("status"); value != "" {
+		filter.Statuses = nil
+		for _, status := range strings.Split(value, ",") {
+			status := domain.ReviewStatus(strings.TrimSpace(status))
+			if !status.IsValid() {
+				ctx.Error(domain.ErrInvalidListOptions)
+				return
+			}
+			filter.Statuses = append(filter.Statuses, status)
+		}
+	}
+
+	page, err := h.reviewService.GetReviews(ctx.Request.Context(), filter)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"responseMessage": "Reviews found for moderation",
+		"responseCode":    http.StatusOK,
+		"data":            page.Items,
+		"responseCount":   len(page.Items),
+		"next_cursor":     page.NextCursor,
+		"total":           page.Total,
+	})
+}
+
+func (h handler) ApproveReview(ctx *gin.Context) {
+	var body moderationBody
+	if ctx.Request.ContentLength != 0 && !bindJSON(ctx, &body) {
+		return
+	}
+
+	review, err := h.reviewService.ApproveReview(ctx.Request.Context(), ctx.Param("review_id"), principalFrom(ctx).Subject, body.Note)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"responseMessage": "Review published",
+		"responseCode":    http.StatusOK,
+		"data":            review,
+	})
+}
+
+func (h handler) RejectReview(ctx *gin.Context) {
+	var body moderationBody
+	if ctx.Request.ContentLength != 0 && !bindJSON(ctx, &body) {
+		return
+	}
+
+	review, err := h.reviewService.RejectReview(ctx.Request.Context(), ctx.Param("review_id"), principalFrom(ctx).Subject, body.Note)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"responseMessage": "Review rejected",
+		"responseCode":    http.StatusOK,
+		"data":            review,
+	})
+}
+
+// FlagReview lets anyone signed in report a live review to the moderators
+func (h handler) FlagReview(ctx *gin.Context) {
+	var body flagBody
+	if ctx.Request.ContentLength != 0 && !bindJSON(ctx, &body) {
+		return
+	}
+
+	principal := principalFrom(ctx)
+	review, err := h.reviewService.FlagReview(ctx.Request.Context(), ctx.Param("review_id"), principal.Subject, body.Reason)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"responseMessage": "Review flagged for moderation",
+		"responseCode":    http.StatusOK,
+		"data":            reviewView(principal, *review),
+	})
+}
+
+// RespondToReview sets the reviewed cleaner's reply, the service checks it is theirs to answer
+func (h handler) RespondToReview(ctx *gin.Context) {
+	var body responseBody
+	if !bindJSON(ctx, &body) {
+		return
+	}
+
+	principal := principalFrom(ctx)
+	if !principal.HasRole(domain.RoleCleaner) {
+		forbid(ctx)
+		return
+	}
+	review, err := h.reviewService.RespondToReview(ctx.Request.Context(), ctx.Param("review_id"), principal.Subject, body.Body)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"responseMessage": "Response saved",
+		"responseCode":    http.StatusOK,
+		"data":            reviewView(principal, *review),
+	})
+}
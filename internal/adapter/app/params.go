@@ -0,0 +1,109 @@
+package app
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+	"github.com/gin-gonic/gin"
+)
+
+// parseListOptions reads limit, cursor and sort from the query string. A sort key
+// prefixed with "-" sorts descending, e.g. ?sort=-requested_date.
+func parseListOptions(ctx *gin.Context) (domain.ListOptions, error) {
+	options := domain.ListOptions{
+		Cursor: ctx.Query("cursor"),
+	}
+
+	if limit := ctx.Query("limit"); limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil || value < 1 {
+			return options, fmt.Errorf("%w: limit must be a positive number", domain.ErrInvalidListOptions)
+		}
+		options.Limit = value
+	}
+
+	if sort := ctx.Query("sort"); sort != "" {
+		options.SortDesc = strings.HasPrefix(sort, "-")
+		options.SortBy = strings.TrimPrefix(sort, "-")
+	}
+	return options, nil
+}
+
+func parseServiceFilter(ctx *gin.Context) (domain.ServiceFilter, error) {
+	options, err := parseListOptions(ctx)
+	return domain.ServiceFilter{ListOptions: options}, err
+}
+
+func parseInvoiceFilter(ctx *gin.Context) (domain.InvoiceFilter, error) {
+	options, err := parseListOptions(ctx)
+	return domain.InvoiceFilter{ListOptions: options}, err
+}
+
+func parseRequestFilter(ctx *gin.Context) (domain.RequestFilter, error) {
+	options, err := parseListOptions(ctx)
+	if err != nil {
+		return domain.RequestFilter{}, err
+	}
+
+	filter := domain.RequestFilter{
+		ListOptions: options,
+		ServiceId:   ctx.Query("service_id"),
+		Status:      domain.RequestStatus(ctx.Query("status")),
+	}
+	if filter.Status != "" && !filter.Status.IsValid() {
+		return filter, fmt.Errorf("%w: unknown status %q", domain.ErrInvalidListOptions, filter.Status)
+	}
+
+	if filter.RequestedFrom, err = parseTimeQuery(ctx, "requested_from"); err != nil {
+		return filter, err
+	}
+	if filter.RequestedTo, err = parseTimeQuery(ctx, "requested_to"); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+func parseReviewFilter(ctx *gin.Context) (domain.ReviewFilter, error) {
+	options, err := parseListOptions(ctx)
+	if err != nil {
+		return domain.ReviewFilter{}, err
+	}
+
+	filter := domain.ReviewFilter{ListOptions: options}
+	if filter.MinRating, err = parseIntQuery(ctx, "min_rating"); err != nil {
+		return filter, err
+	}
+	if filter.MaxRating, err = parseIntQuery(ctx, "max_rating"); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+// parseTimeQuery accepts either a full RFC 3339 timestamp or a plain date
+func parseTimeQuery(ctx *gin.Context, key string) (*time.Time, error) {
+	value := ctx.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return &parsed, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s must be a date or an RFC 3339 timestamp", domain.ErrInvalidListOptions, key)
+}
+
+func parseIntQuery(ctx *gin.Context, key string) (int, error) {
+	value := ctx.Query(key)
+	if value == "" {
+		return 0, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s must be a number", domain.ErrInvalidListOptions, key)
+	}
+	return parsed, nil
+}
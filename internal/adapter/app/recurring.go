@@ -0,0 +1,184 @@
+package app
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+	"github.com/gin-gonic/gin"
+)
+
+type skipOccurrenceBody struct {
+	OccurrenceAt time.Time `json:"occurrence_at" binding:"required"`
+}
+
+type rescheduleOccurrenceBody struct {
+	OccurrenceAt  time.Time `json:"occurrence_at" binding:"required"`
+	RequestedDate time.Time `json:"requested_date" binding:"required"`
+}
+
+// CreateRecurringBooking takes the schedule as an RRULE, e.g. "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,FR;COUNT=10"
+func (h handler) CreateRecurringBooking(ctx *gin.Context) {
+	var booking domain.RecurringBooking
+	if !bindJSON(ctx, &booking) {
+		return
+	}
+
+	principal := principalFrom(ctx)
+	if booking.ClientId == "" && principal.HasRole(domain.RoleClient) {
+		booking.ClientId = principal.Subject
+	}
+	if !principal.CanActAsClient(booking.ClientId) {
+		forbid(ctx)
+		return
+	}
+
+	dbBooking, err := h.recurringService.CreateRecurringBooking(ctx.Request.Context(), booking)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{
+		"responseMessage": "Recurring booking created successfully",
+		"responseCode":    http.StatusCreated,
+		"data":            dbBooking,
+	})
+}
+
+func (h handler) GetRecurringBookingById(ctx *gin.Context) {
+	booking := h.authorizeRecurringBooking(ctx, ctx.Param("booking_id"))
+	if booking == nil {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"responseMessage": "Recurring booking found",
+		"responseCode":    http.StatusOK,
+		"data":            booking,
+	})
+}
+
+func (h handler) GetRecurringBookingsByClient(ctx *gin.Context) {
+	clientId := ctx.Param("client_id")
+
+	bookings, err := h.recurringService.GetRecurringBookingsByClient(ctx.Request.Context(), clientId)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"responseMessage": "Recurring bookings found",
+		"responseCode":    http.StatusOK,
+		"data":            bookings,
+		"responseCount":   len(*bookings),
+	})
+}
+
+// GetRecurringSchedule lists the occurrences between the optional from and to query parameters
+func (h handler) GetRecurringSchedule(ctx *gin.Context) {
+	bookingId := ctx.Param("booking_id")
+	if h.authorizeRecurringBooking(ctx, bookingId) == nil {
+		return
+	}
+	from, to, err := parseCalendarRange(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	occurrences, err := h.recurringService.GetSchedule(ctx.Request.Context(), bookingId, from, to)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"responseMessage": "Occurrences found",
+		"responseCode":    http.StatusOK,
+		"data":            occurrences,
+		"responseCount":   len(*occurrences),
+	})
+}
+
+func (h handler) SkipOccurrence(ctx *gin.Context) {
+	bookingId := ctx.Param("booking_id")
+	var body skipOccurrenceBody
+	if !bindJSON(ctx, &body) {
+		return
+	}
+	if h.authorizeRecurringBooking(ctx, bookingId) == nil {
+		return
+	}
+
+	booking, err := h.recurringService.SkipOccurrence(ctx.Request.Context(), bookingId, body.OccurrenceAt)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"responseMessage": "Occurrence skipped",
+		"responseCode":    http.StatusOK,
+		"data":            booking,
+	})
+}
+
+func (h handler) RescheduleOccurrence(ctx *gin.Context) {
+	bookingId := ctx.Param("booking_id")
+	var body rescheduleOccurrenceBody
+	if !bindJSON(ctx, &body) {
+		return
+	}
+	if h.authorizeRecurringBooking(ctx, bookingId) == nil {
+		return
+	}
+
+	booking, err := h.recurringService.RescheduleOccurrence(ctx.Request.Context(), bookingId, body.OccurrenceAt, body.RequestedDate)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"responseMessage": "Occurrence rescheduled",
+		"responseCode":    http.StatusOK,
+		"data":            booking,
+	})
+}
+
+// CancelRecurringBooking stops the series from now on, occurrences that already took place stand
+func (h handler) CancelRecurringBooking(ctx *gin.Context) {
+	bookingId := ctx.Param("booking_id")
+	if h.authorizeRecurringBooking(ctx, bookingId) == nil {
+		return
+	}
+
+	booking, err := h.recurringService.CancelRecurringBooking(ctx.Request.Context(), bookingId)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"responseMessage": "Recurring booking cancelled",
+		"responseCode":    http.StatusOK,
+		"data":            booking,
+	})
+}
+
+// authorizeRecurringBooking is authorizeRequest for recurring bookings, which only their client and
+// staff may see
+func (h handler) authorizeRecurringBooking(ctx *gin.Context, bookingId string) *domain.RecurringBooking {
+	booking, err := h.recurringService.GetRecurringBookingById(ctx.Request.Context(), bookingId)
+	if err != nil {
+		ctx.Error(err)
+		return nil
+	}
+	if !principalFrom(ctx).CanActAsClient(booking.ClientId) {
+		forbid(ctx)
+		return nil
+	}
+	return booking
+}
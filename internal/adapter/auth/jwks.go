@@ -0,0 +1,245 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/ports"
+)
+
+// minRefreshInterval stops tokens with unknown key ids from making us fetch the key set on every request
+const minRefreshInterval = time.Minute
+
+// jwk is one key of a JSON Web Key Set, RFC 7517. Only the public RSA and EC members are read.
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// signingKey is a public key of the set and the algorithm its JWK restricts it to, if any
+type signingKey struct {
+	key crypto.PublicKey
+	alg string
+}
+
+// keySet caches the public keys of a JWKS document read from a file or an http(s) URL. The keys are
+// fetched again once they are older than refreshInterval, or sooner when a token names a key id
+// the set does not have, which is how a key rotation reaches us.
+type keySet struct {
+	source          string
+	refreshInterval time.Duration
+	http            *http.Client
+	logger          ports.LoggerService
+
+	// refreshing lets one caller fetch the set while the others wait for its result
+	refreshing  sync.Mutex
+	mu          sync.RWMutex
+	keys        map[string]signingKey
+	fetchedAt   time.Time
+	attemptedAt time.Time
+}
+
+func newKeySet(source string, refreshInterval time.Duration, logger ports.LoggerService) (*keySet, error) {
+	set := keySet{
+		source:          source,
+		refreshInterval: refreshInterval,
+		http:            &http.Client{Timeout: 10 * time.Second},
+		logger:          logger,
+	}
+	if err := set.refresh(); err != nil {
+		return nil, err
+	}
+	return &set, nil
+}
+
+// key returns the public key for kid to check a signature made with alg. A token without a kid is
+// accepted when the set holds a single key. A key whose JWK names an algorithm is only used for it.
+func (s *keySet) key(kid, alg string) (crypto.PublicKey, error) {
+	s.mu.RLock()
+	key, ok := s.lookup(kid)
+	due := s.refreshDue(ok)
+	s.mu.RUnlock()
+
+	if due {
+		key, ok = s.refreshFor(kid)
+	}
+	if !ok {
+		return nil, fmt.Errorf("no key with kid %q in JWKS", kid)
+	}
+	if key.alg != "" && key.alg != alg {
+		return nil, fmt.Errorf("key %q is for %s, not %s", kid, key.alg, alg)
+	}
+	return key.key, nil
+}
+
+// refreshDue reports whether the set should be fetched again. Attempts are at least
+// minRefreshInterval apart, whether they succeeded or not.
+func (s *keySet) refreshDue(found bool) bool {
+	if time.Since(s.attemptedAt) < minRefreshInterval {
+		return false
+	}
+	return !found || time.Since(s.fetchedAt) > s.refreshInterval
+}
+
+func (s *keySet) refreshFor(kid string) (signingKey, bool) {
+	s.refreshing.Lock()
+	defer s.refreshing.Unlock()
+
+	// Whoever held the lock before us may have fetched the set already.
+	s.mu.RLock()
+	key, ok := s.lookup(kid)
+	due := s.refreshDue(ok)
+	s.mu.RUnlock()
+	if !due {
+		return key, ok
+	}
+
+	if err := s.refresh(); err != nil {
+		// Keep verifying with the keys we have, the source may only be briefly unreachable.
+		s.logger.Warning(fmt.Sprintf("refreshing JWKS from %s failed: %v", s.source, err))
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lookup(kid)
+}
+
+func (s *keySet) lookup(kid string) (signingKey, bool) {
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+func (s *keySet) refresh() error {
+	s.mu.Lock()
+	s.attemptedAt = time.Now()
+	s.mu.Unlock()
+
+	document, err := s.fetch()
+	if err != nil {
+		return err
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(document, &set); err != nil {
+		return fmt.Errorf("parsing JWKS: %w", err)
+	}
+
+	keys := map[string]signingKey{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			// One key we cannot use should not take the others down with it.
+			s.logger.Warning(fmt.Sprintf("skipping JWKS key %q: %v", k.Kid, err))
+			continue
+		}
+		keys[k.Kid] = signingKey{key: key, alg: k.Alg}
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("JWKS at %s has no usable signing keys", s.source)
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.fetchedAt = time.Now()
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *keySet) fetch() ([]byte, error) {
+	if !strings.HasPrefix(s.source, "http://") && !strings.HasPrefix(s.source, "https://") {
+		return os.ReadFile(strings.TrimPrefix(s.source, "file://"))
+	}
+
+	response, err := s.http.Get(s.source)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching JWKS from %s returned %s", s.source, response.Status)
+	}
+	return io.ReadAll(io.LimitReader(response.Body, 1<<20))
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() < 3 {
+			return nil, fmt.Errorf("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point is not on %s", k.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+	if err != nil {
+		return nil, err
+	}
+	if len(decoded) == 0 {
+		return nil, fmt.Errorf("empty key parameter")
+	}
+	return new(big.Int).SetBytes(decoded), nil
+}
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/ports"
+	"github.com/golang-jwt/jwt"
+)
+
+var ErrInvalidToken = errors.New("invalid access token")
+
+type VerifierConfig struct {
+	// HMACSecret accepts HS256/384/512 tokens signed with the shared secret, leave it empty to refuse them
+	HMACSecret string
+	// JWKSSource is a file path or http(s) URL of the JWKS used for RS256/384/512 and ES256/384/512 tokens
+	JWKSSource  string
+	JWKSRefresh time.Duration
+	Issuer      string
+	Audience    string
+	ClockSkew   time.Duration
+}
+
+type tokenVerifier struct {
+	config  VerifierConfig
+	keys    *keySet
+	methods []string
+}
+
+// NewTokenVerifier checks access tokens signed with the shared HMAC secret, the keys of a JWKS or
+// both. At least one of them has to be configured. The JWKS is loaded here so a bad source fails
+// at startup rather than on the first request.
+func NewTokenVerifier(config VerifierConfig, logger ports.LoggerService) (*tokenVerifier, error) {
+	verifier := tokenVerifier{config: config}
+
+	if config.HMACSecret != "" {
+		verifier.methods = append(verifier.methods, "HS256", "HS384", "HS512")
+	}
+	if config.JWKSSource != "" {
+		if config.JWKSRefresh <= 0 {
+			config.JWKSRefresh = 15 * time.Minute
+		}
+		keys, err := newKeySet(config.JWKSSource, config.JWKSRefresh, logger)
+		if err != nil {
+			return nil, fmt.Errorf("loading JWKS: %w", err)
+		}
+		verifier.keys = keys
+		verifier.methods = append(verifier.methods, "RS256", "RS384", "RS512", "ES256", "ES384", "ES512")
+	}
+	if len(verifier.methods) == 0 {
+		return nil, errors.New("token verification needs an HMAC secret or a JWKS source")
+	}
+	return &verifier, nil
+}
+
+func (v tokenVerifier) Verify(tokenString string) (*domain.Principal, error) {
+	// Time based claims are checked below, where the clock skew is allowed for.
+	parser := jwt.Parser{ValidMethods: v.methods, SkipClaimsValidation: true}
+	token, err := parser.Parse(tokenString, v.key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+	if err := v.validate(claims); err != nil {
+		return nil, err
+	}
+
+	principal := principalFromClaims(claims)
+	return &principal, nil
+}
+
+// key picks the key the token's signature is checked with, by algorithm family and kid
+func (v tokenVerifier) key(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		return []byte(v.config.HMACSecret), nil
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA:
+		kid, _ := token.Header["kid"].(string)
+		return v.keys.key(kid, token.Method.Alg())
+	}
+	return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+}
+
+func (v tokenVerifier) validate(claims jwt.MapClaims) error {
+	now := time.Now()
+	skew := v.config.ClockSkew
+
+	if !claims.VerifyExpiresAt(now.Add(-skew).Unix(), true) {
+		return fmt.Errorf("%w: token is expired or has no exp", ErrInvalidToken)
+	}
+	if !claims.VerifyNotBefore(now.Add(skew).Unix(), false) {
+		return fmt.Errorf("%w: token is not valid yet", ErrInvalidToken)
+	}
+	if !claims.VerifyIssuedAt(now.Add(skew).Unix(), false) {
+		return fmt.Errorf("%w: token was issued in the future", ErrInvalidToken)
+	}
+	if v.config.Issuer != "" && !claims.VerifyIssuer(v.config.Issuer, true) {
+		return fmt.Errorf("%w: unexpected issuer", ErrInvalidToken)
+	}
+	if v.config.Audience != "" && !claims.VerifyAudience(v.config.Audience, true) {
+		return fmt.Errorf("%w: token is not meant for this service", ErrInvalidToken)
+	}
+	return nil
+}
+
+// principalFromClaims reads the caller from the token. Roles come from a "roles" list or a single
+// "role" claim, roles this service does not know are ignored.
+func principalFromClaims(claims jwt.MapClaims) domain.Principal {
+	principal := domain.Principal{}
+	if subject, ok := claims["sub"].(string); ok {
+		principal.Subject = subject
+	}
+
+	names := []interface{}{claims["role"]}
+	if roles, ok := claims["roles"].([]interface{}); ok {
+		names = append(names, roles...)
+	}
+	for _, name := range names {
+		if role, ok := name.(string); ok && domain.Role(role).IsValid() && !principal.HasRole(domain.Role(role)) {
+			principal.Roles = append(principal.Roles, domain.Role(role))
+		}
+	}
+	return principal
+}
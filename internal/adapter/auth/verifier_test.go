@@ -0,0 +1,323 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/ports"
+	"github.com/golang-jwt/jwt"
+)
+
+type testLogger struct{}
+
+func (testLogger) Debug(message string)   {}
+func (testLogger) Info(message string)    {}
+func (testLogger) Warning(message string) {}
+func (testLogger) Error(message string)   {}
+
+func (l testLogger) With(fields map[string]interface{}) ports.LoggerService { return l }
+func (l testLogger) WithContext(ctx context.Context) ports.LoggerService    { return l }
+
+const (
+	testIssuer   = "https://id.usafihub.test"
+	testAudience = "usafi-hub"
+	testSecret   = "shared-secret-for-tests"
+)
+
+func rsaJWK(t *testing.T, kid, alg string, key *rsa.PrivateKey) jwk {
+	t.Helper()
+	return jwk{
+		Kid: kid,
+		Kty: "RSA",
+		Use: "sig",
+		Alg: alg,
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func ecJWK(t *testing.T, kid string, key *ecdsa.PrivateKey) jwk {
+	t.Helper()
+	return jwk{
+		Kid: kid,
+		Kty: "EC",
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(key.X.Bytes()),
+		Y:   base64.RawURLEncoding.EncodeToString(key.Y.Bytes()),
+	}
+}
+
+// jwksServer serves a key set that can be swapped out, as a key rotation would, and counts fetches
+type jwksServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	keys    []jwk
+	status  int
+	fetches int
+}
+
+func newJWKSServer(t *testing.T, keys ...jwk) *jwksServer {
+	server := &jwksServer{keys: keys, status: http.StatusOK}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.mu.Lock()
+		defer server.mu.Unlock()
+		server.fetches++
+		if server.status != http.StatusOK {
+			w.WriteHeader(server.status)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": server.keys})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (s *jwksServer) rotate(status int, keys ...jwk) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = status
+	s.keys = keys
+}
+
+func (s *jwksServer) fetchCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fetches
+}
+
+// age makes the key set look as if it was fetched that much earlier, so refreshes come due
+func age(set *keySet, by time.Duration) {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	set.attemptedAt = set.attemptedAt.Add(-by)
+	set.fetchedAt = set.fetchedAt.Add(-by)
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func validClaims(change func(claims jwt.MapClaims)) jwt.MapClaims {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"sub":   "client-1",
+		"roles": []string{"client", "unknown"},
+		"iss":   testIssuer,
+		"aud":   testAudience,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}
+	if change != nil {
+		change(claims)
+	}
+	return claims
+}
+
+func TestVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherRSAKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+
+	server := newJWKSServer(t, rsaJWK(t, "rsa-1", "RS256", rsaKey), ecJWK(t, "ec-1", ecKey))
+	verifier, err := NewTokenVerifier(VerifierConfig{
+		HMACSecret: testSecret,
+		JWKSSource: server.URL,
+		Issuer:     testIssuer,
+		Audience:   testAudience,
+		ClockSkew:  time.Minute,
+	}, testLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"RS256", sign(t, jwt.SigningMethodRS256, rsaKey, "rsa-1", validClaims(nil)), true},
+		{"ES256", sign(t, jwt.SigningMethodES256, ecKey, "ec-1", validClaims(nil)), true},
+		{"HS256 with the shared secret", sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", validClaims(nil)), true},
+		{"unknown kid", sign(t, jwt.SigningMethodRS256, rsaKey, "rsa-2", validClaims(nil)), false},
+		{"no kid with several keys", sign(t, jwt.SigningMethodRS256, rsaKey, "", validClaims(nil)), false},
+		{"signed with another key", sign(t, jwt.SigningMethodRS256, otherRSAKey, "rsa-1", validClaims(nil)), false},
+		{"HS256 signed with the public key", sign(t, jwt.SigningMethodHS256, publicPEM, "rsa-1", validClaims(nil)), false},
+		{"algorithm the key is not for", sign(t, jwt.SigningMethodRS512, rsaKey, "rsa-1", validClaims(nil)), false},
+		{"RSA algorithm with an EC key", sign(t, jwt.SigningMethodRS256, rsaKey, "ec-1", validClaims(nil)), false},
+		{"unsigned", sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "", validClaims(nil)), false},
+		{"expired", sign(t, jwt.SigningMethodRS256, rsaKey, "rsa-1", validClaims(func(c jwt.MapClaims) {
+			c["exp"] = time.Now().Add(-5 * time.Minute).Unix()
+		})), false},
+		{"expired within the clock skew", sign(t, jwt.SigningMethodRS256, rsaKey, "rsa-1", validClaims(func(c jwt.MapClaims) {
+			c["exp"] = time.Now().Add(-30 * time.Second).Unix()
+		})), true},
+		{"no expiry", sign(t, jwt.SigningMethodRS256, rsaKey, "rsa-1", validClaims(func(c jwt.MapClaims) {
+			delete(c, "exp")
+		})), false},
+		{"not valid yet", sign(t, jwt.SigningMethodRS256, rsaKey, "rsa-1", validClaims(func(c jwt.MapClaims) {
+			c["nbf"] = time.Now().Add(5 * time.Minute).Unix()
+		})), false},
+		{"valid within the clock skew", sign(t, jwt.SigningMethodRS256, rsaKey, "rsa-1", validClaims(func(c jwt.MapClaims) {
+			c["nbf"] = time.Now().Add(30 * time.Second).Unix()
+			c["iat"] = time.Now().Add(30 * time.Second).Unix()
+		})), true},
+		{"issued in the future", sign(t, jwt.SigningMethodRS256, rsaKey, "rsa-1", validClaims(func(c jwt.MapClaims) {
+			c["iat"] = time.Now().Add(5 * time.Minute).Unix()
+		})), false},
+		{"wrong audience", sign(t, jwt.SigningMethodRS256, rsaKey, "rsa-1", validClaims(func(c jwt.MapClaims) {
+			c["aud"] = "another-service"
+		})), false},
+		{"audience among several", sign(t, jwt.SigningMethodRS256, rsaKey, "rsa-1", validClaims(func(c jwt.MapClaims) {
+			c["aud"] = []string{"another-service", testAudience}
+		})), true},
+		{"wrong issuer", sign(t, jwt.SigningMethodRS256, rsaKey, "rsa-1", validClaims(func(c jwt.MapClaims) {
+			c["iss"] = "https://evil.test"
+		})), false},
+		{"malformed", "not.a.token", false},
+	}
+
+	for _, tt := range tests {
+		principal, err := verifier.Verify(tt.token)
+		if !tt.valid {
+			if !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("%s: expected ErrInvalidToken, got %v", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if principal.Subject != "client-1" || len(principal.Roles) != 1 || principal.Roles[0] != domain.RoleClient {
+			t.Errorf("%s: expected client-1 with the client role, got %+v", tt.name, principal)
+		}
+	}
+}
+
+func TestVerifierRefusesUnsupportedAlgorithms(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	document, err := json.Marshal(map[string]interface{}{"keys": []jwk{rsaJWK(t, "", "", rsaKey)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "jwks.json")
+	if err := os.WriteFile(path, document, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without an HMAC secret HS256 is refused outright, with or without a key to confuse it with
+	verifier, err := NewTokenVerifier(VerifierConfig{JWKSSource: path}, testLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verifier.Verify(sign(t, jwt.SigningMethodRS256, rsaKey, "", validClaims(nil))); err != nil {
+		t.Errorf("expected a token without a kid to use the only key in the set, got %v", err)
+	}
+	if _, err := verifier.Verify(sign(t, jwt.SigningMethodHS256, []byte(""), "", validClaims(nil))); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected HS256 to be refused without a secret, got %v", err)
+	}
+
+	if _, err := NewTokenVerifier(VerifierConfig{}, testLogger{}); err == nil {
+		t.Error("expected a verifier without keys to be refused")
+	}
+	if _, err := NewTokenVerifier(VerifierConfig{JWKSSource: filepath.Join(dir, "missing.json")}, testLogger{}); err == nil {
+		t.Error("expected a missing JWKS file to fail at startup")
+	}
+}
+
+func TestJWKSKeyRotation(t *testing.T) {
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newJWKSServer(t, rsaJWK(t, "old", "RS256", oldKey))
+	verifier, err := NewTokenVerifier(VerifierConfig{JWKSSource: server.URL, JWKSRefresh: time.Hour}, testLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldToken := sign(t, jwt.SigningMethodRS256, oldKey, "old", validClaims(nil))
+	newToken := sign(t, jwt.SigningMethodRS256, newKey, "new", validClaims(nil))
+
+	server.rotate(http.StatusOK, rsaJWK(t, "new", "RS256", newKey))
+	// The set was fetched moments ago, unknown kids do not fetch it again until minRefreshInterval passes
+	for i := 0; i < 5; i++ {
+		if _, err := verifier.Verify(newToken); !errors.Is(err, ErrInvalidToken) {
+			t.Fatalf("expected the new key to be unknown until the set is refreshed, got %v", err)
+		}
+	}
+	if server.fetchCount() != 1 {
+		t.Errorf("expected refreshes to be throttled, got %d fetches", server.fetchCount())
+	}
+	if _, err := verifier.Verify(oldToken); err != nil {
+		t.Errorf("expected the old key to be kept until the set is refreshed, got %v", err)
+	}
+
+	age(verifier.keys, minRefreshInterval)
+	if _, err := verifier.Verify(newToken); err != nil {
+		t.Fatalf("expected an unknown kid to refresh the set, got %v", err)
+	}
+	if server.fetchCount() != 2 {
+		t.Errorf("expected one more fetch, got %d", server.fetchCount())
+	}
+	if _, err := verifier.Verify(oldToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected the rotated out key to be refused, got %v", err)
+	}
+
+	// A source that is down keeps the keys already fetched working
+	server.rotate(http.StatusServiceUnavailable)
+	age(verifier.keys, 2*time.Hour)
+	if _, err := verifier.Verify(newToken); err != nil {
+		t.Errorf("expected the cached key to be used when the refresh fails, got %v", err)
+	}
+	if server.fetchCount() != 3 {
+		t.Errorf("expected a set older than its refresh interval to be fetched again, got %d fetches", server.fetchCount())
+	}
+}
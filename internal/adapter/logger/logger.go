@@ -1,43 +1,186 @@
 package logger
 
 import (
-	"log"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
 	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/ports"
 )
 
-type DefaultLogger struct {
-	consoleLogger *log.Logger
-	fileLogger    *log.Logger
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarning
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarning:
+		return "warning"
+	default:
+		return "error"
+	}
 }
 
-func NewDefaultLogger() (*DefaultLogger, error) {
-	path := "logs/logs.log"
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		os.MkdirAll("/logs", 0700)
+func ParseLevel(value string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "debug":
+		return LevelDebug, nil
+	case "", "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarning, nil
+	case "error":
+		return LevelError, nil
 	}
+	return LevelInfo, fmt.Errorf("unknown log level %q", value)
+}
 
-	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return nil, err
+// Options configures a StructuredLogger. The zero value logs info and above to stdout only.
+type Options struct {
+	Level Level
+	// FilePath, when set, also writes every line to a file rotated by size and age
+	FilePath   string
+	MaxSizeMB  int
+	MaxAge     time.Duration
+	MaxBackups int
+}
+
+// StructuredLogger writes one JSON object per line with the time, level, message and any fields
+// attached through With or WithContext.
+type StructuredLogger struct {
+	level  Level
+	out    io.Writer
+	mu     *sync.Mutex
+	fields map[string]interface{}
+}
+
+func NewStructuredLogger(options Options) (*StructuredLogger, error) {
+	var out io.Writer = os.Stdout
+	if options.FilePath != "" {
+		file, err := newRotatingFile(options.FilePath, options.MaxSizeMB, options.MaxAge, options.MaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		out = io.MultiWriter(os.Stdout, file)
 	}
 
-	return &DefaultLogger{
-		consoleLogger: log.New(os.Stdout, "", log.LstdFlags),
-		fileLogger:    log.New(file, "", log.LstdFlags),
+	return &StructuredLogger{
+		level:  options.Level,
+		out:    out,
+		mu:     &sync.Mutex{},
+		fields: map[string]interface{}{},
 	}, nil
 }
 
-func (l *DefaultLogger) Info(message string) {
-	// l.consoleLogger.Println("[INFO]", message)
-	l.fileLogger.Println("[INFO]", message)
+func (l *StructuredLogger) Debug(message string) {
+	l.write(LevelDebug, message)
+}
+
+func (l *StructuredLogger) Info(message string) {
+	l.write(LevelInfo, message)
+}
+
+func (l *StructuredLogger) Warning(message string) {
+	l.write(LevelWarning, message)
+}
+
+func (l *StructuredLogger) Error(message string) {
+	l.write(LevelError, message)
+}
+
+func (l *StructuredLogger) With(fields map[string]interface{}) ports.LoggerService {
+	if len(fields) == 0 {
+		return l
+	}
+	merged := make(map[string]interface{}, len(l.fields)+len(fields))
+	for key, value := range l.fields {
+		merged[key] = value
+	}
+	for key, value := range fields {
+		merged[key] = value
+	}
+	return &StructuredLogger{level: l.level, out: l.out, mu: l.mu, fields: merged}
+}
+
+func (l *StructuredLogger) WithContext(ctx context.Context) ports.LoggerService {
+	return l.With(FieldsFrom(ctx))
+}
+
+func (l *StructuredLogger) write(level Level, message string) {
+	if level < l.level {
+		return
+	}
+
+	// time, level and message lead every line; the fields follow in a stable order
+	var line bytes.Buffer
+	line.WriteString(`{"time":`)
+	writeJSON(&line, time.Now().UTC().Format(time.RFC3339Nano))
+	line.WriteString(`,"level":`)
+	writeJSON(&line, level.String())
+	line.WriteString(`,"message":`)
+	writeJSON(&line, message)
+
+	keys := make([]string, 0, len(l.fields))
+	for key := range l.fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		line.WriteByte(',')
+		writeJSON(&line, key)
+		line.WriteByte(':')
+		writeJSON(&line, l.fields[key])
+	}
+	line.WriteString("}\n")
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.out.Write(line.Bytes())
+}
+
+func writeJSON(buffer *bytes.Buffer, value interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(value))
+	}
+	buffer.Write(data)
 }
 
-func (l *DefaultLogger) Warning(message string) {
-	// l.consoleLogger.Println("[WARNING]", message)
-	l.fileLogger.Println("[WARNING]", message)
+type fieldsKey struct{}
+
+// ContextWithFields returns a copy of ctx carrying fields, in addition to any it already carries,
+// for loggers obtained through WithContext
+func ContextWithFields(ctx context.Context, fields map[string]interface{}) context.Context {
+	merged := map[string]interface{}{}
+	for key, value := range FieldsFrom(ctx) {
+		merged[key] = value
+	}
+	for key, value := range fields {
+		merged[key] = value
+	}
+	return context.WithValue(ctx, fieldsKey{}, merged)
 }
 
-func (l *DefaultLogger) Error(message string) {
-	l.consoleLogger.Println("[ERROR]", message)
-	l.fileLogger.Println("[ERROR]", message)
+func FieldsFrom(ctx context.Context) map[string]interface{} {
+	if ctx == nil {
+		return nil
+	}
+	fields, _ := ctx.Value(fieldsKey{}).(map[string]interface{})
+	return fields
 }
@@ -0,0 +1,155 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const backupTimeLayout = "20060102T150405.000"
+
+// rotatingFile is a log file that is moved aside once it grows past maxSize bytes or has been
+// written to for longer than maxAge. Only the newest maxBackups rotated files are kept.
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+	// lastBackup and sequence count the rotations within the millisecond of the last backup
+	lastBackup string
+	sequence   int
+}
+
+func newRotatingFile(path string, maxSizeMB int, maxAge time.Duration, maxBackups int) (*rotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	f := &rotatingFile{
+		path:       path,
+		maxSize:    int64(maxSizeMB) * 1024 * 1024,
+		maxAge:     maxAge,
+		maxBackups: maxBackups,
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.due(len(p)) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *rotatingFile) due(next int) bool {
+	if f.size == 0 {
+		return false
+	}
+	if f.maxSize > 0 && f.size+int64(next) > f.maxSize {
+		return true
+	}
+	return f.maxAge > 0 && time.Since(f.openedAt) > f.maxAge
+}
+
+// open appends to the existing log file, counting its age from when it was last modified
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	f.file = file
+	f.size = info.Size()
+	f.openedAt = time.Now()
+	if f.size > 0 {
+		f.openedAt = info.ModTime()
+	}
+	return nil
+}
+
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+
+	backup, err := f.backupName(time.Now())
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(f.path, backup); err != nil {
+		return err
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	return f.prune()
+}
+
+// backupName is a free name for the file rotated at now. Rotations in the same millisecond as an
+// earlier one get a counter, so their names still sort by age. The counter only grows, a name freed
+// by prune is not handed out again.
+func (f *rotatingFile) backupName(now time.Time) (string, error) {
+	extension := filepath.Ext(f.path)
+	stem := fmt.Sprintf("%s-%s", strings.TrimSuffix(f.path, extension), now.UTC().Format(backupTimeLayout))
+	if stem != f.lastBackup {
+		f.lastBackup, f.sequence = stem, 0
+	}
+	for ; ; f.sequence++ {
+		backup := stem + extension
+		if f.sequence > 0 {
+			backup = fmt.Sprintf("%s_%03d%s", stem, f.sequence, extension)
+		}
+		_, err := os.Lstat(backup)
+		if errors.Is(err, fs.ErrNotExist) {
+			return backup, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+// prune removes the oldest backups beyond maxBackups. The timestamp in their names sorts by age.
+func (f *rotatingFile) prune() error {
+	if f.maxBackups <= 0 {
+		return nil
+	}
+	extension := filepath.Ext(f.path)
+	backups, err := filepath.Glob(strings.TrimSuffix(f.path, extension) + "-*" + extension)
+	if err != nil {
+		return err
+	}
+	sort.Strings(backups)
+	for len(backups) > f.maxBackups {
+		if err := os.Remove(backups[0]); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestFile opens a rotating file in a temporary directory, maxSize is in bytes rather than the
+// megabytes the service is configured with
+func newTestFile(t *testing.T, maxSize int64, maxAge time.Duration, maxBackups int) *rotatingFile {
+	t.Helper()
+	f, err := newRotatingFile(filepath.Join(t.TempDir(), "logs", "service.log"), 0, maxAge, maxBackups)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.maxSize = maxSize
+	t.Cleanup(func() { f.file.Close() })
+	return f
+}
+
+func write(t *testing.T, f *rotatingFile, lines ...string) {
+	t.Helper()
+	for _, line := range lines {
+		if _, err := f.Write([]byte(line + "\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// backups returns the contents of f's rotated files, oldest first
+func backups(t *testing.T, f *rotatingFile) []string {
+	t.Helper()
+	names, err := filepath.Glob(strings.TrimSuffix(f.path, ".log") + "-*.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(names)
+	contents := make([]string, len(names))
+	for i, name := range names {
+		contents[i] = readFile(t, name)
+	}
+	return contents
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestRotatesBySize(t *testing.T) {
+	f := newTestFile(t, 20, 0, 0)
+
+	write(t, f, "line one", "line two")
+	if got := backups(t, f); len(got) != 0 {
+		t.Fatalf("expected no rotation while the file fits, got %q", got)
+	}
+
+	write(t, f, "line three")
+	if got := backups(t, f); len(got) != 1 || got[0] != "line one\nline two\n" {
+		t.Errorf("expected the full file rotated aside, got %q", got)
+	}
+	if got := readFile(t, f.path); got != "line three\n" {
+		t.Errorf("expected the new line in a fresh file, got %q", got)
+	}
+}
+
+func TestRotatesByAge(t *testing.T) {
+	f := newTestFile(t, 0, time.Hour, 0)
+
+	write(t, f, "yesterday")
+	f.openedAt = time.Now().Add(-2 * time.Hour)
+	write(t, f, "today")
+
+	if got := backups(t, f); len(got) != 1 || got[0] != "yesterday\n" {
+		t.Errorf("expected the old file rotated aside, got %q", got)
+	}
+	if got := readFile(t, f.path); got != "today\n" {
+		t.Errorf("expected the new line in a fresh file, got %q", got)
+	}
+}
+
+// A file left behind by an earlier run is as old as its last write, not the restart
+func TestAgeSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "service.log")
+	if err := os.WriteFile(path, []byte("before the restart\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lastWrite := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(path, lastWrite, lastWrite); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := newRotatingFile(path, 0, time.Hour, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.file.Close() })
+	write(t, f, "after the restart")
+
+	if got := backups(t, f); len(got) != 1 || got[0] != "before the restart\n" {
+		t.Errorf("expected the stale file rotated aside, got %q", got)
+	}
+}
+
+// Rotations in quick succession, many within the same millisecond, each keep their own backup
+func TestRotationsKeepEveryBackup(t *testing.T) {
+	f := newTestFile(t, 1, 0, 0)
+
+	var lines []string
+	for i := 0; i < 50; i++ {
+		lines = append(lines, strings.Repeat("x", i+1))
+	}
+	write(t, f, lines...)
+
+	got := backups(t, f)
+	if len(got) != len(lines)-1 {
+		t.Fatalf("expected %d backups, got %d", len(lines)-1, len(got))
+	}
+	for i, content := range got {
+		if content != lines[i]+"\n" {
+			t.Fatalf("expected backup %d to hold %q, got %q", i, lines[i], content)
+		}
+	}
+}
+
+func TestPrunesOldestBackups(t *testing.T) {
+	f := newTestFile(t, 1, 0, 2)
+
+	write(t, f, "one", "two", "three", "four", "five")
+
+	if got := backups(t, f); len(got) != 2 || got[0] != "three\n" || got[1] != "four\n" {
+		t.Errorf("expected only the two newest backups kept, got %q", got)
+	}
+	if got := readFile(t, f.path); got != "five\n" {
+		t.Errorf("expected the last line in the current file, got %q", got)
+	}
+}
@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"context"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/ports"
+)
+
+// The instrumented services count marketplace events as they pass through the service ports,
+// leaving the core services unaware of monitoring.
+
+type instrumentedRequests struct {
+	ports.RequestService
+	metrics *Metrics
+}
+
+// InstrumentRequests counts bookings, status changes and staff assignments made through requests
+func InstrumentRequests(requests ports.RequestService, metrics *Metrics) ports.RequestService {
+	return instrumentedRequests{RequestService: requests, metrics: metrics}
+}
+
+func (s instrumentedRequests) CreateRequest(ctx context.Context, request domain.Request) (*domain.Request, error) {
+	created, err := s.RequestService.CreateRequest(ctx, request)
+	if err == nil {
+		s.metrics.RequestCreated(created.ServiceId)
+	}
+	return created, err
+}
+
+func (s instrumentedRequests) AssignCleaner(ctx context.Context, request_id, cleaner_id string) error {
+	err := s.RequestService.AssignCleaner(ctx, request_id, cleaner_id)
+	if err == nil {
+		s.metrics.CleanerAssigned("manual")
+		s.metrics.RequestTransitioned(string(domain.RequestAssigned))
+	}
+	return err
+}
+
+func (s instrumentedRequests) TransitionRequest(ctx context.Context, request_id string, status domain.RequestStatus) (*domain.Request, error) {
+	request, err := s.RequestService.TransitionRequest(ctx, request_id, status)
+	if err == nil {
+		s.metrics.RequestTransitioned(string(status))
+	}
+	return request, err
+}
+
+// CancelRequest counts a cancellation, or the request going back to pending when its cleaner pulls out
+func (s instrumentedRequests) CancelRequest(ctx context.Context, request_id string, change domain.BookingChange) (*domain.Request, error) {
+	request, err := s.RequestService.CancelRequest(ctx, request_id, change)
+	if err == nil {
+		s.metrics.RequestTransitioned(string(request.Status))
+	}
+	return request, err
+}
+
+type instrumentedMatching struct {
+	ports.MatchingService
+	metrics *Metrics
+}
+
+// InstrumentMatching counts the assignments the matcher makes
+func InstrumentMatching(matching ports.MatchingService, metrics *Metrics) ports.MatchingService {
+	return instrumentedMatching{MatchingService: matching, metrics: metrics}
+}
+
+func (s instrumentedMatching) AutoAssign(ctx context.Context, request_id string) (*domain.Request, error) {
+	request, err := s.MatchingService.AutoAssign(ctx, request_id)
+	if err == nil {
+		s.metrics.CleanerAssigned("auto")
+		s.metrics.RequestTransitioned(string(domain.RequestAssigned))
+	}
+	return request, err
+}
+
+type instrumentedReviews struct {
+	ports.ReviewService
+	metrics *Metrics
+}
+
+// InstrumentReviews tracks the ratings of new reviews
+func InstrumentReviews(reviews ports.ReviewService, metrics *Metrics) ports.ReviewService {
+	return instrumentedReviews{ReviewService: reviews, metrics: metrics}
+}
+
+func (s instrumentedReviews) CreateReview(ctx context.Context, review domain.Reviews) (*domain.Reviews, error) {
+	created, err := s.ReviewService.CreateReview(ctx, review)
+	if err == nil {
+		s.metrics.ReviewRated(created.Rating)
+	}
+	return created, err
+}
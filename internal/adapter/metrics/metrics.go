@@ -0,0 +1,181 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics are the service's HTTP, database and marketplace metrics
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+
+	requestsCreated    *prometheus.CounterVec
+	requestTransitions *prometheus.CounterVec
+	assignments        *prometheus.CounterVec
+
+	mu              sync.Mutex
+	reviews         float64
+	reviewRatingSum float64
+	pools           map[string]*sql.DB
+}
+
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		pools:    map[string]*sql.DB{},
+
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "usafi_http_requests_total",
+			Help: "HTTP requests served, by route, method and status code.",
+		}, []string{"route", "method", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "usafi_http_request_duration_seconds",
+			Help:    "Time taken to serve HTTP requests, by route and method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+
+		requestsCreated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "usafi_requests_created_total",
+			Help: "Cleaning requests booked, by service.",
+		}, []string{"service_id"}),
+		requestTransitions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "usafi_request_transitions_total",
+			Help: "Cleaning request status changes, by the status moved to.",
+		}, []string{"status"}),
+		assignments: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "usafi_cleaner_assignments_total",
+			Help: "Cleaners assigned to requests, by whether staff or the matcher picked them.",
+		}, []string{"mode"}),
+	}
+
+	m.registry.MustRegister(m.httpRequests, m.httpDuration, m.requestsCreated, m.requestTransitions, m.assignments)
+	m.registry.MustRegister(reviewCollector{m}, poolCollector{m})
+	return m
+}
+
+// ObserveHTTP records a served HTTP request
+func (m *Metrics) ObserveHTTP(route, method string, status int, duration time.Duration) {
+	m.httpRequests.WithLabelValues(route, method, strconv.Itoa(status)).Inc()
+	m.httpDuration.WithLabelValues(route, method).Observe(duration.Seconds())
+}
+
+func (m *Metrics) RequestCreated(serviceId string) {
+	m.requestsCreated.WithLabelValues(serviceId).Inc()
+}
+
+func (m *Metrics) RequestTransitioned(status string) {
+	m.requestTransitions.WithLabelValues(status).Inc()
+}
+
+func (m *Metrics) CleanerAssigned(mode string) {
+	m.assignments.WithLabelValues(mode).Inc()
+}
+
+func (m *Metrics) ReviewRated(rating int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reviews++
+	m.reviewRatingSum += float64(rating)
+}
+
+// AddPool reports the connection pool statistics of db under name
+func (m *Metrics) AddPool(name string, db *sql.DB) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pools[name] = db
+}
+
+// Handler serves the metrics to a Prometheus scraper
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+var (
+	reviewsDesc = prometheus.NewDesc("usafi_reviews_total",
+		"Reviews left with a numeric rating.", nil, nil)
+	reviewRatingSumDesc = prometheus.NewDesc("usafi_review_rating_points_total",
+		"Sum of the numeric ratings counted by usafi_reviews_total.", nil, nil)
+	reviewRatingAverageDesc = prometheus.NewDesc("usafi_review_rating_average",
+		"Average rating of the reviews left since the service started.", nil, nil)
+)
+
+// reviewCollector reports the review count and rating sum together with their average, which
+// is only reported once there is a review to average
+type reviewCollector struct {
+	m *Metrics
+}
+
+func (c reviewCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- reviewsDesc
+	ch <- reviewRatingSumDesc
+	ch <- reviewRatingAverageDesc
+}
+
+func (c reviewCollector) Collect(ch chan<- prometheus.Metric) {
+	c.m.mu.Lock()
+	count, sum := c.m.reviews, c.m.reviewRatingSum
+	c.m.mu.Unlock()
+
+	ch <- prometheus.MustNewConstMetric(reviewsDesc, prometheus.CounterValue, count)
+	ch <- prometheus.MustNewConstMetric(reviewRatingSumDesc, prometheus.CounterValue, sum)
+	if count > 0 {
+		ch <- prometheus.MustNewConstMetric(reviewRatingAverageDesc, prometheus.GaugeValue, sum/count)
+	}
+}
+
+// poolStat is one connection pool statistic, read from sql.DBStats when the metrics are scraped
+type poolStat struct {
+	desc  *prometheus.Desc
+	kind  prometheus.ValueType
+	value func(sql.DBStats) float64
+}
+
+func newPoolStat(name, help string, kind prometheus.ValueType, value func(sql.DBStats) float64) poolStat {
+	return poolStat{desc: prometheus.NewDesc(name, help, []string{"pool"}, nil), kind: kind, value: value}
+}
+
+var poolStats = []poolStat{
+	newPoolStat("usafi_db_open_connections", "Open database connections, in use or idle.", prometheus.GaugeValue,
+		func(s sql.DBStats) float64 { return float64(s.OpenConnections) }),
+	newPoolStat("usafi_db_in_use_connections", "Database connections currently in use.", prometheus.GaugeValue,
+		func(s sql.DBStats) float64 { return float64(s.InUse) }),
+	newPoolStat("usafi_db_idle_connections", "Idle database connections.", prometheus.GaugeValue,
+		func(s sql.DBStats) float64 { return float64(s.Idle) }),
+	newPoolStat("usafi_db_max_open_connections", "Maximum number of open database connections.", prometheus.GaugeValue,
+		func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }),
+	newPoolStat("usafi_db_wait_count_total", "Times a query waited for a free connection.", prometheus.CounterValue,
+		func(s sql.DBStats) float64 { return float64(s.WaitCount) }),
+	newPoolStat("usafi_db_wait_duration_seconds_total", "Time spent waiting for a free connection.", prometheus.CounterValue,
+		func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }),
+}
+
+// poolCollector reports the statistics of the pools added with AddPool
+type poolCollector struct {
+	m *Metrics
+}
+
+func (c poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, stat := range poolStats {
+		ch <- stat.desc
+	}
+}
+
+func (c poolCollector) Collect(ch chan<- prometheus.Metric) {
+	c.m.mu.Lock()
+	defer c.m.mu.Unlock()
+	for name, db := range c.m.pools {
+		stats := db.Stats()
+		for _, stat := range poolStats {
+			ch <- prometheus.MustNewConstMetric(stat.desc, stat.kind, stat.value(stats), name)
+		}
+	}
+}
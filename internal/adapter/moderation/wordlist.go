@@ -0,0 +1,85 @@
+package moderation
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+)
+
+// defaultWordlist is used unless MODERATION_WORDLIST_FILE names another list
+//
+//go:embed wordlist.txt
+var defaultWordlist string
+
+// wordlistFilter objects to text containing any of its terms. Terms are whole words or phrases,
+// matched regardless of case, punctuation and the usual letter for digit swaps.
+type wordlistFilter struct {
+	terms []string
+}
+
+func NewWordlistFilter(terms []string) *wordlistFilter {
+	filter := &wordlistFilter{}
+	for _, term := range terms {
+		if normalized := normalize(term); normalized != "" {
+			filter.terms = append(filter.terms, normalized)
+		}
+	}
+	return filter
+}
+
+// LoadWordlist reads a wordlist, one term per line. Blank lines and lines starting with # are
+// skipped. An empty path loads the built in list.
+func LoadWordlist(path string) (*wordlistFilter, error) {
+	var source io.Reader = strings.NewReader(defaultWordlist)
+	if path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		source = file
+	}
+
+	var terms []string
+	scanner := bufio.NewScanner(source)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		terms = append(terms, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return NewWordlistFilter(terms), nil
+}
+
+func (f *wordlistFilter) Screen(ctx context.Context, text string) (*domain.ContentVerdict, error) {
+	padded := " " + normalize(text) + " "
+	verdict := domain.ContentVerdict{Allowed: true}
+	for _, term := range f.terms {
+		if strings.Contains(padded, " "+term+" ") {
+			verdict.Allowed = false
+			verdict.Matches = append(verdict.Matches, term)
+		}
+	}
+	return &verdict, nil
+}
+
+var lookalikes = strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s")
+
+// normalize lower cases text, undoes letter for digit swaps and reduces it to words separated by
+// single spaces
+func normalize(text string) string {
+	text = lookalikes.Replace(strings.ToLower(text))
+	return strings.Join(strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}), " ")
+}
@@ -0,0 +1,68 @@
+package moderation
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWordlistScreen(t *testing.T) {
+	filter := NewWordlistFilter([]string{"scam", "Rip Off", "  ", "!!!"})
+
+	tests := []struct {
+		text    string
+		matches []string
+	}{
+		{"Spotless kitchen, thank you!", nil},
+		{"This is a scam", []string{"scam"}},
+		{"SCAM artists", []string{"scam"}},
+		{"what a... Scam!", []string{"scam"}},
+		{"a total $c4m", []string{"scam"}},
+		{"total rip off, a scam", []string{"scam", "rip off"}},
+		{"RIP-OFF prices", []string{"rip off"}},
+		{"rip\n\toff", []string{"rip off"}},
+		// Whole words only, a term inside another word is fine
+		{"Scampi for lunch", nil},
+		{"they were unscammable", nil},
+		{"ripped off the tape", nil},
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		verdict, err := filter.Screen(context.Background(), tt.text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if verdict.Allowed != (len(tt.matches) == 0) || strings.Join(verdict.Matches, ",") != strings.Join(tt.matches, ",") {
+			t.Errorf("%q: expected matches %v, got %+v", tt.text, tt.matches, verdict)
+		}
+	}
+}
+
+func TestLoadWordlist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wordlist.txt")
+	if err := os.WriteFile(path, []byte("# house rules\n\nfilthy\n  Lazy Cleaner  \n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	filter, err := LoadWordlist(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(filter.terms, ",") != "filthy,lazy cleaner" {
+		t.Errorf("expected comments and blank lines skipped, got %q", filter.terms)
+	}
+
+	builtIn, err := LoadWordlist("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(builtIn.terms) == 0 {
+		t.Error("expected the built in wordlist to have terms")
+	}
+
+	if _, err := LoadWordlist(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("expected a missing wordlist to be an error")
+	}
+}
@@ -0,0 +1,249 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/adapter/tracing"
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+)
+
+// MpesaSandboxURL is the base URL of Safaricom's Daraja sandbox
+const MpesaSandboxURL = "https://sandbox.safaricom.co.ke"
+
+type MpesaConfig struct {
+	BaseURL        string
+	ConsumerKey    string
+	ConsumerSecret string
+	ShortCode      string
+	PassKey        string
+	CallbackURL    string
+}
+
+// mpesaClient charges clients with Daraja's Lipa na M-Pesa Online (STK push) API
+type mpesaClient struct {
+	config   MpesaConfig
+	http     *http.Client
+	location *time.Location
+
+	mu           sync.Mutex
+	accessToken  string
+	tokenExpires time.Time
+}
+
+func NewMpesaClient(config MpesaConfig) (*mpesaClient, error) {
+	if config.ConsumerKey == "" || config.ConsumerSecret == "" || config.ShortCode == "" || config.PassKey == "" || config.CallbackURL == "" {
+		return nil, fmt.Errorf("mpesa: consumer key, consumer secret, short code, pass key and callback url are required")
+	}
+	if config.BaseURL == "" {
+		config.BaseURL = MpesaSandboxURL
+	}
+
+	// Daraja timestamps are in East Africa Time whatever the service's own time zone is.
+	location, err := time.LoadLocation("Africa/Nairobi")
+	if err != nil {
+		return nil, err
+	}
+
+	return &mpesaClient{
+		config:   config,
+		http:     &http.Client{Timeout: 30 * time.Second},
+		location: location,
+	}, nil
+}
+
+func (c *mpesaClient) Name() string {
+	return "mpesa"
+}
+
+type stkPushRequest struct {
+	BusinessShortCode string `json:"BusinessShortCode"`
+	Password          string `json:"Password"`
+	Timestamp         string `json:"Timestamp"`
+	TransactionType   string `json:"TransactionType"`
+	Amount            int64  `json:"Amount"`
+	PartyA            string `json:"PartyA"`
+	PartyB            string `json:"PartyB"`
+	PhoneNumber       string `json:"PhoneNumber"`
+	CallBackURL       string `json:"CallBackURL"`
+	AccountReference  string `json:"AccountReference"`
+	TransactionDesc   string `json:"TransactionDesc"`
+}
+
+type stkPushResponse struct {
+	MerchantRequestID   string `json:"MerchantRequestID"`
+	CheckoutRequestID   string `json:"CheckoutRequestID"`
+	ResponseCode        string `json:"ResponseCode"`
+	ResponseDescription string `json:"ResponseDescription"`
+	ErrorCode           string `json:"errorCode"`
+	ErrorMessage        string `json:"errorMessage"`
+}
+
+// Check makes sure Daraja accepts our credentials, which also shows it can be reached
+func (c *mpesaClient) Check(ctx context.Context) error {
+	_, err := c.token(ctx)
+	return err
+}
+
+// InitiateCharge sends an STK push prompt to the client's phone and returns its CheckoutRequestID
+func (c *mpesaClient) InitiateCharge(ctx context.Context, payment domain.Payment) (string, error) {
+	if payment.Amount.Currency != "KES" {
+		return "", fmt.Errorf("%w: M-Pesa only charges KES, not %s", domain.ErrInvalidInput, payment.Amount.Currency)
+	}
+
+	token, err := c.token(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	timestamp := time.Now().In(c.location).Format("20060102150405")
+	body, err := json.Marshal(stkPushRequest{
+		BusinessShortCode: c.config.ShortCode,
+		Password:          base64.StdEncoding.EncodeToString([]byte(c.config.ShortCode + c.config.PassKey + timestamp)),
+		Timestamp:         timestamp,
+		TransactionType:   "CustomerPayBillOnline",
+		// M-Pesa takes whole shillings, round any cents up
+		Amount:           (payment.Amount.Amount + 99) / 100,
+		PartyA:           payment.PhoneNumber,
+		PartyB:           c.config.ShortCode,
+		PhoneNumber:      payment.PhoneNumber,
+		CallBackURL:      c.config.CallbackURL,
+		AccountReference: truncate(payment.Reference, 12),
+		TransactionDesc:  "Usafi Hub cleaning",
+	})
+	if err != nil {
+		return "", err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.config.BaseURL+"/mpesa/stkpush/v1/processrequest", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Authorization", "Bearer "+token)
+	request.Header.Set("Content-Type", "application/json")
+	tracing.Inject(ctx, request.Header)
+
+	response, err := c.http.Do(request)
+	if err != nil {
+		return "", fmt.Errorf("%w: mpesa: stk push: %v", domain.ErrUnavailable, err)
+	}
+	defer response.Body.Close()
+
+	var result stkPushResponse
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("%w: mpesa: stk push returned %s: %v", domain.ErrUnavailable, response.Status, err)
+	}
+	if response.StatusCode != http.StatusOK || result.ResponseCode != "0" {
+		message := result.ErrorMessage
+		if message == "" {
+			message = result.ResponseDescription
+		}
+		return "", fmt.Errorf("%w: mpesa: stk push rejected with %s: %s", domain.ErrUnavailable, response.Status, message)
+	}
+	return result.CheckoutRequestID, nil
+}
+
+type stkCallback struct {
+	Body struct {
+		StkCallback struct {
+			MerchantRequestID string `json:"MerchantRequestID"`
+			CheckoutRequestID string `json:"CheckoutRequestID"`
+			ResultCode        int    `json:"ResultCode"`
+			ResultDesc        string `json:"ResultDesc"`
+			CallbackMetadata  struct {
+				Item []struct {
+					Name  string      `json:"Name"`
+					Value interface{} `json:"Value"`
+				} `json:"Item"`
+			} `json:"CallbackMetadata"`
+		} `json:"stkCallback"`
+	} `json:"Body"`
+}
+
+func (c *mpesaClient) ParseCallback(body []byte) (*domain.PaymentCallback, error) {
+	var payload stkCallback
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("%w: malformed M-Pesa callback: %v", domain.ErrInvalidInput, err)
+	}
+
+	result := payload.Body.StkCallback
+	if result.CheckoutRequestID == "" {
+		return nil, fmt.Errorf("%w: M-Pesa callback has no CheckoutRequestID", domain.ErrInvalidInput)
+	}
+
+	callback := domain.PaymentCallback{
+		ProviderReference: result.CheckoutRequestID,
+		Succeeded:         result.ResultCode == 0,
+	}
+	if !callback.Succeeded {
+		callback.FailureReason = result.ResultDesc
+	}
+	for _, item := range result.CallbackMetadata.Item {
+		switch item.Name {
+		case "MpesaReceiptNumber":
+			callback.Receipt = fmt.Sprint(item.Value)
+		case "Amount":
+			// M-Pesa reports whole shillings, anything else leaves the amount zero and the payment unpaid
+			if shillings, ok := item.Value.(float64); ok {
+				callback.Amount = domain.NewMoney(int64(math.Round(shillings*100)), "KES")
+			}
+		}
+	}
+	return &callback, nil
+}
+
+// token returns a cached OAuth access token, fetching a new one shortly before it expires
+func (c *mpesaClient) token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.accessToken != "" && time.Now().Before(c.tokenExpires) {
+		return c.accessToken, nil
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, c.config.BaseURL+"/oauth/v1/generate?grant_type=client_credentials", nil)
+	if err != nil {
+		return "", err
+	}
+	request.SetBasicAuth(c.config.ConsumerKey, c.config.ConsumerSecret)
+
+	response, err := c.http.Do(request)
+	if err != nil {
+		return "", fmt.Errorf("%w: mpesa: oauth: %v", domain.ErrUnavailable, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%w: mpesa: oauth returned %s", domain.ErrUnavailable, response.Status)
+	}
+
+	var result struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   string `json:"expires_in"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("%w: mpesa: oauth: %v", domain.ErrUnavailable, err)
+	}
+
+	expiresIn, err := time.ParseDuration(strings.TrimSpace(result.ExpiresIn) + "s")
+	if err != nil {
+		expiresIn = time.Hour
+	}
+	c.accessToken = result.AccessToken
+	c.tokenExpires = time.Now().Add(expiresIn - time.Minute)
+	return c.accessToken, nil
+}
+
+func truncate(value string, length int) string {
+	if len(value) <= length {
+		return value
+	}
+	return value[:length]
+}
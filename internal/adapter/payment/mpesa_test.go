@@ -0,0 +1,261 @@
+package payment
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+)
+
+const (
+	testConsumerKey    = "consumer-key"
+	testConsumerSecret = "consumer-secret"
+	testShortCode      = "174379"
+	testPassKey        = "pass-key"
+	testCallbackURL    = "https://api.usafihub.co.ke/payments/v1/callback"
+)
+
+// darajaServer fakes the OAuth and STK push endpoints of Daraja. The STK push answers with
+// status and response, unless they are left zero.
+type darajaServer struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	oauthStatus int
+	status      int
+	response    string
+	tokenCount  int
+	pushes      []stkPushRequest
+}
+
+func newDarajaServer(t *testing.T) *darajaServer {
+	t.Helper()
+	daraja := &darajaServer{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth/v1/generate", daraja.oauth)
+	mux.HandleFunc("/mpesa/stkpush/v1/processrequest", daraja.stkPush)
+	daraja.Server = httptest.NewServer(mux)
+	t.Cleanup(daraja.Close)
+	return daraja
+}
+
+func (d *darajaServer) oauth(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key, secret, ok := r.BasicAuth()
+	if d.oauthStatus != 0 || !ok || key != testConsumerKey || secret != testConsumerSecret || r.URL.Query().Get("grant_type") != "client_credentials" {
+		status := d.oauthStatus
+		if status == 0 {
+			status = http.StatusBadRequest
+		}
+		w.WriteHeader(status)
+		return
+	}
+	d.tokenCount++
+	json.NewEncoder(w).Encode(map[string]string{"access_token": "access-token", "expires_in": "3599"})
+}
+
+func (d *darajaServer) stkPush(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if r.Method != http.MethodPost || r.Header.Get("Authorization") != "Bearer access-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"errorCode":"404.001.03","errorMessage":"Invalid Access Token"}`))
+		return
+	}
+	var push stkPushRequest
+	if err := json.NewDecoder(r.Body).Decode(&push); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	d.pushes = append(d.pushes, push)
+
+	if d.status != 0 {
+		w.WriteHeader(d.status)
+		w.Write([]byte(d.response))
+		return
+	}
+	w.Write([]byte(`{"MerchantRequestID":"29115-34620561-1","CheckoutRequestID":"ws_CO_191220191020363925","ResponseCode":"0","ResponseDescription":"Success. Request accepted for processing"}`))
+}
+
+func newTestClient(t *testing.T, daraja *darajaServer) *mpesaClient {
+	t.Helper()
+	client, err := NewMpesaClient(MpesaConfig{
+		BaseURL:        daraja.URL,
+		ConsumerKey:    testConsumerKey,
+		ConsumerSecret: testConsumerSecret,
+		ShortCode:      testShortCode,
+		PassKey:        testPassKey,
+		CallbackURL:    testCallbackURL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func testPayment() domain.Payment {
+	return domain.Payment{
+		Reference:   "INV-2026-000123",
+		Amount:      domain.NewMoney(150050, "KES"),
+		PhoneNumber: "254708374149",
+	}
+}
+
+func TestMpesaInitiateCharge(t *testing.T) {
+	ctx := context.Background()
+	daraja := newDarajaServer(t)
+	client := newTestClient(t, daraja)
+
+	checkout, err := client.InitiateCharge(ctx, testPayment())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkout != "ws_CO_191220191020363925" {
+		t.Errorf("expected the CheckoutRequestID as the provider reference, got %q", checkout)
+	}
+
+	push := daraja.pushes[0]
+	if push.BusinessShortCode != testShortCode || push.PartyB != testShortCode || push.TransactionType != "CustomerPayBillOnline" {
+		t.Errorf("expected a pay bill charge to the short code, got %+v", push)
+	}
+	if push.PartyA != "254708374149" || push.PhoneNumber != "254708374149" || push.CallBackURL != testCallbackURL {
+		t.Errorf("expected the client's phone prompted with our callback, got %+v", push)
+	}
+	if push.Amount != 1501 {
+		t.Errorf("expected KES 1500.50 to be charged as 1501 whole shillings, got %d", push.Amount)
+	}
+	if push.AccountReference != "INV-2026-000" {
+		t.Errorf("expected the reference cut to Daraja's 12 characters, got %q", push.AccountReference)
+	}
+	password := base64.StdEncoding.EncodeToString([]byte(testShortCode + testPassKey + push.Timestamp))
+	if push.Password != password || len(push.Timestamp) != len("20060102150405") {
+		t.Errorf("expected the password to sign the short code, pass key and timestamp, got %+v", push)
+	}
+
+	if _, err := client.InitiateCharge(ctx, testPayment()); err != nil {
+		t.Fatal(err)
+	}
+	if daraja.tokenCount != 1 {
+		t.Errorf("expected the access token to be reused, fetched %d", daraja.tokenCount)
+	}
+}
+
+func TestMpesaInitiateChargeFailures(t *testing.T) {
+	tests := []struct {
+		name        string
+		oauthStatus int
+		status      int
+		response    string
+		payment     func(p *domain.Payment)
+		err         error
+		pushed      bool
+	}{
+		{
+			name:     "stk push rejected",
+			status:   http.StatusOK,
+			response: `{"ResponseCode":"1","ResponseDescription":"Rejected"}`,
+			err:      domain.ErrUnavailable,
+			pushed:   true,
+		},
+		{
+			name:     "stk push error",
+			status:   http.StatusBadRequest,
+			response: `{"requestId":"","errorCode":"400.002.02","errorMessage":"Bad Request - Invalid PhoneNumber"}`,
+			err:      domain.ErrUnavailable,
+			pushed:   true,
+		},
+		{
+			name:     "stk push outage",
+			status:   http.StatusServiceUnavailable,
+			response: `<html>Service Unavailable</html>`,
+			err:      domain.ErrUnavailable,
+			pushed:   true,
+		},
+		{name: "credentials refused", oauthStatus: http.StatusUnauthorized, err: domain.ErrUnavailable},
+		{name: "not shillings", payment: func(p *domain.Payment) { p.Amount = domain.NewMoney(1000, "USD") }, err: domain.ErrInvalidInput},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			daraja := newDarajaServer(t)
+			daraja.oauthStatus, daraja.status, daraja.response = tt.oauthStatus, tt.status, tt.response
+			client := newTestClient(t, daraja)
+
+			payment := testPayment()
+			if tt.payment != nil {
+				tt.payment(&payment)
+			}
+			checkout, err := client.InitiateCharge(context.Background(), payment)
+			if !errors.Is(err, tt.err) || checkout != "" {
+				t.Errorf("expected %v, got %q and %v", tt.err, checkout, err)
+			}
+			if pushed := len(daraja.pushes) > 0; pushed != tt.pushed {
+				t.Errorf("expected a push to be sent: %v, got %v", tt.pushed, pushed)
+			}
+		})
+	}
+}
+
+func TestMpesaCheck(t *testing.T) {
+	daraja := newDarajaServer(t)
+	client := newTestClient(t, daraja)
+	if err := client.Check(context.Background()); err != nil {
+		t.Errorf("expected Daraja to accept the credentials, got %v", err)
+	}
+
+	daraja.oauthStatus = http.StatusInternalServerError
+	client = newTestClient(t, daraja)
+	if err := client.Check(context.Background()); !errors.Is(err, domain.ErrUnavailable) {
+		t.Errorf("expected Daraja to be reported unavailable, got %v", err)
+	}
+}
+
+func TestMpesaParseCallback(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want *domain.PaymentCallback
+		err  error
+	}{
+		{
+			name: "payment made",
+			body: `{"Body":{"stkCallback":{"MerchantRequestID":"29115-34620561-1","CheckoutRequestID":"ws_CO_191220191020363925","ResultCode":0,"ResultDesc":"The service request is processed successfully.","CallbackMetadata":{"Item":[{"Name":"Amount","Value":1501.00},{"Name":"MpesaReceiptNumber","Value":"NLJ7RT61SV"},{"Name":"TransactionDate","Value":20191219102115},{"Name":"PhoneNumber","Value":254708374149}]}}}}`,
+			want: &domain.PaymentCallback{ProviderReference: "ws_CO_191220191020363925", Succeeded: true, Amount: domain.NewMoney(150100, "KES"), Receipt: "NLJ7RT61SV"},
+		},
+		{
+			name: "payment cancelled",
+			body: `{"Body":{"stkCallback":{"MerchantRequestID":"29115-34620561-1","CheckoutRequestID":"ws_CO_191220191020363925","ResultCode":1032,"ResultDesc":"Request cancelled by user"}}}`,
+			want: &domain.PaymentCallback{ProviderReference: "ws_CO_191220191020363925", FailureReason: "Request cancelled by user"},
+		},
+		{name: "not json", body: `ResultCode=0`, err: domain.ErrInvalidInput},
+		{name: "wrong shape", body: `{"Body":{"stkCallback":{"CheckoutRequestID":"ws_CO_1","ResultCode":"0"}}}`, err: domain.ErrInvalidInput},
+		{name: "no checkout request", body: `{"Body":{"stkCallback":{"ResultCode":0}}}`, err: domain.ErrInvalidInput},
+	}
+
+	client := newTestClient(t, newDarajaServer(t))
+	for _, tt := range tests {
+		callback, err := client.ParseCallback([]byte(tt.body))
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("%s: expected %v, got %v", tt.name, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if *callback != *tt.want {
+			t.Errorf("%s: expected %+v, got %+v", tt.name, *tt.want, *callback)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+	"github.com/google/uuid"
+)
+
+// simulator stands in for a real provider in development and tests. Charges are accepted
+// immediately and settle when a SimulatorCallback body is posted to the callback endpoint.
+type simulator struct{}
+
+func NewSimulator() *simulator {
+	return &simulator{}
+}
+
+func (s simulator) Name() string {
+	return "simulator"
+}
+
+func (s simulator) InitiateCharge(ctx context.Context, payment domain.Payment) (string, error) {
+	return "SIM-" + uuid.New().String(), nil
+}
+
+type simulatorCallback struct {
+	Reference     string       `json:"reference"`
+	Succeeded     bool         `json:"succeeded"`
+	Amount        domain.Money `json:"amount"`
+	Receipt       string       `json:"receipt"`
+	FailureReason string       `json:"failure_reason"`
+}
+
+func (s simulator) ParseCallback(body []byte) (*domain.PaymentCallback, error) {
+	var payload simulatorCallback
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("%w: malformed simulator callback: %v", domain.ErrInvalidInput, err)
+	}
+	if payload.Reference == "" {
+		return nil, fmt.Errorf("%w: simulator callback has no reference", domain.ErrInvalidInput)
+	}
+
+	return &domain.PaymentCallback{
+		ProviderReference: payload.Reference,
+		Succeeded:         payload.Succeeded,
+		Amount:            payload.Amount,
+		Receipt:           payload.Receipt,
+		FailureReason:     payload.FailureReason,
+	}, nil
+}
+
+// SimulatorCallback builds the callback body the simulator expects for a charge. A successful
+// charge collects amount.
+func SimulatorCallback(reference string, amount domain.Money, succeeded bool) []byte {
+	payload := simulatorCallback{Reference: reference, Succeeded: succeeded}
+	if succeeded {
+		payload.Amount = amount
+		payload.Receipt = "SIM" + uuid.New().String()[:8]
+	} else {
+		payload.FailureReason = "Request cancelled by user"
+	}
+	body, _ := json.Marshal(payload)
+	return body
+}
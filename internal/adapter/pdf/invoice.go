@@ -0,0 +1,227 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+)
+
+// A4 in points, with the margins every page keeps clear
+const (
+	pageWidth    = 595
+	pageHeight   = 842
+	marginLeft   = 50
+	marginRight  = pageWidth - 50
+	marginTop    = pageHeight - 60
+	marginBottom = 60
+
+	// courierAdvance is the width of every Courier glyph as a share of the font size,
+	// which is what lets amounts be right aligned without font metrics.
+	courierAdvance = 0.6
+	maxDescription = 70
+)
+
+type font string
+
+const (
+	regular       font = "F1"
+	bold          font = "F2"
+	monospace     font = "F3"
+	monospaceBold font = "F4"
+)
+
+// invoiceRenderer lays invoices out as a PDF using only the standard Type 1 fonts,
+// so no font files or external tools are needed.
+type invoiceRenderer struct {
+	companyName string
+	location    *time.Location
+}
+
+func NewInvoiceRenderer(companyName string, location *time.Location) *invoiceRenderer {
+	return &invoiceRenderer{
+		companyName: companyName,
+		location:    location,
+	}
+}
+
+func (r invoiceRenderer) Render(invoice domain.Invoice) ([]byte, error) {
+	doc := newDocument()
+
+	doc.text(bold, 20, marginLeft, r.companyName)
+	doc.advance(30)
+	doc.text(bold, 16, marginLeft, "INVOICE")
+	doc.advance(24)
+
+	details := [][2]string{
+		{"Invoice number", invoice.InvoiceNumber},
+		{"Issued", invoice.IssuedAt.In(r.location).Format("02 Jan 2006")},
+		{"Request", invoice.RequestId},
+		{"Client", invoice.ClientId},
+		{"Cleaner", invoice.CleanerId},
+	}
+	for _, detail := range details {
+		doc.text(bold, 10, marginLeft, detail[0])
+		doc.text(regular, 10, marginLeft+110, detail[1])
+		doc.advance(15)
+	}
+
+	doc.advance(15)
+	doc.text(bold, 11, marginLeft, "Description")
+	doc.textRight(monospaceBold, 11, marginRight, "Amount")
+	doc.advance(8)
+	doc.rule()
+	doc.advance(16)
+
+	for _, line := range invoice.Lines {
+		doc.text(regular, 11, marginLeft, truncate(line.Description, maxDescription))
+		doc.amount(regular, 11, line.Amount)
+		doc.advance(16)
+	}
+
+	doc.rule()
+	doc.advance(18)
+	doc.text(regular, 11, marginLeft+300, "Subtotal")
+	doc.amount(regular, 11, invoice.Subtotal)
+	doc.advance(16)
+	doc.text(regular, 11, marginLeft+300, "VAT "+formatRate(invoice.TaxRateBps))
+	doc.amount(regular, 11, invoice.Tax)
+	doc.advance(18)
+	doc.text(bold, 12, marginLeft+300, "Total")
+	doc.amount(bold, 12, invoice.Total)
+	doc.advance(40)
+
+	doc.text(regular, 10, marginLeft, "Thank you for choosing "+r.companyName+".")
+
+	return doc.bytes(), nil
+}
+
+// document collects page content streams, starting a new page when the cursor reaches the bottom margin
+type document struct {
+	pages []*bytes.Buffer
+	y     float64
+}
+
+func newDocument() *document {
+	doc := document{}
+	doc.newPage()
+	return &doc
+}
+
+func (d *document) newPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+	d.y = marginTop
+}
+
+func (d *document) page() *bytes.Buffer {
+	return d.pages[len(d.pages)-1]
+}
+
+func (d *document) advance(points float64) {
+	d.y -= points
+	if d.y < marginBottom {
+		d.newPage()
+	}
+}
+
+func (d *document) text(f font, size, x float64, value string) {
+	fmt.Fprintf(d.page(), "BT /%s %s Tf %s %s Td (%s) Tj ET\n", f, number(size), number(x), number(d.y), escape(value))
+}
+
+// textRight draws text in one of the Courier fonts so that it ends at right
+func (d *document) textRight(f font, size, right float64, value string) {
+	width := float64(len([]rune(value))) * size * courierAdvance
+	d.text(f, size, right-width, value)
+}
+
+// amount right aligns money in the Courier counterpart of weight
+func (d *document) amount(weight font, size float64, money domain.Money) {
+	f := monospace
+	if weight == bold {
+		f = monospaceBold
+	}
+	d.textRight(f, size, marginRight, money.String())
+}
+
+func (d *document) rule() {
+	fmt.Fprintf(d.page(), "0.5 w %d %s m %d %s l S\n", marginLeft, number(d.y), marginRight, number(d.y))
+}
+
+// bytes writes out the PDF objects followed by the cross reference table
+func (d *document) bytes() []byte {
+	var out bytes.Buffer
+	offsets := []int{}
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n")
+
+	// Objects 1 to 6 are fixed, each page then takes a page object and a content stream.
+	kids := []string{}
+	for i := range d.pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 7+2*i))
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Courier-Bold /Encoding /WinAnsiEncoding >>")
+
+	for i, content := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R /F2 4 0 R /F3 5 0 R /F4 6 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, 8+2*i))
+		stream := strings.TrimSuffix(content.String(), "\n")
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(stream), stream))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes()
+}
+
+// escape makes value safe inside a PDF string. Characters outside Latin-1 have no glyph in the
+// standard fonts and are replaced.
+func escape(value string) string {
+	var out strings.Builder
+	for _, r := range value {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			out.WriteByte('\\')
+			out.WriteRune(r)
+		case r >= 0x20 && r < 0x7f:
+			out.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&out, "\\%03o", r)
+		default:
+			out.WriteByte('?')
+		}
+	}
+	return out.String()
+}
+
+func truncate(value string, length int) string {
+	runes := []rune(value)
+	if len(runes) <= length {
+		return value
+	}
+	return string(runes[:length-3]) + "..."
+}
+
+func number(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// formatRate renders basis points as a percentage, e.g. 1600 as 16%
+func formatRate(bps int64) string {
+	return strconv.FormatFloat(float64(bps)/100, 'f', -1, 64) + "%"
+}
@@ -0,0 +1,129 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+)
+
+func testInvoice() domain.Invoice {
+	return domain.Invoice{
+		InvoiceNumber: "INV-2026-000042",
+		RequestId:     "request-1",
+		ClientId:      "client-1",
+		CleanerId:     "cleaner-1",
+		Currency:      "KES",
+		Lines: []domain.InvoiceLine{
+			{Description: "Deep clean (2 hours)", Amount: domain.NewMoney(300000, "KES")},
+			{Description: "Inside the oven", Amount: domain.NewMoney(50000, "KES")},
+			{Description: "Discount WELCOME", Amount: domain.NewMoney(-35000, "KES")},
+		},
+		Subtotal:   domain.NewMoney(315000, "KES"),
+		TaxRateBps: 1600,
+		Tax:        domain.NewMoney(50400, "KES"),
+		Total:      domain.NewMoney(365400, "KES"),
+		IssuedAt:   time.Date(2026, 10, 17, 22, 30, 0, 0, time.UTC),
+	}
+}
+
+// drawn finds where text was drawn in font at size, failing the test when it wasn't
+func drawn(t *testing.T, document []byte, f font, size int, text string) (float64, float64) {
+	t.Helper()
+	pattern := regexp.MustCompile(fmt.Sprintf(`BT /%s %d Tf ([\d.]+) ([\d.]+) Td \(%s\) Tj ET`, f, size, regexp.QuoteMeta(escape(text))))
+	match := pattern.FindSubmatch(document)
+	if match == nil {
+		t.Fatalf("expected %q drawn in %s at %d points", text, f, size)
+	}
+	x, _ := strconv.ParseFloat(string(match[1]), 64)
+	y, _ := strconv.ParseFloat(string(match[2]), 64)
+	return x, y
+}
+
+func TestRenderInvoiceTotals(t *testing.T) {
+	document, err := NewInvoiceRenderer("Usafi Hub", time.FixedZone("EAT", 3*60*60)).Render(testInvoice())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	totals := []struct {
+		label  string
+		amount string
+		font   font
+		size   int
+	}{
+		{"Subtotal", "KES 3150.00", monospace, 11},
+		{"VAT 16%", "KES 504.00", monospace, 11},
+		{"Total", "KES 3654.00", monospaceBold, 12},
+	}
+	previous := float64(pageHeight)
+	for _, total := range totals {
+		labelFont := regular
+		if total.font == monospaceBold {
+			labelFont = bold
+		}
+		_, labelY := drawn(t, document, labelFont, total.size, total.label)
+		x, y := drawn(t, document, total.font, total.size, total.amount)
+		if y != labelY {
+			t.Errorf("expected %s on the line of its label", total.amount)
+		}
+		if y >= previous {
+			t.Errorf("expected %s below the line before it", total.label)
+		}
+		previous = y
+		// Courier glyphs are all as wide, so the amount ends exactly at the right margin
+		if end := x + float64(len(total.amount))*float64(total.size)*courierAdvance; end != marginRight {
+			t.Errorf("expected %s to end at the right margin, it ends at %v", total.amount, end)
+		}
+	}
+
+	drawn(t, document, monospace, 11, "KES 3000.00")
+	drawn(t, document, monospace, 11, "KES -350.00")
+	drawn(t, document, regular, 11, "Deep clean (2 hours)")
+	drawn(t, document, regular, 10, "INV-2026-000042")
+	// Issued late in the evening UTC, already the next day in the renderer's time zone
+	drawn(t, document, regular, 10, "18 Oct 2026")
+}
+
+func TestRenderIsAValidPDF(t *testing.T) {
+	invoice := testInvoice()
+	for i := 0; i < 60; i++ {
+		invoice.Lines = append(invoice.Lines, domain.InvoiceLine{Description: fmt.Sprintf("Extra room %d", i+1), Amount: domain.NewMoney(10000, "KES")})
+	}
+	document, err := NewInvoiceRenderer("Usafi Hub", time.UTC).Render(invoice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.HasPrefix(document, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(document, []byte("%%EOF\n")) {
+		t.Fatal("expected a PDF header and end of file marker")
+	}
+	if !bytes.Contains(document, []byte("/Type /Pages /Kids [7 0 R 9 0 R] /Count 2")) {
+		t.Error("expected the lines to run onto a second page")
+	}
+
+	// Every entry of the cross reference table points at the object it numbers
+	startxref := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(document)
+	if startxref == nil {
+		t.Fatal("expected a startxref")
+	}
+	xref, _ := strconv.Atoi(string(startxref[1]))
+	if !bytes.HasPrefix(document[xref:], []byte("xref\n")) {
+		t.Fatalf("expected startxref to point at the cross reference table")
+	}
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(document[xref:], -1)
+	if len(entries) != 10 {
+		t.Fatalf("expected 10 objects for two pages, got %d", len(entries))
+	}
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(string(entry[1]))
+		if want := fmt.Sprintf("%d 0 obj\n", i+1); !strings.HasPrefix(string(document[offset:]), want) {
+			t.Errorf("expected object %d at offset %d", i+1, offset)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/ports"
+)
+
+type pingCheck struct {
+	name string
+	db   *sql.DB
+}
+
+// NewPingCheck reports whether a connection to the database can be made
+func NewPingCheck(name string, db *sql.DB) ports.HealthCheck {
+	return pingCheck{name: name, db: db}
+}
+
+func (c pingCheck) Name() string {
+	return c.name
+}
+
+func (c pingCheck) Check(ctx context.Context) error {
+	return mapError(c.db.PingContext(ctx))
+}
+
+type migrationCheck struct {
+	migrator *migrator
+}
+
+// NewMigrationCheck reports whether every migration has been applied
+func NewMigrationCheck(migrator *migrator) ports.HealthCheck {
+	return migrationCheck{migrator: migrator}
+}
+
+func (c migrationCheck) Name() string {
+	return "migrations"
+}
+
+func (c migrationCheck) Check(ctx context.Context) error {
+	return c.migrator.EnsureCurrent(ctx)
+}
@@ -0,0 +1,470 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+)
+
+// memoryClient keeps every entity in process memory. It mirrors the
+// postgres client's behaviour so it can stand in for it in tests and local runs.
+type memoryClient struct {
+	mu sync.RWMutex
+	memoryState
+
+	// txMu runs units of work one at a time
+	txMu sync.Mutex
+}
+
+type memoryState struct {
+	services map[string]domain.Service
+	requests map[string]domain.Request
+	reviews  map[string]domain.Reviews
+	ratings  map[string]domain.RatingAggregate
+
+	workingHours map[string][]domain.WorkingHours
+	exceptions   map[string]domain.AvailabilityException
+	timeOff      map[string]domain.TimeOff
+
+	cleanerProfiles map[string]domain.CleanerProfile
+
+	invoices        map[string]domain.Invoice
+	invoiceSequence int64
+
+	payments map[string]domain.Payment
+
+	recurringBookings map[string]domain.RecurringBooking
+}
+
+func NewMemoryClient() *memoryClient {
+	return &memoryClient{memoryState: memoryState{
+		services: map[string]domain.Service{},
+		requests: map[string]domain.Request{},
+		reviews:  map[string]domain.Reviews{},
+		ratings:  map[string]domain.RatingAggregate{},
+
+		workingHours: map[string][]domain.WorkingHours{},
+		exceptions:   map[string]domain.AvailabilityException{},
+		timeOff:      map[string]domain.TimeOff{},
+
+		cleanerProfiles: map[string]domain.CleanerProfile{},
+
+		invoices: map[string]domain.Invoice{},
+
+		payments: map[string]domain.Payment{},
+
+		recurringBookings: map[string]domain.RecurringBooking{},
+	}}
+}
+
+type memoryTxKey struct{}
+
+// Do runs fn and, if it fails, restores the data as it was before. Writes made outside of a unit
+// of work while it runs are lost on a rollback too, which is fine for development and tests.
+func (svc *memoryClient) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx.Value(memoryTxKey{}) != nil {
+		return fn(ctx)
+	}
+	svc.txMu.Lock()
+	defer svc.txMu.Unlock()
+
+	svc.mu.RLock()
+	saved := svc.memoryState.clone()
+	svc.mu.RUnlock()
+
+	if err := fn(context.WithValue(ctx, memoryTxKey{}, true)); err != nil {
+		svc.mu.Lock()
+		svc.memoryState = saved
+		svc.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (s memoryState) clone() memoryState {
+	return memoryState{
+		services:        cloneMap(s.services),
+		requests:        cloneMap(s.requests),
+		reviews:         cloneMap(s.reviews),
+		ratings:         cloneMap(s.ratings),
+		workingHours:    cloneMap(s.workingHours),
+		exceptions:      cloneMap(s.exceptions),
+		timeOff:         cloneMap(s.timeOff),
+		cleanerProfiles: cloneMap(s.cleanerProfiles),
+		invoices:        cloneMap(s.invoices),
+		invoiceSequence: s.invoiceSequence,
+		payments:        cloneMap(s.payments),
+
+		recurringBookings: cloneMap(s.recurringBookings),
+	}
+}
+
+// cloneMap is shallow, the stored values are replaced on update rather than changed in place
+func cloneMap[T any](m map[string]T) map[string]T {
+	clone := make(map[string]T, len(m))
+	for key, value := range m {
+		clone[key] = value
+	}
+	return clone
+}
+
+// CreateService stores a new service
+func (svc *memoryClient) CreateService(ctx context.Context, service domain.Service) (*domain.Service, error) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	if _, ok := svc.services[service.ServiceId]; ok {
+		return nil, domain.ErrAlreadyExists
+	}
+	svc.services[service.ServiceId] = service
+	return &service, nil
+}
+
+// GetServiceById retrieves a service using service id
+func (svc *memoryClient) GetServiceById(ctx context.Context, serviceId string) (*domain.Service, error) {
+	svc.mu.RLock()
+	defer svc.mu.RUnlock()
+
+	service, ok := svc.services[serviceId]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	return &service, nil
+}
+
+// GetServices retrieves a page of services
+func (svc *memoryClient) GetServices(ctx context.Context, filter domain.ServiceFilter) (*domain.Page[domain.Service], error) {
+	query, err := newListQuery(serviceSortFields, filter.ListOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	svc.mu.RLock()
+	defer svc.mu.RUnlock()
+
+	services := []domain.Service{}
+	for _, service := range svc.services {
+		services = append(services, service)
+	}
+	return query.apply(services, serviceIdOf), nil
+}
+
+// UpdateService updates an existing service, keeping its creation time
+func (svc *memoryClient) UpdateService(ctx context.Context, service domain.Service) (*domain.Service, error) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	dbService, ok := svc.services[service.ServiceId]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	service.CreatedAt = dbService.CreatedAt
+	svc.services[service.ServiceId] = service
+	return &service, nil
+}
+
+// DeleteService removes a service
+func (svc *memoryClient) DeleteService(ctx context.Context, serviceId string) error {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	if _, ok := svc.services[serviceId]; !ok {
+		return domain.ErrNotFound
+	}
+	delete(svc.services, serviceId)
+	return nil
+}
+
+func (svc *memoryClient) CreateRequest(ctx context.Context, request domain.Request) (*domain.Request, error) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	if _, ok := svc.requests[request.RequestId]; ok {
+		return nil, domain.ErrAlreadyExists
+	}
+	if request.RecurringBookingId != "" {
+		for _, dbRequest := range svc.requests {
+			if dbRequest.RecurringBookingId == request.RecurringBookingId && dbRequest.OccurrenceAt != nil &&
+				request.OccurrenceAt != nil && dbRequest.OccurrenceAt.Equal(*request.OccurrenceAt) {
+				return nil, domain.ErrAlreadyExists
+			}
+		}
+	}
+	svc.requests[request.RequestId] = request
+	return &request, nil
+}
+
+func (svc *memoryClient) GetRequestById(ctx context.Context, requestId string) (*domain.Request, error) {
+	svc.mu.RLock()
+	defer svc.mu.RUnlock()
+
+	request, ok := svc.requests[requestId]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	return &request, nil
+}
+
+func (svc *memoryClient) GetRequests(ctx context.Context, filter domain.RequestFilter) (*domain.Page[domain.Request], error) {
+	query, err := newListQuery(requestSortFields, filter.ListOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	svc.mu.RLock()
+	defer svc.mu.RUnlock()
+
+	requests := []domain.Request{}
+	for _, request := range svc.requests {
+		if matchRequest(filter, request) {
+			requests = append(requests, request)
+		}
+	}
+	return query.apply(requests, requestIdOf), nil
+}
+
+func (svc *memoryClient) UpdateRequest(ctx context.Context, request domain.Request, from domain.RequestStatus) (*domain.Request, error) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	dbRequest, ok := svc.requests[request.RequestId]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	if dbRequest.Status != from {
+		return nil, statusChanged(request.RequestId, from)
+	}
+	request.CreatedAt = dbRequest.CreatedAt
+	request.PaidAt = dbRequest.PaidAt
+	request.CompletedAt = dbRequest.CompletedAt
+	request.RecurringBookingId = dbRequest.RecurringBookingId
+	request.OccurrenceAt = dbRequest.OccurrenceAt
+	svc.requests[request.RequestId] = request
+	return &request, nil
+}
+
+func (svc *memoryClient) DeleteRequest(ctx context.Context, requestId string) error {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	if _, ok := svc.requests[requestId]; !ok {
+		return domain.ErrNotFound
+	}
+	delete(svc.requests, requestId)
+	return nil
+}
+
+func (svc *memoryClient) AssignCleaner(ctx context.Context, requestId, cleanerId string) error {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	request, ok := svc.requests[requestId]
+	if !ok {
+		return domain.ErrNotFound
+	}
+	request.CleanerId = cleanerId
+	svc.requests[requestId] = request
+	return nil
+}
+
+func (svc *memoryClient) UpdateRequestStatus(ctx context.Context, requestId string, from, status domain.RequestStatus) error {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	request, ok := svc.requests[requestId]
+	if !ok {
+		return domain.ErrNotFound
+	}
+	if request.Status != from {
+		return statusChanged(requestId, from)
+	}
+	request.Status = status
+	request.UpdatedAt = time.Now()
+	if status == domain.RequestCompleted {
+		completedAt := request.UpdatedAt
+		request.CompletedAt = &completedAt
+	}
+	svc.requests[requestId] = request
+	return nil
+}
+
+func (svc *memoryClient) MarkRequestPaid(ctx context.Context, requestId string, paidAt time.Time) error {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	request, ok := svc.requests[requestId]
+	if !ok {
+		return domain.ErrNotFound
+	}
+	request.PaidAt = &paidAt
+	request.UpdatedAt = time.Now()
+	svc.requests[requestId] = request
+	return nil
+}
+
+func (svc *memoryClient) GetRequestByClient(ctx context.Context, clientId string, filter domain.RequestFilter) (*domain.Page[domain.Request], error) {
+	filter.ClientId = clientId
+	return svc.GetRequests(ctx, filter)
+}
+
+func (svc *memoryClient) GetRequestByCleaner(ctx context.Context, cleanerId string, filter domain.RequestFilter) (*domain.Page[domain.Request], error) {
+	filter.CleanerId = cleanerId
+	return svc.GetRequests(ctx, filter)
+}
+
+func matchRequest(filter domain.RequestFilter, request domain.Request) bool {
+	switch {
+	case filter.ClientId != "" && request.ClientId != filter.ClientId:
+		return false
+	case filter.CleanerId != "" && request.CleanerId != filter.CleanerId:
+		return false
+	case filter.ServiceId != "" && request.ServiceId != filter.ServiceId:
+		return false
+	case filter.Status != "" && request.Status != filter.Status:
+		return false
+	case filter.RequestedFrom != nil && request.RequestedDate.Before(*filter.RequestedFrom):
+		return false
+	case filter.RequestedTo != nil && !request.RequestedDate.Before(*filter.RequestedTo):
+		return false
+	case filter.RecurringBookingId != "" && request.RecurringBookingId != filter.RecurringBookingId:
+		return false
+	}
+	return true
+}
+
+func (svc *memoryClient) CreateReview(ctx context.Context, review domain.Reviews) (*domain.Reviews, error) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	if _, ok := svc.reviews[review.ReviewId]; ok {
+		return nil, domain.ErrAlreadyExists
+	}
+	for _, existing := range svc.reviews {
+		if existing.RequestId == review.RequestId {
+			return nil, domain.ErrAlreadyExists
+		}
+	}
+	svc.reviews[review.ReviewId] = review
+	return &review, nil
+}
+
+func (svc *memoryClient) GetReviewById(ctx context.Context, reviewId string) (*domain.Reviews, error) {
+	svc.mu.RLock()
+	defer svc.mu.RUnlock()
+
+	review, ok := svc.reviews[reviewId]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	return &review, nil
+}
+
+func (svc *memoryClient) UpdateReview(ctx context.Context, review domain.Reviews) (*domain.Reviews, error) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	dbReview, ok := svc.reviews[review.ReviewId]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	review.CreatedAt = dbReview.CreatedAt
+	svc.reviews[review.ReviewId] = review
+	return &review, nil
+}
+
+func (svc *memoryClient) DeleteReview(ctx context.Context, reviewId string) error {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	if _, ok := svc.reviews[reviewId]; !ok {
+		return domain.ErrNotFound
+	}
+	delete(svc.reviews, reviewId)
+	return nil
+}
+
+func (svc *memoryClient) GetReviewByClient(ctx context.Context, clientId string, filter domain.ReviewFilter) (*domain.Page[domain.Reviews], error) {
+	filter.ClientId = clientId
+	return svc.getReviews(filter)
+}
+
+func (svc *memoryClient) GetReviewByCleaner(ctx context.Context, cleanerId string, filter domain.ReviewFilter) (*domain.Page[domain.Reviews], error) {
+	filter.CleanerId = cleanerId
+	return svc.getReviews(filter)
+}
+
+func (svc *memoryClient) GetReviews(ctx context.Context, filter domain.ReviewFilter) (*domain.Page[domain.Reviews], error) {
+	return svc.getReviews(filter)
+}
+
+func (svc *memoryClient) getReviews(filter domain.ReviewFilter) (*domain.Page[domain.Reviews], error) {
+	query, err := newListQuery(reviewSortFields, filter.ListOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	svc.mu.RLock()
+	defer svc.mu.RUnlock()
+
+	reviews := []domain.Reviews{}
+	for _, review := range svc.reviews {
+		if matchReview(filter, review) {
+			reviews = append(reviews, review)
+		}
+	}
+	return query.apply(reviews, reviewIdOf), nil
+}
+
+func (svc *memoryClient) AddRatings(ctx context.Context, delta domain.RatingAggregate) error {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	key := ratingKey(delta.SubjectType, delta.SubjectId)
+	aggregate, ok := svc.ratings[key]
+	if !ok {
+		aggregate = domain.RatingAggregate{SubjectType: delta.SubjectType, SubjectId: delta.SubjectId}
+	}
+	aggregate.Add(delta)
+	svc.ratings[key] = aggregate
+	return nil
+}
+
+func (svc *memoryClient) GetRatingAggregate(ctx context.Context, subjectType domain.RatingSubject, subjectId string) (*domain.RatingAggregate, error) {
+	svc.mu.RLock()
+	defer svc.mu.RUnlock()
+
+	aggregate, ok := svc.ratings[ratingKey(subjectType, subjectId)]
+	if !ok {
+		aggregate = domain.RatingAggregate{SubjectType: subjectType, SubjectId: subjectId}
+	}
+	return &aggregate, nil
+}
+
+func ratingKey(subjectType domain.RatingSubject, subjectId string) string {
+	return string(subjectType) + "/" + subjectId
+}
+
+func matchReview(filter domain.ReviewFilter, review domain.Reviews) bool {
+	if filter.ClientId != "" && review.ClientId != filter.ClientId {
+		return false
+	}
+	if filter.CleanerId != "" && review.CleanerId != filter.CleanerId {
+		return false
+	}
+	if filter.MinRating > 0 && review.Rating < filter.MinRating {
+		return false
+	}
+	if filter.MaxRating > 0 && review.Rating > filter.MaxRating {
+		return false
+	}
+	if len(filter.Statuses) > 0 {
+		listed := false
+		for _, status := range filter.Statuses {
+			listed = listed || review.Status == status
+		}
+		return listed
+	}
+	return true
+}
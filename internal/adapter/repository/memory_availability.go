@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+)
+
+func (svc *memoryClient) SetWorkingHours(ctx context.Context, cleanerId string, hours []domain.WorkingHours) error {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	stored := make([]domain.WorkingHours, len(hours))
+	for i, window := range hours {
+		window.CleanerId = cleanerId
+		stored[i] = window
+	}
+	sort.Slice(stored, func(i, j int) bool {
+		if stored[i].Weekday != stored[j].Weekday {
+			return stored[i].Weekday < stored[j].Weekday
+		}
+		return stored[i].StartTime < stored[j].StartTime
+	})
+	svc.workingHours[cleanerId] = stored
+	return nil
+}
+
+// LockCleaner has nothing to do, units of work on the memory backend already run one at a time
+func (svc *memoryClient) LockCleaner(ctx context.Context, cleanerId string) error {
+	return nil
+}
+
+func (svc *memoryClient) GetWorkingHours(ctx context.Context, cleanerId string) (*[]domain.WorkingHours, error) {
+	svc.mu.RLock()
+	defer svc.mu.RUnlock()
+
+	hours := append([]domain.WorkingHours{}, svc.workingHours[cleanerId]...)
+	return &hours, nil
+}
+
+func (svc *memoryClient) CreateException(ctx context.Context, exception domain.AvailabilityException) (*domain.AvailabilityException, error) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	if _, ok := svc.exceptions[exception.ExceptionId]; ok {
+		return nil, domain.ErrAlreadyExists
+	}
+	svc.exceptions[exception.ExceptionId] = exception
+	return &exception, nil
+}
+
+func (svc *memoryClient) GetExceptions(ctx context.Context, cleanerId string, from, to time.Time) (*[]domain.AvailabilityException, error) {
+	svc.mu.RLock()
+	defer svc.mu.RUnlock()
+
+	first, last := from.Format(domain.DateLayout), to.Format(domain.DateLayout)
+	exceptions := []domain.AvailabilityException{}
+	for _, exception := range svc.exceptions {
+		if exception.CleanerId == cleanerId && exception.Date >= first && exception.Date <= last {
+			exceptions = append(exceptions, exception)
+		}
+	}
+	sort.Slice(exceptions, func(i, j int) bool {
+		if exceptions[i].Date != exceptions[j].Date {
+			return exceptions[i].Date < exceptions[j].Date
+		}
+		return exceptions[i].StartTime < exceptions[j].StartTime
+	})
+	return &exceptions, nil
+}
+
+func (svc *memoryClient) DeleteException(ctx context.Context, cleanerId, exceptionId string) error {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	exception, ok := svc.exceptions[exceptionId]
+	if !ok || exception.CleanerId != cleanerId {
+		return domain.ErrNotFound
+	}
+	delete(svc.exceptions, exceptionId)
+	return nil
+}
+
+func (svc *memoryClient) CreateTimeOff(ctx context.Context, timeOff domain.TimeOff) (*domain.TimeOff, error) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	if _, ok := svc.timeOff[timeOff.TimeOffId]; ok {
+		return nil, domain.ErrAlreadyExists
+	}
+	svc.timeOff[timeOff.TimeOffId] = timeOff
+	return &timeOff, nil
+}
+
+func (svc *memoryClient) GetTimeOff(ctx context.Context, cleanerId string, from, to time.Time) (*[]domain.TimeOff, error) {
+	svc.mu.RLock()
+	defer svc.mu.RUnlock()
+
+	timeOffs := []domain.TimeOff{}
+	for _, timeOff := range svc.timeOff {
+		if timeOff.CleanerId == cleanerId && timeOff.StartsAt.Before(to) && timeOff.EndsAt.After(from) {
+			timeOffs = append(timeOffs, timeOff)
+		}
+	}
+	sort.Slice(timeOffs, func(i, j int) bool {
+		return timeOffs[i].StartsAt.Before(timeOffs[j].StartsAt)
+	})
+	return &timeOffs, nil
+}
+
+func (svc *memoryClient) DeleteTimeOff(ctx context.Context, cleanerId, timeOffId string) error {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	timeOff, ok := svc.timeOff[timeOffId]
+	if !ok || timeOff.CleanerId != cleanerId {
+		return domain.ErrNotFound
+	}
+	delete(svc.timeOff, timeOffId)
+	return nil
+}
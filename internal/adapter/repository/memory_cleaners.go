@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"sort"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+)
+
+func (svc *memoryClient) UpsertCleanerProfile(ctx context.Context, profile domain.CleanerProfile) (*domain.CleanerProfile, error) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	if dbProfile, ok := svc.cleanerProfiles[profile.CleanerId]; ok {
+		profile.CreatedAt = dbProfile.CreatedAt
+	}
+	profile.ServiceIds = append([]string{}, profile.ServiceIds...)
+	svc.cleanerProfiles[profile.CleanerId] = profile
+	return &profile, nil
+}
+
+func (svc *memoryClient) GetCleanerProfile(ctx context.Context, cleanerId string) (*domain.CleanerProfile, error) {
+	svc.mu.RLock()
+	defer svc.mu.RUnlock()
+
+	profile, ok := svc.cleanerProfiles[cleanerId]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	return &profile, nil
+}
+
+func (svc *memoryClient) GetActiveCleanersForService(ctx context.Context, serviceId string) (*[]domain.CleanerProfile, error) {
+	svc.mu.RLock()
+	defer svc.mu.RUnlock()
+
+	profiles := []domain.CleanerProfile{}
+	for _, profile := range svc.cleanerProfiles {
+		if profile.Active && profile.Offers(serviceId) {
+			profiles = append(profiles, profile)
+		}
+	}
+	sort.Slice(profiles, func(i, j int) bool {
+		return profiles[i].CleanerId < profiles[j].CleanerId
+	})
+	return &profiles, nil
+}
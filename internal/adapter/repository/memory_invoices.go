@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+)
+
+// CreateInvoice stores an invoice under the next invoice number
+func (svc *memoryClient) CreateInvoice(ctx context.Context, invoice domain.Invoice) (*domain.Invoice, error) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	if _, ok := svc.invoices[invoice.InvoiceId]; ok {
+		return nil, domain.ErrAlreadyExists
+	}
+	for _, dbInvoice := range svc.invoices {
+		if dbInvoice.RequestId == invoice.RequestId {
+			return nil, domain.ErrAlreadyExists
+		}
+	}
+
+	svc.invoiceSequence++
+	invoice.Sequence = svc.invoiceSequence
+	invoice.InvoiceNumber = domain.FormatInvoiceNumber(invoice.Sequence)
+	svc.invoices[invoice.InvoiceId] = invoice
+	return &invoice, nil
+}
+
+func (svc *memoryClient) GetInvoiceById(ctx context.Context, invoiceId string) (*domain.Invoice, error) {
+	svc.mu.RLock()
+	defer svc.mu.RUnlock()
+
+	invoice, ok := svc.invoices[invoiceId]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	return &invoice, nil
+}
+
+func (svc *memoryClient) GetInvoiceByRequest(ctx context.Context, requestId string) (*domain.Invoice, error) {
+	svc.mu.RLock()
+	defer svc.mu.RUnlock()
+
+	for _, invoice := range svc.invoices {
+		if invoice.RequestId == requestId {
+			return &invoice, nil
+		}
+	}
+	return nil, domain.ErrNotFound
+}
+
+func (svc *memoryClient) GetInvoicesByClient(ctx context.Context, clientId string, filter domain.InvoiceFilter) (*domain.Page[domain.Invoice], error) {
+	query, err := newListQuery(invoiceSortFields, filter.ListOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	svc.mu.RLock()
+	defer svc.mu.RUnlock()
+
+	invoices := []domain.Invoice{}
+	for _, invoice := range svc.invoices {
+		if invoice.ClientId == clientId {
+			invoices = append(invoices, invoice)
+		}
+	}
+	return query.apply(invoices, invoiceIdOf), nil
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+)
+
+func (svc *memoryClient) CreatePayment(ctx context.Context, payment domain.Payment) (*domain.Payment, error) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	if _, ok := svc.payments[payment.PaymentId]; ok {
+		return nil, domain.ErrAlreadyExists
+	}
+	for _, dbPayment := range svc.payments {
+		if payment.ProviderReference != "" && dbPayment.Provider == payment.Provider && dbPayment.ProviderReference == payment.ProviderReference {
+			return nil, domain.ErrAlreadyExists
+		}
+		// Like the partial unique index in Postgres, a request has one open or successful payment at most
+		if dbPayment.RequestId == payment.RequestId && dbPayment.Status != domain.PaymentFailed && payment.Status != domain.PaymentFailed {
+			return nil, domain.ErrAlreadyExists
+		}
+	}
+	svc.payments[payment.PaymentId] = payment
+	return &payment, nil
+}
+
+func (svc *memoryClient) GetPaymentById(ctx context.Context, paymentId string) (*domain.Payment, error) {
+	svc.mu.RLock()
+	defer svc.mu.RUnlock()
+
+	payment, ok := svc.payments[paymentId]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	return &payment, nil
+}
+
+func (svc *memoryClient) GetPaymentByReference(ctx context.Context, provider, reference string) (*domain.Payment, error) {
+	svc.mu.RLock()
+	defer svc.mu.RUnlock()
+
+	for _, payment := range svc.payments {
+		if payment.Provider == provider && payment.ProviderReference == reference {
+			return &payment, nil
+		}
+	}
+	return nil, domain.ErrNotFound
+}
+
+func (svc *memoryClient) GetPaymentsByRequest(ctx context.Context, requestId string) (*[]domain.Payment, error) {
+	svc.mu.RLock()
+	defer svc.mu.RUnlock()
+
+	payments := []domain.Payment{}
+	for _, payment := range svc.payments {
+		if payment.RequestId == requestId {
+			payments = append(payments, payment)
+		}
+	}
+	sort.Slice(payments, func(i, j int) bool {
+		return payments[i].CreatedAt.Before(payments[j].CreatedAt)
+	})
+	return &payments, nil
+}
+
+func (svc *memoryClient) UpdatePayment(ctx context.Context, payment domain.Payment) (*domain.Payment, error) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	dbPayment, ok := svc.payments[payment.PaymentId]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	if dbPayment.Status != domain.PaymentPending {
+		return nil, fmt.Errorf("%w: payment %s", domain.ErrPaymentSettled, payment.PaymentId)
+	}
+	payment.CreatedAt = dbPayment.CreatedAt
+	svc.payments[payment.PaymentId] = payment
+	return &payment, nil
+}
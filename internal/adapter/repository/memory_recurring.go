@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+)
+
+func (svc *memoryClient) CreateRecurringBooking(ctx context.Context, booking domain.RecurringBooking) (*domain.RecurringBooking, error) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	if _, ok := svc.recurringBookings[booking.RecurringBookingId]; ok {
+		return nil, domain.ErrAlreadyExists
+	}
+	svc.recurringBookings[booking.RecurringBookingId] = booking
+	return &booking, nil
+}
+
+func (svc *memoryClient) GetRecurringBookingById(ctx context.Context, recurringBookingId string) (*domain.RecurringBooking, error) {
+	svc.mu.RLock()
+	defer svc.mu.RUnlock()
+
+	booking, ok := svc.recurringBookings[recurringBookingId]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	return &booking, nil
+}
+
+// LockRecurringBookingById needs no lock of its own, units of work already run one at a time
+func (svc *memoryClient) LockRecurringBookingById(ctx context.Context, recurringBookingId string) (*domain.RecurringBooking, error) {
+	return svc.GetRecurringBookingById(ctx, recurringBookingId)
+}
+
+func (svc *memoryClient) GetRecurringBookingsByClient(ctx context.Context, clientId string) (*[]domain.RecurringBooking, error) {
+	svc.mu.RLock()
+	defer svc.mu.RUnlock()
+
+	bookings := []domain.RecurringBooking{}
+	for _, booking := range svc.recurringBookings {
+		if booking.ClientId == clientId {
+			bookings = append(bookings, booking)
+		}
+	}
+	sortRecurringBookings(bookings)
+	return &bookings, nil
+}
+
+func (svc *memoryClient) GetActiveRecurringBookings(ctx context.Context, until time.Time) (*[]domain.RecurringBooking, error) {
+	svc.mu.RLock()
+	defer svc.mu.RUnlock()
+
+	bookings := []domain.RecurringBooking{}
+	for _, booking := range svc.recurringBookings {
+		if booking.Status == domain.RecurringActive && booking.GeneratedUntil.Before(until) {
+			bookings = append(bookings, booking)
+		}
+	}
+	sortRecurringBookings(bookings)
+	return &bookings, nil
+}
+
+func (svc *memoryClient) UpdateRecurringBooking(ctx context.Context, booking domain.RecurringBooking) (*domain.RecurringBooking, error) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	dbBooking, ok := svc.recurringBookings[booking.RecurringBookingId]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	booking.CreatedAt = dbBooking.CreatedAt
+	svc.recurringBookings[booking.RecurringBookingId] = booking
+	return &booking, nil
+}
+
+func (svc *memoryClient) SetGeneratedUntil(ctx context.Context, recurringBookingId string, generatedUntil, updatedAt time.Time) error {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	booking, ok := svc.recurringBookings[recurringBookingId]
+	if !ok || booking.Status != domain.RecurringActive {
+		return inactiveRecurringBooking(recurringBookingId)
+	}
+	booking.GeneratedUntil = generatedUntil
+	booking.UpdatedAt = updatedAt
+	svc.recurringBookings[recurringBookingId] = booking
+	return nil
+}
+
+func sortRecurringBookings(bookings []domain.RecurringBooking) {
+	sort.Slice(bookings, func(i, j int) bool {
+		if !bookings[i].CreatedAt.Equal(bookings[j].CreatedAt) {
+			return bookings[i].CreatedAt.Before(bookings[j].CreatedAt)
+		}
+		return bookings[i].RecurringBookingId < bookings[j].RecurringBookingId
+	})
+}
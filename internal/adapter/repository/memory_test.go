@@ -0,0 +1,245 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+)
+
+func TestMemoryNotFound(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryClient()
+
+	checks := []struct {
+		name string
+		err  error
+	}{
+		{"get service", second(repo.GetServiceById(ctx, "missing"))},
+		{"update service", second(repo.UpdateService(ctx, domain.Service{ServiceId: "missing"}))},
+		{"delete service", repo.DeleteService(ctx, "missing")},
+		{"get request", second(repo.GetRequestById(ctx, "missing"))},
+		{"update request", second(repo.UpdateRequest(ctx, domain.Request{RequestId: "missing"}, domain.RequestPending))},
+		{"delete request", repo.DeleteRequest(ctx, "missing")},
+		{"assign cleaner", repo.AssignCleaner(ctx, "missing", "cleaner-1")},
+		{"update request status", repo.UpdateRequestStatus(ctx, "missing", domain.RequestPending, domain.RequestAssigned)},
+		{"get review", second(repo.GetReviewById(ctx, "missing"))},
+		{"get invoice", second(repo.GetInvoiceById(ctx, "missing"))},
+		{"get invoice by request", second(repo.GetInvoiceByRequest(ctx, "missing"))},
+		{"get recurring booking", second(repo.GetRecurringBookingById(ctx, "missing"))},
+	}
+	for _, check := range checks {
+		if !errors.Is(check.err, domain.ErrNotFound) {
+			t.Errorf("%s: expected ErrNotFound, got %v", check.name, check.err)
+		}
+	}
+}
+
+func second[T any](_ T, err error) error {
+	return err
+}
+
+func TestMemoryDuplicates(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryClient()
+
+	service := domain.Service{ServiceId: "service-1", Name: "Deep clean"}
+	if _, err := repo.CreateService(ctx, service); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateService(ctx, service); !errors.Is(err, domain.ErrAlreadyExists) {
+		t.Errorf("expected ErrAlreadyExists for a second service with the same id, got %v", err)
+	}
+
+	request := domain.Request{RequestId: "request-1", ClientId: "client-1", ServiceId: "service-1", Status: domain.RequestPending}
+	if _, err := repo.CreateRequest(ctx, request); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateRequest(ctx, request); !errors.Is(err, domain.ErrAlreadyExists) {
+		t.Errorf("expected ErrAlreadyExists for a second request with the same id, got %v", err)
+	}
+
+	occurrence := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	first := domain.Request{RequestId: "occurrence-1", RecurringBookingId: "series-1", OccurrenceAt: &occurrence}
+	if _, err := repo.CreateRequest(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	again := domain.Request{RequestId: "occurrence-2", RecurringBookingId: "series-1", OccurrenceAt: &occurrence}
+	if _, err := repo.CreateRequest(ctx, again); !errors.Is(err, domain.ErrAlreadyExists) {
+		t.Errorf("expected an occurrence to be booked once, got %v", err)
+	}
+
+	review := domain.Reviews{ReviewId: "review-1", RequestId: "request-1", ClientId: "client-1", Rating: 5}
+	if _, err := repo.CreateReview(ctx, review); err != nil {
+		t.Fatal(err)
+	}
+	review.ReviewId = "review-2"
+	if _, err := repo.CreateReview(ctx, review); !errors.Is(err, domain.ErrAlreadyExists) {
+		t.Errorf("expected a request to be reviewed once, got %v", err)
+	}
+}
+
+func TestMemoryRequestPagination(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryClient()
+
+	created := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		client := "client-1"
+		if i%2 == 1 {
+			client = "client-2"
+		}
+		request := domain.Request{
+			RequestId:     fmt.Sprintf("request-%d", i),
+			ClientId:      client,
+			RequestedDate: created.AddDate(0, 0, 5-i),
+			Status:        domain.RequestPending,
+			CreatedAt:     created.Add(time.Duration(i) * time.Hour),
+		}
+		if _, err := repo.CreateRequest(ctx, request); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var ids []string
+	filter := domain.RequestFilter{ListOptions: domain.ListOptions{Limit: 2}}
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("expected the cursor to run out")
+		}
+		page, err := repo.GetRequests(ctx, filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.Total != 5 {
+			t.Errorf("expected a total of 5, got %d", page.Total)
+		}
+		for _, request := range page.Items {
+			ids = append(ids, request.RequestId)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+	if fmt.Sprint(ids) != "[request-0 request-1 request-2 request-3 request-4]" {
+		t.Errorf("expected every request once, oldest first, got %v", ids)
+	}
+
+	page, err := repo.GetRequests(ctx, domain.RequestFilter{ClientId: "client-1", ListOptions: domain.ListOptions{SortBy: "requested_date"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 3 || page.Items[0].RequestId != "request-4" || page.Items[2].RequestId != "request-0" {
+		t.Errorf("expected client-1's requests by requested date, got %+v", page.Items)
+	}
+
+	if _, err := repo.GetRequests(ctx, domain.RequestFilter{ListOptions: domain.ListOptions{SortBy: "client_id"}}); !errors.Is(err, domain.ErrInvalidListOptions) {
+		t.Errorf("expected an unknown sort field to be refused, got %v", err)
+	}
+	if _, err := repo.GetRequests(ctx, domain.RequestFilter{ListOptions: domain.ListOptions{SortBy: "requested_date", Cursor: filter.Cursor}}); !errors.Is(err, domain.ErrInvalidListOptions) {
+		t.Errorf("expected a cursor from another sort to be refused, got %v", err)
+	}
+	if _, err := repo.GetRequests(ctx, domain.RequestFilter{ListOptions: domain.ListOptions{Cursor: "not a cursor"}}); !errors.Is(err, domain.ErrInvalidListOptions) {
+		t.Errorf("expected a malformed cursor to be refused, got %v", err)
+	}
+}
+
+func TestMemoryUnitOfWorkRollsBack(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryClient()
+
+	failure := errors.New("failed halfway")
+	err := repo.Do(ctx, func(ctx context.Context) error {
+		if _, err := repo.CreateService(ctx, domain.Service{ServiceId: "service-1"}); err != nil {
+			return err
+		}
+		// Nested units of work join the outer one
+		return repo.Do(ctx, func(ctx context.Context) error {
+			if _, err := repo.CreateRequest(ctx, domain.Request{RequestId: "request-1"}); err != nil {
+				return err
+			}
+			return failure
+		})
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("expected the unit of work's error, got %v", err)
+	}
+	if _, err := repo.GetServiceById(ctx, "service-1"); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("expected the service to be rolled back, got %v", err)
+	}
+	if _, err := repo.GetRequestById(ctx, "request-1"); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("expected the request to be rolled back, got %v", err)
+	}
+
+	if err := repo.Do(ctx, func(ctx context.Context) error {
+		_, err := repo.CreateService(ctx, domain.Service{ServiceId: "service-1"})
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.GetServiceById(ctx, "service-1"); err != nil {
+		t.Errorf("expected a committed unit of work to keep its writes, got %v", err)
+	}
+}
+
+func TestMemoryStatusWritesAreConditional(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryClient()
+
+	if _, err := repo.CreateRequest(ctx, domain.Request{RequestId: "request-1", Status: domain.RequestInProgress}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.UpdateRequestStatus(ctx, "request-1", domain.RequestInProgress, domain.RequestCompleted); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.UpdateRequestStatus(ctx, "request-1", domain.RequestInProgress, domain.RequestCompleted); !errors.Is(err, domain.ErrInvalidTransition) {
+		t.Errorf("expected a stale transition to be refused, got %v", err)
+	}
+	if _, err := repo.UpdateRequest(ctx, domain.Request{RequestId: "request-1", Status: domain.RequestCancelled}, domain.RequestInProgress); !errors.Is(err, domain.ErrInvalidTransition) {
+		t.Errorf("expected a stale update to be refused, got %v", err)
+	}
+
+	request, err := repo.GetRequestById(ctx, "request-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request.Status != domain.RequestCompleted || request.CompletedAt == nil {
+		t.Errorf("expected the request completed with its completion time, got %+v", request)
+	}
+}
+
+func TestMemoryPaymentsSettleOnce(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryClient()
+
+	pending := domain.Payment{PaymentId: "payment-1", RequestId: "request-1", Provider: "simulator", Status: domain.PaymentPending}
+	if _, err := repo.CreatePayment(ctx, pending); err != nil {
+		t.Fatal(err)
+	}
+	second := domain.Payment{PaymentId: "payment-2", RequestId: "request-1", Provider: "simulator", Status: domain.PaymentPending}
+	if _, err := repo.CreatePayment(ctx, second); !errors.Is(err, domain.ErrAlreadyExists) {
+		t.Errorf("expected a second open payment of the request to be refused, got %v", err)
+	}
+
+	pending.ProviderReference = "SIM-1"
+	if _, err := repo.UpdatePayment(ctx, pending); err != nil {
+		t.Fatal(err)
+	}
+	settled := pending
+	settled.Status = domain.PaymentSucceeded
+	if _, err := repo.UpdatePayment(ctx, settled); err != nil {
+		t.Fatal(err)
+	}
+	failed := pending
+	failed.Status = domain.PaymentFailed
+	if _, err := repo.UpdatePayment(ctx, failed); !errors.Is(err, domain.ErrPaymentSettled) {
+		t.Errorf("expected a settled payment not to be settled again, got %v", err)
+	}
+	if _, err := repo.CreatePayment(ctx, second); !errors.Is(err, domain.ErrAlreadyExists) {
+		t.Errorf("expected a paid request not to get another payment, got %v", err)
+	}
+}
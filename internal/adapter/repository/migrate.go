@@ -0,0 +1,325 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"text/template"
+	"time"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/config"
+	"github.com/lib/pq"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+var migrationFilename = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+var ErrSchemaBehind = errors.New("database schema is behind, run the migrate command")
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// migrationTables holds the table names substituted into the migration templates,
+// since every environment keeps its own set of tables.
+type migrationTables struct {
+	ServiceTable               string
+	RequestTable               string
+	ReviewTable                string
+	WorkingHoursTable          string
+	AvailabilityExceptionTable string
+	TimeOffTable               string
+	CleanerProfileTable        string
+	InvoiceTable               string
+	PaymentTable               string
+	RatingAggregateTable       string
+	RecurringBookingTable      string
+}
+
+type migrator struct {
+	db         *sql.DB
+	table      string
+	migrations []Migration
+}
+
+func NewMigrator(db *sql.DB, config config.Config) (*migrator, error) {
+	tables := migrationTables{
+		ServiceTable:               config.SERVICE_TABLE,
+		RequestTable:               config.REQUEST_TABLE,
+		ReviewTable:                config.REVIEWS_TABLE,
+		WorkingHoursTable:          config.WORKING_HOURS_TABLE,
+		AvailabilityExceptionTable: config.AVAILABILITY_EXCEPTIONS_TABLE,
+		TimeOffTable:               config.TIME_OFF_TABLE,
+		CleanerProfileTable:        config.CLEANER_PROFILES_TABLE,
+		InvoiceTable:               config.INVOICES_TABLE,
+		PaymentTable:               config.PAYMENTS_TABLE,
+		RatingAggregateTable:       config.RATING_AGGREGATES_TABLE,
+		RecurringBookingTable:      config.RECURRING_BOOKINGS_TABLE,
+	}
+
+	migrations, err := loadMigrations(migrationFiles, tables)
+	if err != nil {
+		return nil, err
+	}
+
+	return &migrator{
+		db:         db,
+		table:      config.MIGRATIONS_TABLE,
+		migrations: migrations,
+	}, nil
+}
+
+// loadMigrations reads the embedded up/down pairs, renders the table names into them and sorts them by version
+func loadMigrations(files fs.FS, tables migrationTables) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := migrationFilename.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %q", entry.Name())
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, migration.Name, match[2])
+		}
+
+		content, err := fs.ReadFile(files, "migrations/"+entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		tmpl, err := template.New(entry.Name()).Option("missingkey=error").Parse(string(content))
+		if err != nil {
+			return nil, err
+		}
+		var rendered bytes.Buffer
+		if err := tmpl.Execute(&rendered, tables); err != nil {
+			return nil, err
+		}
+
+		if match[3] == "up" {
+			migration.Up = rendered.String()
+		} else {
+			migration.Down = rendered.String()
+		}
+	}
+
+	migrations := []Migration{}
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Up applies every pending migration in version order and returns the ones applied
+func (m *migrator) Up() ([]Migration, error) {
+	applied := []Migration{}
+	err := m.withLock(func(conn *sql.Conn) error {
+		versions, err := m.appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := versions[migration.Version]; ok {
+				continue
+			}
+			insert := fmt.Sprintf("INSERT INTO %s (version, name, applied_at) VALUES ($1, $2, $3)", m.table)
+			if err := m.run(conn, migration.Up, insert, migration.Version, migration.Name, time.Now()); err != nil {
+				return fmt.Errorf("migration %d_%s up: %w", migration.Version, migration.Name, err)
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down rolls back the latest steps applied migrations and returns the ones rolled back
+func (m *migrator) Down(steps int) ([]Migration, error) {
+	reverted := []Migration{}
+	err := m.withLock(func(conn *sql.Conn) error {
+		versions, err := m.appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := versions[migration.Version]; !ok {
+				continue
+			}
+			remove := fmt.Sprintf("DELETE FROM %s WHERE version = $1", m.table)
+			if err := m.run(conn, migration.Down, remove, migration.Version); err != nil {
+				return fmt.Errorf("migration %d_%s down: %w", migration.Version, migration.Name, err)
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status lists every known migration along with when it was applied, if at all
+func (m *migrator) Status() ([]MigrationStatus, error) {
+	statuses := []MigrationStatus{}
+	err := m.withConn(func(conn *sql.Conn) error {
+		versions, err := m.appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+			if appliedAt, ok := versions[migration.Version]; ok {
+				status.AppliedAt = &appliedAt
+			}
+			statuses = append(statuses, status)
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// EnsureCurrent returns ErrSchemaBehind when any embedded migration has not been applied yet.
+// It only reads the bookkeeping table, a database that has never been migrated is behind.
+func (m *migrator) EnsureCurrent(ctx context.Context) error {
+	rows, err := m.db.QueryContext(ctx, fmt.Sprintf("SELECT version FROM %s", m.table))
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "42P01" {
+		return fmt.Errorf("%w: %d pending migration(s)", ErrSchemaBehind, len(m.migrations))
+	}
+	if err != nil {
+		return mapError(err)
+	}
+	defer rows.Close()
+
+	applied := map[int]bool{}
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return err
+		}
+		applied[version] = true
+	}
+	if err := rows.Err(); err != nil {
+		return mapError(err)
+	}
+
+	pending := 0
+	for _, migration := range m.migrations {
+		if !applied[migration.Version] {
+			pending++
+		}
+	}
+	if pending > 0 {
+		return fmt.Errorf("%w: %d pending migration(s)", ErrSchemaBehind, pending)
+	}
+	return nil
+}
+
+// run executes a migration script and its bookkeeping statement in one transaction
+func (m *migrator) run(conn *sql.Conn, script, bookkeeping string, args ...interface{}) error {
+	ctx := context.Background()
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, bookkeeping, args...); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m *migrator) appliedVersions(conn *sql.Conn) (map[int]time.Time, error) {
+	ctx := context.Background()
+	query := fmt.Sprintf(`
+        CREATE TABLE IF NOT EXISTS %s (
+            version BIGINT PRIMARY KEY,
+            name VARCHAR(255) NOT NULL,
+            applied_at TIMESTAMP NOT NULL
+        )
+	`, m.table)
+	if _, err := conn.ExecContext(ctx, query); err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.QueryContext(ctx, fmt.Sprintf("SELECT version, applied_at FROM %s", m.table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := map[int]time.Time{}
+	for rows.Next() {
+		var (
+			version   int
+			appliedAt time.Time
+		)
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		versions[version] = appliedAt
+	}
+	return versions, rows.Err()
+}
+
+func (m *migrator) withConn(fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(context.Background())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return fn(conn)
+}
+
+// withLock holds a postgres advisory lock so two instances never migrate at the same time
+func (m *migrator) withLock(fn func(conn *sql.Conn) error) error {
+	return m.withConn(func(conn *sql.Conn) error {
+		ctx := context.Background()
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock(hashtext($1))", m.table); err != nil {
+			return err
+		}
+		defer conn.ExecContext(ctx, "SELECT pg_advisory_unlock(hashtext($1))", m.table)
+		return fn(conn)
+	})
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+var testTables = migrationTables{
+	ServiceTable:               "test_services",
+	RequestTable:               "test_requests",
+	ReviewTable:                "test_reviews",
+	WorkingHoursTable:          "test_working_hours",
+	AvailabilityExceptionTable: "test_availability_exceptions",
+	TimeOffTable:               "test_time_off",
+	CleanerProfileTable:        "test_cleaner_profiles",
+	InvoiceTable:               "test_invoices",
+	PaymentTable:               "test_payments",
+	RatingAggregateTable:       "test_rating_aggregates",
+	RecurringBookingTable:      "test_recurring_bookings",
+}
+
+func TestLoadMigrations(t *testing.T) {
+	files := fstest.MapFS{
+		"migrations/0002_add_notes.up.sql":   {Data: []byte("ALTER TABLE {{.RequestTable}} ADD COLUMN notes TEXT;")},
+		"migrations/0002_add_notes.down.sql": {Data: []byte("ALTER TABLE {{.RequestTable}} DROP COLUMN notes;")},
+		"migrations/0001_initial.up.sql":     {Data: []byte("CREATE TABLE {{.ServiceTable}} ();")},
+		"migrations/0001_initial.down.sql":   {Data: []byte("DROP TABLE {{.ServiceTable}};")},
+	}
+
+	migrations, err := loadMigrations(files, testTables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 2 || migrations[0].Version != 1 || migrations[1].Version != 2 {
+		t.Fatalf("expected migrations 1 and 2 in order, got %+v", migrations)
+	}
+	if migrations[0].Name != "initial" || migrations[0].Up != "CREATE TABLE test_services ();" || migrations[0].Down != "DROP TABLE test_services;" {
+		t.Errorf("expected the table names rendered into the first migration, got %+v", migrations[0])
+	}
+	if migrations[1].Up != "ALTER TABLE test_requests ADD COLUMN notes TEXT;" {
+		t.Errorf("unexpected second migration %q", migrations[1].Up)
+	}
+}
+
+func TestLoadMigrationsRefusesBrokenFiles(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+		err   string
+	}{
+		{"bad file name", fstest.MapFS{
+			"migrations/initial.up.sql": {Data: []byte("SELECT 1;")},
+		}, "invalid migration file name"},
+		{"missing down", fstest.MapFS{
+			"migrations/0001_initial.up.sql": {Data: []byte("SELECT 1;")},
+		}, "needs both an up and a down file"},
+		{"conflicting names", fstest.MapFS{
+			"migrations/0001_initial.up.sql": {Data: []byte("SELECT 1;")},
+			"migrations/0001_other.down.sql": {Data: []byte("SELECT 1;")},
+		}, "conflicting names"},
+		{"unknown table", fstest.MapFS{
+			"migrations/0001_initial.up.sql":   {Data: []byte("CREATE TABLE {{.UnknownTable}} ();")},
+			"migrations/0001_initial.down.sql": {Data: []byte("SELECT 1;")},
+		}, "UnknownTable"},
+		{"bad template", fstest.MapFS{
+			"migrations/0001_initial.up.sql":   {Data: []byte("CREATE TABLE {{.ServiceTable ();")},
+			"migrations/0001_initial.down.sql": {Data: []byte("SELECT 1;")},
+		}, "0001_initial.up.sql"},
+	}
+
+	for _, tt := range tests {
+		_, err := loadMigrations(tt.files, testTables)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: expected an error mentioning %q, got %v", tt.name, tt.err, err)
+		}
+	}
+}
+
+// TestEmbeddedMigrations renders the migrations the service ships, so a template naming a table
+// migrationTables does not have fails here rather than on deploy
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := loadMigrations(migrationFiles, testTables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			t.Errorf("expected migration versions without gaps, found %d at position %d", migration.Version, i+1)
+		}
+		if strings.Contains(migration.Up+migration.Down, "{{") {
+			t.Errorf("migration %d_%s was not fully rendered", migration.Version, migration.Name)
+		}
+	}
+}
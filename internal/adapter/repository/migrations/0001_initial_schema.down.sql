@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS {{.ReviewTable}};
+DROP TABLE IF EXISTS {{.RequestTable}};
+DROP TABLE IF EXISTS {{.ServiceTable}};
@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS {{.ServiceTable}} (
+    service_id VARCHAR(255) PRIMARY KEY UNIQUE,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    price_per_hour FLOAT NOT NULL,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS {{.RequestTable}} (
+    request_id VARCHAR(255) PRIMARY KEY UNIQUE,
+    client_id VARCHAR(255) NOT NULL,
+    cleaner_id VARCHAR(255) NOT NULL,
+    service_id VARCHAR(255) NOT NULL,
+    requested_date TIMESTAMP NOT NULL,
+    status VARCHAR(50) NOT NULL,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS {{.ReviewTable}} (
+    review_id VARCHAR(255) PRIMARY KEY UNIQUE,
+    request_id VARCHAR(255) NOT NULL,
+    client_id VARCHAR(255) NOT NULL,
+    cleaner_id VARCHAR(255) NOT NULL,
+    rating VARCHAR(50) NOT NULL,
+    comment TEXT,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP
+);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS {{.ReviewTable}}_cleaner_id_idx;
+DROP INDEX IF EXISTS {{.ReviewTable}}_client_id_idx;
+DROP INDEX IF EXISTS {{.RequestTable}}_cleaner_id_idx;
+DROP INDEX IF EXISTS {{.RequestTable}}_client_id_idx;
@@ -0,0 +1,4 @@
+CREATE INDEX IF NOT EXISTS {{.RequestTable}}_client_id_idx ON {{.RequestTable}} (client_id);
+CREATE INDEX IF NOT EXISTS {{.RequestTable}}_cleaner_id_idx ON {{.RequestTable}} (cleaner_id);
+CREATE INDEX IF NOT EXISTS {{.ReviewTable}}_client_id_idx ON {{.ReviewTable}} (client_id);
+CREATE INDEX IF NOT EXISTS {{.ReviewTable}}_cleaner_id_idx ON {{.ReviewTable}} (cleaner_id);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS {{.ReviewTable}}_created_at_idx;
+DROP INDEX IF EXISTS {{.RequestTable}}_status_idx;
+DROP INDEX IF EXISTS {{.RequestTable}}_requested_date_idx;
+DROP INDEX IF EXISTS {{.RequestTable}}_created_at_idx;
+DROP INDEX IF EXISTS {{.ServiceTable}}_created_at_idx;
@@ -0,0 +1,5 @@
+CREATE INDEX IF NOT EXISTS {{.ServiceTable}}_created_at_idx ON {{.ServiceTable}} (created_at, service_id);
+CREATE INDEX IF NOT EXISTS {{.RequestTable}}_created_at_idx ON {{.RequestTable}} (created_at, request_id);
+CREATE INDEX IF NOT EXISTS {{.RequestTable}}_requested_date_idx ON {{.RequestTable}} (requested_date, request_id);
+CREATE INDEX IF NOT EXISTS {{.RequestTable}}_status_idx ON {{.RequestTable}} (status);
+CREATE INDEX IF NOT EXISTS {{.ReviewTable}}_created_at_idx ON {{.ReviewTable}} (created_at, review_id);
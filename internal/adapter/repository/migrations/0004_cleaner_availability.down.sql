@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS {{.RequestTable}}_cleaner_date_idx;
+DROP TABLE IF EXISTS {{.TimeOffTable}};
+DROP TABLE IF EXISTS {{.AvailabilityExceptionTable}};
+DROP TABLE IF EXISTS {{.WorkingHoursTable}};
+ALTER TABLE {{.RequestTable}} DROP COLUMN IF EXISTS duration_minutes;
+ALTER TABLE {{.ServiceTable}} DROP COLUMN IF EXISTS duration_minutes;
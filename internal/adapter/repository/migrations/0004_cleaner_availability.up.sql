@@ -0,0 +1,34 @@
+ALTER TABLE {{.ServiceTable}} ADD COLUMN IF NOT EXISTS duration_minutes INTEGER NOT NULL DEFAULT 120;
+ALTER TABLE {{.RequestTable}} ADD COLUMN IF NOT EXISTS duration_minutes INTEGER NOT NULL DEFAULT 120;
+
+CREATE TABLE IF NOT EXISTS {{.WorkingHoursTable}} (
+    cleaner_id VARCHAR(255) NOT NULL,
+    weekday SMALLINT NOT NULL CHECK (weekday BETWEEN 0 AND 6),
+    start_time VARCHAR(5) NOT NULL,
+    end_time VARCHAR(5) NOT NULL,
+    PRIMARY KEY (cleaner_id, weekday, start_time)
+);
+
+CREATE TABLE IF NOT EXISTS {{.AvailabilityExceptionTable}} (
+    exception_id VARCHAR(255) PRIMARY KEY,
+    cleaner_id VARCHAR(255) NOT NULL,
+    date DATE NOT NULL,
+    start_time VARCHAR(5) NOT NULL DEFAULT '',
+    end_time VARCHAR(5) NOT NULL DEFAULT '',
+    available BOOLEAN NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS {{.AvailabilityExceptionTable}}_cleaner_date_idx ON {{.AvailabilityExceptionTable}} (cleaner_id, date);
+
+CREATE TABLE IF NOT EXISTS {{.TimeOffTable}} (
+    time_off_id VARCHAR(255) PRIMARY KEY,
+    cleaner_id VARCHAR(255) NOT NULL,
+    starts_at TIMESTAMP NOT NULL,
+    ends_at TIMESTAMP NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS {{.TimeOffTable}}_cleaner_range_idx ON {{.TimeOffTable}} (cleaner_id, starts_at, ends_at);
+
+CREATE INDEX IF NOT EXISTS {{.RequestTable}}_cleaner_date_idx ON {{.RequestTable}} (cleaner_id, requested_date);
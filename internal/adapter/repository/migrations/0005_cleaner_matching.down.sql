@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS {{.CleanerProfileTable}};
+ALTER TABLE {{.RequestTable}} DROP COLUMN IF EXISTS longitude;
+ALTER TABLE {{.RequestTable}} DROP COLUMN IF EXISTS latitude;
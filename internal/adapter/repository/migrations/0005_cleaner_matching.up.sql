@@ -0,0 +1,14 @@
+ALTER TABLE {{.RequestTable}} ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE {{.RequestTable}} ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS {{.CleanerProfileTable}} (
+    cleaner_id VARCHAR(255) PRIMARY KEY,
+    service_ids TEXT[] NOT NULL DEFAULT '{}',
+    latitude DOUBLE PRECISION NOT NULL DEFAULT 0,
+    longitude DOUBLE PRECISION NOT NULL DEFAULT 0,
+    max_distance_km DOUBLE PRECISION NOT NULL DEFAULT 0,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS {{.CleanerProfileTable}}_service_ids_idx ON {{.CleanerProfileTable}} USING GIN (service_ids);
@@ -0,0 +1,10 @@
+ALTER TABLE {{.RequestTable}} DROP COLUMN IF EXISTS quote;
+ALTER TABLE {{.RequestTable}} DROP COLUMN IF EXISTS discount_code;
+ALTER TABLE {{.RequestTable}} DROP COLUMN IF EXISTS add_ons;
+
+DROP INDEX IF EXISTS {{.ServiceTable}}_hourly_rate_idx;
+ALTER TABLE {{.ServiceTable}} ADD COLUMN IF NOT EXISTS price_per_hour FLOAT NOT NULL DEFAULT 0;
+UPDATE {{.ServiceTable}} SET price_per_hour = hourly_rate_minor / 100.0;
+ALTER TABLE {{.ServiceTable}} DROP COLUMN IF EXISTS add_ons;
+ALTER TABLE {{.ServiceTable}} DROP COLUMN IF EXISTS currency;
+ALTER TABLE {{.ServiceTable}} DROP COLUMN IF EXISTS hourly_rate_minor;
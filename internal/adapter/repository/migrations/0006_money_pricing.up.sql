@@ -0,0 +1,10 @@
+ALTER TABLE {{.ServiceTable}} ADD COLUMN IF NOT EXISTS hourly_rate_minor BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE {{.ServiceTable}} ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'KES';
+ALTER TABLE {{.ServiceTable}} ADD COLUMN IF NOT EXISTS add_ons JSONB NOT NULL DEFAULT '[]';
+UPDATE {{.ServiceTable}} SET hourly_rate_minor = ROUND(price_per_hour * 100);
+ALTER TABLE {{.ServiceTable}} DROP COLUMN price_per_hour;
+CREATE INDEX IF NOT EXISTS {{.ServiceTable}}_hourly_rate_idx ON {{.ServiceTable}} (hourly_rate_minor, service_id);
+
+ALTER TABLE {{.RequestTable}} ADD COLUMN IF NOT EXISTS add_ons TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE {{.RequestTable}} ADD COLUMN IF NOT EXISTS discount_code VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE {{.RequestTable}} ADD COLUMN IF NOT EXISTS quote JSONB;
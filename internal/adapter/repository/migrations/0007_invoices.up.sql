@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS {{.InvoiceTable}} (
+    invoice_id VARCHAR(255) PRIMARY KEY,
+    invoice_number VARCHAR(32) NOT NULL UNIQUE,
+    sequence BIGINT NOT NULL UNIQUE,
+    request_id VARCHAR(255) NOT NULL UNIQUE,
+    client_id VARCHAR(255) NOT NULL,
+    cleaner_id VARCHAR(255) NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    lines JSONB NOT NULL DEFAULT '[]',
+    subtotal_minor BIGINT NOT NULL,
+    tax_rate_bps BIGINT NOT NULL,
+    tax_minor BIGINT NOT NULL,
+    total_minor BIGINT NOT NULL,
+    issued_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS {{.InvoiceTable}}_client_idx ON {{.InvoiceTable}} (client_id, created_at, invoice_id);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS {{.PaymentTable}};
+ALTER TABLE {{.RequestTable}} DROP COLUMN IF EXISTS paid_at;
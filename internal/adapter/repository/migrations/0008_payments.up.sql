@@ -0,0 +1,21 @@
+ALTER TABLE {{.RequestTable}} ADD COLUMN IF NOT EXISTS paid_at TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS {{.PaymentTable}} (
+    payment_id VARCHAR(255) PRIMARY KEY,
+    request_id VARCHAR(255) NOT NULL,
+    invoice_id VARCHAR(255) NOT NULL,
+    reference VARCHAR(32) NOT NULL,
+    client_id VARCHAR(255) NOT NULL,
+    amount_minor BIGINT NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    phone_number VARCHAR(20) NOT NULL,
+    provider VARCHAR(50) NOT NULL,
+    provider_reference VARCHAR(255) NOT NULL,
+    receipt VARCHAR(255) NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL,
+    failure_reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    UNIQUE (provider, provider_reference)
+);
+CREATE INDEX IF NOT EXISTS {{.PaymentTable}}_request_idx ON {{.PaymentTable}} (request_id, created_at);
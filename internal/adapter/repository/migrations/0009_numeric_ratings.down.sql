@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS {{.RatingAggregateTable}};
+DROP INDEX IF EXISTS {{.ReviewTable}}_service_idx;
+ALTER TABLE {{.ReviewTable}} DROP COLUMN IF EXISTS service_id;
+ALTER TABLE {{.ReviewTable}} DROP COLUMN IF EXISTS communication;
+ALTER TABLE {{.ReviewTable}} DROP COLUMN IF EXISTS thoroughness;
+ALTER TABLE {{.ReviewTable}} DROP COLUMN IF EXISTS punctuality;
+ALTER TABLE {{.ReviewTable}} DROP CONSTRAINT IF EXISTS {{.ReviewTable}}_rating_check;
+ALTER TABLE {{.ReviewTable}} ALTER COLUMN rating DROP DEFAULT;
+ALTER TABLE {{.ReviewTable}} ALTER COLUMN rating TYPE VARCHAR(50)
+    USING (CASE WHEN rating = 0 THEN '' ELSE rating::TEXT END);
@@ -0,0 +1,47 @@
+-- Ratings that were not a whole number from 1 to 5 cannot be recovered and are kept as 0, unrated
+ALTER TABLE {{.ReviewTable}} ALTER COLUMN rating TYPE SMALLINT
+    USING (CASE WHEN rating ~ '^\s*[1-5]\s*$' THEN trim(rating)::SMALLINT ELSE 0 END);
+ALTER TABLE {{.ReviewTable}} ALTER COLUMN rating SET DEFAULT 0;
+ALTER TABLE {{.ReviewTable}} ALTER COLUMN rating SET NOT NULL;
+ALTER TABLE {{.ReviewTable}} ADD CONSTRAINT {{.ReviewTable}}_rating_check CHECK (rating BETWEEN 0 AND 5);
+ALTER TABLE {{.ReviewTable}} ADD COLUMN IF NOT EXISTS punctuality SMALLINT CHECK (punctuality BETWEEN 1 AND 5);
+ALTER TABLE {{.ReviewTable}} ADD COLUMN IF NOT EXISTS thoroughness SMALLINT CHECK (thoroughness BETWEEN 1 AND 5);
+ALTER TABLE {{.ReviewTable}} ADD COLUMN IF NOT EXISTS communication SMALLINT CHECK (communication BETWEEN 1 AND 5);
+ALTER TABLE {{.ReviewTable}} ADD COLUMN IF NOT EXISTS service_id VARCHAR(255) NOT NULL DEFAULT '';
+UPDATE {{.ReviewTable}} AS review SET service_id = request.service_id
+    FROM {{.RequestTable}} AS request
+    WHERE request.request_id = review.request_id;
+CREATE INDEX IF NOT EXISTS {{.ReviewTable}}_service_idx ON {{.ReviewTable}} (service_id);
+
+CREATE TABLE IF NOT EXISTS {{.RatingAggregateTable}} (
+    subject_type VARCHAR(20) NOT NULL,
+    subject_id VARCHAR(255) NOT NULL,
+    rating_sum BIGINT NOT NULL DEFAULT 0,
+    rating_count BIGINT NOT NULL DEFAULT 0,
+    ones BIGINT NOT NULL DEFAULT 0,
+    twos BIGINT NOT NULL DEFAULT 0,
+    threes BIGINT NOT NULL DEFAULT 0,
+    fours BIGINT NOT NULL DEFAULT 0,
+    fives BIGINT NOT NULL DEFAULT 0,
+    punctuality_sum BIGINT NOT NULL DEFAULT 0,
+    punctuality_count BIGINT NOT NULL DEFAULT 0,
+    thoroughness_sum BIGINT NOT NULL DEFAULT 0,
+    thoroughness_count BIGINT NOT NULL DEFAULT 0,
+    communication_sum BIGINT NOT NULL DEFAULT 0,
+    communication_count BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (subject_type, subject_id)
+);
+
+-- From here on the aggregates are kept up to date as reviews change
+INSERT INTO {{.RatingAggregateTable}}
+SELECT subject.subject_type, subject.subject_id,
+    SUM(rating), COUNT(*),
+    COUNT(*) FILTER (WHERE rating = 1), COUNT(*) FILTER (WHERE rating = 2), COUNT(*) FILTER (WHERE rating = 3),
+    COUNT(*) FILTER (WHERE rating = 4), COUNT(*) FILTER (WHERE rating = 5),
+    COALESCE(SUM(punctuality), 0), COUNT(punctuality),
+    COALESCE(SUM(thoroughness), 0), COUNT(thoroughness),
+    COALESCE(SUM(communication), 0), COUNT(communication)
+FROM {{.ReviewTable}} AS review
+CROSS JOIN LATERAL (VALUES ('cleaner', review.cleaner_id), ('service', review.service_id), ('platform', '')) AS subject (subject_type, subject_id)
+WHERE review.rating > 0 AND (subject.subject_type = 'platform' OR subject.subject_id <> '')
+GROUP BY subject.subject_type, subject.subject_id;
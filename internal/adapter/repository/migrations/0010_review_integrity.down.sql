@@ -0,0 +1,22 @@
+DROP INDEX IF EXISTS {{.ReviewTable}}_request_key;
+
+-- The duplicate reviews the up migration removed come back, and count towards the ratings again
+ALTER TABLE {{.ReviewTable}}_duplicates DROP COLUMN archived_at;
+INSERT INTO {{.ReviewTable}} SELECT * FROM {{.ReviewTable}}_duplicates;
+DROP TABLE {{.ReviewTable}}_duplicates;
+
+DELETE FROM {{.RatingAggregateTable}};
+INSERT INTO {{.RatingAggregateTable}}
+SELECT subject.subject_type, subject.subject_id,
+    SUM(rating), COUNT(*),
+    COUNT(*) FILTER (WHERE rating = 1), COUNT(*) FILTER (WHERE rating = 2), COUNT(*) FILTER (WHERE rating = 3),
+    COUNT(*) FILTER (WHERE rating = 4), COUNT(*) FILTER (WHERE rating = 5),
+    COALESCE(SUM(punctuality), 0), COUNT(punctuality),
+    COALESCE(SUM(thoroughness), 0), COUNT(thoroughness),
+    COALESCE(SUM(communication), 0), COUNT(communication)
+FROM {{.ReviewTable}} AS review
+CROSS JOIN LATERAL (VALUES ('cleaner', review.cleaner_id), ('service', review.service_id), ('platform', '')) AS subject (subject_type, subject_id)
+WHERE review.rating > 0 AND (subject.subject_type = 'platform' OR subject.subject_id <> '')
+GROUP BY subject.subject_type, subject.subject_id;
+
+ALTER TABLE {{.RequestTable}} DROP COLUMN IF EXISTS completed_at;
@@ -0,0 +1,36 @@
+ALTER TABLE {{.RequestTable}} ADD COLUMN IF NOT EXISTS completed_at TIMESTAMP;
+UPDATE {{.RequestTable}} SET completed_at = updated_at WHERE status = 'completed' AND completed_at IS NULL;
+
+-- Only the first review of a request is kept, the rating aggregates are rebuilt without the others.
+-- The other reviews are copied to the duplicates table first, for operators to go through and for
+-- the down migration to put back.
+CREATE TABLE IF NOT EXISTS {{.ReviewTable}}_duplicates (
+    LIKE {{.ReviewTable}} INCLUDING DEFAULTS,
+    archived_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+INSERT INTO {{.ReviewTable}}_duplicates
+SELECT review.* FROM {{.ReviewTable}} AS review
+WHERE EXISTS (
+    SELECT 1 FROM {{.ReviewTable}} AS earlier
+    WHERE earlier.request_id = review.request_id
+        AND (earlier.created_at, earlier.review_id) < (review.created_at, review.review_id)
+);
+DELETE FROM {{.ReviewTable}} AS review
+USING {{.ReviewTable}} AS earlier
+WHERE earlier.request_id = review.request_id
+    AND (earlier.created_at, earlier.review_id) < (review.created_at, review.review_id);
+CREATE UNIQUE INDEX IF NOT EXISTS {{.ReviewTable}}_request_key ON {{.ReviewTable}} (request_id);
+
+DELETE FROM {{.RatingAggregateTable}};
+INSERT INTO {{.RatingAggregateTable}}
+SELECT subject.subject_type, subject.subject_id,
+    SUM(rating), COUNT(*),
+    COUNT(*) FILTER (WHERE rating = 1), COUNT(*) FILTER (WHERE rating = 2), COUNT(*) FILTER (WHERE rating = 3),
+    COUNT(*) FILTER (WHERE rating = 4), COUNT(*) FILTER (WHERE rating = 5),
+    COALESCE(SUM(punctuality), 0), COUNT(punctuality),
+    COALESCE(SUM(thoroughness), 0), COUNT(thoroughness),
+    COALESCE(SUM(communication), 0), COUNT(communication)
+FROM {{.ReviewTable}} AS review
+CROSS JOIN LATERAL (VALUES ('cleaner', review.cleaner_id), ('service', review.service_id), ('platform', '')) AS subject (subject_type, subject_id)
+WHERE review.rating > 0 AND (subject.subject_type = 'platform' OR subject.subject_id <> '')
+GROUP BY subject.subject_type, subject.subject_id;
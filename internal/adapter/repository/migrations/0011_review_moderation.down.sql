@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS {{.ReviewTable}}_status_idx;
+ALTER TABLE {{.ReviewTable}} DROP COLUMN IF EXISTS response;
+ALTER TABLE {{.ReviewTable}} DROP COLUMN IF EXISTS flags;
+ALTER TABLE {{.ReviewTable}} DROP COLUMN IF EXISTS moderated_at;
+ALTER TABLE {{.ReviewTable}} DROP COLUMN IF EXISTS moderated_by;
+ALTER TABLE {{.ReviewTable}} DROP COLUMN IF EXISTS moderation_note;
+ALTER TABLE {{.ReviewTable}} DROP COLUMN IF EXISTS status;
@@ -0,0 +1,9 @@
+-- Reviews written so far are already live
+ALTER TABLE {{.ReviewTable}} ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'published';
+ALTER TABLE {{.ReviewTable}} ALTER COLUMN status SET DEFAULT 'pending';
+ALTER TABLE {{.ReviewTable}} ADD COLUMN IF NOT EXISTS moderation_note TEXT NOT NULL DEFAULT '';
+ALTER TABLE {{.ReviewTable}} ADD COLUMN IF NOT EXISTS moderated_by VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE {{.ReviewTable}} ADD COLUMN IF NOT EXISTS moderated_at TIMESTAMP;
+ALTER TABLE {{.ReviewTable}} ADD COLUMN IF NOT EXISTS flags JSONB NOT NULL DEFAULT '[]';
+ALTER TABLE {{.ReviewTable}} ADD COLUMN IF NOT EXISTS response JSONB;
+CREATE INDEX IF NOT EXISTS {{.ReviewTable}}_status_idx ON {{.ReviewTable}} (status, created_at, review_id);
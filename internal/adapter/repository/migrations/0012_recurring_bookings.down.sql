@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS {{.RequestTable}}_occurrence_key;
+ALTER TABLE {{.RequestTable}} DROP COLUMN IF EXISTS occurrence_at;
+ALTER TABLE {{.RequestTable}} DROP COLUMN IF EXISTS recurring_booking_id;
+DROP TABLE IF EXISTS {{.RecurringBookingTable}};
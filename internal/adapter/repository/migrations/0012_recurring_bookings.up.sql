@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS {{.RecurringBookingTable}} (
+    recurring_booking_id VARCHAR(255) PRIMARY KEY,
+    client_id VARCHAR(255) NOT NULL,
+    cleaner_id VARCHAR(255) NOT NULL DEFAULT '',
+    service_id VARCHAR(255) NOT NULL,
+    starts_at TIMESTAMPTZ NOT NULL,
+    timezone VARCHAR(64) NOT NULL,
+    rule TEXT NOT NULL,
+    latitude DOUBLE PRECISION NOT NULL,
+    longitude DOUBLE PRECISION NOT NULL,
+    add_ons TEXT[] NOT NULL DEFAULT '{}',
+    discount_code VARCHAR(255) NOT NULL DEFAULT '',
+    exceptions JSONB NOT NULL DEFAULT '[]',
+    status VARCHAR(20) NOT NULL,
+    generated_until TIMESTAMPTZ NOT NULL,
+    cancelled_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS {{.RecurringBookingTable}}_client_idx ON {{.RecurringBookingTable}} (client_id, created_at);
+CREATE INDEX IF NOT EXISTS {{.RecurringBookingTable}}_active_idx ON {{.RecurringBookingTable}} (generated_until) WHERE status = 'active';
+
+ALTER TABLE {{.RequestTable}} ADD COLUMN IF NOT EXISTS recurring_booking_id VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE {{.RequestTable}} ADD COLUMN IF NOT EXISTS occurrence_at TIMESTAMPTZ;
+-- An occurrence is materialised once however often the generator runs
+CREATE UNIQUE INDEX IF NOT EXISTS {{.RequestTable}}_occurrence_key ON {{.RequestTable}} (recurring_booking_id, occurrence_at) WHERE recurring_booking_id <> '';
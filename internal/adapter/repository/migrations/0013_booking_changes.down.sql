@@ -0,0 +1 @@
+ALTER TABLE {{.RequestTable}} DROP COLUMN IF EXISTS changes;
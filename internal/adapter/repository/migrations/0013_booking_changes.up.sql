@@ -0,0 +1,2 @@
+-- Cancellations, reschedules and releases are kept on the request instead of deleting it
+ALTER TABLE {{.RequestTable}} ADD COLUMN IF NOT EXISTS changes JSONB NOT NULL DEFAULT '[]';
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS {{.PaymentTable}}_open_key;
+DROP INDEX IF EXISTS {{.PaymentTable}}_reference_key;
+
+-- Charges that never started have no provider reference, the payment id keeps them apart
+UPDATE {{.PaymentTable}} SET provider_reference = payment_id WHERE provider_reference = '';
+ALTER TABLE {{.PaymentTable}} ADD CONSTRAINT {{.PaymentTable}}_provider_provider_reference_key UNIQUE (provider, provider_reference);
@@ -0,0 +1,17 @@
+-- A payment is recorded before its charge is started, so it has no provider reference yet
+ALTER TABLE {{.PaymentTable}} DROP CONSTRAINT IF EXISTS {{.PaymentTable}}_provider_provider_reference_key;
+CREATE UNIQUE INDEX IF NOT EXISTS {{.PaymentTable}}_reference_key ON {{.PaymentTable}} (provider, provider_reference)
+WHERE provider_reference <> '';
+
+-- A request is charged once at a time. Of the pending payments left over from before, only the
+-- latest stays pending, unless the request is already paid.
+UPDATE {{.PaymentTable}} AS payment
+SET status = 'failed', failure_reason = 'superseded by another charge', updated_at = NOW()
+WHERE payment.status = 'pending' AND EXISTS (
+    SELECT 1 FROM {{.PaymentTable}} AS other
+    WHERE other.request_id = payment.request_id
+        AND (other.status = 'succeeded'
+            OR other.status = 'pending' AND (other.created_at, other.payment_id) > (payment.created_at, payment.payment_id))
+);
+CREATE UNIQUE INDEX IF NOT EXISTS {{.PaymentTable}}_open_key ON {{.PaymentTable}} (request_id)
+WHERE status IN ('pending', 'succeeded');
@@ -0,0 +1,243 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+)
+
+type sortKind int
+
+const (
+	sortTime sortKind = iota
+	sortText
+	sortNumber
+)
+
+// sortField describes a sortable field: the column it maps to in postgres and how to
+// read the same value from an entity for cursors and the memory client.
+type sortField[T any] struct {
+	column string
+	kind   sortKind
+	value  func(T) interface{}
+}
+
+var serviceSortFields = map[string]sortField[domain.Service]{
+	"created_at":  {"created_at", sortTime, func(s domain.Service) interface{} { return s.CreatedAt }},
+	"updated_at":  {"updated_at", sortTime, func(s domain.Service) interface{} { return s.UpdatedAt }},
+	"name":        {"name", sortText, func(s domain.Service) interface{} { return s.Name }},
+	"hourly_rate": {"hourly_rate_minor", sortNumber, func(s domain.Service) interface{} { return float64(s.HourlyRate.Amount) }},
+}
+
+var requestSortFields = map[string]sortField[domain.Request]{
+	"created_at":     {"created_at", sortTime, func(r domain.Request) interface{} { return r.CreatedAt }},
+	"updated_at":     {"updated_at", sortTime, func(r domain.Request) interface{} { return r.UpdatedAt }},
+	"requested_date": {"requested_date", sortTime, func(r domain.Request) interface{} { return r.RequestedDate }},
+}
+
+var reviewSortFields = map[string]sortField[domain.Reviews]{
+	"created_at": {"created_at", sortTime, func(r domain.Reviews) interface{} { return r.CreatedAt }},
+	"updated_at": {"updated_at", sortTime, func(r domain.Reviews) interface{} { return r.UpdatedAt }},
+	"rating":     {"rating", sortNumber, func(r domain.Reviews) interface{} { return float64(r.Rating) }},
+}
+
+var invoiceSortFields = map[string]sortField[domain.Invoice]{
+	"created_at":     {"created_at", sortTime, func(i domain.Invoice) interface{} { return i.CreatedAt }},
+	"invoice_number": {"sequence", sortNumber, func(i domain.Invoice) interface{} { return float64(i.Sequence) }},
+}
+
+// listQuery is the resolved form of domain.ListOptions for one entity
+type listQuery[T any] struct {
+	sortBy string
+	desc   bool
+	field  sortField[T]
+	limit  int
+	after  *domain.Cursor
+}
+
+func newListQuery[T any](fields map[string]sortField[T], options domain.ListOptions) (*listQuery[T], error) {
+	sortBy := options.SortBy
+	if sortBy == "" {
+		sortBy = "created_at"
+	}
+	field, ok := fields[sortBy]
+	if !ok {
+		return nil, fmt.Errorf("%w: cannot sort by %q", domain.ErrInvalidListOptions, sortBy)
+	}
+
+	query := listQuery[T]{
+		sortBy: sortBy,
+		desc:   options.SortDesc,
+		field:  field,
+		limit:  options.PageLimit(),
+	}
+
+	if options.Cursor != "" {
+		cursor, err := domain.DecodeCursor(options.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if cursor.SortBy != sortBy || cursor.SortDesc != options.SortDesc {
+			return nil, fmt.Errorf("%w: cursor does not match the requested sort", domain.ErrInvalidListOptions)
+		}
+		if _, err := query.parseValue(cursor.Value); err != nil {
+			return nil, err
+		}
+		query.after = &cursor
+	}
+	return &query, nil
+}
+
+func (q listQuery[T]) formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func (q listQuery[T]) parseValue(value string) (interface{}, error) {
+	switch q.field.kind {
+	case sortTime:
+		parsed, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: malformed cursor", domain.ErrInvalidListOptions)
+		}
+		return parsed, nil
+	case sortNumber:
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: malformed cursor", domain.ErrInvalidListOptions)
+		}
+		return parsed, nil
+	default:
+		return value, nil
+	}
+}
+
+// page trims a result fetched with limit+1 rows and fills in the cursor for the next page
+func (q listQuery[T]) page(items []T, total int, id func(T) string) *domain.Page[T] {
+	page := domain.Page[T]{Items: items, Total: total}
+	if len(items) > q.limit {
+		page.Items = items[:q.limit]
+		last := page.Items[q.limit-1]
+		page.NextCursor = domain.EncodeCursor(domain.Cursor{
+			SortBy:   q.sortBy,
+			SortDesc: q.desc,
+			Value:    q.formatValue(q.field.value(last)),
+			Id:       id(last),
+		})
+	}
+	return &page
+}
+
+// orderBy renders the ORDER BY clause, using the id column as a tie breaker
+func (q listQuery[T]) orderBy(idColumn string) string {
+	direction := "ASC"
+	if q.desc {
+		direction = "DESC"
+	}
+	return fmt.Sprintf("ORDER BY %s %s, %s %s", q.field.column, direction, idColumn, direction)
+}
+
+// keyset adds the condition selecting rows after the cursor, if there is one
+func (q listQuery[T]) keyset(where *whereClause, idColumn string) {
+	if q.after == nil {
+		return
+	}
+	value, _ := q.parseValue(q.after.Value)
+	operator := ">"
+	if q.desc {
+		operator = "<"
+	}
+	where.add(fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND %[3]s %[2]s ?))", q.field.column, operator, idColumn), value, value, q.after.Id)
+}
+
+// apply sorts, filters past the cursor and pages items held in memory the same way postgres would
+func (q listQuery[T]) apply(items []T, id func(T) string) *domain.Page[T] {
+	less := func(a, b T) bool {
+		if c := compareValues(q.field.value(a), q.field.value(b)); c != 0 {
+			return c < 0
+		}
+		return id(a) < id(b)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if q.desc {
+			return less(items[j], items[i])
+		}
+		return less(items[i], items[j])
+	})
+
+	total := len(items)
+	if q.after != nil {
+		value, _ := q.parseValue(q.after.Value)
+		start := sort.Search(len(items), func(i int) bool {
+			c := compareValues(q.field.value(items[i]), value)
+			if c == 0 {
+				c = strings.Compare(id(items[i]), q.after.Id)
+			}
+			if q.desc {
+				return c < 0
+			}
+			return c > 0
+		})
+		items = items[start:]
+	}
+	if len(items) > q.limit+1 {
+		items = items[:q.limit+1]
+	}
+	return q.page(items, total, id)
+}
+
+func compareValues(a, b interface{}) int {
+	switch av := a.(type) {
+	case time.Time:
+		bv := b.(time.Time)
+		if av.Before(bv) {
+			return -1
+		}
+		if av.After(bv) {
+			return 1
+		}
+		return 0
+	case float64:
+		bv := b.(float64)
+		if av < bv {
+			return -1
+		}
+		if av > bv {
+			return 1
+		}
+		return 0
+	default:
+		return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+	}
+}
+
+// whereClause collects SQL conditions written with ? placeholders and numbers them for postgres
+type whereClause struct {
+	conditions []string
+	args       []interface{}
+}
+
+func (w *whereClause) add(condition string, args ...interface{}) {
+	for _, arg := range args {
+		w.args = append(w.args, arg)
+		condition = strings.Replace(condition, "?", fmt.Sprintf("$%d", len(w.args)), 1)
+	}
+	w.conditions = append(w.conditions, condition)
+}
+
+func (w whereClause) String() string {
+	if len(w.conditions) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(w.conditions, " AND ")
+}
@@ -1,632 +1,850 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
+	"time"
 
 	"github.com/AntonyIS/usafi-hub-cleaning-service/config"
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/adapter/tracing"
 	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
-	_ "github.com/lib/pq"
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/ports"
+	"github.com/lib/pq"
+)
+
+const (
+	serviceColumns = "service_id, name, description, hourly_rate_minor, currency, add_ons, duration_minutes, created_at, updated_at"
+	requestColumns = "request_id, client_id, cleaner_id, service_id, requested_date, duration_minutes, latitude, longitude, add_ons, discount_code, quote, paid_at, completed_at, recurring_booking_id, occurrence_at, changes, status, created_at, updated_at"
+	reviewColumns  = "review_id, request_id, client_id, cleaner_id, service_id, rating, punctuality, thoroughness, communication, comment, status, moderation_note, moderated_by, moderated_at, flags, response, created_at, updated_at"
 )
 
 type postgresClient struct {
-	db               *sql.DB
-	serviceTablename string
-	requestablename  string
-	reviewTablename  string
+	db                      *sql.DB
+	serviceTablename        string
+	requestablename         string
+	reviewTablename         string
+	workingHoursTablename   string
+	exceptionTablename      string
+	timeOffTablename        string
+	cleanerProfileTablename string
+	invoiceTablename        string
+	paymentTablename        string
+	ratingTablename         string
+	recurringTablename      string
+	queryTimeout            time.Duration
 }
 
-func NewServicePostgresClient(config config.Config) (*postgresClient, error) {
-	dbname := config.POSTGRES_DB
-	user := config.POSTGRES_USER
-	password := config.POSTGRES_PASSWORD
-	port := config.POSTGRES_PORT
-	host := config.POSTGRES_HOST
+// connectTimeout bounds the first ping, so an unreachable database fails startup instead of hanging it
+const connectTimeout = 10 * time.Second
 
-	dsn := fmt.Sprintf("host=%s port=%s user=%s dbname=%s password=%s sslmode=disable", host, port, user, dbname, password)
+// NewPostgresDB opens and verifies the connection pool every repository shares. POSTGRES_DSN, when
+// set, replaces the connection settings built from the other POSTGRES_ variables.
+func NewPostgresDB(config config.Config) (*sql.DB, error) {
+	dsn := config.POSTGRES_DSN
+	if dsn == "" {
+		dsn = fmt.Sprintf("host=%s port=%s user=%s dbname=%s password=%s sslmode=%s",
+			config.POSTGRES_HOST, config.POSTGRES_PORT, config.POSTGRES_USER, config.POSTGRES_DB, config.POSTGRES_PASSWORD, config.POSTGRES_SSLMODE)
+	}
 
 	db, err := sql.Open("postgres", dsn)
 	if err != nil {
 		return nil, err
 	}
-
-	err = db.Ping()
-	if err != nil {
-		return nil, err
+	db.SetMaxOpenConns(config.Int("POSTGRES_MAX_OPEN_CONNS"))
+	db.SetMaxIdleConns(config.Int("POSTGRES_MAX_IDLE_CONNS"))
+	db.SetConnMaxLifetime(config.Duration("POSTGRES_CONN_MAX_LIFETIME"))
+	db.SetConnMaxIdleTime(config.Duration("POSTGRES_CONN_MAX_IDLE_TIME"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		if config.POSTGRES_DSN != "" {
+			return nil, fmt.Errorf("connecting to postgres at POSTGRES_DSN: %w", err)
+		}
+		return nil, fmt.Errorf("connecting to postgres at %s:%s: %w", config.POSTGRES_HOST, config.POSTGRES_PORT, err)
 	}
+	return db, nil
+}
 
-	queryString := fmt.Sprintf(`
-    CREATE TABLE IF NOT EXISTS %s (
-        service_id VARCHAR(255) PRIMARY KEY UNIQUE,
-        name VARCHAR(255) NOT NULL,
-        description TEXT,
-        price_per_hour FLOAT NOT NULL,
-        created_at TIMESTAMP,
-        updated_at TIMESTAMP
-    )
-	`, config.SERVICE_TABLE)
-
-	_, err = db.Exec(queryString)
-	if err != nil {
-		return nil, err
-	}
+// NewPostgresClient serves every repository port from db. The tables themselves are created by the
+// migrations in migrate.go.
+func NewPostgresClient(db *sql.DB, config config.Config) *postgresClient {
 	return &postgresClient{
-		db:               db,
-		serviceTablename: config.SERVICE_TABLE,
-		requestablename:  config.REQUEST_TABLE,
-		reviewTablename:  config.REVIEWS_TABLE,
-	}, nil
+		db:                      db,
+		serviceTablename:        config.SERVICE_TABLE,
+		requestablename:         config.REQUEST_TABLE,
+		reviewTablename:         config.REVIEWS_TABLE,
+		workingHoursTablename:   config.WORKING_HOURS_TABLE,
+		exceptionTablename:      config.AVAILABILITY_EXCEPTIONS_TABLE,
+		timeOffTablename:        config.TIME_OFF_TABLE,
+		cleanerProfileTablename: config.CLEANER_PROFILES_TABLE,
+		invoiceTablename:        config.INVOICES_TABLE,
+		paymentTablename:        config.PAYMENTS_TABLE,
+		ratingTablename:         config.RATING_AGGREGATES_TABLE,
+		recurringTablename:      config.RECURRING_BOOKINGS_TABLE,
+		queryTimeout:            config.Duration("QUERY_TIMEOUT"),
+	}
 }
 
-func NewRequestPostgresClient(config config.Config) (*postgresClient, error) {
-	dbname := config.POSTGRES_DB
-	user := config.POSTGRES_USER
-	password := config.POSTGRES_PASSWORD
-	port := config.POSTGRES_PORT
-	host := config.POSTGRES_HOST
-
-	dsn := fmt.Sprintf("host=%s port=%s user=%s dbname=%s password=%s sslmode=disable", host, port, user, dbname, password)
-
-	db, err := sql.Open("postgres", dsn)
-	if err != nil {
-		return nil, err
-	}
+// tracer starts a span for every repository call. Tracing is off until SetTracer is called.
+var tracer ports.Tracer = tracing.NoopTracer{}
 
-	err = db.Ping()
-	if err != nil {
-		return nil, err
-	}
+func SetTracer(t ports.Tracer) {
+	tracer = t
+}
 
-	queryString := fmt.Sprintf(`
-    CREATE TABLE IF NOT EXISTS %s (
-        request_id VARCHAR(255) PRIMARY KEY UNIQUE,
-        client_id VARCHAR(255) NOT NULL,
-        cleaner_id VARCHAR(255) NOT NULL,
-        service_id VARCHAR(255) NOT NULL,
-        requested_date TIMESTAMP NOT NULL,
-        status VARCHAR(50) NOT NULL,
-        created_at TIMESTAMP,
-        updated_at TIMESTAMP
-    )
-	`, config.REQUEST_TABLE)
-
-	_, err = db.Exec(queryString)
-	if err != nil {
-		return nil, err
+// startQuery traces one repository call and bounds it by QUERY_TIMEOUT, within whatever deadline
+// the caller's context already carries. The returned func ends both.
+func (svc postgresClient) startQuery(ctx context.Context, operation, table string) (context.Context, func()) {
+	ctx, span := tracer.Start(ctx, "postgres "+operation+" "+table)
+	span.SetAttribute("db.system", "postgresql")
+	span.SetAttribute("db.operation", operation)
+	span.SetAttribute("db.sql.table", table)
+
+	cancel := context.CancelFunc(func() {})
+	if svc.queryTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, svc.queryTimeout)
+	}
+	return ctx, func() {
+		if err := ctx.Err(); errors.Is(err, context.DeadlineExceeded) {
+			span.RecordError(err)
+		}
+		cancel()
+		span.End()
 	}
-	return &postgresClient{
-		db:               db,
-		serviceTablename: config.SERVICE_TABLE,
-		requestablename:  config.REQUEST_TABLE,
-		reviewTablename:  config.REVIEWS_TABLE,
-	}, nil
 }
 
-func NewReviewPostgresClient(config config.Config) (*postgresClient, error) {
-	dbname := config.POSTGRES_DB
-	user := config.POSTGRES_USER
-	password := config.POSTGRES_PASSWORD
-	port := config.POSTGRES_PORT
-	host := config.POSTGRES_HOST
+// querier is satisfied by both the pool and a transaction
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
 
-	dsn := fmt.Sprintf("host=%s port=%s user=%s dbname=%s password=%s sslmode=disable", host, port, user, dbname, password)
+type txKey struct{}
 
-	db, err := sql.Open("postgres", dsn)
-	if err != nil {
-		return nil, err
+// conn returns the transaction of the unit of work ctx belongs to, or the pool outside of one
+func (svc postgresClient) conn(ctx context.Context) querier {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
 	}
+	return svc.db
+}
 
-	err = db.Ping()
-	if err != nil {
-		return nil, err
+// Do runs fn in a transaction that every repository call made with fn's context takes part in.
+// It commits when fn succeeds and rolls back otherwise. A unit of work started within another
+// joins it, leaving the commit to the outermost one.
+func (svc postgresClient) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
 	}
 
-	queryString := fmt.Sprintf(`
-    CREATE TABLE IF NOT EXISTS %s (
-        review_id VARCHAR(255) PRIMARY KEY UNIQUE,
-        request_id VARCHAR(255) NOT NULL,
-        client_id VARCHAR(255) NOT NULL,
-        cleaner_id VARCHAR(255) NOT NULL,
-        rating VARCHAR(50) NOT NULL,
-        comment TEXT,
-        created_at TIMESTAMP,
-        updated_at TIMESTAMP
-    )
-	`, config.REVIEWS_TABLE)
-
-	_, err = db.Exec(queryString)
+	tx, err := svc.db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, err
+		return mapError(err)
 	}
-	return &postgresClient{
-		db:               db,
-		serviceTablename: config.SERVICE_TABLE,
-		requestablename:  config.REQUEST_TABLE,
-		reviewTablename:  config.REVIEWS_TABLE,
-	}, nil
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return mapError(tx.Commit())
 }
 
 // CreateService creates a service  using
-func (svc postgresClient) CreateService(service domain.Service) (*domain.Service, error) {
+func (svc postgresClient) CreateService(ctx context.Context, service domain.Service) (*domain.Service, error) {
+	ctx, end := svc.startQuery(ctx, "insert", svc.serviceTablename)
+	defer end()
+
 	query := fmt.Sprintf(`
-        INSERT INTO %s (service_id, name, description, price_per_hour, created_at, updated_at)
-        VALUES ($1, $2, $3, $4, $5, $6)
+        INSERT INTO %s (service_id, name, description, hourly_rate_minor, currency, add_ons, duration_minutes, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
     `, svc.serviceTablename)
 
-	_, err := svc.db.Exec(query,
+	_, err := svc.conn(ctx).ExecContext(ctx, query,
 		service.ServiceId,
 		service.Name,
 		service.Description,
-		service.PricePerHour,
+		service.HourlyRate.Amount,
+		service.HourlyRate.Currency,
+		jsonb(addOnsOf(service)),
+		service.DurationMinutes,
 		service.CreatedAt,
 		service.UpdatedAt,
 	)
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
-	return svc.GetServiceById(service.ServiceId)
+	return svc.GetServiceById(ctx, service.ServiceId)
 }
 
 // GetServiceById retrieves a service  using service id from the services table
-func (svc postgresClient) GetServiceById(serviceId string) (*domain.Service, error) {
+func (svc postgresClient) GetServiceById(ctx context.Context, serviceId string) (*domain.Service, error) {
+	ctx, end := svc.startQuery(ctx, "select", svc.serviceTablename)
+	defer end()
+
 	query := fmt.Sprintf(`
-        SELECT service_id, name, description, price_per_hour, created_at, updated_at
+        SELECT %s
         FROM %s
         WHERE service_id = $1
-    `, svc.serviceTablename)
+    `, serviceColumns, svc.serviceTablename)
 
-	var service domain.Service
-	err := svc.db.QueryRow(query, serviceId).Scan(
-		&service.ServiceId,
-		&service.Name,
-		&service.Description,
-		&service.PricePerHour,
-		&service.CreatedAt,
-		&service.UpdatedAt,
-	)
+	service, err := scanService(svc.conn(ctx).QueryRowContext(ctx, query, serviceId))
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 	return &service, nil
 }
 
-// GetServices retrieves all services from the services table
-func (svc postgresClient) GetServices() (*[]domain.Service, error) {
-	query := fmt.Sprintf(`
-        SELECT service_id, name, description, price_per_hour, created_at, updated_at
-        FROM %s
-    `, svc.serviceTablename)
+// GetServices retrieves a page of services from the services table
+func (svc postgresClient) GetServices(ctx context.Context, filter domain.ServiceFilter) (*domain.Page[domain.Service], error) {
+	ctx, end := svc.startQuery(ctx, "select", svc.serviceTablename)
+	defer end()
 
-	rows, err := svc.db.Query(query)
+	query, err := newListQuery(serviceSortFields, filter.ListOptions)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var services []domain.Service
-	for rows.Next() {
-		var service domain.Service
-		err := rows.Scan(
-			&service.ServiceId,
-			&service.Name,
-			&service.Description,
-			&service.PricePerHour,
-			&service.CreatedAt,
-			&service.UpdatedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
-		services = append(services, service)
-	}
-
-	if err = rows.Err(); err != nil {
-		return nil, err
-	}
 
-	return &services, nil
+	return listPage(ctx, svc.conn(ctx), svc.serviceTablename, serviceColumns, "service_id", whereClause{}, query, scanService, serviceIdOf)
 }
 
 // UpdateService updates an existing service in the services table
-func (svc postgresClient) UpdateService(service domain.Service) (*domain.Service, error) {
+func (svc postgresClient) UpdateService(ctx context.Context, service domain.Service) (*domain.Service, error) {
+	ctx, end := svc.startQuery(ctx, "update", svc.serviceTablename)
+	defer end()
+
 	query := fmt.Sprintf(`
         UPDATE %s
-        SET name = $2, description = $3, price_per_hour = $4, updated_at = $5
+        SET name = $2, description = $3, hourly_rate_minor = $4, currency = $5, add_ons = $6, duration_minutes = $7, updated_at = $8
         WHERE service_id = $1
     `, svc.serviceTablename)
 
-	_, err := svc.db.Exec(query,
+	result, err := svc.conn(ctx).ExecContext(ctx, query,
 		service.ServiceId,
 		service.Name,
 		service.Description,
-		service.PricePerHour,
+		service.HourlyRate.Amount,
+		service.HourlyRate.Currency,
+		jsonb(addOnsOf(service)),
+		service.DurationMinutes,
 		service.UpdatedAt,
 	)
 	if err != nil {
+		return nil, mapError(err)
+	}
+	if err := checkRowsAffected(result); err != nil {
 		return nil, err
 	}
-	return svc.GetServiceById(service.ServiceId)
+	return svc.GetServiceById(ctx, service.ServiceId)
 }
 
 // DeleteService deletes a service from the services table
-func (svc postgresClient) DeleteService(serviceId string) error {
+func (svc postgresClient) DeleteService(ctx context.Context, serviceId string) error {
+	ctx, end := svc.startQuery(ctx, "delete", svc.serviceTablename)
+	defer end()
+
 	query := fmt.Sprintf(`
         DELETE FROM %s
         WHERE service_id = $1
     `, svc.serviceTablename)
 
-	_, err := svc.db.Exec(query, serviceId)
+	result, err := svc.conn(ctx).ExecContext(ctx, query, serviceId)
 	if err != nil {
-		return err
+		return mapError(err)
 	}
-	return nil
+	return checkRowsAffected(result)
 }
 
-func (svc postgresClient) CreateRequest(request domain.Request) (*domain.Request, error) {
+func (svc postgresClient) CreateRequest(ctx context.Context, request domain.Request) (*domain.Request, error) {
+	ctx, end := svc.startQuery(ctx, "insert", svc.requestablename)
+	defer end()
+
 	query := fmt.Sprintf(`
-        INSERT INTO %s (request_id, client_id, cleaner_id, service_id, requested_date, status, created_at, updated_at)
-        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        INSERT INTO %s (request_id, client_id, cleaner_id, service_id, requested_date, duration_minutes, latitude, longitude, add_ons, discount_code, quote, recurring_booking_id, occurrence_at, changes, status, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
     `, svc.requestablename)
 
-	_, err := svc.db.Exec(query,
+	_, err := svc.conn(ctx).ExecContext(ctx, query,
 		request.RequestId,
 		request.ClientId,
 		request.CleanerId,
 		request.ServiceId,
 		request.RequestedDate,
+		request.DurationMinutes,
+		request.Latitude,
+		request.Longitude,
+		stringArray(request.AddOns),
+		request.DiscountCode,
+		jsonb(request.Quote),
+		request.RecurringBookingId,
+		request.OccurrenceAt,
+		jsonb(changesOf(request)),
 		request.Status,
 		request.CreatedAt,
 		request.UpdatedAt,
 	)
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
-	return svc.GetRequestById(request.RequestId)
+	return svc.GetRequestById(ctx, request.RequestId)
 }
 
-func (svc postgresClient) GetRequestById(requestId string) (*domain.Request, error) {
+func (svc postgresClient) GetRequestById(ctx context.Context, requestId string) (*domain.Request, error) {
+	ctx, end := svc.startQuery(ctx, "select", svc.requestablename)
+	defer end()
+
 	query := fmt.Sprintf(`
-        SELECT request_id, client_id, cleaner_id, service_id, requested_date, status, created_at, updated_at
+        SELECT %s
         FROM %s
         WHERE request_id = $1
-    `, svc.requestablename)
+    `, requestColumns, svc.requestablename)
 
-	var request domain.Request
-	err := svc.db.QueryRow(query, requestId).Scan(
-		&request.RequestId,
-		&request.ClientId,
-		&request.CleanerId,
-		&request.ServiceId,
-		&request.RequestedDate,
-		&request.Status,
-		&request.CreatedAt,
-		&request.UpdatedAt,
-	)
+	request, err := scanRequest(svc.conn(ctx).QueryRowContext(ctx, query, requestId))
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 	return &request, nil
 }
 
-func (svc postgresClient) GetRequests() (*[]domain.Request, error) {
-	query := fmt.Sprintf(`
-        SELECT request_id, client_id, cleaner_id, service_id, requested_date, status, created_at, updated_at
-        FROM %s
-    `, svc.requestablename)
+func (svc postgresClient) GetRequests(ctx context.Context, filter domain.RequestFilter) (*domain.Page[domain.Request], error) {
+	ctx, end := svc.startQuery(ctx, "select", svc.requestablename)
+	defer end()
 
-	rows, err := svc.db.Query(query)
+	query, err := newListQuery(requestSortFields, filter.ListOptions)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	var requests []domain.Request
-	for rows.Next() {
-		var request domain.Request
-		err := rows.Scan(
-			&request.RequestId,
-			&request.ClientId,
-			&request.CleanerId,
-			&request.ServiceId,
-			&request.RequestedDate,
-			&request.Status,
-			&request.CreatedAt,
-			&request.UpdatedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
-		requests = append(requests, request)
+	where := whereClause{}
+	if filter.ClientId != "" {
+		where.add("client_id = ?", filter.ClientId)
 	}
-
-	if err = rows.Err(); err != nil {
-		return nil, err
+	if filter.CleanerId != "" {
+		where.add("cleaner_id = ?", filter.CleanerId)
+	}
+	if filter.ServiceId != "" {
+		where.add("service_id = ?", filter.ServiceId)
+	}
+	if filter.Status != "" {
+		where.add("status = ?", filter.Status)
+	}
+	if filter.RequestedFrom != nil {
+		where.add("requested_date >= ?", *filter.RequestedFrom)
+	}
+	if filter.RequestedTo != nil {
+		where.add("requested_date < ?", *filter.RequestedTo)
+	}
+	if filter.RecurringBookingId != "" {
+		where.add("recurring_booking_id = ?", filter.RecurringBookingId)
 	}
 
-	return &requests, nil
+	return listPage(ctx, svc.conn(ctx), svc.requestablename, requestColumns, "request_id", where, query, scanRequest, requestIdOf)
 }
 
-func (svc postgresClient) UpdateRequest(request domain.Request) (*domain.Request, error) {
+func (svc postgresClient) UpdateRequest(ctx context.Context, request domain.Request, from domain.RequestStatus) (*domain.Request, error) {
+	ctx, end := svc.startQuery(ctx, "update", svc.requestablename)
+	defer end()
+
 	query := fmt.Sprintf(`
         UPDATE %s
-        SET client_id = $2, cleaner_id = $3, service_id = $4, requested_date = $5, status = $6, updated_at = $7
-        WHERE request_id = $1
+        SET client_id = $2, cleaner_id = $3, service_id = $4, requested_date = $5, duration_minutes = $6, latitude = $7, longitude = $8, add_ons = $9, discount_code = $10, quote = $11, changes = $12, status = $13, updated_at = $14
+        WHERE request_id = $1 AND status = $15
     `, svc.requestablename)
 
-	_, err := svc.db.Exec(query,
+	result, err := svc.conn(ctx).ExecContext(ctx, query,
 		request.RequestId,
 		request.ClientId,
 		request.CleanerId,
 		request.ServiceId,
 		request.RequestedDate,
+		request.DurationMinutes,
+		request.Latitude,
+		request.Longitude,
+		stringArray(request.AddOns),
+		request.DiscountCode,
+		jsonb(request.Quote),
+		jsonb(changesOf(request)),
 		request.Status,
 		request.UpdatedAt,
+		from,
 	)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	err = checkRowsAffected(result)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, statusChanged(request.RequestId, from)
+	}
 	if err != nil {
 		return nil, err
 	}
-	return svc.GetRequestById(request.RequestId)
+	return svc.GetRequestById(ctx, request.RequestId)
 }
 
-func (svc postgresClient) DeleteRequest(requestId string) error {
+func (svc postgresClient) DeleteRequest(ctx context.Context, requestId string) error {
+	ctx, end := svc.startQuery(ctx, "delete", svc.requestablename)
+	defer end()
+
 	query := fmt.Sprintf(`
         DELETE FROM %s
         WHERE request_id = $1
     `, svc.requestablename)
 
-	_, err := svc.db.Exec(query, requestId)
+	result, err := svc.conn(ctx).ExecContext(ctx, query, requestId)
 	if err != nil {
-		return err
+		return mapError(err)
 	}
-	return nil
+	return checkRowsAffected(result)
 }
 
-func (svc postgresClient) AssignCleaner(requestId, cleanerId string) error {
+func (svc postgresClient) AssignCleaner(ctx context.Context, requestId, cleanerId string) error {
+	ctx, end := svc.startQuery(ctx, "update", svc.requestablename)
+	defer end()
+
 	query := fmt.Sprintf(`
         UPDATE %s
         SET cleaner_id = $2
         WHERE request_id = $1
     `, svc.requestablename)
 
-	_, err := svc.db.Exec(query, requestId, cleanerId)
+	result, err := svc.conn(ctx).ExecContext(ctx, query, requestId, cleanerId)
 	if err != nil {
-		return err
+		return mapError(err)
 	}
-	return nil
+	return checkRowsAffected(result)
 }
 
-func (svc postgresClient) GetRequestByClient(clientId string) (*[]domain.Request, error) {
+func (svc postgresClient) UpdateRequestStatus(ctx context.Context, requestId string, from, to domain.RequestStatus) error {
+	ctx, end := svc.startQuery(ctx, "update", svc.requestablename)
+	defer end()
+
 	query := fmt.Sprintf(`
-        SELECT request_id, client_id, cleaner_id, service_id, requested_date, status, created_at, updated_at
-        FROM %s
-        WHERE client_id = $1
+        UPDATE %s
+        SET status = $2, updated_at = $3,
+            completed_at = CASE WHEN $2 = $4 THEN $3 ELSE completed_at END
+        WHERE request_id = $1 AND status = $5
     `, svc.requestablename)
 
-	rows, err := svc.db.Query(query, clientId)
+	result, err := svc.conn(ctx).ExecContext(ctx, query, requestId, to, time.Now(), domain.RequestCompleted, from)
 	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var requests []domain.Request
-	for rows.Next() {
-		var request domain.Request
-		err := rows.Scan(
-			&request.RequestId,
-			&request.ClientId,
-			&request.CleanerId,
-			&request.ServiceId,
-			&request.RequestedDate,
-			&request.Status,
-			&request.CreatedAt,
-			&request.UpdatedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
-		requests = append(requests, request)
+		return mapError(err)
 	}
-
-	if err = rows.Err(); err != nil {
-		return nil, err
+	err = checkRowsAffected(result)
+	if errors.Is(err, domain.ErrNotFound) {
+		return statusChanged(requestId, from)
 	}
+	return err
+}
 
-	return &requests, nil
+// statusChanged is returned when a request's status changed between reading and writing it
+func statusChanged(requestId string, from domain.RequestStatus) error {
+	return fmt.Errorf("%w: request %s is no longer %s", domain.ErrInvalidTransition, requestId, from)
 }
 
-func (svc postgresClient) GetRequestByCleaner(cleanerId string) (*[]domain.Request, error) {
+// MarkRequestPaid records when a request's payment went through
+func (svc postgresClient) MarkRequestPaid(ctx context.Context, requestId string, paidAt time.Time) error {
+	ctx, end := svc.startQuery(ctx, "update", svc.requestablename)
+	defer end()
+
 	query := fmt.Sprintf(`
-        SELECT request_id, client_id, cleaner_id, service_id, requested_date, status, created_at, updated_at
-        FROM %s
-        WHERE cleaner_id = $1
+        UPDATE %s
+        SET paid_at = $2, updated_at = $3
+        WHERE request_id = $1
     `, svc.requestablename)
 
-	rows, err := svc.db.Query(query, cleanerId)
+	result, err := svc.conn(ctx).ExecContext(ctx, query, requestId, paidAt, time.Now())
 	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var requests []domain.Request
-	for rows.Next() {
-		var request domain.Request
-		err := rows.Scan(
-			&request.RequestId,
-			&request.ClientId,
-			&request.CleanerId,
-			&request.ServiceId,
-			&request.RequestedDate,
-			&request.Status,
-			&request.CreatedAt,
-			&request.UpdatedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
-		requests = append(requests, request)
+		return mapError(err)
 	}
+	return checkRowsAffected(result)
+}
 
-	if err = rows.Err(); err != nil {
-		return nil, err
-	}
+func (svc postgresClient) GetRequestByClient(ctx context.Context, clientId string, filter domain.RequestFilter) (*domain.Page[domain.Request], error) {
+	filter.ClientId = clientId
+	return svc.GetRequests(ctx, filter)
+}
 
-	return &requests, nil
+func (svc postgresClient) GetRequestByCleaner(ctx context.Context, cleanerId string, filter domain.RequestFilter) (*domain.Page[domain.Request], error) {
+	filter.CleanerId = cleanerId
+	return svc.GetRequests(ctx, filter)
 }
 
-func (svc postgresClient) CreateReview(review domain.Reviews) (*domain.Reviews, error) {
+func (svc postgresClient) CreateReview(ctx context.Context, review domain.Reviews) (*domain.Reviews, error) {
+	ctx, end := svc.startQuery(ctx, "insert", svc.reviewTablename)
+	defer end()
+
 	query := fmt.Sprintf(`
-        INSERT INTO %s (review_id, request_id, client_id, cleaner_id, rating, comment, created_at, updated_at)
-        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-    `, svc.reviewTablename)
+        INSERT INTO %s (%s)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
+    `, svc.reviewTablename, reviewColumns)
 
-	_, err := svc.db.Exec(query,
+	_, err := svc.conn(ctx).ExecContext(ctx, query,
 		review.ReviewId,
 		review.RequestId,
 		review.ClientId,
 		review.CleanerId,
+		review.ServiceId,
 		review.Rating,
+		review.SubScores.Punctuality,
+		review.SubScores.Thoroughness,
+		review.SubScores.Communication,
 		review.Comment,
+		review.Status,
+		review.ModerationNote,
+		review.ModeratedBy,
+		review.ModeratedAt,
+		jsonb(flagsOf(review)),
+		jsonb(review.Response),
 		review.CreatedAt,
 		review.UpdatedAt,
 	)
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
-	return svc.GetReviewById(review.ReviewId)
+	return svc.GetReviewById(ctx, review.ReviewId)
 }
 
-func (svc postgresClient) GetReviewById(reviewId string) (*domain.Reviews, error) {
+func (svc postgresClient) GetReviewById(ctx context.Context, reviewId string) (*domain.Reviews, error) {
+	ctx, end := svc.startQuery(ctx, "select", svc.reviewTablename)
+	defer end()
+
 	query := fmt.Sprintf(`
-        SELECT review_id, request_id, client_id, cleaner_id, rating, comment, created_at, updated_at
+        SELECT %s
         FROM %s
         WHERE review_id = $1
-    `, svc.reviewTablename)
+    `, reviewColumns, svc.reviewTablename)
 
-	var review domain.Reviews
-	err := svc.db.QueryRow(query, reviewId).Scan(
-		&review.ReviewId,
-		&review.RequestId,
-		&review.ClientId,
-		&review.CleanerId,
-		&review.Rating,
-		&review.Comment,
-		&review.CreatedAt,
-		&review.UpdatedAt,
-	)
+	review, err := scanReview(svc.conn(ctx).QueryRowContext(ctx, query, reviewId))
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 	return &review, nil
 }
 
-func (svc postgresClient) UpdateReview(review domain.Reviews) (*domain.Reviews, error) {
+func (svc postgresClient) UpdateReview(ctx context.Context, review domain.Reviews) (*domain.Reviews, error) {
+	ctx, end := svc.startQuery(ctx, "update", svc.reviewTablename)
+	defer end()
+
 	query := fmt.Sprintf(`
         UPDATE %s
-        SET request_id = $2, client_id = $3, cleaner_id = $4, rating = $5, comment = $6, updated_at = $7
+        SET request_id = $2, client_id = $3, cleaner_id = $4, service_id = $5, rating = $6,
+            punctuality = $7, thoroughness = $8, communication = $9, comment = $10, status = $11,
+            moderation_note = $12, moderated_by = $13, moderated_at = $14, flags = $15, response = $16, updated_at = $17
         WHERE review_id = $1
     `, svc.reviewTablename)
 
-	_, err := svc.db.Exec(query,
+	result, err := svc.conn(ctx).ExecContext(ctx, query,
 		review.ReviewId,
 		review.RequestId,
 		review.ClientId,
 		review.CleanerId,
+		review.ServiceId,
 		review.Rating,
+		review.SubScores.Punctuality,
+		review.SubScores.Thoroughness,
+		review.SubScores.Communication,
 		review.Comment,
+		review.Status,
+		review.ModerationNote,
+		review.ModeratedBy,
+		review.ModeratedAt,
+		jsonb(flagsOf(review)),
+		jsonb(review.Response),
 		review.UpdatedAt,
 	)
 	if err != nil {
+		return nil, mapError(err)
+	}
+	if err := checkRowsAffected(result); err != nil {
 		return nil, err
 	}
-	return svc.GetReviewById(review.ReviewId)
+	return svc.GetReviewById(ctx, review.ReviewId)
 }
 
-func (svc postgresClient) DeleteReview(reviewId string) error {
+func (svc postgresClient) DeleteReview(ctx context.Context, reviewId string) error {
+	ctx, end := svc.startQuery(ctx, "delete", svc.reviewTablename)
+	defer end()
+
 	query := fmt.Sprintf(`
         DELETE FROM %s
         WHERE review_id = $1
     `, svc.reviewTablename)
 
-	_, err := svc.db.Exec(query, reviewId)
+	result, err := svc.conn(ctx).ExecContext(ctx, query, reviewId)
 	if err != nil {
-		return err
+		return mapError(err)
 	}
-	return nil
+	return checkRowsAffected(result)
 }
 
-func (svc postgresClient) GetReviewByClient(clientId string) (*[]domain.Reviews, error) {
-	query := fmt.Sprintf(`
-        SELECT review_id, request_id, client_id, cleaner_id, rating, comment, created_at, updated_at
-        FROM %s
-        WHERE client_id = $1
-    `, svc.reviewTablename)
+func (svc postgresClient) GetReviewByClient(ctx context.Context, clientId string, filter domain.ReviewFilter) (*domain.Page[domain.Reviews], error) {
+	ctx, end := svc.startQuery(ctx, "select", svc.reviewTablename)
+	defer end()
+
+	filter.ClientId = clientId
+	return svc.getReviews(ctx, filter)
+}
+
+func (svc postgresClient) GetReviewByCleaner(ctx context.Context, cleanerId string, filter domain.ReviewFilter) (*domain.Page[domain.Reviews], error) {
+	ctx, end := svc.startQuery(ctx, "select", svc.reviewTablename)
+	defer end()
+
+	filter.CleanerId = cleanerId
+	return svc.getReviews(ctx, filter)
+}
 
-	rows, err := svc.db.Query(query, clientId)
+// GetReviews retrieves a page of reviews of any client or cleaner, for moderators
+func (svc postgresClient) GetReviews(ctx context.Context, filter domain.ReviewFilter) (*domain.Page[domain.Reviews], error) {
+	ctx, end := svc.startQuery(ctx, "select", svc.reviewTablename)
+	defer end()
+
+	return svc.getReviews(ctx, filter)
+}
+
+func (svc postgresClient) getReviews(ctx context.Context, filter domain.ReviewFilter) (*domain.Page[domain.Reviews], error) {
+	query, err := newListQuery(reviewSortFields, filter.ListOptions)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	var reviews []domain.Reviews
-	for rows.Next() {
-		var review domain.Reviews
-		err := rows.Scan(
-			&review.ReviewId,
-			&review.RequestId,
-			&review.ClientId,
-			&review.CleanerId,
-			&review.Rating,
-			&review.Comment,
-			&review.CreatedAt,
-			&review.UpdatedAt,
-		)
-		if err != nil {
-			return nil, err
+	where := whereClause{}
+	if filter.ClientId != "" {
+		where.add("client_id = ?", filter.ClientId)
+	}
+	if filter.CleanerId != "" {
+		where.add("cleaner_id = ?", filter.CleanerId)
+	}
+	if filter.MinRating > 0 {
+		where.add("rating >= ?", filter.MinRating)
+	}
+	if filter.MaxRating > 0 {
+		where.add("rating <= ?", filter.MaxRating)
+	}
+	if len(filter.Statuses) > 0 {
+		statuses := make([]string, len(filter.Statuses))
+		for i, status := range filter.Statuses {
+			statuses[i] = string(status)
 		}
-		reviews = append(reviews, review)
+		where.add("status = ANY(?)", pq.Array(statuses))
 	}
 
-	if err = rows.Err(); err != nil {
+	return listPage(ctx, svc.conn(ctx), svc.reviewTablename, reviewColumns, "review_id", where, query, scanReview, reviewIdOf)
+}
+
+// mapError translates driver errors into the domain errors shared with the memory client.
+// Timeouts and lost connections become domain.ErrUnavailable so callers can retry.
+func mapError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.ErrNotFound
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch {
+		case pqErr.Code == "23505":
+			return domain.ErrAlreadyExists
+		// connection exceptions, insufficient resources and operator intervention such as shutdowns
+		case pqErr.Code.Class() == "08", pqErr.Code.Class() == "53", pqErr.Code.Class() == "57":
+			return fmt.Errorf("%w: %v", domain.ErrUnavailable, err)
+		}
+		return err
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) || errors.As(err, &netErr) {
+		return fmt.Errorf("%w: %v", domain.ErrUnavailable, err)
+	}
+	return err
+}
+
+// checkRowsAffected reports domain.ErrNotFound when a write matched no rows
+func checkRowsAffected(result sql.Result) error {
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanService(row rowScanner) (domain.Service, error) {
+	var service domain.Service
+	err := row.Scan(
+		&service.ServiceId,
+		&service.Name,
+		&service.Description,
+		&service.HourlyRate.Amount,
+		&service.HourlyRate.Currency,
+		jsonb(&service.AddOns),
+		&service.DurationMinutes,
+		&service.CreatedAt,
+		&service.UpdatedAt,
+	)
+	return service, err
+}
+
+func scanRequest(row rowScanner) (domain.Request, error) {
+	var request domain.Request
+	err := row.Scan(
+		&request.RequestId,
+		&request.ClientId,
+		&request.CleanerId,
+		&request.ServiceId,
+		&request.RequestedDate,
+		&request.DurationMinutes,
+		&request.Latitude,
+		&request.Longitude,
+		pq.Array(&request.AddOns),
+		&request.DiscountCode,
+		jsonb(&request.Quote),
+		&request.PaidAt,
+		&request.CompletedAt,
+		&request.RecurringBookingId,
+		&request.OccurrenceAt,
+		jsonb(&request.Changes),
+		&request.Status,
+		&request.CreatedAt,
+		&request.UpdatedAt,
+	)
+	return request, err
+}
+
+func scanReview(row rowScanner) (domain.Reviews, error) {
+	var review domain.Reviews
+	err := row.Scan(
+		&review.ReviewId,
+		&review.RequestId,
+		&review.ClientId,
+		&review.CleanerId,
+		&review.ServiceId,
+		&review.Rating,
+		&review.SubScores.Punctuality,
+		&review.SubScores.Thoroughness,
+		&review.SubScores.Communication,
+		&review.Comment,
+		&review.Status,
+		&review.ModerationNote,
+		&review.ModeratedBy,
+		&review.ModeratedAt,
+		jsonb(&review.Flags),
+		jsonb(&review.Response),
+		&review.CreatedAt,
+		&review.UpdatedAt,
+	)
+	return review, err
+}
+
+// flagsOf keeps the flags column an array, never NULL
+func flagsOf(review domain.Reviews) []domain.ReviewFlag {
+	if review.Flags == nil {
+		return []domain.ReviewFlag{}
+	}
+	return review.Flags
+}
+
+// jsonColumn reads and writes a value as JSONB. A nil value is stored as NULL.
+type jsonColumn struct {
+	value interface{}
+}
+
+func jsonb(value interface{}) jsonColumn {
+	return jsonColumn{value: value}
+}
+
+func (c jsonColumn) Value() (driver.Value, error) {
+	data, err := json.Marshal(c.value)
+	if err != nil || string(data) == "null" {
 		return nil, err
 	}
+	return string(data), nil
+}
 
-	return &reviews, nil
+// Scan unmarshals into the value, which must be a pointer
+func (c jsonColumn) Scan(src interface{}) error {
+	switch data := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(data, c.value)
+	case string:
+		return json.Unmarshal([]byte(data), c.value)
+	default:
+		return fmt.Errorf("cannot scan %T into a JSON column", src)
+	}
 }
 
-func (svc postgresClient) GetReviewByCleaner(cleanerId string) (*[]domain.Reviews, error) {
-	query := fmt.Sprintf(`
-        SELECT review_id, request_id, client_id, cleaner_id, rating, comment, created_at, updated_at
+// stringArray writes a nil slice as an empty array, since array columns are NOT NULL
+func stringArray(values []string) interface{} {
+	if values == nil {
+		values = []string{}
+	}
+	return pq.Array(values)
+}
+
+func changesOf(request domain.Request) []domain.BookingChange {
+	if request.Changes == nil {
+		return []domain.BookingChange{}
+	}
+	return request.Changes
+}
+
+func addOnsOf(service domain.Service) []domain.AddOn {
+	if service.AddOns == nil {
+		return []domain.AddOn{}
+	}
+	return service.AddOns
+}
+
+func serviceIdOf(service domain.Service) string { return service.ServiceId }
+func requestIdOf(request domain.Request) string { return request.RequestId }
+func reviewIdOf(review domain.Reviews) string   { return review.ReviewId }
+
+// listPage counts the rows matching where, then reads the page after the cursor
+func listPage[T any](ctx context.Context, db querier, table, columns, idColumn string, where whereClause, query *listQuery[T], scan func(rowScanner) (T, error), id func(T) string) (*domain.Page[T], error) {
+	var total int
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s %s", table, where)
+	if err := db.QueryRowContext(ctx, countQuery, where.args...).Scan(&total); err != nil {
+		return nil, mapError(err)
+	}
+
+	query.keyset(&where, idColumn)
+	where.args = append(where.args, query.limit+1)
+	selectQuery := fmt.Sprintf(`
+        SELECT %s
         FROM %s
-        WHERE cleaner_id = $1
-    `, svc.reviewTablename)
+        %s
+        %s
+        LIMIT $%d
+    `, columns, table, where, query.orderBy(idColumn), len(where.args))
 
-	rows, err := svc.db.Query(query, cleanerId)
+	rows, err := db.QueryContext(ctx, selectQuery, where.args...)
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 	defer rows.Close()
 
-	var reviews []domain.Reviews
+	items := []T{}
 	for rows.Next() {
-		var review domain.Reviews
-		err := rows.Scan(
-			&review.ReviewId,
-			&review.RequestId,
-			&review.ClientId,
-			&review.CleanerId,
-			&review.Rating,
-			&review.Comment,
-			&review.CreatedAt,
-			&review.UpdatedAt,
-		)
+		item, err := scan(rows)
 		if err != nil {
-			return nil, err
+			return nil, mapError(err)
 		}
-		reviews = append(reviews, review)
+		items = append(items, item)
 	}
-
 	if err = rows.Err(); err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
-	return &reviews, nil
+
+	return query.page(items, total, id), nil
 }
@@ -0,0 +1,252 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+)
+
+// SetWorkingHours replaces all weekly working hours of a cleaner
+func (svc postgresClient) SetWorkingHours(ctx context.Context, cleanerId string, hours []domain.WorkingHours) error {
+	ctx, end := svc.startQuery(ctx, "replace", svc.workingHoursTablename)
+	defer end()
+
+	return svc.Do(ctx, func(ctx context.Context) error {
+		tx := svc.conn(ctx)
+		_, err := tx.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE cleaner_id = $1`, svc.workingHoursTablename), cleanerId)
+		if err != nil {
+			return mapError(err)
+		}
+
+		query := fmt.Sprintf(`
+            INSERT INTO %s (cleaner_id, weekday, start_time, end_time)
+            VALUES ($1, $2, $3, $4)
+        `, svc.workingHoursTablename)
+		for _, window := range hours {
+			_, err = tx.ExecContext(ctx, query, cleanerId, window.Weekday, window.StartTime, window.EndTime)
+			if err != nil {
+				return mapError(err)
+			}
+		}
+		return nil
+	})
+}
+
+// LockCleaner takes a transaction-level advisory lock on the cleaner, Postgres releases it on
+// commit or rollback. Outside a unit of work there is no transaction to hold it for.
+func (svc postgresClient) LockCleaner(ctx context.Context, cleanerId string) error {
+	tx, ok := ctx.Value(txKey{}).(*sql.Tx)
+	if !ok {
+		return nil
+	}
+	ctx, end := svc.startQuery(ctx, "lock", svc.requestablename)
+	defer end()
+
+	_, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, cleanerId)
+	return mapError(err)
+}
+
+func (svc postgresClient) GetWorkingHours(ctx context.Context, cleanerId string) (*[]domain.WorkingHours, error) {
+	ctx, end := svc.startQuery(ctx, "select", svc.workingHoursTablename)
+	defer end()
+
+	query := fmt.Sprintf(`
+        SELECT cleaner_id, weekday, start_time, end_time
+        FROM %s
+        WHERE cleaner_id = $1
+        ORDER BY weekday, start_time
+    `, svc.workingHoursTablename)
+
+	rows, err := svc.conn(ctx).QueryContext(ctx, query, cleanerId)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	hours := []domain.WorkingHours{}
+	for rows.Next() {
+		var window domain.WorkingHours
+		err := rows.Scan(
+			&window.CleanerId,
+			&window.Weekday,
+			&window.StartTime,
+			&window.EndTime,
+		)
+		if err != nil {
+			return nil, mapError(err)
+		}
+		hours = append(hours, window)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, mapError(err)
+	}
+	return &hours, nil
+}
+
+func (svc postgresClient) CreateException(ctx context.Context, exception domain.AvailabilityException) (*domain.AvailabilityException, error) {
+	ctx, end := svc.startQuery(ctx, "insert", svc.exceptionTablename)
+	defer end()
+
+	query := fmt.Sprintf(`
+        INSERT INTO %s (exception_id, cleaner_id, date, start_time, end_time, available, reason, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+    `, svc.exceptionTablename)
+
+	_, err := svc.conn(ctx).ExecContext(ctx, query,
+		exception.ExceptionId,
+		exception.CleanerId,
+		exception.Date,
+		exception.StartTime,
+		exception.EndTime,
+		exception.Available,
+		exception.Reason,
+		exception.CreatedAt,
+	)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &exception, nil
+}
+
+// GetExceptions retrieves the exceptions of a cleaner dated between from and to, inclusive
+func (svc postgresClient) GetExceptions(ctx context.Context, cleanerId string, from, to time.Time) (*[]domain.AvailabilityException, error) {
+	ctx, end := svc.startQuery(ctx, "select", svc.exceptionTablename)
+	defer end()
+
+	query := fmt.Sprintf(`
+        SELECT exception_id, cleaner_id, to_char(date, 'YYYY-MM-DD'), start_time, end_time, available, reason, created_at
+        FROM %s
+        WHERE cleaner_id = $1 AND date BETWEEN $2 AND $3
+        ORDER BY date, start_time
+    `, svc.exceptionTablename)
+
+	rows, err := svc.conn(ctx).QueryContext(ctx, query, cleanerId, from.Format(domain.DateLayout), to.Format(domain.DateLayout))
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	exceptions := []domain.AvailabilityException{}
+	for rows.Next() {
+		var exception domain.AvailabilityException
+		err := rows.Scan(
+			&exception.ExceptionId,
+			&exception.CleanerId,
+			&exception.Date,
+			&exception.StartTime,
+			&exception.EndTime,
+			&exception.Available,
+			&exception.Reason,
+			&exception.CreatedAt,
+		)
+		if err != nil {
+			return nil, mapError(err)
+		}
+		exceptions = append(exceptions, exception)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, mapError(err)
+	}
+	return &exceptions, nil
+}
+
+func (svc postgresClient) DeleteException(ctx context.Context, cleanerId, exceptionId string) error {
+	ctx, end := svc.startQuery(ctx, "delete", svc.exceptionTablename)
+	defer end()
+
+	query := fmt.Sprintf(`
+        DELETE FROM %s
+        WHERE exception_id = $1 AND cleaner_id = $2
+    `, svc.exceptionTablename)
+
+	result, err := svc.conn(ctx).ExecContext(ctx, query, exceptionId, cleanerId)
+	if err != nil {
+		return mapError(err)
+	}
+	return checkRowsAffected(result)
+}
+
+func (svc postgresClient) CreateTimeOff(ctx context.Context, timeOff domain.TimeOff) (*domain.TimeOff, error) {
+	ctx, end := svc.startQuery(ctx, "insert", svc.timeOffTablename)
+	defer end()
+
+	query := fmt.Sprintf(`
+        INSERT INTO %s (time_off_id, cleaner_id, starts_at, ends_at, reason, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+    `, svc.timeOffTablename)
+
+	_, err := svc.conn(ctx).ExecContext(ctx, query,
+		timeOff.TimeOffId,
+		timeOff.CleanerId,
+		timeOff.StartsAt,
+		timeOff.EndsAt,
+		timeOff.Reason,
+		timeOff.CreatedAt,
+	)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &timeOff, nil
+}
+
+// GetTimeOff retrieves the time off of a cleaner overlapping the range from to
+func (svc postgresClient) GetTimeOff(ctx context.Context, cleanerId string, from, to time.Time) (*[]domain.TimeOff, error) {
+	ctx, end := svc.startQuery(ctx, "select", svc.timeOffTablename)
+	defer end()
+
+	query := fmt.Sprintf(`
+        SELECT time_off_id, cleaner_id, starts_at, ends_at, reason, created_at
+        FROM %s
+        WHERE cleaner_id = $1 AND starts_at < $3 AND ends_at > $2
+        ORDER BY starts_at
+    `, svc.timeOffTablename)
+
+	rows, err := svc.conn(ctx).QueryContext(ctx, query, cleanerId, from, to)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	timeOffs := []domain.TimeOff{}
+	for rows.Next() {
+		var timeOff domain.TimeOff
+		err := rows.Scan(
+			&timeOff.TimeOffId,
+			&timeOff.CleanerId,
+			&timeOff.StartsAt,
+			&timeOff.EndsAt,
+			&timeOff.Reason,
+			&timeOff.CreatedAt,
+		)
+		if err != nil {
+			return nil, mapError(err)
+		}
+		timeOffs = append(timeOffs, timeOff)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, mapError(err)
+	}
+	return &timeOffs, nil
+}
+
+func (svc postgresClient) DeleteTimeOff(ctx context.Context, cleanerId, timeOffId string) error {
+	ctx, end := svc.startQuery(ctx, "delete", svc.timeOffTablename)
+	defer end()
+
+	query := fmt.Sprintf(`
+        DELETE FROM %s
+        WHERE time_off_id = $1 AND cleaner_id = $2
+    `, svc.timeOffTablename)
+
+	result, err := svc.conn(ctx).ExecContext(ctx, query, timeOffId, cleanerId)
+	if err != nil {
+		return mapError(err)
+	}
+	return checkRowsAffected(result)
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+	"github.com/lib/pq"
+)
+
+const cleanerProfileColumns = "cleaner_id, service_ids, latitude, longitude, max_distance_km, active, created_at, updated_at"
+
+// UpsertCleanerProfile creates a cleaner profile or replaces the existing one
+func (svc postgresClient) UpsertCleanerProfile(ctx context.Context, profile domain.CleanerProfile) (*domain.CleanerProfile, error) {
+	ctx, end := svc.startQuery(ctx, "upsert", svc.cleanerProfileTablename)
+	defer end()
+
+	query := fmt.Sprintf(`
+        INSERT INTO %s (%s)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        ON CONFLICT (cleaner_id) DO UPDATE
+        SET service_ids = $2, latitude = $3, longitude = $4, max_distance_km = $5, active = $6, updated_at = $8
+    `, svc.cleanerProfileTablename, cleanerProfileColumns)
+
+	_, err := svc.conn(ctx).ExecContext(ctx, query,
+		profile.CleanerId,
+		stringArray(profile.ServiceIds),
+		profile.Latitude,
+		profile.Longitude,
+		profile.MaxDistanceKm,
+		profile.Active,
+		profile.CreatedAt,
+		profile.UpdatedAt,
+	)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return svc.GetCleanerProfile(ctx, profile.CleanerId)
+}
+
+func (svc postgresClient) GetCleanerProfile(ctx context.Context, cleanerId string) (*domain.CleanerProfile, error) {
+	ctx, end := svc.startQuery(ctx, "select", svc.cleanerProfileTablename)
+	defer end()
+
+	query := fmt.Sprintf(`
+        SELECT %s
+        FROM %s
+        WHERE cleaner_id = $1
+    `, cleanerProfileColumns, svc.cleanerProfileTablename)
+
+	profile, err := scanCleanerProfile(svc.conn(ctx).QueryRowContext(ctx, query, cleanerId))
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &profile, nil
+}
+
+// GetActiveCleanersForService retrieves the active cleaners offering a service
+func (svc postgresClient) GetActiveCleanersForService(ctx context.Context, serviceId string) (*[]domain.CleanerProfile, error) {
+	ctx, end := svc.startQuery(ctx, "select", svc.cleanerProfileTablename)
+	defer end()
+
+	query := fmt.Sprintf(`
+        SELECT %s
+        FROM %s
+        WHERE active AND service_ids @> ARRAY[$1]::TEXT[]
+        ORDER BY cleaner_id
+    `, cleanerProfileColumns, svc.cleanerProfileTablename)
+
+	rows, err := svc.conn(ctx).QueryContext(ctx, query, serviceId)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	profiles := []domain.CleanerProfile{}
+	for rows.Next() {
+		profile, err := scanCleanerProfile(rows)
+		if err != nil {
+			return nil, mapError(err)
+		}
+		profiles = append(profiles, profile)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, mapError(err)
+	}
+	return &profiles, nil
+}
+
+func scanCleanerProfile(row rowScanner) (domain.CleanerProfile, error) {
+	var profile domain.CleanerProfile
+	err := row.Scan(
+		&profile.CleanerId,
+		pq.Array(&profile.ServiceIds),
+		&profile.Latitude,
+		&profile.Longitude,
+		&profile.MaxDistanceKm,
+		&profile.Active,
+		&profile.CreatedAt,
+		&profile.UpdatedAt,
+	)
+	return profile, err
+}
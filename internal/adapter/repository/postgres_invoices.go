@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+)
+
+const invoiceColumns = "invoice_id, invoice_number, sequence, request_id, client_id, cleaner_id, currency, lines, subtotal_minor, tax_rate_bps, tax_minor, total_minor, issued_at, created_at"
+
+// CreateInvoice stores an invoice under the next invoice number. Numbers are taken under a
+// transaction scoped lock so they stay gapless.
+func (svc postgresClient) CreateInvoice(ctx context.Context, invoice domain.Invoice) (*domain.Invoice, error) {
+	ctx, end := svc.startQuery(ctx, "insert", svc.invoiceTablename)
+	defer end()
+
+	err := svc.Do(ctx, func(ctx context.Context) error {
+		tx := svc.conn(ctx)
+		if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", svc.invoiceTablename); err != nil {
+			return mapError(err)
+		}
+		sequenceQuery := fmt.Sprintf("SELECT COALESCE(MAX(sequence), 0) + 1 FROM %s", svc.invoiceTablename)
+		if err := tx.QueryRowContext(ctx, sequenceQuery).Scan(&invoice.Sequence); err != nil {
+			return mapError(err)
+		}
+		invoice.InvoiceNumber = domain.FormatInvoiceNumber(invoice.Sequence)
+
+		query := fmt.Sprintf(`
+            INSERT INTO %s (%s)
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+        `, svc.invoiceTablename, invoiceColumns)
+
+		_, err := tx.ExecContext(ctx, query,
+			invoice.InvoiceId,
+			invoice.InvoiceNumber,
+			invoice.Sequence,
+			invoice.RequestId,
+			invoice.ClientId,
+			invoice.CleanerId,
+			invoice.Currency,
+			jsonb(invoice.Lines),
+			invoice.Subtotal.Amount,
+			invoice.TaxRateBps,
+			invoice.Tax.Amount,
+			invoice.Total.Amount,
+			invoice.IssuedAt,
+			invoice.CreatedAt,
+		)
+		return mapError(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return svc.GetInvoiceById(ctx, invoice.InvoiceId)
+}
+
+func (svc postgresClient) GetInvoiceById(ctx context.Context, invoiceId string) (*domain.Invoice, error) {
+	ctx, end := svc.startQuery(ctx, "select", svc.invoiceTablename)
+	defer end()
+
+	query := fmt.Sprintf(`
+        SELECT %s
+        FROM %s
+        WHERE invoice_id = $1
+    `, invoiceColumns, svc.invoiceTablename)
+
+	invoice, err := scanInvoice(svc.conn(ctx).QueryRowContext(ctx, query, invoiceId))
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &invoice, nil
+}
+
+func (svc postgresClient) GetInvoiceByRequest(ctx context.Context, requestId string) (*domain.Invoice, error) {
+	ctx, end := svc.startQuery(ctx, "select", svc.invoiceTablename)
+	defer end()
+
+	query := fmt.Sprintf(`
+        SELECT %s
+        FROM %s
+        WHERE request_id = $1
+    `, invoiceColumns, svc.invoiceTablename)
+
+	invoice, err := scanInvoice(svc.conn(ctx).QueryRowContext(ctx, query, requestId))
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &invoice, nil
+}
+
+// GetInvoicesByClient retrieves a page of a client's invoices
+func (svc postgresClient) GetInvoicesByClient(ctx context.Context, clientId string, filter domain.InvoiceFilter) (*domain.Page[domain.Invoice], error) {
+	ctx, end := svc.startQuery(ctx, "select", svc.invoiceTablename)
+	defer end()
+
+	query, err := newListQuery(invoiceSortFields, filter.ListOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	var where whereClause
+	where.add("client_id = ?", clientId)
+	return listPage(ctx, svc.conn(ctx), svc.invoiceTablename, invoiceColumns, "invoice_id", where, query, scanInvoice, invoiceIdOf)
+}
+
+func scanInvoice(row rowScanner) (domain.Invoice, error) {
+	var invoice domain.Invoice
+	err := row.Scan(
+		&invoice.InvoiceId,
+		&invoice.InvoiceNumber,
+		&invoice.Sequence,
+		&invoice.RequestId,
+		&invoice.ClientId,
+		&invoice.CleanerId,
+		&invoice.Currency,
+		jsonb(&invoice.Lines),
+		&invoice.Subtotal.Amount,
+		&invoice.TaxRateBps,
+		&invoice.Tax.Amount,
+		&invoice.Total.Amount,
+		&invoice.IssuedAt,
+		&invoice.CreatedAt,
+	)
+	invoice.Subtotal.Currency = invoice.Currency
+	invoice.Tax.Currency = invoice.Currency
+	invoice.Total.Currency = invoice.Currency
+	return invoice, err
+}
+
+func invoiceIdOf(invoice domain.Invoice) string { return invoice.InvoiceId }
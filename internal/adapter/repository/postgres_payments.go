@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+)
+
+const paymentColumns = "payment_id, request_id, invoice_id, reference, client_id, amount_minor, currency, phone_number, provider, provider_reference, receipt, status, failure_reason, created_at, updated_at"
+
+func (svc postgresClient) CreatePayment(ctx context.Context, payment domain.Payment) (*domain.Payment, error) {
+	ctx, end := svc.startQuery(ctx, "insert", svc.paymentTablename)
+	defer end()
+
+	query := fmt.Sprintf(`
+        INSERT INTO %s (%s)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+    `, svc.paymentTablename, paymentColumns)
+
+	_, err := svc.conn(ctx).ExecContext(ctx, query,
+		payment.PaymentId,
+		payment.RequestId,
+		payment.InvoiceId,
+		payment.Reference,
+		payment.ClientId,
+		payment.Amount.Amount,
+		payment.Amount.Currency,
+		payment.PhoneNumber,
+		payment.Provider,
+		payment.ProviderReference,
+		payment.Receipt,
+		payment.Status,
+		payment.FailureReason,
+		payment.CreatedAt,
+		payment.UpdatedAt,
+	)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return svc.GetPaymentById(ctx, payment.PaymentId)
+}
+
+func (svc postgresClient) GetPaymentById(ctx context.Context, paymentId string) (*domain.Payment, error) {
+	ctx, end := svc.startQuery(ctx, "select", svc.paymentTablename)
+	defer end()
+
+	query := fmt.Sprintf(`
+        SELECT %s
+        FROM %s
+        WHERE payment_id = $1
+    `, paymentColumns, svc.paymentTablename)
+
+	payment, err := scanPayment(svc.conn(ctx).QueryRowContext(ctx, query, paymentId))
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &payment, nil
+}
+
+func (svc postgresClient) GetPaymentByReference(ctx context.Context, provider, reference string) (*domain.Payment, error) {
+	ctx, end := svc.startQuery(ctx, "select", svc.paymentTablename)
+	defer end()
+
+	query := fmt.Sprintf(`
+        SELECT %s
+        FROM %s
+        WHERE provider = $1 AND provider_reference = $2
+    `, paymentColumns, svc.paymentTablename)
+
+	payment, err := scanPayment(svc.conn(ctx).QueryRowContext(ctx, query, provider, reference))
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &payment, nil
+}
+
+func (svc postgresClient) GetPaymentsByRequest(ctx context.Context, requestId string) (*[]domain.Payment, error) {
+	ctx, end := svc.startQuery(ctx, "select", svc.paymentTablename)
+	defer end()
+
+	query := fmt.Sprintf(`
+        SELECT %s
+        FROM %s
+        WHERE request_id = $1
+        ORDER BY created_at
+    `, paymentColumns, svc.paymentTablename)
+
+	rows, err := svc.conn(ctx).QueryContext(ctx, query, requestId)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	payments := []domain.Payment{}
+	for rows.Next() {
+		payment, err := scanPayment(rows)
+		if err != nil {
+			return nil, mapError(err)
+		}
+		payments = append(payments, payment)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, mapError(err)
+	}
+	return &payments, nil
+}
+
+func (svc postgresClient) UpdatePayment(ctx context.Context, payment domain.Payment) (*domain.Payment, error) {
+	ctx, end := svc.startQuery(ctx, "update", svc.paymentTablename)
+	defer end()
+
+	query := fmt.Sprintf(`
+        UPDATE %s
+        SET provider_reference = $2, receipt = $3, status = $4, failure_reason = $5, updated_at = $6
+        WHERE payment_id = $1 AND status = $7
+    `, svc.paymentTablename)
+
+	result, err := svc.conn(ctx).ExecContext(ctx, query,
+		payment.PaymentId,
+		payment.ProviderReference,
+		payment.Receipt,
+		payment.Status,
+		payment.FailureReason,
+		payment.UpdatedAt,
+		domain.PaymentPending,
+	)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	err = checkRowsAffected(result)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, fmt.Errorf("%w: payment %s", domain.ErrPaymentSettled, payment.PaymentId)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return svc.GetPaymentById(ctx, payment.PaymentId)
+}
+
+func scanPayment(row rowScanner) (domain.Payment, error) {
+	var payment domain.Payment
+	err := row.Scan(
+		&payment.PaymentId,
+		&payment.RequestId,
+		&payment.InvoiceId,
+		&payment.Reference,
+		&payment.ClientId,
+		&payment.Amount.Amount,
+		&payment.Amount.Currency,
+		&payment.PhoneNumber,
+		&payment.Provider,
+		&payment.ProviderReference,
+		&payment.Receipt,
+		&payment.Status,
+		&payment.FailureReason,
+		&payment.CreatedAt,
+		&payment.UpdatedAt,
+	)
+	return payment, err
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+)
+
+const ratingColumns = "rating_sum, rating_count, ones, twos, threes, fours, fives, punctuality_sum, punctuality_count, thoroughness_sum, thoroughness_count, communication_sum, communication_count"
+
+// AddRatings adds delta to the stored totals in a single statement, so concurrent reviews of the
+// same cleaner never overwrite each other's changes
+func (svc postgresClient) AddRatings(ctx context.Context, delta domain.RatingAggregate) error {
+	ctx, end := svc.startQuery(ctx, "upsert", svc.ratingTablename)
+	defer end()
+
+	query := fmt.Sprintf(`
+        INSERT INTO %[1]s (subject_type, subject_id, %[2]s)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+        ON CONFLICT (subject_type, subject_id) DO UPDATE
+        SET rating_sum = %[1]s.rating_sum + EXCLUDED.rating_sum,
+            rating_count = %[1]s.rating_count + EXCLUDED.rating_count,
+            ones = %[1]s.ones + EXCLUDED.ones,
+            twos = %[1]s.twos + EXCLUDED.twos,
+            threes = %[1]s.threes + EXCLUDED.threes,
+            fours = %[1]s.fours + EXCLUDED.fours,
+            fives = %[1]s.fives + EXCLUDED.fives,
+            punctuality_sum = %[1]s.punctuality_sum + EXCLUDED.punctuality_sum,
+            punctuality_count = %[1]s.punctuality_count + EXCLUDED.punctuality_count,
+            thoroughness_sum = %[1]s.thoroughness_sum + EXCLUDED.thoroughness_sum,
+            thoroughness_count = %[1]s.thoroughness_count + EXCLUDED.thoroughness_count,
+            communication_sum = %[1]s.communication_sum + EXCLUDED.communication_sum,
+            communication_count = %[1]s.communication_count + EXCLUDED.communication_count
+    `, svc.ratingTablename, ratingColumns)
+
+	_, err := svc.conn(ctx).ExecContext(ctx, query,
+		delta.SubjectType,
+		delta.SubjectId,
+		delta.Rating.Sum,
+		delta.Rating.Count,
+		delta.Histogram[0],
+		delta.Histogram[1],
+		delta.Histogram[2],
+		delta.Histogram[3],
+		delta.Histogram[4],
+		delta.Punctuality.Sum,
+		delta.Punctuality.Count,
+		delta.Thoroughness.Sum,
+		delta.Thoroughness.Count,
+		delta.Communication.Sum,
+		delta.Communication.Count,
+	)
+	return mapError(err)
+}
+
+func (svc postgresClient) GetRatingAggregate(ctx context.Context, subjectType domain.RatingSubject, subjectId string) (*domain.RatingAggregate, error) {
+	ctx, end := svc.startQuery(ctx, "select", svc.ratingTablename)
+	defer end()
+
+	query := fmt.Sprintf(`
+        SELECT %s
+        FROM %s
+        WHERE subject_type = $1 AND subject_id = $2
+    `, ratingColumns, svc.ratingTablename)
+
+	aggregate := domain.RatingAggregate{SubjectType: subjectType, SubjectId: subjectId}
+	err := svc.conn(ctx).QueryRowContext(ctx, query, subjectType, subjectId).Scan(
+		&aggregate.Rating.Sum,
+		&aggregate.Rating.Count,
+		&aggregate.Histogram[0],
+		&aggregate.Histogram[1],
+		&aggregate.Histogram[2],
+		&aggregate.Histogram[3],
+		&aggregate.Histogram[4],
+		&aggregate.Punctuality.Sum,
+		&aggregate.Punctuality.Count,
+		&aggregate.Thoroughness.Sum,
+		&aggregate.Thoroughness.Count,
+		&aggregate.Communication.Sum,
+		&aggregate.Communication.Count,
+	)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, mapError(err)
+	}
+	return &aggregate, nil
+}
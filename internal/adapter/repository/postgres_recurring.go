@@ -0,0 +1,232 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+	"github.com/lib/pq"
+)
+
+const recurringBookingColumns = "recurring_booking_id, client_id, cleaner_id, service_id, starts_at, timezone, rule, latitude, longitude, add_ons, discount_code, exceptions, status, generated_until, cancelled_at, created_at, updated_at"
+
+func (svc postgresClient) CreateRecurringBooking(ctx context.Context, booking domain.RecurringBooking) (*domain.RecurringBooking, error) {
+	ctx, end := svc.startQuery(ctx, "insert", svc.recurringTablename)
+	defer end()
+
+	query := fmt.Sprintf(`
+        INSERT INTO %s (%s)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+    `, svc.recurringTablename, recurringBookingColumns)
+
+	_, err := svc.conn(ctx).ExecContext(ctx, query,
+		booking.RecurringBookingId,
+		booking.ClientId,
+		booking.CleanerId,
+		booking.ServiceId,
+		booking.StartsAt,
+		booking.StartsAt.Location().String(),
+		booking.Rule.String(),
+		booking.Latitude,
+		booking.Longitude,
+		stringArray(booking.AddOns),
+		booking.DiscountCode,
+		jsonb(exceptionsOf(booking)),
+		booking.Status,
+		booking.GeneratedUntil,
+		booking.CancelledAt,
+		booking.CreatedAt,
+		booking.UpdatedAt,
+	)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return svc.GetRecurringBookingById(ctx, booking.RecurringBookingId)
+}
+
+func (svc postgresClient) GetRecurringBookingById(ctx context.Context, recurringBookingId string) (*domain.RecurringBooking, error) {
+	ctx, end := svc.startQuery(ctx, "select", svc.recurringTablename)
+	defer end()
+
+	query := fmt.Sprintf(`
+        SELECT %s
+        FROM %s
+        WHERE recurring_booking_id = $1
+    `, recurringBookingColumns, svc.recurringTablename)
+
+	booking, err := scanRecurringBooking(svc.conn(ctx).QueryRowContext(ctx, query, recurringBookingId))
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &booking, nil
+}
+
+func (svc postgresClient) LockRecurringBookingById(ctx context.Context, recurringBookingId string) (*domain.RecurringBooking, error) {
+	ctx, end := svc.startQuery(ctx, "select", svc.recurringTablename)
+	defer end()
+
+	query := fmt.Sprintf(`
+        SELECT %s
+        FROM %s
+        WHERE recurring_booking_id = $1
+        FOR UPDATE
+    `, recurringBookingColumns, svc.recurringTablename)
+
+	booking, err := scanRecurringBooking(svc.conn(ctx).QueryRowContext(ctx, query, recurringBookingId))
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &booking, nil
+}
+
+func (svc postgresClient) GetRecurringBookingsByClient(ctx context.Context, clientId string) (*[]domain.RecurringBooking, error) {
+	query := fmt.Sprintf(`
+        SELECT %s
+        FROM %s
+        WHERE client_id = $1
+        ORDER BY created_at, recurring_booking_id
+    `, recurringBookingColumns, svc.recurringTablename)
+
+	return svc.queryRecurringBookings(ctx, query, clientId)
+}
+
+func (svc postgresClient) GetActiveRecurringBookings(ctx context.Context, until time.Time) (*[]domain.RecurringBooking, error) {
+	query := fmt.Sprintf(`
+        SELECT %s
+        FROM %s
+        WHERE status = $1 AND generated_until < $2
+        ORDER BY created_at, recurring_booking_id
+    `, recurringBookingColumns, svc.recurringTablename)
+
+	return svc.queryRecurringBookings(ctx, query, domain.RecurringActive, until)
+}
+
+func (svc postgresClient) queryRecurringBookings(ctx context.Context, query string, args ...interface{}) (*[]domain.RecurringBooking, error) {
+	ctx, end := svc.startQuery(ctx, "select", svc.recurringTablename)
+	defer end()
+
+	rows, err := svc.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	bookings := []domain.RecurringBooking{}
+	for rows.Next() {
+		booking, err := scanRecurringBooking(rows)
+		if err != nil {
+			return nil, mapError(err)
+		}
+		bookings = append(bookings, booking)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, mapError(err)
+	}
+	return &bookings, nil
+}
+
+func (svc postgresClient) UpdateRecurringBooking(ctx context.Context, booking domain.RecurringBooking) (*domain.RecurringBooking, error) {
+	ctx, end := svc.startQuery(ctx, "update", svc.recurringTablename)
+	defer end()
+
+	query := fmt.Sprintf(`
+        UPDATE %s
+        SET cleaner_id = $2, latitude = $3, longitude = $4, add_ons = $5, discount_code = $6, exceptions = $7, status = $8, generated_until = $9, cancelled_at = $10, updated_at = $11
+        WHERE recurring_booking_id = $1
+    `, svc.recurringTablename)
+
+	result, err := svc.conn(ctx).ExecContext(ctx, query,
+		booking.RecurringBookingId,
+		booking.CleanerId,
+		booking.Latitude,
+		booking.Longitude,
+		stringArray(booking.AddOns),
+		booking.DiscountCode,
+		jsonb(exceptionsOf(booking)),
+		booking.Status,
+		booking.GeneratedUntil,
+		booking.CancelledAt,
+		booking.UpdatedAt,
+	)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	if err := checkRowsAffected(result); err != nil {
+		return nil, err
+	}
+	return svc.GetRecurringBookingById(ctx, booking.RecurringBookingId)
+}
+
+// SetGeneratedUntil only writes generated_until, so a skip or a cancellation made while the
+// generator ran is never overwritten
+func (svc postgresClient) SetGeneratedUntil(ctx context.Context, recurringBookingId string, generatedUntil, updatedAt time.Time) error {
+	ctx, end := svc.startQuery(ctx, "update", svc.recurringTablename)
+	defer end()
+
+	query := fmt.Sprintf(`
+        UPDATE %s
+        SET generated_until = $2, updated_at = $3
+        WHERE recurring_booking_id = $1 AND status = $4
+    `, svc.recurringTablename)
+
+	result, err := svc.conn(ctx).ExecContext(ctx, query, recurringBookingId, generatedUntil, updatedAt, domain.RecurringActive)
+	if err != nil {
+		return mapError(err)
+	}
+	err = checkRowsAffected(result)
+	if errors.Is(err, domain.ErrNotFound) {
+		return inactiveRecurringBooking(recurringBookingId)
+	}
+	return err
+}
+
+// inactiveRecurringBooking is returned when the series to generate was cancelled or removed meanwhile
+func inactiveRecurringBooking(recurringBookingId string) error {
+	return fmt.Errorf("%w: recurring booking %s is no longer active", domain.ErrInvalidTransition, recurringBookingId)
+}
+
+// scanRecurringBooking puts starts_at back in the location the series was booked in, the
+// occurrences keep its time of day there
+func scanRecurringBooking(row rowScanner) (domain.RecurringBooking, error) {
+	var booking domain.RecurringBooking
+	var timezone, rule string
+	err := row.Scan(
+		&booking.RecurringBookingId,
+		&booking.ClientId,
+		&booking.CleanerId,
+		&booking.ServiceId,
+		&booking.StartsAt,
+		&timezone,
+		&rule,
+		&booking.Latitude,
+		&booking.Longitude,
+		pq.Array(&booking.AddOns),
+		&booking.DiscountCode,
+		jsonb(&booking.Exceptions),
+		&booking.Status,
+		&booking.GeneratedUntil,
+		&booking.CancelledAt,
+		&booking.CreatedAt,
+		&booking.UpdatedAt,
+	)
+	if err != nil {
+		return booking, err
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return booking, err
+	}
+	booking.StartsAt = booking.StartsAt.In(location)
+	booking.Rule, err = domain.ParseRecurrenceRule(rule)
+	return booking, err
+}
+
+func exceptionsOf(booking domain.RecurringBooking) []domain.OccurrenceException {
+	if booking.Exceptions == nil {
+		return []domain.OccurrenceException{}
+	}
+	return booking.Exceptions
+}
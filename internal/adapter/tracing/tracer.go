@@ -0,0 +1,117 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/ports"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// propagator reads and writes W3C traceparent headers
+var propagator = propagation.TraceContext{}
+
+// Tracer starts spans through the OpenTelemetry SDK, which exports the finished ones in batches
+type Tracer struct {
+	provider *sdktrace.TracerProvider
+	tracer   trace.Tracer
+}
+
+// NewTracer exports spans through exporter under serviceName. Spans continuing a trace follow
+// the caller's sampling decision, new traces are always sampled. Errors the SDK runs into, such
+// as failed exports, are written to logger.
+func NewTracer(exporter sdktrace.SpanExporter, serviceName string, logger ports.LoggerService) *Tracer {
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		logger.Error(fmt.Sprintf("tracing: %v", err))
+	}))
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.AlwaysSample())),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	)
+	return &Tracer{provider: provider, tracer: provider.Tracer(serviceName)}
+}
+
+// Start begins an internal span, the child of the span ctx carries
+func (t *Tracer) Start(ctx context.Context, name string) (context.Context, ports.Span) {
+	ctx, span := t.tracer.Start(ctx, name)
+	return ctx, Span{span: span}
+}
+
+// StartServer begins the server span of an incoming request, continuing the caller's trace when
+// header carries a traceparent
+func (t *Tracer) StartServer(ctx context.Context, name string, header http.Header) (context.Context, Span) {
+	ctx = propagator.Extract(ctx, propagation.HeaderCarrier(header))
+	ctx, span := t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer))
+	return ctx, Span{span: span}
+}
+
+// Shutdown exports the spans still waiting in the batch
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	return t.provider.Shutdown(ctx)
+}
+
+// Inject adds the traceparent of the span ctx carries to an outgoing request's headers
+func Inject(ctx context.Context, header http.Header) {
+	propagator.Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// Span is one timed operation of a trace
+type Span struct {
+	span trace.Span
+}
+
+func (s Span) SetAttribute(key string, value interface{}) {
+	switch v := value.(type) {
+	case string:
+		s.span.SetAttributes(attribute.String(key, v))
+	case bool:
+		s.span.SetAttributes(attribute.Bool(key, v))
+	case int:
+		s.span.SetAttributes(attribute.Int(key, v))
+	case int64:
+		s.span.SetAttributes(attribute.Int64(key, v))
+	case float64:
+		s.span.SetAttributes(attribute.Float64(key, v))
+	default:
+		s.span.SetAttributes(attribute.String(key, fmt.Sprint(v)))
+	}
+}
+
+func (s Span) RecordError(err error) {
+	if err == nil {
+		return
+	}
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+func (s Span) End() {
+	s.span.End()
+}
+
+// TraceId is the hex id of the span's trace, for log lines
+func (s Span) TraceId() string {
+	return s.span.SpanContext().TraceID().String()
+}
+
+// NoopTracer is used when tracing is switched off
+type NoopTracer struct{}
+
+func (NoopTracer) Start(ctx context.Context, name string) (context.Context, ports.Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(key string, value interface{}) {}
+func (noopSpan) RecordError(err error)                      {}
+func (noopSpan) End()                                       {}
@@ -0,0 +1,180 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/ports"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+const testTraceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+// testLogger keeps the error lines it is given
+type testLogger struct {
+	mu     sync.Mutex
+	errors []string
+}
+
+func (l *testLogger) Debug(message string)   {}
+func (l *testLogger) Info(message string)    {}
+func (l *testLogger) Warning(message string) {}
+func (l *testLogger) Error(message string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.errors = append(l.errors, message)
+}
+func (l *testLogger) With(fields map[string]interface{}) ports.LoggerService { return l }
+func (l *testLogger) WithContext(ctx context.Context) ports.LoggerService    { return l }
+
+func TestSpansFormATrace(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tracer := NewTracer(exporter, "usafi-hub-cleaning-service", &testLogger{})
+
+	ctx, server := tracer.StartServer(context.Background(), "GET /requests/v1/:request_id", http.Header{"Traceparent": {testTraceParent}})
+	_, child := tracer.Start(ctx, "RequestService.GetRequestById")
+	child.End()
+	server.End()
+
+	_, root := tracer.Start(context.Background(), "worker")
+	root.End()
+	_, dropped := tracer.StartServer(context.Background(), "not sampled", http.Header{"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"}})
+	dropped.End()
+
+	// The in-memory exporter forgets its spans when it is shut down
+	if err := tracer.provider.ForceFlush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer tracer.Shutdown(context.Background())
+
+	spans := map[string]tracetest.SpanStub{}
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+	if len(spans) != 3 {
+		t.Fatalf("expected the three sampled spans exported, got %d", len(spans))
+	}
+	if _, ok := spans["not sampled"]; ok {
+		t.Error("expected the unsampled span not to be exported")
+	}
+
+	exportedServer := spans["GET /requests/v1/:request_id"]
+	if server.TraceId() != "4bf92f3577b34da6a3ce929d0e0e4736" || exportedServer.Parent.SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("expected the server span to continue the caller's trace, got %+v", exportedServer.SpanContext)
+	}
+	exportedChild := spans["RequestService.GetRequestById"]
+	if exportedChild.SpanContext.TraceID() != exportedServer.SpanContext.TraceID() || exportedChild.Parent.SpanID() != exportedServer.SpanContext.SpanID() {
+		t.Errorf("expected the internal span to be the server span's child, got %+v", exportedChild.Parent)
+	}
+	exportedRoot := spans["worker"]
+	if exportedRoot.SpanContext.TraceID() == exportedServer.SpanContext.TraceID() || exportedRoot.Parent.IsValid() {
+		t.Errorf("expected a span without a parent to start a trace of its own, got %+v", exportedRoot.SpanContext)
+	}
+}
+
+func TestInject(t *testing.T) {
+	tracer := NewTracer(tracetest.NewInMemoryExporter(), "usafi-hub-cleaning-service", &testLogger{})
+	defer tracer.Shutdown(context.Background())
+
+	header := http.Header{}
+	Inject(context.Background(), header)
+	if header.Get("traceparent") != "" {
+		t.Error("expected no traceparent without a span")
+	}
+
+	ctx, span := tracer.Start(context.Background(), "mpesa.stk_push")
+	Inject(ctx, header)
+	if !strings.HasPrefix(header.Get("traceparent"), "00-"+span.(Span).TraceId()+"-") {
+		t.Errorf("expected the span's traceparent, got %q", header.Get("traceparent"))
+	}
+}
+
+func TestOTLPExport(t *testing.T) {
+	received := make(chan *coltracepb.ExportTraceServiceRequest, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		request := &coltracepb.ExportTraceServiceRequest{}
+		if err := proto.Unmarshal(body, request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received <- request
+	}))
+	defer collector.Close()
+
+	exporter, err := NewOTLPExporter(collector.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracer := NewTracer(exporter, "usafi-hub-cleaning-service", &testLogger{})
+	ctx, parent := tracer.Start(context.Background(), "RequestService.CompleteRequest")
+	_, span := tracer.Start(ctx, "postgres.UpdateRequestStatus")
+	span.SetAttribute("db.table", "requests")
+	span.SetAttribute("db.rows", 1)
+	span.RecordError(errors.New("connection reset"))
+	span.End()
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	request := <-received
+	resource := request.ResourceSpans[0]
+	if attribute := resource.Resource.Attributes[0]; attribute.Key != "service.name" || attribute.Value.GetStringValue() != "usafi-hub-cleaning-service" {
+		t.Errorf("expected the service name on the resource, got %+v", resource.Resource)
+	}
+	spans := resource.ScopeSpans[0].Spans
+	if len(spans) != 1 {
+		t.Fatalf("expected the one ended span, got %d", len(spans))
+	}
+	exported := spans[0]
+	if hex.EncodeToString(exported.TraceId) != parent.(Span).TraceId() {
+		t.Errorf("expected the span in its parent's trace, got %x", exported.TraceId)
+	}
+	if exported.Name != "postgres.UpdateRequestStatus" || exported.Status.GetMessage() != "connection reset" {
+		t.Errorf("unexpected exported span %+v", exported)
+	}
+	attributes := map[string]*commonpb.AnyValue{}
+	for _, attribute := range exported.Attributes {
+		attributes[attribute.Key] = attribute.Value
+	}
+	if attributes["db.table"].GetStringValue() != "requests" || attributes["db.rows"].GetIntValue() != 1 {
+		t.Errorf("expected the attributes in their OTLP types, got %+v", attributes)
+	}
+}
+
+func TestExportFailuresAreLogged(t *testing.T) {
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer collector.Close()
+
+	exporter, err := NewOTLPExporter(collector.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := &testLogger{}
+	tracer := NewTracer(exporter, "usafi-hub-cleaning-service", logger)
+	_, span := tracer.Start(context.Background(), "worker")
+	span.End()
+	tracer.Shutdown(context.Background())
+
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	if len(logger.errors) == 0 || !strings.HasPrefix(logger.errors[0], "tracing: ") {
+		t.Errorf("expected the failed export logged, got %v", logger.errors)
+	}
+}
@@ -0,0 +1,69 @@
+package domain
+
+var ErrForbidden = newError(KindForbidden, "forbidden", "forbidden")
+
+type Role string
+
+const (
+	RoleAdmin      Role = "admin"
+	RoleDispatcher Role = "dispatcher"
+	RoleCleaner    Role = "cleaner"
+	RoleClient     Role = "client"
+)
+
+func (r Role) IsValid() bool {
+	switch r {
+	case RoleAdmin, RoleDispatcher, RoleCleaner, RoleClient:
+		return true
+	}
+	return false
+}
+
+// Principal is the authenticated caller, taken from the claims of their access token. Subject is
+// the caller's own id, their client id or cleaner id depending on the role.
+type Principal struct {
+	Subject string `json:"subject"`
+	Roles   []Role `json:"roles"`
+}
+
+func (p Principal) HasRole(roles ...Role) bool {
+	for _, held := range p.Roles {
+		for _, role := range roles {
+			if held == role {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsStaff reports whether the caller works for the business and may act on anyone's behalf
+func (p Principal) IsStaff() bool {
+	return p.HasRole(RoleAdmin, RoleDispatcher)
+}
+
+// CanActAsClient reports whether the caller is client_id or staff
+func (p Principal) CanActAsClient(client_id string) bool {
+	return p.IsStaff() || (p.HasRole(RoleClient) && p.Subject != "" && p.Subject == client_id)
+}
+
+// CanActAsCleaner reports whether the caller is cleaner_id or staff
+func (p Principal) CanActAsCleaner(cleaner_id string) bool {
+	return p.IsStaff() || (p.HasRole(RoleCleaner) && p.Subject != "" && p.Subject == cleaner_id)
+}
+
+// CanViewRequest allows staff, the client who booked the request and the cleaner assigned to it
+func (p Principal) CanViewRequest(request Request) bool {
+	return p.CanActAsClient(request.ClientId) || p.CanActAsCleaner(request.CleanerId)
+}
+
+// CanViewReview allows staff, the client who wrote the review and, once it is live, the cleaner it
+// is about
+func (p Principal) CanViewReview(review Reviews) bool {
+	return p.IsStaff() || p.CanActAsClient(review.ClientId) || (review.Status.IsLive() && p.CanActAsCleaner(review.CleanerId))
+}
+
+// CanEditReview allows the client who wrote the review and admins, dispatchers do not edit reviews
+func (p Principal) CanEditReview(review Reviews) bool {
+	return p.HasRole(RoleAdmin) || (p.HasRole(RoleClient) && p.Subject != "" && p.Subject == review.ClientId)
+}
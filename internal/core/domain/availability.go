@@ -0,0 +1,228 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	DateLayout = "2006-01-02"
+
+	// DefaultDurationMinutes is used for services created without a duration
+	DefaultDurationMinutes = 120
+)
+
+var (
+	ErrInvalidInput       = newError(KindValidation, "invalid_input", "invalid input")
+	ErrCleanerUnavailable = newError(KindConflict, "cleaner_unavailable", "cleaner is not available")
+)
+
+// WorkingHours is one weekly working window of a cleaner, in the service's local time.
+type WorkingHours struct {
+	CleanerId string       `json:"cleaner_id"`
+	Weekday   time.Weekday `json:"weekday"`
+	StartTime string       `json:"start_time"`
+	EndTime   string       `json:"end_time"`
+}
+
+// AvailabilityException overrides the weekly hours on a single date. An available exception
+// replaces that day's hours with its own window, an unavailable one blocks its window, or the
+// whole day when no times are given.
+type AvailabilityException struct {
+	ExceptionId string    `json:"exception_id"`
+	CleanerId   string    `json:"cleaner_id"`
+	Date        string    `json:"date"`
+	StartTime   string    `json:"start_time"`
+	EndTime     string    `json:"end_time"`
+	Available   bool      `json:"available"`
+	Reason      string    `json:"reason"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type TimeOff struct {
+	TimeOffId string    `json:"time_off_id"`
+	CleanerId string    `json:"cleaner_id"`
+	StartsAt  time.Time `json:"starts_at"`
+	EndsAt    time.Time `json:"ends_at"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Slot struct {
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"`
+}
+
+func (s Slot) Overlaps(other Slot) bool {
+	return s.StartsAt.Before(other.EndsAt) && other.StartsAt.Before(s.EndsAt)
+}
+
+// ParseClock turns an "HH:MM" string into minutes after midnight
+func ParseClock(clock string) (int, error) {
+	parsed, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q is not a HH:MM time", ErrInvalidInput, clock)
+	}
+	return parsed.Hour()*60 + parsed.Minute(), nil
+}
+
+// clockWindow returns the slot between two "HH:MM" times on the given day
+func clockWindow(day time.Time, startTime, endTime string) (Slot, error) {
+	start, err := ParseClock(startTime)
+	if err != nil {
+		return Slot{}, err
+	}
+	end, err := ParseClock(endTime)
+	if err != nil {
+		return Slot{}, err
+	}
+	if end <= start {
+		return Slot{}, fmt.Errorf("%w: end time %s must be after start time %s", ErrInvalidInput, endTime, startTime)
+	}
+	return Slot{
+		StartsAt: day.Add(time.Duration(start) * time.Minute),
+		EndsAt:   day.Add(time.Duration(end) * time.Minute),
+	}, nil
+}
+
+func (h WorkingHours) Validate() error {
+	if h.Weekday < time.Sunday || h.Weekday > time.Saturday {
+		return fmt.Errorf("%w: weekday must be between 0 (Sunday) and 6 (Saturday)", ErrInvalidInput)
+	}
+	_, err := clockWindow(time.Time{}, h.StartTime, h.EndTime)
+	return err
+}
+
+func (e AvailabilityException) Validate() error {
+	if _, err := time.Parse(DateLayout, e.Date); err != nil {
+		return fmt.Errorf("%w: date must be formatted as %s", ErrInvalidInput, DateLayout)
+	}
+	if e.StartTime == "" && e.EndTime == "" {
+		if e.Available {
+			return fmt.Errorf("%w: an available exception needs a start and end time", ErrInvalidInput)
+		}
+		return nil
+	}
+	_, err := clockWindow(time.Time{}, e.StartTime, e.EndTime)
+	return err
+}
+
+func (t TimeOff) Validate() error {
+	if !t.EndsAt.After(t.StartsAt) {
+		return fmt.Errorf("%w: time off must end after it starts", ErrInvalidInput)
+	}
+	return nil
+}
+
+// DaySchedule gathers everything that decides when a cleaner can work on one day.
+type DaySchedule struct {
+	Day          time.Time
+	WorkingHours []WorkingHours
+	Exceptions   []AvailabilityException
+	TimeOff      []TimeOff
+	Bookings     []Slot
+}
+
+// FreeWindows returns the parts of the day the cleaner works and has nothing booked, in order.
+func (d DaySchedule) FreeWindows() ([]Slot, error) {
+	day := time.Date(d.Day.Year(), d.Day.Month(), d.Day.Day(), 0, 0, 0, 0, d.Day.Location())
+	date := day.Format(DateLayout)
+
+	var windows, blocked []Slot
+	overridden := false
+	for _, exception := range d.Exceptions {
+		if exception.Date != date {
+			continue
+		}
+		if exception.StartTime == "" && exception.EndTime == "" {
+			blocked = append(blocked, Slot{StartsAt: day, EndsAt: day.AddDate(0, 0, 1)})
+			continue
+		}
+		window, err := clockWindow(day, exception.StartTime, exception.EndTime)
+		if err != nil {
+			return nil, err
+		}
+		if exception.Available {
+			if !overridden {
+				windows, overridden = nil, true
+			}
+			windows = append(windows, window)
+		} else {
+			blocked = append(blocked, window)
+		}
+	}
+
+	if !overridden {
+		for _, hours := range d.WorkingHours {
+			if hours.Weekday != day.Weekday() {
+				continue
+			}
+			window, err := clockWindow(day, hours.StartTime, hours.EndTime)
+			if err != nil {
+				return nil, err
+			}
+			windows = append(windows, window)
+		}
+	}
+
+	for _, timeOff := range d.TimeOff {
+		blocked = append(blocked, Slot{StartsAt: timeOff.StartsAt, EndsAt: timeOff.EndsAt})
+	}
+	blocked = append(blocked, d.Bookings...)
+
+	for _, block := range blocked {
+		windows = subtractSlot(windows, block)
+	}
+	sort.Slice(windows, func(i, j int) bool {
+		return windows[i].StartsAt.Before(windows[j].StartsAt)
+	})
+	return windows, nil
+}
+
+// Slots splits the free windows into bookable slots of the given length, starting every step.
+func (d DaySchedule) Slots(length, step time.Duration) ([]Slot, error) {
+	windows, err := d.FreeWindows()
+	if err != nil {
+		return nil, err
+	}
+
+	slots := []Slot{}
+	for _, window := range windows {
+		for start := window.StartsAt; !start.Add(length).After(window.EndsAt); start = start.Add(step) {
+			slots = append(slots, Slot{StartsAt: start, EndsAt: start.Add(length)})
+		}
+	}
+	return slots, nil
+}
+
+// Fits reports whether the slot lies entirely within one free window.
+func (d DaySchedule) Fits(slot Slot) (bool, error) {
+	windows, err := d.FreeWindows()
+	if err != nil {
+		return false, err
+	}
+	for _, window := range windows {
+		if !slot.StartsAt.Before(window.StartsAt) && !slot.EndsAt.After(window.EndsAt) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func subtractSlot(windows []Slot, block Slot) []Slot {
+	result := []Slot{}
+	for _, window := range windows {
+		if !window.Overlaps(block) {
+			result = append(result, window)
+			continue
+		}
+		if window.StartsAt.Before(block.StartsAt) {
+			result = append(result, Slot{StartsAt: window.StartsAt, EndsAt: block.StartsAt})
+		}
+		if block.EndsAt.Before(window.EndsAt) {
+			result = append(result, Slot{StartsAt: block.EndsAt, EndsAt: window.EndsAt})
+		}
+	}
+	return result
+}
@@ -0,0 +1,198 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// ChangeInitiator is who asked for a booking change, it decides which part of the policy applies
+type ChangeInitiator string
+
+const (
+	InitiatorClient  ChangeInitiator = "client"
+	InitiatorCleaner ChangeInitiator = "cleaner"
+	InitiatorStaff   ChangeInitiator = "staff"
+)
+
+type BookingChangeKind string
+
+const (
+	ChangeCancelled   BookingChangeKind = "cancelled"
+	ChangeRescheduled BookingChangeKind = "rescheduled"
+	// ChangeReleased is a cleaner pulling out of a job, which goes back to dispatch
+	ChangeReleased BookingChangeKind = "released"
+)
+
+const (
+	QuoteLineFee = "fee"
+
+	MaxChangeReasonLength = 500
+)
+
+// CancellationPolicy prices cancelling and rescheduling a booking. Changes made at least the free
+// window before the booking starts cost nothing, later ones cost a share of the booking's total.
+// Cleaners pulling out never cost the client anything.
+type CancellationPolicy struct {
+	FreeCancellationWindow time.Duration
+	LateCancellationFeeBps int64
+	FreeRescheduleWindow   time.Duration
+	LateRescheduleFeeBps   int64
+}
+
+// BookingChange records a cancellation, reschedule or release of a request, who made it and why
+type BookingChange struct {
+	Kind      BookingChangeKind `json:"kind"`
+	Initiator ChangeInitiator   `json:"initiator"`
+	By        string            `json:"by"`
+	Reason    string            `json:"reason"`
+	// PreviousDate and RequestedDate are the booking's start before and after a reschedule
+	PreviousDate  *time.Time `json:"previous_date,omitempty"`
+	RequestedDate *time.Time `json:"requested_date,omitempty"`
+	// PreviousCleanerId is the cleaner who released the job
+	PreviousCleanerId string `json:"previous_cleaner_id,omitempty"`
+	Fee               Money  `json:"fee"`
+	// FeeWaived is set when staff make a change free that the policy would charge for
+	FeeWaived bool      `json:"fee_waived"`
+	At        time.Time `json:"at"`
+}
+
+func (c BookingChange) Validate() error {
+	switch c.Initiator {
+	case InitiatorClient, InitiatorCleaner, InitiatorStaff:
+	default:
+		return fmt.Errorf("%w: unknown change initiator %q", ErrInvalidInput, c.Initiator)
+	}
+	if c.By == "" {
+		return fmt.Errorf("%w: a booking change needs to say who made it", ErrInvalidInput)
+	}
+	if len(c.Reason) > MaxChangeReasonLength {
+		return fmt.Errorf("%w: reason is longer than %d characters", ErrInvalidInput, MaxChangeReasonLength)
+	}
+	if c.FeeWaived && c.Initiator != InitiatorStaff {
+		return fmt.Errorf("%w: only staff can waive a fee", ErrForbidden)
+	}
+	return nil
+}
+
+// ChangeFee is what the policy charges for a change made at at to a booking starting at start and
+// priced at quote
+func (p CancellationPolicy) ChangeFee(quote Quote, kind BookingChangeKind, initiator ChangeInitiator, start, at time.Time) Money {
+	free := NewMoney(0, quote.Total.Currency)
+	if initiator == InitiatorCleaner {
+		return free
+	}
+
+	window, feeBps := p.FreeCancellationWindow, p.LateCancellationFeeBps
+	switch kind {
+	case ChangeRescheduled:
+		window, feeBps = p.FreeRescheduleWindow, p.LateRescheduleFeeBps
+	case ChangeReleased:
+		return free
+	}
+	if start.Sub(at) >= window {
+		return free
+	}
+	return quote.BookingTotal().Percent(feeBps)
+}
+
+// BookingTotal is the quote's total less the fees charged for changes, which fees are not taken on
+func (q Quote) BookingTotal() Money {
+	total := q.Total
+	for _, line := range q.Lines {
+		if line.Kind == QuoteLineFee {
+			total = total.Sub(line.Amount)
+		}
+	}
+	return total
+}
+
+// Cancel cancels the request and records the change. The request is kept, a cancelled booking
+// stays in the client's history.
+func (r *Request) Cancel(change BookingChange) error {
+	if err := r.Transition(RequestCancelled); err != nil {
+		return err
+	}
+	change.Kind = ChangeCancelled
+	r.Changes = append(r.Changes, change)
+	return nil
+}
+
+// Release takes the cleaner off a job they can no longer do and hands it back to dispatch
+func (r *Request) Release(change BookingChange) error {
+	if r.CleanerId == "" || (r.Status != RequestAssigned && r.Status != RequestEnRoute) {
+		return fmt.Errorf("%w: request %s is %s, only jobs a cleaner has not started can be released", ErrInvalidTransition, r.RequestId, r.Status)
+	}
+	change.Kind = ChangeReleased
+	change.PreviousCleanerId = r.CleanerId
+	r.CleanerId = ""
+	r.Status = RequestPending
+	r.Changes = append(r.Changes, change)
+	return nil
+}
+
+// Reschedule moves a booking nobody has set off for yet to requestedDate and records the change
+func (r *Request) Reschedule(requestedDate time.Time, change BookingChange) error {
+	if r.Status != RequestPending && r.Status != RequestAssigned {
+		return fmt.Errorf("%w: request %s is %s, only pending and assigned requests can be rescheduled", ErrInvalidTransition, r.RequestId, r.Status)
+	}
+	if !requestedDate.After(change.At) {
+		return fmt.Errorf("%w: a booking can only be moved to the future", ErrInvalidInput)
+	}
+	previous := r.RequestedDate
+	change.Kind = ChangeRescheduled
+	change.PreviousDate = &previous
+	change.RequestedDate = &requestedDate
+	r.RequestedDate = requestedDate
+	r.Changes = append(r.Changes, change)
+	return nil
+}
+
+// FeeLines are the quote lines for the fees charged on top of the booking by its reschedules
+func (r Request) FeeLines() []QuoteLine {
+	var lines []QuoteLine
+	for _, change := range r.Changes {
+		if change.Kind == ChangeRescheduled && !change.Fee.IsZero() {
+			lines = append(lines, QuoteLine{Kind: QuoteLineFee, Description: "Late reschedule fee", Amount: change.Fee})
+		}
+	}
+	return lines
+}
+
+// WithFees adds the request's reschedule fees to quote. The fees were charged in the currency the
+// booking was priced in then, so a quote in another currency returns ErrCurrencyMismatch.
+func (r Request) WithFees(quote *Quote) (*Quote, error) {
+	for _, line := range r.FeeLines() {
+		if err := quote.Total.CheckCurrency(line.Amount); err != nil {
+			return nil, err
+		}
+		quote.Lines = append(quote.Lines, line)
+		quote.Subtotal = quote.Subtotal.Add(line.Amount)
+		quote.Total = quote.Total.Add(line.Amount)
+	}
+	return quote, nil
+}
+
+// CancellationQuote is what a cancelled request is billed, its late cancellation fee. It is nil
+// when the request was not cancelled or cancelling it was free.
+func (r Request) CancellationQuote() *Quote {
+	if r.Status != RequestCancelled {
+		return nil
+	}
+	for i := len(r.Changes) - 1; i >= 0; i-- {
+		change := r.Changes[i]
+		if change.Kind != ChangeCancelled {
+			continue
+		}
+		if change.Fee.IsZero() {
+			return nil
+		}
+		return &Quote{
+			Lines:    []QuoteLine{{Kind: QuoteLineFee, Description: "Late cancellation fee", Amount: change.Fee}},
+			Subtotal: change.Fee,
+			Discount: NewMoney(0, change.Fee.Currency),
+			Total:    change.Fee,
+			PricedAt: change.At,
+		}
+	}
+	return nil
+}
@@ -1,14 +1,90 @@
 package domain
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 type Service struct {
-	ServiceId    string    `json:"service_id"`
-	Name         string    `json:"name"`
-	Description  string    `json:"description"`
-	PricePerHour float32       `json:"price_per_hour"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ServiceId   string  `json:"service_id"`
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	HourlyRate  Money   `json:"hourly_rate"`
+	AddOns      []AddOn `json:"add_ons"`
+	// DurationMinutes is how long a booking of this service keeps a cleaner busy
+	DurationMinutes int       `json:"duration_minutes"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+type RequestStatus string
+
+const (
+	RequestPending    RequestStatus = "pending"
+	RequestAssigned   RequestStatus = "assigned"
+	RequestEnRoute    RequestStatus = "en_route"
+	RequestInProgress RequestStatus = "in_progress"
+	RequestCompleted  RequestStatus = "completed"
+	RequestCancelled  RequestStatus = "cancelled"
+	RequestNoShow     RequestStatus = "no_show"
+)
+
+// requestTransitions lists, for every status, the statuses a request may move to next.
+var requestTransitions = map[RequestStatus][]RequestStatus{
+	RequestPending:    {RequestAssigned, RequestCancelled},
+	RequestAssigned:   {RequestEnRoute, RequestCancelled, RequestNoShow},
+	RequestEnRoute:    {RequestInProgress, RequestCancelled, RequestNoShow},
+	RequestInProgress: {RequestCompleted},
+	RequestCompleted:  {},
+	RequestCancelled:  {},
+	RequestNoShow:     {},
+}
+
+var (
+	ErrNotFound          = newError(KindNotFound, "not_found", "record not found")
+	ErrAlreadyExists     = newError(KindConflict, "already_exists", "record already exists")
+	ErrInvalidTransition = newError(KindConflict, "invalid_transition", "invalid request status transition")
+)
+
+type TransitionError struct {
+	From RequestStatus
+	To   RequestStatus
+}
+
+func (e TransitionError) Error() string {
+	return fmt.Sprintf("cannot move request from %q to %q", e.From, e.To)
+}
+
+func (e TransitionError) Unwrap() error {
+	return ErrInvalidTransition
+}
+
+func (s RequestStatus) IsValid() bool {
+	_, ok := requestTransitions[s]
+	return ok
+}
+
+// IsFinal reports whether no further transitions are possible from s.
+func (s RequestStatus) IsFinal() bool {
+	return s.IsValid() && len(requestTransitions[s]) == 0
+}
+
+func (s RequestStatus) CanTransitionTo(next RequestStatus) bool {
+	for _, status := range requestTransitions[s] {
+		if status == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Transition moves the request to next, returning a TransitionError when the lifecycle does not allow it.
+func (r *Request) Transition(next RequestStatus) error {
+	if !r.Status.CanTransitionTo(next) {
+		return TransitionError{From: r.Status, To: next}
+	}
+	r.Status = next
+	return nil
 }
 
 type Request struct {
@@ -17,18 +93,61 @@ type Request struct {
 	CleanerId     string    `json:"cleaner_id"`
 	ServiceId     string    `json:"service_id"`
 	RequestedDate time.Time `json:"requested_date"`
-	Status        string    `json:"status"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+	// DurationMinutes is copied from the service when the request is booked
+	DurationMinutes int      `json:"duration_minutes"`
+	Latitude        float64  `json:"latitude"`
+	Longitude       float64  `json:"longitude"`
+	AddOns          []string `json:"add_ons"`
+	DiscountCode    string   `json:"discount_code"`
+	// Quote is priced when the request is booked, so later rate changes leave it alone
+	Quote *Quote `json:"quote"`
+	// PaidAt is set once a payment for the request succeeds
+	PaidAt *time.Time `json:"paid_at"`
+	// RecurringBookingId and OccurrenceAt tie a request to the occurrence of a recurring booking it
+	// was generated for
+	RecurringBookingId string     `json:"recurring_booking_id,omitempty"`
+	OccurrenceAt       *time.Time `json:"occurrence_at,omitempty"`
+	// Changes are the cancellations, reschedules and releases made to the booking, oldest first
+	Changes []BookingChange `json:"changes"`
+	// CompletedAt is set when the request is completed, it opens the review window
+	CompletedAt *time.Time    `json:"completed_at"`
+	Status      RequestStatus `json:"status"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+}
+
+// Slot is the time the request keeps its cleaner busy
+func (r Request) Slot() Slot {
+	return Slot{
+		StartsAt: r.RequestedDate,
+		EndsAt:   r.RequestedDate.Add(time.Duration(r.DurationMinutes) * time.Minute),
+	}
+}
+
+// OccupiesCleaner reports whether the request still blocks its cleaner's calendar
+func (r Request) OccupiesCleaner() bool {
+	return r.CleanerId != "" && r.Status != RequestCancelled && r.Status != RequestNoShow
 }
 
 type Reviews struct {
-	ReviewId  string    `json:"review_id"`
-	RequestId string    `json:"request_id"`
-	ClientId  string    `json:"client_id"`
-	CleanerId string    `json:"cleaner_id"`
-	Rating    string    `json:"rating"`
-	Comment   string    `json:"comment"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ReviewId  string `json:"review_id"`
+	RequestId string `json:"request_id"`
+	ClientId  string `json:"client_id"`
+	CleanerId string `json:"cleaner_id"`
+	// ServiceId is taken from the reviewed request
+	ServiceId string `json:"service_id"`
+	// Rating is 1 to 5. Reviews left before ratings were numeric may have none, shown as 0.
+	Rating    int          `json:"rating"`
+	SubScores SubScores    `json:"sub_scores"`
+	Comment   string       `json:"comment"`
+	Status    ReviewStatus `json:"status"`
+	// ModerationNote says why the review was held, rejected or published by a moderator
+	ModerationNote string       `json:"moderation_note"`
+	ModeratedBy    string       `json:"moderated_by,omitempty"`
+	ModeratedAt    *time.Time   `json:"moderated_at"`
+	Flags          []ReviewFlag `json:"flags,omitempty"`
+	// Response is the reviewed cleaner's public reply
+	Response  *ReviewResponse `json:"response"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
 }
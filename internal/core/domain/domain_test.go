@@ -1 +1,500 @@
-package domain
\ No newline at end of file
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRequestTransition(t *testing.T) {
+	tests := []struct {
+		from RequestStatus
+		to   RequestStatus
+		ok   bool
+	}{
+		{RequestPending, RequestAssigned, true},
+		{RequestPending, RequestCancelled, true},
+		{RequestPending, RequestInProgress, false},
+		{RequestAssigned, RequestEnRoute, true},
+		{RequestAssigned, RequestNoShow, true},
+		{RequestEnRoute, RequestInProgress, true},
+		{RequestInProgress, RequestCompleted, true},
+		{RequestInProgress, RequestCancelled, false},
+		{RequestCompleted, RequestPending, false},
+		{RequestCancelled, RequestAssigned, false},
+		{RequestPending, RequestStatus("archived"), false},
+	}
+
+	for _, tt := range tests {
+		request := Request{Status: tt.from}
+		err := request.Transition(tt.to)
+		if tt.ok && err != nil {
+			t.Errorf("%s -> %s: unexpected error %v", tt.from, tt.to, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrInvalidTransition) {
+			t.Errorf("%s -> %s: expected ErrInvalidTransition, got %v", tt.from, tt.to, err)
+		}
+		if tt.ok && request.Status != tt.to {
+			t.Errorf("%s -> %s: status is %s", tt.from, tt.to, request.Status)
+		}
+	}
+}
+
+func TestPricingRulesQuote(t *testing.T) {
+	discounts, err := ParseDiscounts("welcome10=10%, FLAT500=500")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := PricingRules{
+		WeekendSurchargeBps:    2000,
+		AfterHoursSurchargeBps: 2500,
+		BusinessHoursStart:     "08:00",
+		BusinessHoursEnd:       "18:00",
+		Discounts:              discounts,
+	}
+	service := Service{
+		Name:       "Deep clean",
+		HourlyRate: NewMoney(100000, "KES"),
+		AddOns:     []AddOn{{Code: "oven", Name: "Inside the oven", Price: NewMoney(50000, "KES")}},
+	}
+	monday := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	saturday := time.Date(2026, 10, 24, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		start    time.Time
+		addOns   []string
+		discount string
+		total    int64
+		err      error
+	}{
+		{"weekday", monday.Add(10 * time.Hour), nil, "", 250000, nil},
+		{"add-on", monday.Add(10 * time.Hour), []string{"oven"}, "", 300000, nil},
+		{"weekend", saturday.Add(10 * time.Hour), []string{"oven"}, "", 360000, nil},
+		{"after hours", monday.Add(17 * time.Hour), nil, "", 312500, nil},
+		{"weekend after hours with percent discount", saturday.Add(17 * time.Hour), []string{"oven"}, "WELCOME10", 391500, nil},
+		{"fixed discount", monday.Add(10 * time.Hour), nil, "flat500", 200000, nil},
+		{"unknown add-on", monday.Add(10 * time.Hour), []string{"windows"}, "", 0, ErrInvalidInput},
+		{"unknown discount", monday.Add(10 * time.Hour), nil, "FREE", 0, ErrInvalidInput},
+	}
+
+	for _, tt := range tests {
+		request := Request{RequestedDate: tt.start, DurationMinutes: 150, AddOns: tt.addOns, DiscountCode: tt.discount}
+		quote, err := rules.Quote(service, request, tt.start)
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("%s: expected %v, got %v", tt.name, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if quote.Total != NewMoney(tt.total, "KES") {
+			t.Errorf("%s: expected a total of %d, got %s", tt.name, tt.total, quote.Total)
+		}
+	}
+}
+
+func TestPrincipalPolicies(t *testing.T) {
+	request := Request{ClientId: "client-1", CleanerId: "cleaner-1"}
+	review := Reviews{ClientId: "client-1", CleanerId: "cleaner-1", Status: ReviewPublished}
+
+	tests := []struct {
+		name       string
+		principal  Principal
+		viewJob    bool
+		workJob    bool
+		viewReview bool
+		editReview bool
+	}{
+		{"admin", Principal{Subject: "admin-1", Roles: []Role{RoleAdmin}}, true, true, true, true},
+		{"dispatcher", Principal{Subject: "dispatcher-1", Roles: []Role{RoleDispatcher}}, true, true, true, false},
+		{"owning client", Principal{Subject: "client-1", Roles: []Role{RoleClient}}, true, false, true, true},
+		{"other client", Principal{Subject: "client-2", Roles: []Role{RoleClient}}, false, false, false, false},
+		{"assigned cleaner", Principal{Subject: "cleaner-1", Roles: []Role{RoleCleaner}}, true, true, true, false},
+		{"other cleaner", Principal{Subject: "cleaner-2", Roles: []Role{RoleCleaner}}, false, false, false, false},
+		{"cleaner id without the role", Principal{Subject: "cleaner-1", Roles: []Role{RoleClient}}, false, false, false, false},
+		{"no roles", Principal{Subject: "client-1"}, false, false, false, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.principal.CanViewRequest(request); got != tt.viewJob {
+			t.Errorf("%s: CanViewRequest = %v", tt.name, got)
+		}
+		if got := tt.principal.CanActAsCleaner(request.CleanerId); got != tt.workJob {
+			t.Errorf("%s: CanActAsCleaner = %v", tt.name, got)
+		}
+		if got := tt.principal.CanViewReview(review); got != tt.viewReview {
+			t.Errorf("%s: CanViewReview = %v", tt.name, got)
+		}
+		if got := tt.principal.CanEditReview(review); got != tt.editReview {
+			t.Errorf("%s: CanEditReview = %v", tt.name, got)
+		}
+	}
+
+	if (Principal{Roles: []Role{RoleCleaner}}).CanViewRequest(Request{ClientId: "client-1"}) {
+		t.Error("expected a cleaner without a subject not to match an unassigned request")
+	}
+	held := Reviews{ClientId: "client-1", CleanerId: "cleaner-1", Status: ReviewPending}
+	if (Principal{Subject: "cleaner-1", Roles: []Role{RoleCleaner}}).CanViewReview(held) {
+		t.Error("expected a cleaner not to see a review held for moderation")
+	}
+	if !(Principal{Subject: "client-1", Roles: []Role{RoleClient}}).CanViewReview(held) {
+		t.Error("expected the author to see their held review")
+	}
+}
+
+func TestErrorKinds(t *testing.T) {
+	tests := []struct {
+		err  error
+		kind ErrorKind
+		code string
+	}{
+		{fmt.Errorf("%w: request 42", ErrNotFound), KindNotFound, "not_found"},
+		{TransitionError{From: RequestCompleted, To: RequestCancelled}, KindConflict, "invalid_transition"},
+		{fmt.Errorf("%w: cleaner 7", ErrCleanerUnavailable), KindConflict, "cleaner_unavailable"},
+		{fmt.Errorf("%w: limit must be a positive number", ErrInvalidListOptions), KindValidation, "invalid_list_options"},
+		{fmt.Errorf("%w: dial tcp: connection refused", ErrUnavailable), KindUnavailable, "service_unavailable"},
+		{ErrForbidden, KindForbidden, "forbidden"},
+	}
+
+	for _, tt := range tests {
+		domainErr, ok := AsError(tt.err)
+		if !ok {
+			t.Errorf("%v: expected a domain error", tt.err)
+			continue
+		}
+		if domainErr.Kind != tt.kind || domainErr.Code != tt.code {
+			t.Errorf("%v: got %s/%s, want %s/%s", tt.err, domainErr.Kind, domainErr.Code, tt.kind, tt.code)
+		}
+	}
+
+	if !errors.Is(TransitionError{From: RequestCompleted, To: RequestCancelled}, ErrInvalidTransition) {
+		t.Error("expected a TransitionError to match ErrInvalidTransition")
+	}
+	if _, ok := AsError(errors.New(`pq: syntax error at or near "FROM"`)); ok {
+		t.Error("expected a driver error not to be a domain error")
+	}
+}
+
+func TestValidateRating(t *testing.T) {
+	score := func(value int) *int { return &value }
+	tests := []struct {
+		name   string
+		review Reviews
+		valid  bool
+	}{
+		{"rating only", Reviews{Rating: 4}, true},
+		{"with sub scores", Reviews{Rating: 5, SubScores: SubScores{Punctuality: score(5), Communication: score(1)}}, true},
+		{"missing rating", Reviews{}, false},
+		{"rating too high", Reviews{Rating: 6}, false},
+		{"sub score too low", Reviews{Rating: 3, SubScores: SubScores{Thoroughness: score(0)}}, false},
+	}
+
+	for _, tt := range tests {
+		err := tt.review.ValidateRating()
+		if tt.valid && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("%s: expected ErrInvalidInput, got %v", tt.name, err)
+		}
+	}
+}
+
+func TestRatingSummary(t *testing.T) {
+	punctual := 4
+	var cleaner, platform RatingAggregate
+	for _, review := range []Reviews{{Rating: 5, SubScores: SubScores{Punctuality: &punctual}}, {Rating: 5}, {Rating: 2}} {
+		review.Status = ReviewPublished
+		cleaner.Add(review.RatingDelta(1))
+	}
+	for _, rating := range []int{1, 2, 3, 4, 5} {
+		platform.Add(Reviews{Rating: rating, Status: ReviewPublished}.RatingDelta(1))
+	}
+
+	summary := cleaner.Summary(platform)
+	if summary.Count != 3 || summary.Mean != 4 {
+		t.Errorf("expected 3 ratings averaging 4, got %d averaging %v", summary.Count, summary.Mean)
+	}
+	if summary.Histogram["5"] != 2 || summary.Histogram["2"] != 1 || summary.Histogram["1"] != 0 {
+		t.Errorf("unexpected histogram %v", summary.Histogram)
+	}
+	// five pseudo ratings at the platform mean of 3, plus the cleaner's 12 points over 3 ratings
+	if want := (5*3.0 + 12) / 8; summary.WeightedScore != want {
+		t.Errorf("expected a weighted score of %v, got %v", want, summary.WeightedScore)
+	}
+	if summary.Punctuality == nil || *summary.Punctuality != 4 || summary.Thoroughness != nil {
+		t.Errorf("expected punctuality 4 and no thoroughness, got %v and %v", summary.Punctuality, summary.Thoroughness)
+	}
+
+	cleaner.Add(Reviews{Rating: 2, Status: ReviewFlagged}.RatingDelta(-1))
+	if cleaner.Rating.Count != 2 || cleaner.Histogram[1] != 0 {
+		t.Errorf("expected removing a review to take it out of the totals, got %+v", cleaner)
+	}
+	if !(Reviews{Rating: 0, Status: ReviewPublished}).RatingDelta(1).IsZero() {
+		t.Error("expected an unrated review to add nothing")
+	}
+	if !(Reviews{Rating: 4, Status: ReviewPending}).RatingDelta(1).IsZero() {
+		t.Error("expected a review waiting for moderation to add nothing")
+	}
+}
+
+func TestCheckReviewable(t *testing.T) {
+	completedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	request := Request{RequestId: "request-1", ClientId: "client-1", Status: RequestCompleted, CompletedAt: &completedAt}
+	window := 14 * 24 * time.Hour
+
+	tests := []struct {
+		name     string
+		request  Request
+		clientId string
+		now      time.Time
+		want     error
+	}{
+		{"within the window", request, "client-1", completedAt.Add(window), nil},
+		{"after the window", request, "client-1", completedAt.Add(window + time.Second), ErrNotReviewable},
+		{"another client", request, "client-2", completedAt, ErrForbidden},
+		{"no client", request, "", completedAt, ErrForbidden},
+		{"not completed", Request{RequestId: "request-2", ClientId: "client-1", Status: RequestInProgress}, "client-1", completedAt, ErrNotReviewable},
+	}
+
+	for _, tt := range tests {
+		err := tt.request.CheckReviewable(tt.clientId, tt.now, window)
+		if tt.want == nil && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestPaymentSettle(t *testing.T) {
+	charged := NewMoney(232050, "KES")
+	tests := []struct {
+		name     string
+		callback PaymentCallback
+		status   PaymentStatus
+		reason   string
+	}{
+		{"paid", PaymentCallback{Succeeded: true, Amount: charged, Receipt: "NLJ7RT61SV"}, PaymentSucceeded, ""},
+		{"rounded up", PaymentCallback{Succeeded: true, Amount: NewMoney(232100, "KES"), Receipt: "NLJ7RT61SV"}, PaymentSucceeded, ""},
+		{"declined", PaymentCallback{FailureReason: "Request cancelled by user"}, PaymentFailed, "Request cancelled by user"},
+		{"short", PaymentCallback{Succeeded: true, Amount: NewMoney(100, "KES"), Receipt: "NLJ7RT61SV"}, PaymentFailed, "collected KES 1.00 of KES 2320.50"},
+		{"other currency", PaymentCallback{Succeeded: true, Amount: NewMoney(232050, "USD")}, PaymentFailed, "collected USD 2320.50 of KES 2320.50"},
+	}
+
+	for _, tt := range tests {
+		payment := Payment{Amount: charged, Status: PaymentPending}
+		if !payment.Settle(tt.callback) {
+			t.Fatalf("%s: expected a pending payment to settle", tt.name)
+		}
+		if payment.Status != tt.status || payment.FailureReason != tt.reason {
+			t.Errorf("%s: expected %s %q, got %s %q", tt.name, tt.status, tt.reason, payment.Status, payment.FailureReason)
+		}
+		if payment.Settle(PaymentCallback{Succeeded: true, Amount: charged}) {
+			t.Errorf("%s: expected a retried callback to leave the settled payment alone", tt.name)
+		}
+	}
+}
+
+func TestRecurrenceRuleOccurrences(t *testing.T) {
+	// Tuesday 2 January 2024, 09:00
+	start := time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC)
+	day := func(month time.Month, day int) time.Time {
+		return time.Date(2024, month, day, 9, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		rule  string
+		start time.Time
+		want  []time.Time
+	}{
+		{"FREQ=WEEKLY;COUNT=3", start, []time.Time{day(1, 2), day(1, 9), day(1, 16)}},
+		{"RRULE:FREQ=WEEKLY;INTERVAL=2;COUNT=3", start, []time.Time{day(1, 2), day(1, 16), day(1, 30)}},
+		// Monday is before the start in the first week, so the series opens on the Thursday
+		{"FREQ=WEEKLY;BYDAY=MO,TH;COUNT=4", start, []time.Time{day(1, 4), day(1, 8), day(1, 11), day(1, 15)}},
+		{"FREQ=WEEKLY;UNTIL=20240116", start, []time.Time{day(1, 2), day(1, 9), day(1, 16)}},
+		{"FREQ=MONTHLY;COUNT=4", day(1, 31), []time.Time{day(1, 31), day(3, 31), day(5, 31), day(7, 31)}},
+		{"FREQ=MONTHLY;INTERVAL=3;UNTIL=20241001T000000Z", day(1, 15), []time.Time{day(1, 15), day(4, 15), day(7, 15)}},
+	}
+
+	for _, tt := range tests {
+		rule, err := ParseRecurrenceRule(tt.rule)
+		if err != nil {
+			t.Errorf("%s: %v", tt.rule, err)
+			continue
+		}
+		got := rule.Occurrences(tt.start, tt.start, tt.start.AddDate(2, 0, 0))
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.rule, tt.want, got)
+		}
+		for _, at := range tt.want {
+			if !rule.IsOccurrence(tt.start, at) {
+				t.Errorf("%s: expected %s to be an occurrence", tt.rule, at)
+			}
+		}
+		if rule.IsOccurrence(tt.start, tt.want[0].Add(time.Hour)) {
+			t.Errorf("%s: expected an hour later not to be an occurrence", tt.rule)
+		}
+
+		parsed, err := ParseRecurrenceRule(rule.String())
+		if err != nil || parsed.String() != rule.String() {
+			t.Errorf("%s: expected %q to read back the same, got %q, %v", tt.rule, rule.String(), parsed.String(), err)
+		}
+	}
+
+	// The time of day holds in the series' location across a change of offset
+	nairobi := time.FixedZone("EAT", 3*60*60)
+	rule, _ := ParseRecurrenceRule("FREQ=WEEKLY;COUNT=2")
+	for _, at := range rule.Occurrences(time.Date(2024, 3, 5, 9, 0, 0, 0, nairobi), time.Time{}, start.AddDate(1, 0, 0)) {
+		if at.Hour() != 9 || at.Location() != nairobi {
+			t.Errorf("expected occurrences at 09:00 EAT, got %s", at)
+		}
+	}
+
+	for _, invalid := range []string{"", "FREQ=DAILY", "FREQ=WEEKLY;INTERVAL=0", "FREQ=MONTHLY;BYDAY=MO", "FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=WEEKLY;COUNT=2;UNTIL=20240301", "FREQ=WEEKLY;UNTIL=tomorrow", "FREQ=WEEKLY;BYSETPOS=1"} {
+		if _, err := ParseRecurrenceRule(invalid); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("%q: expected ErrInvalidInput, got %v", invalid, err)
+		}
+	}
+}
+
+func TestRecurringBookingExceptions(t *testing.T) {
+	start := time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC)
+	rule, _ := ParseRecurrenceRule("FREQ=WEEKLY;COUNT=4")
+	booking := RecurringBooking{RecurringBookingId: "series-1", StartsAt: start, Rule: rule, Status: RecurringActive}
+	now := start.Add(-time.Hour)
+
+	moved := start.AddDate(0, 0, 15)
+	if err := booking.SetException(OccurrenceException{OccurrenceAt: start.AddDate(0, 0, 7), Skipped: true}, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := booking.SetException(OccurrenceException{OccurrenceAt: start.AddDate(0, 0, 14), RescheduledTo: &moved}, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := booking.SetException(OccurrenceException{OccurrenceAt: start.Add(time.Hour), Skipped: true}, now); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a time that is not an occurrence, got %v", err)
+	}
+	if err := booking.SetException(OccurrenceException{OccurrenceAt: start, Skipped: true}, start.Add(time.Hour)); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput for an occurrence that has passed, got %v", err)
+	}
+
+	schedule := booking.Schedule(start, start.AddDate(0, 1, 0))
+	if len(schedule) != 4 || schedule[0].Skipped || !schedule[1].Skipped || !schedule[2].ScheduledAt.Equal(moved) || !schedule[3].ScheduledAt.Equal(schedule[3].OccurrenceAt) {
+		t.Errorf("expected the second occurrence skipped and the third moved, got %+v", schedule)
+	}
+
+	if err := booking.Cancel(start.AddDate(0, 0, 10)); err != nil {
+		t.Fatal(err)
+	}
+	if schedule := booking.Schedule(start, start.AddDate(0, 1, 0)); len(schedule) != 2 {
+		t.Errorf("expected only the occurrences before the cancellation, got %+v", schedule)
+	}
+	if err := booking.Cancel(start.AddDate(0, 0, 11)); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected cancelling twice to fail, got %v", err)
+	}
+}
+
+func TestCancellationPolicyChangeFee(t *testing.T) {
+	policy := CancellationPolicy{
+		FreeCancellationWindow: 24 * time.Hour,
+		LateCancellationFeeBps: 5000,
+		FreeRescheduleWindow:   12 * time.Hour,
+		LateRescheduleFeeBps:   1000,
+	}
+	quote := Quote{
+		Lines: []QuoteLine{
+			{Kind: QuoteLineBase, Amount: NewMoney(200000, "KES")},
+			{Kind: QuoteLineFee, Amount: NewMoney(20000, "KES")},
+		},
+		Total: NewMoney(220000, "KES"),
+	}
+	start := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		kind      BookingChangeKind
+		initiator ChangeInitiator
+		before    time.Duration
+		fee       int64
+	}{
+		{"early cancellation", ChangeCancelled, InitiatorClient, 48 * time.Hour, 0},
+		{"cancellation on the window", ChangeCancelled, InitiatorClient, 24 * time.Hour, 0},
+		{"late cancellation", ChangeCancelled, InitiatorClient, 2 * time.Hour, 100000},
+		{"late cancellation by staff", ChangeCancelled, InitiatorStaff, 2 * time.Hour, 100000},
+		{"cancellation by the cleaner", ChangeCancelled, InitiatorCleaner, 2 * time.Hour, 0},
+		{"release", ChangeReleased, InitiatorClient, 2 * time.Hour, 0},
+		{"early reschedule", ChangeRescheduled, InitiatorClient, 18 * time.Hour, 0},
+		{"late reschedule", ChangeRescheduled, InitiatorClient, 2 * time.Hour, 20000},
+	}
+
+	for _, tt := range tests {
+		fee := policy.ChangeFee(quote, tt.kind, tt.initiator, start, start.Add(-tt.before))
+		if fee != NewMoney(tt.fee, "KES") {
+			t.Errorf("%s: expected a fee of %d, got %s", tt.name, tt.fee, fee)
+		}
+	}
+}
+
+func TestRequestBookingChanges(t *testing.T) {
+	at := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	start := at.Add(48 * time.Hour)
+	change := BookingChange{Initiator: InitiatorClient, By: "client-1", At: at}
+
+	request := Request{RequestId: "request-1", Status: RequestCompleted, RequestedDate: start}
+	if err := request.Reschedule(start.Add(time.Hour), change); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("expected a completed request not to be rescheduled, got %v", err)
+	}
+	if err := request.Release(change); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("expected a completed request not to be released, got %v", err)
+	}
+
+	request = Request{RequestId: "request-1", Status: RequestAssigned, CleanerId: "cleaner-1", RequestedDate: start}
+	if err := request.Reschedule(at.Add(-time.Hour), change); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected a booking not to be moved into the past, got %v", err)
+	}
+	change.Fee = NewMoney(5000, "KES")
+	if err := request.Reschedule(start.Add(time.Hour), change); err != nil {
+		t.Fatal(err)
+	}
+	if lines := request.FeeLines(); len(lines) != 1 || lines[0].Amount != change.Fee {
+		t.Errorf("expected the reschedule fee as a quote line, got %+v", lines)
+	}
+	if request.CancellationQuote() != nil {
+		t.Error("expected no cancellation quote for an open request")
+	}
+
+	change.Fee = NewMoney(50000, "KES")
+	if err := request.Cancel(change); err != nil {
+		t.Fatal(err)
+	}
+	quote := request.CancellationQuote()
+	if quote == nil || quote.Total != change.Fee || len(quote.Lines) != 1 {
+		t.Errorf("expected the cancellation fee to be billed on its own, got %+v", quote)
+	}
+	if len(request.Changes) != 2 || request.Changes[0].Kind != ChangeRescheduled || request.Changes[1].Kind != ChangeCancelled {
+		t.Errorf("expected both changes to be kept in order, got %+v", request.Changes)
+	}
+
+	rescheduled := Request{Changes: request.Changes[:1]}
+	if _, err := rescheduled.WithFees(&Quote{Subtotal: NewMoney(1000, "USD"), Total: NewMoney(1000, "USD")}); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("expected fees in another currency to be refused, got %v", err)
+	}
+	quote, err := rescheduled.WithFees(&Quote{Subtotal: NewMoney(100000, "KES"), Total: NewMoney(100000, "KES")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quote.Total != NewMoney(105000, "KES") {
+		t.Errorf("expected the reschedule fee on top, got %s", quote.Total)
+	}
+
+	if err := (BookingChange{Initiator: InitiatorCleaner, By: "cleaner-1", FeeWaived: true}).Validate(); !errors.Is(err, ErrForbidden) {
+		t.Errorf("expected only staff to waive fees, got %v", err)
+	}
+}
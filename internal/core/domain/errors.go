@@ -0,0 +1,42 @@
+package domain
+
+import "errors"
+
+// ErrorKind classifies domain errors by what went wrong, independent of the transport reporting them
+type ErrorKind string
+
+const (
+	KindNotFound    ErrorKind = "not_found"
+	KindConflict    ErrorKind = "conflict"
+	KindValidation  ErrorKind = "validation"
+	KindForbidden   ErrorKind = "forbidden"
+	KindUnavailable ErrorKind = "unavailable"
+)
+
+// Error is a sentinel error of a known kind. Code is a stable machine readable identifier
+// clients can rely on; the message is for people and may change.
+type Error struct {
+	Kind    ErrorKind
+	Code    string
+	Message string
+}
+
+func newError(kind ErrorKind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// ErrUnavailable reports that a dependency such as the database or payment provider could not be reached
+var ErrUnavailable = newError(KindUnavailable, "service_unavailable", "service temporarily unavailable")
+
+// AsError finds the domain error err wraps, if any
+func AsError(err error) (*Error, bool) {
+	var domainErr *Error
+	if errors.As(err, &domainErr) {
+		return domainErr, true
+	}
+	return nil, false
+}
@@ -0,0 +1,63 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+type InvoiceLine struct {
+	Description string `json:"description"`
+	Amount      Money  `json:"amount"`
+}
+
+// Invoice is issued once per completed request. Amounts are tax exclusive, the tax is added on top
+// of the subtotal.
+type Invoice struct {
+	InvoiceId     string        `json:"invoice_id"`
+	InvoiceNumber string        `json:"invoice_number"`
+	Sequence      int64         `json:"-"`
+	RequestId     string        `json:"request_id"`
+	ClientId      string        `json:"client_id"`
+	CleanerId     string        `json:"cleaner_id"`
+	Currency      string        `json:"currency"`
+	Lines         []InvoiceLine `json:"lines"`
+	Subtotal      Money         `json:"subtotal"`
+	TaxRateBps    int64         `json:"tax_rate_bps"`
+	Tax           Money         `json:"tax"`
+	Total         Money         `json:"total"`
+	IssuedAt      time.Time     `json:"issued_at"`
+	CreatedAt     time.Time     `json:"created_at"`
+}
+
+type InvoiceFilter struct {
+	ListOptions
+	ClientId string
+}
+
+// NewInvoice builds the invoice for a completed request from its quote. The number is assigned
+// when the invoice is stored.
+func NewInvoice(request Request, quote Quote, taxRateBps int64) (*Invoice, error) {
+	if request.Status != RequestCompleted && request.CancellationQuote() == nil {
+		return nil, fmt.Errorf("%w: request %s is %s, only completed requests and late cancellations are invoiced", ErrInvalidInput, request.RequestId, request.Status)
+	}
+
+	invoice := Invoice{
+		RequestId:  request.RequestId,
+		ClientId:   request.ClientId,
+		CleanerId:  request.CleanerId,
+		Currency:   quote.Total.Currency,
+		Subtotal:   quote.Total,
+		TaxRateBps: taxRateBps,
+		Tax:        quote.Total.Percent(taxRateBps),
+	}
+	for _, line := range quote.Lines {
+		invoice.Lines = append(invoice.Lines, InvoiceLine{Description: line.Description, Amount: line.Amount})
+	}
+	invoice.Total = invoice.Subtotal.Add(invoice.Tax)
+	return &invoice, nil
+}
+
+// FormatInvoiceNumber renders an invoice sequence number, e.g. INV-000042
+func FormatInvoiceNumber(sequence int64) string {
+	return fmt.Sprintf("INV-%06d", sequence)
+}
@@ -0,0 +1,87 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+var ErrNoEligibleCleaner = newError(KindConflict, "no_eligible_cleaner", "no eligible cleaner")
+
+const earthRadiusKm = 6371.0
+
+// CleanerProfile is what the matching engine knows about a cleaner: the services they
+// offer, where they start from and how far they are willing to travel.
+type CleanerProfile struct {
+	CleanerId     string    `json:"cleaner_id"`
+	ServiceIds    []string  `json:"service_ids"`
+	Latitude      float64   `json:"latitude"`
+	Longitude     float64   `json:"longitude"`
+	MaxDistanceKm float64   `json:"max_distance_km"`
+	Active        bool      `json:"active"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func (p CleanerProfile) Validate() error {
+	if err := validateCoordinates(p.Latitude, p.Longitude); err != nil {
+		return err
+	}
+	if p.MaxDistanceKm < 0 {
+		return fmt.Errorf("%w: max_distance_km cannot be negative", ErrInvalidInput)
+	}
+	return nil
+}
+
+func (p CleanerProfile) Offers(service_id string) bool {
+	for _, id := range p.ServiceIds {
+		if id == service_id {
+			return true
+		}
+	}
+	return false
+}
+
+// CleanerCandidate is an eligible cleaner together with the facts a matching strategy ranks on.
+type CleanerCandidate struct {
+	Profile       CleanerProfile
+	DistanceKm    float64
+	HasDistance   bool
+	AverageRating float64
+	ReviewCount   int
+}
+
+type CleanerMatch struct {
+	CleanerId     string  `json:"cleaner_id"`
+	Score         float64 `json:"score"`
+	DistanceKm    float64 `json:"distance_km"`
+	AverageRating float64 `json:"average_rating"`
+	ReviewCount   int     `json:"review_count"`
+}
+
+// HasLocation reports whether the request carries job coordinates. 0,0 is treated as unset.
+func (r Request) HasLocation() bool {
+	return r.Latitude != 0 || r.Longitude != 0
+}
+
+func (r Request) ValidateLocation() error {
+	return validateCoordinates(r.Latitude, r.Longitude)
+}
+
+// DistanceKm is the great circle distance between two points
+func DistanceKm(fromLatitude, fromLongitude, toLatitude, toLongitude float64) float64 {
+	radians := func(degrees float64) float64 { return degrees * math.Pi / 180 }
+
+	deltaLatitude := radians(toLatitude - fromLatitude)
+	deltaLongitude := radians(toLongitude - fromLongitude)
+	a := math.Sin(deltaLatitude/2)*math.Sin(deltaLatitude/2) +
+		math.Cos(radians(fromLatitude))*math.Cos(radians(toLatitude))*math.Sin(deltaLongitude/2)*math.Sin(deltaLongitude/2)
+	return earthRadiusKm * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+func validateCoordinates(latitude, longitude float64) error {
+	if latitude < -90 || latitude > 90 || longitude < -180 || longitude > 180 {
+		return fmt.Errorf("%w: coordinates %v,%v are out of range", ErrInvalidInput, latitude, longitude)
+	}
+	return nil
+}
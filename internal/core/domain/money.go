@@ -0,0 +1,88 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultCurrency is used for prices that do not name a currency
+const DefaultCurrency = "KES"
+
+// ErrCurrencyMismatch is returned when amounts in different currencies would have to be added up
+var ErrCurrencyMismatch = newError(KindConflict, "currency_mismatch", "amounts are in different currencies")
+
+// Money is an amount in the minor unit of its currency, e.g. cents, so prices never go through floats
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+func NewMoney(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+func (m Money) Validate() error {
+	if m.Amount < 0 {
+		return fmt.Errorf("%w: amount cannot be negative", ErrInvalidInput)
+	}
+	if len(m.Currency) != 3 || strings.ToUpper(m.Currency) != m.Currency {
+		return fmt.Errorf("%w: currency %q is not an ISO 4217 code", ErrInvalidInput, m.Currency)
+	}
+	return nil
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// Add sums two amounts. Both must be in the same currency.
+func (m Money) Add(other Money) Money {
+	m.mustMatch(other)
+	return Money{Amount: m.Amount + other.Amount, Currency: m.Currency}
+}
+
+func (m Money) Sub(other Money) Money {
+	m.mustMatch(other)
+	return Money{Amount: m.Amount - other.Amount, Currency: m.Currency}
+}
+
+// Ratio scales the amount by numerator/denominator, rounding half away from zero
+func (m Money) Ratio(numerator, denominator int64) Money {
+	product := m.Amount * numerator
+	quotient, remainder := product/denominator, product%denominator
+	if remainder*2 >= denominator {
+		quotient++
+	} else if remainder*2 <= -denominator {
+		quotient--
+	}
+	return Money{Amount: quotient, Currency: m.Currency}
+}
+
+// Percent takes basis points of the amount, 100 bps being one percent
+func (m Money) Percent(bps int64) Money {
+	return m.Ratio(bps, 10000)
+}
+
+func (m Money) String() string {
+	sign := ""
+	amount := m.Amount
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	return fmt.Sprintf("%s %s%d.%02d", m.Currency, sign, amount/100, amount%100)
+}
+
+// CheckCurrency returns ErrCurrencyMismatch unless other is in the same currency. Amounts that
+// were not priced together are checked with it before they are added up.
+func (m Money) CheckCurrency(other Money) error {
+	if m.Currency != other.Currency {
+		return fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+	}
+	return nil
+}
+
+func (m Money) mustMatch(other Money) {
+	if m.Currency != other.Currency {
+		panic(fmt.Sprintf("money: currency mismatch %s and %s", m.Currency, other.Currency))
+	}
+}
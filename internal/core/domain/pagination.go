@@ -0,0 +1,92 @@
+package domain
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+var ErrInvalidListOptions = newError(KindValidation, "invalid_list_options", "invalid list options")
+
+// ListOptions holds the paging and sorting parameters shared by every list endpoint.
+// SortBy names a field of the listed entity, the zero value sorts by created_at.
+type ListOptions struct {
+	Limit    int
+	Cursor   string
+	SortBy   string
+	SortDesc bool
+}
+
+func (o ListOptions) PageLimit() int {
+	if o.Limit <= 0 {
+		return DefaultPageLimit
+	}
+	if o.Limit > MaxPageLimit {
+		return MaxPageLimit
+	}
+	return o.Limit
+}
+
+type ServiceFilter struct {
+	ListOptions
+}
+
+type RequestFilter struct {
+	ListOptions
+	ClientId      string
+	CleanerId     string
+	ServiceId     string
+	Status        RequestStatus
+	RequestedFrom *time.Time
+	RequestedTo   *time.Time
+	// RecurringBookingId limits the requests to those generated for a recurring booking
+	RecurringBookingId string
+}
+
+type ReviewFilter struct {
+	ListOptions
+	ClientId  string
+	CleanerId string
+	MinRating int
+	MaxRating int
+	// Statuses limits the reviews to these statuses, all of them when empty
+	Statuses []ReviewStatus
+}
+
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor"`
+	Total      int    `json:"total"`
+}
+
+// Cursor marks the last row of a page. It carries the sort it was produced under so it
+// cannot be replayed against a different ordering.
+type Cursor struct {
+	SortBy   string `json:"s"`
+	SortDesc bool   `json:"d,omitempty"`
+	Value    string `json:"v"`
+	Id       string `json:"i"`
+}
+
+func EncodeCursor(cursor Cursor) string {
+	content, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(content)
+}
+
+func DecodeCursor(encoded string) (Cursor, error) {
+	var cursor Cursor
+	content, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return cursor, fmt.Errorf("%w: malformed cursor", ErrInvalidListOptions)
+	}
+	if err := json.Unmarshal(content, &cursor); err != nil {
+		return cursor, fmt.Errorf("%w: malformed cursor", ErrInvalidListOptions)
+	}
+	return cursor, nil
+}
@@ -0,0 +1,95 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrPaymentSettled is returned when a payment that was read as pending has been settled since
+var ErrPaymentSettled = newError(KindConflict, "payment_settled", "payment is already settled")
+
+type PaymentStatus string
+
+const (
+	PaymentPending   PaymentStatus = "pending"
+	PaymentSucceeded PaymentStatus = "succeeded"
+	PaymentFailed    PaymentStatus = "failed"
+)
+
+func (s PaymentStatus) IsFinal() bool {
+	return s == PaymentSucceeded || s == PaymentFailed
+}
+
+// Payment is one attempt to collect an invoice from the client through a payment provider
+type Payment struct {
+	PaymentId string `json:"payment_id"`
+	RequestId string `json:"request_id"`
+	InvoiceId string `json:"invoice_id"`
+	// Reference is what the client sees on the payment prompt, the invoice number
+	Reference   string `json:"reference"`
+	ClientId    string `json:"client_id"`
+	Amount      Money  `json:"amount"`
+	PhoneNumber string `json:"phone_number"`
+	Provider    string `json:"provider"`
+	// ProviderReference is the provider's id for the charge, e.g. the M-Pesa CheckoutRequestID
+	ProviderReference string        `json:"provider_reference"`
+	Receipt           string        `json:"receipt"`
+	Status            PaymentStatus `json:"status"`
+	FailureReason     string        `json:"failure_reason"`
+	CreatedAt         time.Time     `json:"created_at"`
+	UpdatedAt         time.Time     `json:"updated_at"`
+}
+
+// PaymentCallback is a provider's asynchronous charge result, translated out of its own format
+type PaymentCallback struct {
+	ProviderReference string
+	Succeeded         bool
+	// Amount is what the provider collected, reported with successful charges
+	Amount        Money
+	Receipt       string
+	FailureReason string
+}
+
+// Settle records the outcome of the charge. Callbacks for a payment that is already settled are
+// ignored, providers retry them. A charge that collected less than the payment, or in another
+// currency, does not pay it and is recorded as failed. Providers may collect a little more, M-Pesa
+// rounds up to whole shillings.
+func (p *Payment) Settle(callback PaymentCallback) bool {
+	if p.Status.IsFinal() {
+		return false
+	}
+	switch {
+	case !callback.Succeeded:
+		p.Status = PaymentFailed
+		p.FailureReason = callback.FailureReason
+	case callback.Amount.Currency != p.Amount.Currency || callback.Amount.Amount < p.Amount.Amount:
+		p.Status = PaymentFailed
+		p.Receipt = callback.Receipt
+		p.FailureReason = fmt.Sprintf("collected %s of %s", callback.Amount, p.Amount)
+	default:
+		p.Status = PaymentSucceeded
+		p.Receipt = callback.Receipt
+	}
+	return true
+}
+
+// NormalizePhoneNumber turns a Kenyan mobile number written as 07XXXXXXXX, 01XXXXXXXX, +254... or
+// 254... into the 254XXXXXXXXX form M-Pesa expects.
+func NormalizePhoneNumber(phone string) (string, error) {
+	digits := strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimPrefix(strings.TrimSpace(phone), "+"))
+	if strings.HasPrefix(digits, "0") {
+		digits = "254" + digits[1:]
+	}
+
+	valid := len(digits) == 12 && (strings.HasPrefix(digits, "2547") || strings.HasPrefix(digits, "2541"))
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			valid = false
+		}
+	}
+	if !valid {
+		return "", fmt.Errorf("%w: %q is not a Kenyan mobile number", ErrInvalidInput, phone)
+	}
+	return digits, nil
+}
@@ -0,0 +1,206 @@
+package domain
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AddOn is an optional extra offered with a service, e.g. inside the oven, at a flat price
+type AddOn struct {
+	Code  string `json:"code"`
+	Name  string `json:"name"`
+	Price Money  `json:"price"`
+}
+
+// Discount takes either a percentage in basis points or a fixed amount off a quote
+type Discount struct {
+	Code       string `json:"code"`
+	PercentBps int64  `json:"percent_bps,omitempty"`
+	Amount     Money  `json:"amount"`
+}
+
+type QuoteLine struct {
+	Kind        string `json:"kind"`
+	Description string `json:"description"`
+	Amount      Money  `json:"amount"`
+}
+
+const (
+	QuoteLineBase      = "base"
+	QuoteLineAddOn     = "add_on"
+	QuoteLineSurcharge = "surcharge"
+	QuoteLineDiscount  = "discount"
+)
+
+// Quote is the price of a request, fixed at booking time
+type Quote struct {
+	Lines    []QuoteLine `json:"lines"`
+	Subtotal Money       `json:"subtotal"`
+	Discount Money       `json:"discount"`
+	Total    Money       `json:"total"`
+	PricedAt time.Time   `json:"priced_at"`
+}
+
+// PricingRules are the surcharges and discounts applied on top of a service's rates
+type PricingRules struct {
+	WeekendSurchargeBps    int64
+	AfterHoursSurchargeBps int64
+	// BusinessHoursStart and BusinessHoursEnd bound the working day, "HH:MM" in the service's time zone
+	BusinessHoursStart string
+	BusinessHoursEnd   string
+	Discounts          map[string]Discount
+	Cancellation       CancellationPolicy
+}
+
+func (s Service) Validate() error {
+	if err := s.HourlyRate.Validate(); err != nil {
+		return err
+	}
+	codes := map[string]bool{}
+	for _, addOn := range s.AddOns {
+		if addOn.Code == "" || codes[addOn.Code] {
+			return fmt.Errorf("%w: add-on codes must be present and unique", ErrInvalidInput)
+		}
+		codes[addOn.Code] = true
+		if err := addOn.Price.Validate(); err != nil {
+			return err
+		}
+		if addOn.Price.Currency != s.HourlyRate.Currency {
+			return fmt.Errorf("%w: add-on %s is not priced in %s", ErrInvalidInput, addOn.Code, s.HourlyRate.Currency)
+		}
+	}
+	return nil
+}
+
+func (s Service) AddOn(code string) (AddOn, bool) {
+	for _, addOn := range s.AddOns {
+		if addOn.Code == code {
+			return addOn, true
+		}
+	}
+	return AddOn{}, false
+}
+
+// Quote prices request for service: the hourly rate over the booked duration plus add-ons, then
+// weekend and after-hours surcharges, less any discount. start is the booking start in the
+// service's time zone.
+func (rules PricingRules) Quote(service Service, request Request, start time.Time) (*Quote, error) {
+	currency := service.HourlyRate.Currency
+	quote := Quote{PricedAt: time.Now()}
+
+	base := service.HourlyRate.Ratio(int64(request.DurationMinutes), 60)
+	quote.Lines = append(quote.Lines, QuoteLine{
+		Kind:        QuoteLineBase,
+		Description: fmt.Sprintf("%s, %d minutes", service.Name, request.DurationMinutes),
+		Amount:      base,
+	})
+	subtotal := base
+
+	for _, code := range request.AddOns {
+		addOn, ok := service.AddOn(code)
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown add-on %q", ErrInvalidInput, code)
+		}
+		quote.Lines = append(quote.Lines, QuoteLine{Kind: QuoteLineAddOn, Description: addOn.Name, Amount: addOn.Price})
+		subtotal = subtotal.Add(addOn.Price)
+	}
+
+	surcharged := subtotal
+	if weekday := start.Weekday(); rules.WeekendSurchargeBps > 0 && (weekday == time.Saturday || weekday == time.Sunday) {
+		surcharge := subtotal.Percent(rules.WeekendSurchargeBps)
+		quote.Lines = append(quote.Lines, QuoteLine{Kind: QuoteLineSurcharge, Description: "Weekend surcharge", Amount: surcharge})
+		surcharged = surcharged.Add(surcharge)
+	}
+
+	afterHours, err := rules.isAfterHours(start, request.Slot().EndsAt.In(start.Location()))
+	if err != nil {
+		return nil, err
+	}
+	if rules.AfterHoursSurchargeBps > 0 && afterHours {
+		surcharge := subtotal.Percent(rules.AfterHoursSurchargeBps)
+		quote.Lines = append(quote.Lines, QuoteLine{Kind: QuoteLineSurcharge, Description: "After-hours surcharge", Amount: surcharge})
+		surcharged = surcharged.Add(surcharge)
+	}
+	quote.Subtotal = surcharged
+
+	quote.Discount = NewMoney(0, currency)
+	if request.DiscountCode != "" {
+		discount, ok := rules.Discounts[strings.ToUpper(request.DiscountCode)]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown discount code %q", ErrInvalidInput, request.DiscountCode)
+		}
+		amount := surcharged.Percent(discount.PercentBps)
+		if discount.PercentBps == 0 {
+			if discount.Amount.Currency != currency {
+				return nil, fmt.Errorf("%w: discount %s does not apply to prices in %s", ErrInvalidInput, discount.Code, currency)
+			}
+			amount = discount.Amount
+		}
+		if amount.Amount > surcharged.Amount {
+			amount = surcharged
+		}
+		quote.Lines = append(quote.Lines, QuoteLine{Kind: QuoteLineDiscount, Description: "Discount " + discount.Code, Amount: NewMoney(-amount.Amount, currency)})
+		quote.Discount = amount
+	}
+
+	quote.Total = surcharged.Sub(quote.Discount)
+	return &quote, nil
+}
+
+// isAfterHours reports whether any part of the booking falls outside business hours
+func (rules PricingRules) isAfterHours(start, end time.Time) (bool, error) {
+	if rules.BusinessHoursStart == "" || rules.BusinessHoursEnd == "" {
+		return false, nil
+	}
+	day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+	business, err := clockWindow(day, rules.BusinessHoursStart, rules.BusinessHoursEnd)
+	if err != nil {
+		return false, err
+	}
+	return start.Before(business.StartsAt) || end.After(business.EndsAt), nil
+}
+
+// ParsePercent turns a percentage such as "12.5" into basis points
+func ParsePercent(value string) (int64, error) {
+	parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || parsed < 0 {
+		return 0, fmt.Errorf("%w: %q is not a percentage", ErrInvalidInput, value)
+	}
+	return int64(parsed*100 + 0.5), nil
+}
+
+// ParseDiscounts reads discount codes written as "CODE=10%" for a percentage or "CODE=500" for a
+// fixed amount of the default currency in major units, separated by commas.
+func ParseDiscounts(value string) (map[string]Discount, error) {
+	discounts := map[string]Discount{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		code, amount, ok := strings.Cut(entry, "=")
+		if !ok || code == "" {
+			return nil, fmt.Errorf("%w: malformed discount %q", ErrInvalidInput, entry)
+		}
+
+		discount := Discount{Code: strings.ToUpper(strings.TrimSpace(code))}
+		amount = strings.TrimSpace(amount)
+		if percent, isPercent := strings.CutSuffix(amount, "%"); isPercent {
+			bps, err := ParsePercent(percent)
+			if err != nil || bps == 0 || bps > 10000 {
+				return nil, fmt.Errorf("%w: malformed discount %q", ErrInvalidInput, entry)
+			}
+			discount.PercentBps = bps
+		} else {
+			parsed, err := strconv.ParseFloat(amount, 64)
+			if err != nil || parsed <= 0 {
+				return nil, fmt.Errorf("%w: malformed discount %q", ErrInvalidInput, entry)
+			}
+			discount.Amount = NewMoney(int64(parsed*100+0.5), DefaultCurrency)
+		}
+		discounts[discount.Code] = discount
+	}
+	return discounts, nil
+}
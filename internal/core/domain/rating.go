@@ -0,0 +1,167 @@
+package domain
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const (
+	MinRating = 1
+	MaxRating = 5
+	// RatingPriorWeight is how many reviews at the platform mean every cleaner and service starts
+	// with, so a single five star review does not outrank a hundred four star ones
+	RatingPriorWeight = 5
+)
+
+// SubScores are the optional 1 to 5 ratings of particular aspects of a cleaning
+type SubScores struct {
+	Punctuality   *int `json:"punctuality,omitempty"`
+	Thoroughness  *int `json:"thoroughness,omitempty"`
+	Communication *int `json:"communication,omitempty"`
+}
+
+// ValidateRating checks the rating and sub scores of a review
+func (r Reviews) ValidateRating() error {
+	if err := validateScore("rating", &r.Rating); err != nil {
+		return err
+	}
+	for name, score := range map[string]*int{
+		"punctuality":   r.SubScores.Punctuality,
+		"thoroughness":  r.SubScores.Thoroughness,
+		"communication": r.SubScores.Communication,
+	} {
+		if err := validateScore(name, score); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateScore(name string, score *int) error {
+	if score != nil && (*score < MinRating || *score > MaxRating) {
+		return fmt.Errorf("%w: %s must be a whole number from %d to %d", ErrInvalidInput, name, MinRating, MaxRating)
+	}
+	return nil
+}
+
+// RatingSubject is what a rating aggregate summarises
+type RatingSubject string
+
+const (
+	RatingSubjectCleaner  RatingSubject = "cleaner"
+	RatingSubjectService  RatingSubject = "service"
+	RatingSubjectPlatform RatingSubject = "platform"
+)
+
+// ScoreTotal is the running sum and count of one score
+type ScoreTotal struct {
+	Sum   int
+	Count int
+}
+
+func (t *ScoreTotal) add(score *int, sign int) {
+	if score != nil {
+		t.Sum += sign * *score
+		t.Count += sign
+	}
+}
+
+func (t ScoreTotal) mean() *float64 {
+	if t.Count <= 0 {
+		return nil
+	}
+	mean := float64(t.Sum) / float64(t.Count)
+	return &mean
+}
+
+// RatingAggregate holds the running totals of the ratings of one cleaner, one service or the whole
+// platform. It is kept up to date as reviews change rather than recomputed.
+type RatingAggregate struct {
+	SubjectType RatingSubject
+	SubjectId   string
+	Rating      ScoreTotal
+	// Histogram counts the ratings of each value, index 0 holding the ones
+	Histogram     [MaxRating]int
+	Punctuality   ScoreTotal
+	Thoroughness  ScoreTotal
+	Communication ScoreTotal
+}
+
+// RatingDelta is what the review adds to an aggregate, or with a negative sign takes away from
+// it. Reviews without a rating, or that are not live, contribute nothing.
+func (r Reviews) RatingDelta(sign int) RatingAggregate {
+	var delta RatingAggregate
+	if !r.Status.IsLive() || r.Rating < MinRating || r.Rating > MaxRating {
+		return delta
+	}
+	delta.Rating.add(&r.Rating, sign)
+	delta.Histogram[r.Rating-1] = sign
+	delta.Punctuality.add(r.SubScores.Punctuality, sign)
+	delta.Thoroughness.add(r.SubScores.Thoroughness, sign)
+	delta.Communication.add(r.SubScores.Communication, sign)
+	return delta
+}
+
+// IsZero reports whether the aggregate would not change anything it is added to
+func (a RatingAggregate) IsZero() bool {
+	return a.Rating == ScoreTotal{} && a.Histogram == [MaxRating]int{} &&
+		a.Punctuality == ScoreTotal{} && a.Thoroughness == ScoreTotal{} && a.Communication == ScoreTotal{}
+}
+
+// Add folds delta into the aggregate
+func (a *RatingAggregate) Add(delta RatingAggregate) {
+	a.Rating.Sum += delta.Rating.Sum
+	a.Rating.Count += delta.Rating.Count
+	for i := range a.Histogram {
+		a.Histogram[i] += delta.Histogram[i]
+	}
+	for _, pair := range [][2]*ScoreTotal{
+		{&a.Punctuality, &delta.Punctuality},
+		{&a.Thoroughness, &delta.Thoroughness},
+		{&a.Communication, &delta.Communication},
+	} {
+		pair[0].Sum += pair[1].Sum
+		pair[0].Count += pair[1].Count
+	}
+}
+
+// RatingSummary is the public view of a rating aggregate
+type RatingSummary struct {
+	SubjectType RatingSubject `json:"subject_type"`
+	SubjectId   string        `json:"subject_id"`
+	Count       int           `json:"count"`
+	Mean        float64       `json:"mean"`
+	// WeightedScore is the mean pulled towards the platform mean, the less so the more reviews
+	// there are. It is the one to rank by.
+	WeightedScore float64        `json:"weighted_score"`
+	Histogram     map[string]int `json:"histogram"`
+	Punctuality   *float64       `json:"punctuality"`
+	Thoroughness  *float64       `json:"thoroughness"`
+	Communication *float64       `json:"communication"`
+}
+
+// Summary describes the aggregate, weighting its mean against the platform aggregate
+func (a RatingAggregate) Summary(platform RatingAggregate) RatingSummary {
+	summary := RatingSummary{
+		SubjectType:   a.SubjectType,
+		SubjectId:     a.SubjectId,
+		Count:         a.Rating.Count,
+		Histogram:     make(map[string]int, MaxRating),
+		Punctuality:   a.Punctuality.mean(),
+		Thoroughness:  a.Thoroughness.mean(),
+		Communication: a.Communication.mean(),
+	}
+	for i, count := range a.Histogram {
+		summary.Histogram[strconv.Itoa(i+1)] = count
+	}
+	if mean := a.Rating.mean(); mean != nil {
+		summary.Mean = *mean
+	}
+
+	prior := float64(MinRating+MaxRating) / 2
+	if mean := platform.Rating.mean(); mean != nil {
+		prior = *mean
+	}
+	summary.WeightedScore = (RatingPriorWeight*prior + float64(a.Rating.Sum)) / float64(RatingPriorWeight+a.Rating.Count)
+	return summary
+}
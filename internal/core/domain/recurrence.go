@@ -0,0 +1,369 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Frequency string
+
+const (
+	FrequencyWeekly  Frequency = "WEEKLY"
+	FrequencyMonthly Frequency = "MONTHLY"
+)
+
+// MaxOccurrences bounds how many occurrences a series can have and how far a schedule is searched
+const MaxOccurrences = 1000
+
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// RecurrenceRule is the subset of an iCalendar RRULE bookings use: FREQ=WEEKLY or MONTHLY, an
+// INTERVAL, BYDAY for weekly rules, and an end given by COUNT or UNTIL. Fortnightly is
+// FREQ=WEEKLY;INTERVAL=2. Monthly rules repeat on the day of the month of the first occurrence and
+// skip months too short to have it.
+type RecurrenceRule struct {
+	Frequency Frequency
+	Interval  int
+	Weekdays  []time.Weekday
+	Count     int
+	Until     *time.Time
+}
+
+// ParseRecurrenceRule reads a rule such as "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH;COUNT=10". An
+// "RRULE:" prefix is allowed. UNTIL is a date, 20250131, or a UTC time, 20250131T170000Z.
+func ParseRecurrenceRule(value string) (RecurrenceRule, error) {
+	rule := RecurrenceRule{Interval: 1}
+	value = strings.TrimPrefix(strings.TrimSpace(value), "RRULE:")
+	for _, part := range strings.Split(value, ";") {
+		key, val, ok := strings.Cut(part, "=")
+		if !ok {
+			return rule, fmt.Errorf("%w: recurrence rule part %q is not KEY=VALUE", ErrInvalidInput, part)
+		}
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			rule.Frequency = Frequency(strings.ToUpper(val))
+		case "INTERVAL":
+			interval, err := strconv.Atoi(val)
+			if err != nil {
+				return rule, fmt.Errorf("%w: INTERVAL must be a whole number", ErrInvalidInput)
+			}
+			rule.Interval = interval
+		case "BYDAY":
+			for _, code := range strings.Split(strings.ToUpper(val), ",") {
+				weekday, ok := weekdayCodes[code]
+				if !ok {
+					return rule, fmt.Errorf("%w: BYDAY %q is not one of MO, TU, WE, TH, FR, SA, SU", ErrInvalidInput, code)
+				}
+				rule.Weekdays = append(rule.Weekdays, weekday)
+			}
+		case "COUNT":
+			count, err := strconv.Atoi(val)
+			if err != nil {
+				return rule, fmt.Errorf("%w: COUNT must be a whole number", ErrInvalidInput)
+			}
+			rule.Count = count
+		case "UNTIL":
+			until, err := parseUntil(val)
+			if err != nil {
+				return rule, err
+			}
+			rule.Until = &until
+		default:
+			return rule, fmt.Errorf("%w: recurrence rule part %s is not supported", ErrInvalidInput, key)
+		}
+	}
+	return rule, rule.Validate()
+}
+
+func parseUntil(value string) (time.Time, error) {
+	if until, err := time.Parse("20060102T150405Z", value); err == nil {
+		return until, nil
+	}
+	if until, err := time.Parse("20060102", value); err == nil {
+		// A date includes the whole of that day
+		return until.Add(24*time.Hour - time.Second), nil
+	}
+	return time.Time{}, fmt.Errorf("%w: UNTIL must be formatted as 20060102 or 20060102T150405Z", ErrInvalidInput)
+}
+
+func (r RecurrenceRule) Validate() error {
+	switch {
+	case r.Frequency != FrequencyWeekly && r.Frequency != FrequencyMonthly:
+		return fmt.Errorf("%w: FREQ must be WEEKLY or MONTHLY", ErrInvalidInput)
+	case r.Interval < 1:
+		return fmt.Errorf("%w: INTERVAL must be at least 1", ErrInvalidInput)
+	case len(r.Weekdays) > 0 && r.Frequency != FrequencyWeekly:
+		return fmt.Errorf("%w: BYDAY is only supported with FREQ=WEEKLY", ErrInvalidInput)
+	case r.Count < 0 || r.Count > MaxOccurrences:
+		return fmt.Errorf("%w: COUNT must be between 1 and %d", ErrInvalidInput, MaxOccurrences)
+	case r.Count > 0 && r.Until != nil:
+		return fmt.Errorf("%w: a rule ends either after COUNT occurrences or at UNTIL, not both", ErrInvalidInput)
+	}
+	return nil
+}
+
+func (r RecurrenceRule) String() string {
+	parts := []string{"FREQ=" + string(r.Frequency)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.Weekdays) > 0 {
+		codes := make([]string, len(r.Weekdays))
+		for i, weekday := range r.Weekdays {
+			codes[i] = strings.ToUpper(weekday.String()[:2])
+		}
+		parts = append(parts, "BYDAY="+strings.Join(codes, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	return strings.Join(parts, ";")
+}
+
+// MarshalText writes the rule in its RRULE form, which is how it is sent and stored
+func (r RecurrenceRule) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+func (r *RecurrenceRule) UnmarshalText(text []byte) error {
+	rule, err := ParseRecurrenceRule(string(text))
+	if err != nil {
+		return err
+	}
+	*r = rule
+	return nil
+}
+
+// Occurrences returns the occurrences of a series first occurring at start that fall in
+// [from, to). Occurrences keep start's time of day in start's location, whatever the offset of
+// that location at the time.
+func (r RecurrenceRule) Occurrences(start, from, to time.Time) []time.Time {
+	var occurrences []time.Time
+	r.walk(start, func(at time.Time) bool {
+		if !at.Before(to) {
+			return false
+		}
+		if !at.Before(from) {
+			occurrences = append(occurrences, at)
+		}
+		return true
+	})
+	return occurrences
+}
+
+// IsOccurrence reports whether at is one of the occurrences of the series first occurring at start
+func (r RecurrenceRule) IsOccurrence(start, at time.Time) bool {
+	found := false
+	r.walk(start, func(next time.Time) bool {
+		found = next.Equal(at)
+		return next.Before(at)
+	})
+	return found
+}
+
+// walk calls visit with every occurrence in order until visit returns false or the series ends
+func (r RecurrenceRule) walk(start time.Time, visit func(time.Time) bool) {
+	location := start.Location()
+	hour, minute, second := start.Clock()
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, hour, minute, second, 0, location)
+	}
+
+	emitted := 0
+	emit := func(occurrence time.Time) bool {
+		if occurrence.Before(start) {
+			return true
+		}
+		if (r.Count > 0 && emitted >= r.Count) || (r.Until != nil && occurrence.After(*r.Until)) {
+			return false
+		}
+		emitted++
+		return visit(occurrence)
+	}
+
+	switch r.Frequency {
+	case FrequencyWeekly:
+		weekdays := r.Weekdays
+		if len(weekdays) == 0 {
+			weekdays = []time.Weekday{start.Weekday()}
+		}
+		// Weeks start on Monday, as they do in RRULE by default
+		offsets := make([]int, len(weekdays))
+		for i, weekday := range weekdays {
+			offsets[i] = (int(weekday) + 6) % 7
+		}
+		sort.Ints(offsets)
+		year, month, day := start.Date()
+		monday := day - (int(start.Weekday())+6)%7
+		for week := 0; week < MaxOccurrences; week += r.Interval {
+			for _, offset := range offsets {
+				if !emit(at(year, month, monday+7*week+offset)) {
+					return
+				}
+			}
+		}
+
+	case FrequencyMonthly:
+		year, month, day := start.Date()
+		for step := 0; step < MaxOccurrences; step += r.Interval {
+			occurrence := at(year, month+time.Month(step), day)
+			// time.Date carries a 31st into the next month, which is not an occurrence
+			if occurrence.Day() != day {
+				continue
+			}
+			if !emit(occurrence) {
+				return
+			}
+		}
+	}
+}
+
+type RecurringBookingStatus string
+
+const (
+	RecurringActive    RecurringBookingStatus = "active"
+	RecurringCancelled RecurringBookingStatus = "cancelled"
+)
+
+// RecurringBooking books the same cleaning on a schedule. Its occurrences are materialised as
+// ordinary requests some time ahead, carrying the booking's id and the occurrence they stand for.
+type RecurringBooking struct {
+	RecurringBookingId string `json:"recurring_booking_id"`
+	ClientId           string `json:"client_id"`
+	// CleanerId is the cleaner each occurrence is booked with when they are free, the occurrence is
+	// left for dispatch otherwise
+	CleanerId    string         `json:"cleaner_id"`
+	ServiceId    string         `json:"service_id"`
+	StartsAt     time.Time      `json:"starts_at"`
+	Rule         RecurrenceRule `json:"rule"`
+	Latitude     float64        `json:"latitude"`
+	Longitude    float64        `json:"longitude"`
+	AddOns       []string       `json:"add_ons"`
+	DiscountCode string         `json:"discount_code"`
+	// Exceptions are the occurrences skipped or moved, keyed by when they were due
+	Exceptions []OccurrenceException  `json:"exceptions"`
+	Status     RecurringBookingStatus `json:"status"`
+	// GeneratedUntil is how far ahead occurrences have been materialised as requests
+	GeneratedUntil time.Time  `json:"generated_until"`
+	CancelledAt    *time.Time `json:"cancelled_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// OccurrenceException skips one occurrence of a series, or moves it to RescheduledTo
+type OccurrenceException struct {
+	OccurrenceAt  time.Time  `json:"occurrence_at"`
+	Skipped       bool       `json:"skipped"`
+	RescheduledTo *time.Time `json:"rescheduled_to,omitempty"`
+}
+
+// Occurrence is one scheduled cleaning of a series
+type Occurrence struct {
+	// OccurrenceAt is when the rule has the occurrence, it identifies the occurrence
+	OccurrenceAt time.Time `json:"occurrence_at"`
+	// ScheduledAt is when the cleaning happens, later or earlier if it was rescheduled
+	ScheduledAt time.Time `json:"scheduled_at"`
+	Skipped     bool      `json:"skipped"`
+}
+
+// Schedule lists the occurrences due in [from, to) with the exceptions applied. Skipped
+// occurrences are listed too, marked as such.
+func (b RecurringBooking) Schedule(from, to time.Time) []Occurrence {
+	if b.Status == RecurringCancelled && b.CancelledAt != nil && b.CancelledAt.Before(to) {
+		to = *b.CancelledAt
+	}
+	var occurrences []Occurrence
+	for _, at := range b.Rule.Occurrences(b.StartsAt, from, to) {
+		occurrence := Occurrence{OccurrenceAt: at, ScheduledAt: at}
+		if exception := b.exception(at); exception != nil {
+			occurrence.Skipped = exception.Skipped
+			if exception.RescheduledTo != nil {
+				occurrence.ScheduledAt = *exception.RescheduledTo
+			}
+		}
+		occurrences = append(occurrences, occurrence)
+	}
+	return occurrences
+}
+
+func (b RecurringBooking) exception(at time.Time) *OccurrenceException {
+	for i := range b.Exceptions {
+		if b.Exceptions[i].OccurrenceAt.Equal(at) {
+			return &b.Exceptions[i]
+		}
+	}
+	return nil
+}
+
+// ValidateNew checks a booking before it is created at now
+func (b RecurringBooking) ValidateNew(now time.Time) error {
+	if b.ClientId == "" || b.ServiceId == "" {
+		return fmt.Errorf("%w: a recurring booking needs a client and a service", ErrInvalidInput)
+	}
+	if !b.StartsAt.After(now) {
+		return fmt.Errorf("%w: starts_at must be in the future", ErrInvalidInput)
+	}
+	if err := validateCoordinates(b.Latitude, b.Longitude); err != nil {
+		return err
+	}
+	return b.Rule.Validate()
+}
+
+// RequestFor is the request booking the occurrence
+func (b RecurringBooking) RequestFor(occurrence Occurrence) Request {
+	occurrenceAt := occurrence.OccurrenceAt
+	return Request{
+		ClientId:           b.ClientId,
+		CleanerId:          b.CleanerId,
+		ServiceId:          b.ServiceId,
+		RequestedDate:      occurrence.ScheduledAt,
+		Latitude:           b.Latitude,
+		Longitude:          b.Longitude,
+		AddOns:             b.AddOns,
+		DiscountCode:       b.DiscountCode,
+		RecurringBookingId: b.RecurringBookingId,
+		OccurrenceAt:       &occurrenceAt,
+	}
+}
+
+// SetException skips or reschedules the occurrence due at occurrenceAt, replacing what was set for
+// it before. Only future occurrences of an active series can change.
+func (b *RecurringBooking) SetException(exception OccurrenceException, now time.Time) error {
+	if b.Status != RecurringActive {
+		return fmt.Errorf("%w: recurring booking %s is %s", ErrInvalidInput, b.RecurringBookingId, b.Status)
+	}
+	if !b.Rule.IsOccurrence(b.StartsAt, exception.OccurrenceAt) {
+		return fmt.Errorf("%w: recurring booking %s has no occurrence at %s", ErrNotFound, b.RecurringBookingId, exception.OccurrenceAt.Format(time.RFC3339))
+	}
+	if !exception.OccurrenceAt.After(now) {
+		return fmt.Errorf("%w: the occurrence at %s has passed", ErrInvalidInput, exception.OccurrenceAt.Format(time.RFC3339))
+	}
+	if exception.RescheduledTo != nil && !exception.RescheduledTo.After(now) {
+		return fmt.Errorf("%w: an occurrence can only be moved to the future", ErrInvalidInput)
+	}
+	if existing := b.exception(exception.OccurrenceAt); existing != nil {
+		*existing = exception
+		return nil
+	}
+	b.Exceptions = append(b.Exceptions, exception)
+	return nil
+}
+
+// Cancel ends the series at now, occurrences before then stand
+func (b *RecurringBooking) Cancel(now time.Time) error {
+	if b.Status == RecurringCancelled {
+		return fmt.Errorf("%w: recurring booking %s is already cancelled", ErrInvalidInput, b.RecurringBookingId)
+	}
+	b.Status = RecurringCancelled
+	b.CancelledAt = &now
+	return nil
+}
@@ -0,0 +1,173 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrNotReviewable reports a request that cannot be reviewed, at least not any more
+var ErrNotReviewable = newError(KindConflict, "not_reviewable", "request cannot be reviewed")
+
+// CheckReviewable checks that clientId may review the request at now: the request must be theirs,
+// completed, and completed no longer than window ago
+func (r Request) CheckReviewable(clientId string, now time.Time, window time.Duration) error {
+	if clientId == "" || clientId != r.ClientId {
+		return fmt.Errorf("%w: only the client of request %s can review it", ErrForbidden, r.RequestId)
+	}
+	if r.Status != RequestCompleted {
+		return fmt.Errorf("%w: request %s is %s, only completed requests are reviewed", ErrNotReviewable, r.RequestId, r.Status)
+	}
+	completedAt := r.UpdatedAt
+	if r.CompletedAt != nil {
+		completedAt = *r.CompletedAt
+	}
+	if now.After(completedAt.Add(window)) {
+		return fmt.Errorf("%w: reviews of request %s closed on %s", ErrNotReviewable, r.RequestId, completedAt.Add(window).Format(time.RFC3339))
+	}
+	return nil
+}
+
+type ReviewStatus string
+
+const (
+	// ReviewPending reviews were held by the content filter and wait for a moderator
+	ReviewPending   ReviewStatus = "pending"
+	ReviewPublished ReviewStatus = "published"
+	ReviewRejected  ReviewStatus = "rejected"
+	// ReviewFlagged reviews were reported by a user. They stay up until a moderator decides, so
+	// flagging cannot be used to take down a fair review.
+	ReviewFlagged ReviewStatus = "flagged"
+)
+
+// reviewTransitions lists, for every status, the statuses a moderator may move a review to
+var reviewTransitions = map[ReviewStatus][]ReviewStatus{
+	ReviewPending:   {ReviewPublished, ReviewRejected},
+	ReviewPublished: {ReviewRejected},
+	ReviewFlagged:   {ReviewPublished, ReviewRejected},
+	ReviewRejected:  {ReviewPublished},
+}
+
+func (s ReviewStatus) IsValid() bool {
+	_, ok := reviewTransitions[s]
+	return ok
+}
+
+// IsLive reports whether reviews in status s are shown publicly and count towards ratings
+func (s ReviewStatus) IsLive() bool {
+	return s == ReviewPublished || s == ReviewFlagged
+}
+
+// LiveReviewStatuses are the statuses of the reviews everyone may see
+var LiveReviewStatuses = []ReviewStatus{ReviewPublished, ReviewFlagged}
+
+type ReviewTransitionError struct {
+	From ReviewStatus
+	To   ReviewStatus
+}
+
+func (e ReviewTransitionError) Error() string {
+	return fmt.Sprintf("cannot move review from %q to %q", e.From, e.To)
+}
+
+func (e ReviewTransitionError) Unwrap() error {
+	return ErrInvalidTransition
+}
+
+// ReviewFlag is one user's report of a review
+type ReviewFlag struct {
+	FlaggedBy string    `json:"flagged_by"`
+	Reason    string    `json:"reason"`
+	FlaggedAt time.Time `json:"flagged_at"`
+}
+
+// ReviewResponse is the cleaner's public reply to a review about them
+type ReviewResponse struct {
+	Body        string    `json:"body"`
+	RespondedAt time.Time `json:"responded_at"`
+}
+
+// MaxResponseLength bounds a cleaner's response, in characters
+const MaxResponseLength = 2000
+
+// ContentVerdict is a content filter's judgement of some text
+type ContentVerdict struct {
+	Allowed bool
+	// Matches are the terms that made the filter object
+	Matches []string
+}
+
+// Moderate moves the review to status on behalf of moderator, noting why
+func (r *Reviews) Moderate(status ReviewStatus, moderator, note string, at time.Time) error {
+	allowed := false
+	for _, next := range reviewTransitions[r.Status] {
+		allowed = allowed || next == status
+	}
+	if !allowed {
+		return ReviewTransitionError{From: r.Status, To: status}
+	}
+	r.Status = status
+	r.ModerationNote = note
+	r.ModeratedBy = moderator
+	r.ModeratedAt = &at
+	if status == ReviewPublished {
+		r.Flags = nil
+	}
+	return nil
+}
+
+// Screen holds the review for moderation if the content filter objected to its comment, and sends
+// a rejected review back for moderation once its author has changed it
+func (r *Reviews) Screen(verdict ContentVerdict) {
+	switch {
+	case !verdict.Allowed:
+		r.Status = ReviewPending
+		r.ModerationNote = "held by the content filter: " + strings.Join(verdict.Matches, ", ")
+	case r.Status == "":
+		r.Status = ReviewPublished
+	case r.Status == ReviewRejected:
+		r.Status = ReviewPending
+	}
+}
+
+// Flag records a user's report of a live review, once per user
+func (r *Reviews) Flag(flag ReviewFlag) error {
+	if !r.Status.IsLive() {
+		return ReviewTransitionError{From: r.Status, To: ReviewFlagged}
+	}
+	if flag.FlaggedBy == "" {
+		return fmt.Errorf("%w: a flag needs the user raising it", ErrInvalidInput)
+	}
+	for _, existing := range r.Flags {
+		if existing.FlaggedBy == flag.FlaggedBy {
+			return fmt.Errorf("%w: review %s is already flagged by %s", ErrAlreadyExists, r.ReviewId, flag.FlaggedBy)
+		}
+	}
+	r.Flags = append(r.Flags, flag)
+	r.Status = ReviewFlagged
+	return nil
+}
+
+// Respond sets the reviewed cleaner's reply, replacing any earlier one
+func (r *Reviews) Respond(cleanerId, body string, at time.Time) error {
+	if cleanerId == "" || cleanerId != r.CleanerId {
+		return fmt.Errorf("%w: only the reviewed cleaner can respond to review %s", ErrForbidden, r.ReviewId)
+	}
+	if !r.Status.IsLive() {
+		return fmt.Errorf("%w: review %s is %s, only published reviews can be responded to", ErrInvalidInput, r.ReviewId, r.Status)
+	}
+	body = strings.TrimSpace(body)
+	if body == "" || utf8.RuneCountInString(body) > MaxResponseLength {
+		return fmt.Errorf("%w: a response must be between 1 and %d characters", ErrInvalidInput, MaxResponseLength)
+	}
+	r.Response = &ReviewResponse{Body: body, RespondedAt: at}
+	return nil
+}
+
+// PublicView hides who moderated and who flagged the review, which only moderators may see
+func (r Reviews) PublicView() Reviews {
+	r.ModeratedBy = ""
+	r.Flags = nil
+	return r
+}
@@ -1,65 +1,255 @@
 package ports
 
-import "github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+import (
+	"context"
+	"time"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+)
 
 type ServiceService interface {
-	CreateService(service domain.Service) (*domain.Service, error)
-	GetServiceById(service_id string) (*domain.Service, error)
-	GetServices() (*[]domain.Service, error)
-	UpdateService(service domain.Service) (*domain.Service, error)
-	DeleteService(service_id string) error
+	CreateService(ctx context.Context, service domain.Service) (*domain.Service, error)
+	GetServiceById(ctx context.Context, service_id string) (*domain.Service, error)
+	GetServices(ctx context.Context, filter domain.ServiceFilter) (*domain.Page[domain.Service], error)
+	UpdateService(ctx context.Context, service domain.Service) (*domain.Service, error)
+	DeleteService(ctx context.Context, service_id string) error
 }
 
 type RequestService interface {
-	CreateRequest(request domain.Request) (*domain.Request, error)
-	GetRequestById(request_id string) (*domain.Request, error)
-	GetRequests() (*[]domain.Request, error)
-	UpdateRequest(request domain.Request) (*domain.Request, error)
-	DeleteRequest(request_id string) error
-	AssignCleaner(request_id, cleaner_id string) error
-	GetRequestByClient(client_id string) (*[]domain.Request, error)
-	GetRequestByCleaner(cleaner_id string) (*[]domain.Request, error)
+	CreateRequest(ctx context.Context, request domain.Request) (*domain.Request, error)
+	GetRequestById(ctx context.Context, request_id string) (*domain.Request, error)
+	GetRequests(ctx context.Context, filter domain.RequestFilter) (*domain.Page[domain.Request], error)
+	UpdateRequest(ctx context.Context, request domain.Request) (*domain.Request, error)
+	AssignCleaner(ctx context.Context, request_id, cleaner_id string) error
+	TransitionRequest(ctx context.Context, request_id string, status domain.RequestStatus) (*domain.Request, error)
+	// CancelRequest cancels a booking under the cancellation policy, or hands it back to dispatch
+	// when its cleaner pulls out. The request is kept either way.
+	CancelRequest(ctx context.Context, request_id string, change domain.BookingChange) (*domain.Request, error)
+	RescheduleRequest(ctx context.Context, request_id string, requested_date time.Time, change domain.BookingChange) (*domain.Request, error)
+	GetRequestByClient(ctx context.Context, client_id string, filter domain.RequestFilter) (*domain.Page[domain.Request], error)
+	GetRequestByCleaner(ctx context.Context, cleaner_id string, filter domain.RequestFilter) (*domain.Page[domain.Request], error)
 }
 
 type ReviewService interface {
-	CreateReview(review domain.Reviews) (*domain.Reviews, error)
-	GetReviewById(review_id string) (*domain.Reviews, error)
-	UpdateReview(review domain.Reviews) (*domain.Reviews, error)
-	DeleteReview(review_id string) error
-	GetReviewByClient(client_id string) (*[]domain.Reviews, error)
-	GetReviewByCleaner(cleaner_id string) (*[]domain.Reviews, error)
+	CreateReview(ctx context.Context, review domain.Reviews) (*domain.Reviews, error)
+	GetReviewById(ctx context.Context, review_id string) (*domain.Reviews, error)
+	UpdateReview(ctx context.Context, review domain.Reviews) (*domain.Reviews, error)
+	DeleteReview(ctx context.Context, review_id string) error
+	GetReviewByClient(ctx context.Context, client_id string, filter domain.ReviewFilter) (*domain.Page[domain.Reviews], error)
+	GetReviewByCleaner(ctx context.Context, cleaner_id string, filter domain.ReviewFilter) (*domain.Page[domain.Reviews], error)
+	GetCleanerRatingSummary(ctx context.Context, cleaner_id string) (*domain.RatingSummary, error)
+	GetServiceRatingSummary(ctx context.Context, service_id string) (*domain.RatingSummary, error)
+	GetReviews(ctx context.Context, filter domain.ReviewFilter) (*domain.Page[domain.Reviews], error)
+	ApproveReview(ctx context.Context, review_id, moderator_id, note string) (*domain.Reviews, error)
+	RejectReview(ctx context.Context, review_id, moderator_id, note string) (*domain.Reviews, error)
+	FlagReview(ctx context.Context, review_id, flagged_by, reason string) (*domain.Reviews, error)
+	RespondToReview(ctx context.Context, review_id, cleaner_id, body string) (*domain.Reviews, error)
+}
+
+// ContentFilter screens text users publish, such as review comments
+type ContentFilter interface {
+	Screen(ctx context.Context, text string) (*domain.ContentVerdict, error)
+}
+
+type AvailabilityService interface {
+	SetWorkingHours(ctx context.Context, cleaner_id string, hours []domain.WorkingHours) (*[]domain.WorkingHours, error)
+	GetWorkingHours(ctx context.Context, cleaner_id string) (*[]domain.WorkingHours, error)
+	CreateException(ctx context.Context, exception domain.AvailabilityException) (*domain.AvailabilityException, error)
+	GetExceptions(ctx context.Context, cleaner_id string, from, to time.Time) (*[]domain.AvailabilityException, error)
+	DeleteException(ctx context.Context, cleaner_id, exception_id string) error
+	CreateTimeOff(ctx context.Context, timeOff domain.TimeOff) (*domain.TimeOff, error)
+	GetTimeOff(ctx context.Context, cleaner_id string, from, to time.Time) (*[]domain.TimeOff, error)
+	DeleteTimeOff(ctx context.Context, cleaner_id, time_off_id string) error
+	GetSlots(ctx context.Context, cleaner_id string, date time.Time, service_id string) (*[]domain.Slot, error)
+	CheckAvailability(ctx context.Context, cleaner_id string, slot domain.Slot, exclude_request_id string) error
+}
+
+type PricingService interface {
+	QuoteRequest(ctx context.Context, request domain.Request) (*domain.Quote, error)
+	// QuoteChangeFee prices cancelling or rescheduling the request at at under the cancellation policy
+	QuoteChangeFee(ctx context.Context, request domain.Request, kind domain.BookingChangeKind, initiator domain.ChangeInitiator, at time.Time) (*domain.Money, error)
+}
+
+type InvoiceService interface {
+	GenerateInvoice(ctx context.Context, request_id string) (*domain.Invoice, error)
+	GetInvoiceById(ctx context.Context, invoice_id string) (*domain.Invoice, error)
+	GetInvoiceByRequest(ctx context.Context, request_id string) (*domain.Invoice, error)
+	GetInvoicesByClient(ctx context.Context, client_id string, filter domain.InvoiceFilter) (*domain.Page[domain.Invoice], error)
+	RenderInvoicePDF(ctx context.Context, invoice_id string) ([]byte, error)
+}
+
+// InvoiceRenderer turns an invoice into a printable document
+type InvoiceRenderer interface {
+	Render(invoice domain.Invoice) ([]byte, error)
+}
+
+type PaymentService interface {
+	InitiatePayment(ctx context.Context, request_id, phone_number string) (*domain.Payment, error)
+	HandleCallback(ctx context.Context, body []byte) (*domain.Payment, error)
+	GetPaymentById(ctx context.Context, payment_id string) (*domain.Payment, error)
+	GetPaymentsByRequest(ctx context.Context, request_id string) (*[]domain.Payment, error)
+}
+
+// PaymentProvider charges a client through an external payment network. Charges complete
+// asynchronously, the provider later posts a callback that ParseCallback understands.
+type PaymentProvider interface {
+	Name() string
+	InitiateCharge(ctx context.Context, payment domain.Payment) (string, error)
+	ParseCallback(body []byte) (*domain.PaymentCallback, error)
+}
+
+type RecurringBookingService interface {
+	CreateRecurringBooking(ctx context.Context, booking domain.RecurringBooking) (*domain.RecurringBooking, error)
+	GetRecurringBookingById(ctx context.Context, recurring_booking_id string) (*domain.RecurringBooking, error)
+	GetRecurringBookingsByClient(ctx context.Context, client_id string) (*[]domain.RecurringBooking, error)
+	GetSchedule(ctx context.Context, recurring_booking_id string, from, to time.Time) (*[]domain.Occurrence, error)
+	SkipOccurrence(ctx context.Context, recurring_booking_id string, occurrence_at time.Time) (*domain.RecurringBooking, error)
+	RescheduleOccurrence(ctx context.Context, recurring_booking_id string, occurrence_at, requested_date time.Time) (*domain.RecurringBooking, error)
+	CancelRecurringBooking(ctx context.Context, recurring_booking_id string) (*domain.RecurringBooking, error)
+	// GenerateOccurrences materialises the occurrences of every active series due within the
+	// generation horizon as requests
+	GenerateOccurrences(ctx context.Context) error
+}
+
+type MatchingService interface {
+	UpsertCleanerProfile(ctx context.Context, profile domain.CleanerProfile) (*domain.CleanerProfile, error)
+	GetCleanerProfile(ctx context.Context, cleaner_id string) (*domain.CleanerProfile, error)
+	RankCleaners(ctx context.Context, request_id string) (*[]domain.CleanerMatch, error)
+	AutoAssign(ctx context.Context, request_id string) (*domain.Request, error)
+}
+
+// MatchingStrategy orders the cleaners that passed the eligibility checks, best first
+type MatchingStrategy interface {
+	Rank(request domain.Request, candidates []domain.CleanerCandidate) []domain.CleanerMatch
 }
 
 type ServiceRepository interface {
-	CreateService(service domain.Service) (*domain.Service, error)
-	GetServiceById(service_id string) (*domain.Service, error)
-	GetServices() (*[]domain.Service, error)
-	UpdateService(service domain.Service) (*domain.Service, error)
-	DeleteService(service_id string) error
+	CreateService(ctx context.Context, service domain.Service) (*domain.Service, error)
+	GetServiceById(ctx context.Context, service_id string) (*domain.Service, error)
+	GetServices(ctx context.Context, filter domain.ServiceFilter) (*domain.Page[domain.Service], error)
+	UpdateService(ctx context.Context, service domain.Service) (*domain.Service, error)
+	DeleteService(ctx context.Context, service_id string) error
 }
 
 type RequestRepository interface {
-	CreateRequest(request domain.Request) (*domain.Request, error)
-	GetRequestById(request_id string) (*domain.Request, error)
-	GetRequests() (*[]domain.Request, error)
-	UpdateRequest(request domain.Request) (*domain.Request, error)
-	DeleteRequest(request_id string) error
-	AssignCleaner(request_id, cleaner_id string) error
-	GetRequestByClient(client_id string) (*[]domain.Request, error)
-	GetRequestByCleaner(cleaner_id string) (*[]domain.Request, error)
+	CreateRequest(ctx context.Context, request domain.Request) (*domain.Request, error)
+	GetRequestById(ctx context.Context, request_id string) (*domain.Request, error)
+	GetRequests(ctx context.Context, filter domain.RequestFilter) (*domain.Page[domain.Request], error)
+	// UpdateRequest writes the request if it is still in the status from it was read in, and returns
+	// ErrInvalidTransition otherwise
+	UpdateRequest(ctx context.Context, request domain.Request, from domain.RequestStatus) (*domain.Request, error)
+	DeleteRequest(ctx context.Context, request_id string) error
+	AssignCleaner(ctx context.Context, request_id, cleaner_id string) error
+	// UpdateRequestStatus moves the request from one status to another. It returns
+	// ErrInvalidTransition when the request is no longer in from, so of two concurrent transitions
+	// only one goes through.
+	UpdateRequestStatus(ctx context.Context, request_id string, from, to domain.RequestStatus) error
+	MarkRequestPaid(ctx context.Context, request_id string, paid_at time.Time) error
+	GetRequestByClient(ctx context.Context, client_id string, filter domain.RequestFilter) (*domain.Page[domain.Request], error)
+	GetRequestByCleaner(ctx context.Context, cleaner_id string, filter domain.RequestFilter) (*domain.Page[domain.Request], error)
 }
 
 type ReviewRepository interface {
-	CreateReview(review domain.Reviews) (*domain.Reviews, error)
-	GetReviewById(review_id string) (*domain.Reviews, error)
-	UpdateReview(review domain.Reviews) (*domain.Reviews, error)
-	DeleteReview(review_id string) error
-	GetReviewByClient(client_id string) (*[]domain.Reviews, error)
-	GetReviewByCleaner(cleaner_id string) (*[]domain.Reviews, error)
+	CreateReview(ctx context.Context, review domain.Reviews) (*domain.Reviews, error)
+	GetReviewById(ctx context.Context, review_id string) (*domain.Reviews, error)
+	UpdateReview(ctx context.Context, review domain.Reviews) (*domain.Reviews, error)
+	DeleteReview(ctx context.Context, review_id string) error
+	GetReviewByClient(ctx context.Context, client_id string, filter domain.ReviewFilter) (*domain.Page[domain.Reviews], error)
+	GetReviewByCleaner(ctx context.Context, cleaner_id string, filter domain.ReviewFilter) (*domain.Page[domain.Reviews], error)
+	GetReviews(ctx context.Context, filter domain.ReviewFilter) (*domain.Page[domain.Reviews], error)
+	// AddRatings adds delta to the aggregate of its subject, creating the aggregate if needed
+	AddRatings(ctx context.Context, delta domain.RatingAggregate) error
+	// GetRatingAggregate returns an empty aggregate for a subject nobody has rated yet
+	GetRatingAggregate(ctx context.Context, subject_type domain.RatingSubject, subject_id string) (*domain.RatingAggregate, error)
+}
+
+type RecurringBookingRepository interface {
+	CreateRecurringBooking(ctx context.Context, booking domain.RecurringBooking) (*domain.RecurringBooking, error)
+	GetRecurringBookingById(ctx context.Context, recurring_booking_id string) (*domain.RecurringBooking, error)
+	// LockRecurringBookingById reads the series for a unit of work that changes it, nothing else can
+	// change the series until the unit of work ends
+	LockRecurringBookingById(ctx context.Context, recurring_booking_id string) (*domain.RecurringBooking, error)
+	GetRecurringBookingsByClient(ctx context.Context, client_id string) (*[]domain.RecurringBooking, error)
+	// GetActiveRecurringBookings returns the series with occurrences not yet generated before until
+	GetActiveRecurringBookings(ctx context.Context, until time.Time) (*[]domain.RecurringBooking, error)
+	UpdateRecurringBooking(ctx context.Context, booking domain.RecurringBooking) (*domain.RecurringBooking, error)
+	// SetGeneratedUntil records how far an active series has been generated and leaves the rest of
+	// it alone. It returns ErrInvalidTransition when the series is no longer active.
+	SetGeneratedUntil(ctx context.Context, recurring_booking_id string, generated_until, updated_at time.Time) error
+}
+
+type AvailabilityRepository interface {
+	SetWorkingHours(ctx context.Context, cleaner_id string, hours []domain.WorkingHours) error
+	GetWorkingHours(ctx context.Context, cleaner_id string) (*[]domain.WorkingHours, error)
+	CreateException(ctx context.Context, exception domain.AvailabilityException) (*domain.AvailabilityException, error)
+	GetExceptions(ctx context.Context, cleaner_id string, from, to time.Time) (*[]domain.AvailabilityException, error)
+	DeleteException(ctx context.Context, cleaner_id, exception_id string) error
+	CreateTimeOff(ctx context.Context, timeOff domain.TimeOff) (*domain.TimeOff, error)
+	GetTimeOff(ctx context.Context, cleaner_id string, from, to time.Time) (*[]domain.TimeOff, error)
+	DeleteTimeOff(ctx context.Context, cleaner_id, time_off_id string) error
+	// LockCleaner holds the cleaner's calendar until the unit of work ctx belongs to ends, so two
+	// bookings checked and written in their own units of work cannot both take the same slot.
+	LockCleaner(ctx context.Context, cleaner_id string) error
+}
+
+type InvoiceRepository interface {
+	CreateInvoice(ctx context.Context, invoice domain.Invoice) (*domain.Invoice, error)
+	GetInvoiceById(ctx context.Context, invoice_id string) (*domain.Invoice, error)
+	GetInvoiceByRequest(ctx context.Context, request_id string) (*domain.Invoice, error)
+	GetInvoicesByClient(ctx context.Context, client_id string, filter domain.InvoiceFilter) (*domain.Page[domain.Invoice], error)
+}
+
+type PaymentRepository interface {
+	CreatePayment(ctx context.Context, payment domain.Payment) (*domain.Payment, error)
+	GetPaymentById(ctx context.Context, payment_id string) (*domain.Payment, error)
+	GetPaymentByReference(ctx context.Context, provider, reference string) (*domain.Payment, error)
+	GetPaymentsByRequest(ctx context.Context, request_id string) (*[]domain.Payment, error)
+	// UpdatePayment writes a payment that is still pending. It returns ErrPaymentSettled when the
+	// payment was settled since it was read, so a retried callback cannot settle it twice.
+	UpdatePayment(ctx context.Context, payment domain.Payment) (*domain.Payment, error)
+}
+
+type CleanerProfileRepository interface {
+	UpsertCleanerProfile(ctx context.Context, profile domain.CleanerProfile) (*domain.CleanerProfile, error)
+	GetCleanerProfile(ctx context.Context, cleaner_id string) (*domain.CleanerProfile, error)
+	GetActiveCleanersForService(ctx context.Context, service_id string) (*[]domain.CleanerProfile, error)
+}
+
+// TokenVerifier checks an access token and returns the caller it was issued to
+type TokenVerifier interface {
+	Verify(token string) (*domain.Principal, error)
 }
 
 type LoggerService interface {
+	Debug(message string)
 	Info(message string)
 	Warning(message string)
 	Error(message string)
+	// With returns a logger that adds fields to every line it writes
+	With(fields map[string]interface{}) LoggerService
+	// WithContext returns a logger that adds the request fields ctx carries, such as the request id
+	WithContext(ctx context.Context) LoggerService
+}
+
+// Tracer starts spans. A span started from a context that already carries one becomes its child.
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+type Span interface {
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+}
+
+// HealthCheck probes one dependency the service needs in order to serve requests
+type HealthCheck interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+// UnitOfWork makes the repository calls fn makes with the context it is given atomic. They are
+// committed together when fn returns nil and rolled back otherwise.
+type UnitOfWork interface {
+	Do(ctx context.Context, fn func(ctx context.Context) error) error
 }
@@ -0,0 +1,249 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/ports"
+	"github.com/google/uuid"
+)
+
+// slotStep is the spacing between the start times offered by GetSlots
+const slotStep = 30 * time.Minute
+
+type AvailabilityServiceManagement struct {
+	repo        ports.AvailabilityRepository
+	requestRepo ports.RequestRepository
+	serviceRepo ports.ServiceRepository
+	location    *time.Location
+	logger      ports.LoggerService
+}
+
+func NewAvailabilityServiceManagement(repo ports.AvailabilityRepository, requestRepo ports.RequestRepository, serviceRepo ports.ServiceRepository, location *time.Location, logger ports.LoggerService) *AvailabilityServiceManagement {
+	service := AvailabilityServiceManagement{
+		repo:        repo,
+		requestRepo: requestRepo,
+		serviceRepo: serviceRepo,
+		location:    location,
+		logger:      logger,
+	}
+	return &service
+}
+
+func (svc AvailabilityServiceManagement) SetWorkingHours(ctx context.Context, cleaner_id string, hours []domain.WorkingHours) (*[]domain.WorkingHours, error) {
+	ctx, span := tracer.Start(ctx, "AvailabilityService.SetWorkingHours")
+	defer span.End()
+
+	for i := range hours {
+		if err := hours[i].Validate(); err != nil {
+			return nil, err
+		}
+		hours[i].CleanerId = cleaner_id
+	}
+
+	if err := svc.repo.SetWorkingHours(ctx, cleaner_id, hours); err != nil {
+		return nil, err
+	}
+	return svc.repo.GetWorkingHours(ctx, cleaner_id)
+}
+
+func (svc AvailabilityServiceManagement) GetWorkingHours(ctx context.Context, cleaner_id string) (*[]domain.WorkingHours, error) {
+	ctx, span := tracer.Start(ctx, "AvailabilityService.GetWorkingHours")
+	defer span.End()
+
+	return svc.repo.GetWorkingHours(ctx, cleaner_id)
+}
+
+func (svc AvailabilityServiceManagement) CreateException(ctx context.Context, exception domain.AvailabilityException) (*domain.AvailabilityException, error) {
+	ctx, span := tracer.Start(ctx, "AvailabilityService.CreateException")
+	defer span.End()
+
+	if err := exception.Validate(); err != nil {
+		return nil, err
+	}
+	exception.ExceptionId = uuid.New().String()
+	exception.CreatedAt = time.Now()
+	return svc.repo.CreateException(ctx, exception)
+}
+
+func (svc AvailabilityServiceManagement) GetExceptions(ctx context.Context, cleaner_id string, from, to time.Time) (*[]domain.AvailabilityException, error) {
+	ctx, span := tracer.Start(ctx, "AvailabilityService.GetExceptions")
+	defer span.End()
+
+	return svc.repo.GetExceptions(ctx, cleaner_id, from, to)
+}
+
+func (svc AvailabilityServiceManagement) DeleteException(ctx context.Context, cleaner_id, exception_id string) error {
+	ctx, span := tracer.Start(ctx, "AvailabilityService.DeleteException")
+	defer span.End()
+
+	return svc.repo.DeleteException(ctx, cleaner_id, exception_id)
+}
+
+func (svc AvailabilityServiceManagement) CreateTimeOff(ctx context.Context, timeOff domain.TimeOff) (*domain.TimeOff, error) {
+	ctx, span := tracer.Start(ctx, "AvailabilityService.CreateTimeOff")
+	defer span.End()
+
+	if err := timeOff.Validate(); err != nil {
+		return nil, err
+	}
+	timeOff.TimeOffId = uuid.New().String()
+	timeOff.CreatedAt = time.Now()
+	return svc.repo.CreateTimeOff(ctx, timeOff)
+}
+
+func (svc AvailabilityServiceManagement) GetTimeOff(ctx context.Context, cleaner_id string, from, to time.Time) (*[]domain.TimeOff, error) {
+	ctx, span := tracer.Start(ctx, "AvailabilityService.GetTimeOff")
+	defer span.End()
+
+	return svc.repo.GetTimeOff(ctx, cleaner_id, from, to)
+}
+
+func (svc AvailabilityServiceManagement) DeleteTimeOff(ctx context.Context, cleaner_id, time_off_id string) error {
+	ctx, span := tracer.Start(ctx, "AvailabilityService.DeleteTimeOff")
+	defer span.End()
+
+	return svc.repo.DeleteTimeOff(ctx, cleaner_id, time_off_id)
+}
+
+// GetSlots lists the open start times on date long enough for the given service, or for the default duration when no service is given.
+func (svc AvailabilityServiceManagement) GetSlots(ctx context.Context, cleaner_id string, date time.Time, service_id string) (*[]domain.Slot, error) {
+	ctx, span := tracer.Start(ctx, "AvailabilityService.GetSlots")
+	defer span.End()
+
+	duration := domain.DefaultDurationMinutes
+	if service_id != "" {
+		service, err := svc.serviceRepo.GetServiceById(ctx, service_id)
+		if err != nil {
+			return nil, err
+		}
+		duration = service.DurationMinutes
+	}
+
+	// The date is taken as a calendar day in the service's time zone, whatever zone it was parsed in.
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, svc.location)
+	schedule, err := svc.daySchedule(ctx, cleaner_id, day, "")
+	if err != nil {
+		return nil, err
+	}
+
+	slots, err := schedule.Slots(time.Duration(duration)*time.Minute, slotStep)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	open := []domain.Slot{}
+	for _, slot := range slots {
+		if slot.StartsAt.After(now) {
+			open = append(open, slot)
+		}
+	}
+	return &open, nil
+}
+
+// CheckAvailability returns ErrCleanerUnavailable unless the cleaner works and is free for the whole slot.
+// exclude_request_id leaves a request's own booking out, so it can be rescheduled or reassigned.
+// Called within a unit of work it locks the cleaner first, so the booking written in the same unit
+// is the only one made against what was checked.
+func (svc AvailabilityServiceManagement) CheckAvailability(ctx context.Context, cleaner_id string, slot domain.Slot, exclude_request_id string) error {
+	ctx, span := tracer.Start(ctx, "AvailabilityService.CheckAvailability")
+	defer span.End()
+
+	if err := svc.repo.LockCleaner(ctx, cleaner_id); err != nil {
+		return err
+	}
+	schedule, err := svc.daySchedule(ctx, cleaner_id, slot.StartsAt, exclude_request_id)
+	if err != nil {
+		return err
+	}
+
+	fits, err := schedule.Fits(slot)
+	if err != nil {
+		return err
+	}
+	if !fits {
+		return fmt.Errorf("%w: cleaner %s is not free from %s to %s", domain.ErrCleanerUnavailable, cleaner_id,
+			slot.StartsAt.In(svc.location).Format(time.RFC3339), slot.EndsAt.In(svc.location).Format(time.RFC3339))
+	}
+	return nil
+}
+
+func (svc AvailabilityServiceManagement) daySchedule(ctx context.Context, cleaner_id string, date time.Time, exclude_request_id string) (domain.DaySchedule, error) {
+	local := date.In(svc.location)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, svc.location)
+	nextDay := day.AddDate(0, 0, 1)
+
+	hours, err := svc.repo.GetWorkingHours(ctx, cleaner_id)
+	if err != nil {
+		return domain.DaySchedule{}, err
+	}
+	exceptions, err := svc.repo.GetExceptions(ctx, cleaner_id, day, day)
+	if err != nil {
+		return domain.DaySchedule{}, err
+	}
+	timeOff, err := svc.repo.GetTimeOff(ctx, cleaner_id, day, nextDay)
+	if err != nil {
+		return domain.DaySchedule{}, err
+	}
+
+	// A booking can start the day before and run past midnight, so look one day back.
+	requests, err := collectRequests(ctx, func(ctx context.Context, filter domain.RequestFilter) (*domain.Page[domain.Request], error) {
+		return svc.requestRepo.GetRequestByCleaner(ctx, cleaner_id, filter)
+	}, domain.RequestFilter{RequestedFrom: timePtr(day.AddDate(0, 0, -1)), RequestedTo: &nextDay})
+	if err != nil {
+		return domain.DaySchedule{}, err
+	}
+
+	dayWindow := domain.Slot{StartsAt: day, EndsAt: nextDay}
+	bookings := []domain.Slot{}
+	for _, request := range requests {
+		if request.RequestId == exclude_request_id || !request.OccupiesCleaner() {
+			continue
+		}
+		if booking := request.Slot(); booking.Overlaps(dayWindow) {
+			bookings = append(bookings, booking)
+		}
+	}
+
+	return domain.DaySchedule{
+		Day:          day,
+		WorkingHours: *hours,
+		Exceptions:   *exceptions,
+		TimeOff:      *timeOff,
+		Bookings:     bookings,
+	}, nil
+}
+
+// collectRequests walks every page of a request listing
+func collectRequests(ctx context.Context, list func(context.Context, domain.RequestFilter) (*domain.Page[domain.Request], error), filter domain.RequestFilter) ([]domain.Request, error) {
+	filter.Limit = domain.MaxPageLimit
+	requests := []domain.Request{}
+	for {
+		page, err := list(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, page.Items...)
+		if page.NextCursor == "" {
+			return requests, nil
+		}
+		filter.Cursor = page.NextCursor
+	}
+}
+
+// resolveService looks up the service a request books, reporting an unknown id as invalid input
+func resolveService(ctx context.Context, serviceRepo ports.ServiceRepository, service_id string) (*domain.Service, error) {
+	service, err := serviceRepo.GetServiceById(ctx, service_id)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, fmt.Errorf("%w: unknown service %q", domain.ErrInvalidInput, service_id)
+	}
+	return service, err
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/ports"
+	"github.com/google/uuid"
+)
+
+type InvoiceServiceManagement struct {
+	repo        ports.InvoiceRepository
+	requestRepo ports.RequestRepository
+	pricing     ports.PricingService
+	renderer    ports.InvoiceRenderer
+	taxRateBps  int64
+	logger      ports.LoggerService
+}
+
+func NewInvoiceServiceManagement(repo ports.InvoiceRepository, requestRepo ports.RequestRepository, pricing ports.PricingService, renderer ports.InvoiceRenderer, taxRateBps int64, logger ports.LoggerService) *InvoiceServiceManagement {
+	service := InvoiceServiceManagement{
+		repo:        repo,
+		requestRepo: requestRepo,
+		pricing:     pricing,
+		renderer:    renderer,
+		taxRateBps:  taxRateBps,
+		logger:      logger,
+	}
+	return &service
+}
+
+// GenerateInvoice issues the invoice for a completed request, or for the fee of a late cancellation. It is idempotent, a request that
+// already has an invoice gets the existing one back.
+func (svc InvoiceServiceManagement) GenerateInvoice(ctx context.Context, request_id string) (*domain.Invoice, error) {
+	ctx, span := tracer.Start(ctx, "InvoiceService.GenerateInvoice")
+	defer span.End()
+
+	invoice, err := svc.repo.GetInvoiceByRequest(ctx, request_id)
+	if err == nil {
+		return invoice, nil
+	}
+	if !errors.Is(err, domain.ErrNotFound) {
+		return nil, err
+	}
+
+	request, err := svc.requestRepo.GetRequestById(ctx, request_id)
+	if err != nil {
+		return nil, err
+	}
+
+	// A late cancellation is billed its fee. Requests booked before pricing existed carry no quote,
+	// so price them at today's rates.
+	quote := request.Quote
+	if cancellation := request.CancellationQuote(); cancellation != nil {
+		quote = cancellation
+	} else if quote == nil {
+		svc.logger.WithContext(ctx).Warning("request " + request_id + " has no stored quote, invoicing at current rates")
+		quote, err = svc.pricing.QuoteRequest(ctx, *request)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	invoice, err = domain.NewInvoice(*request, *quote, svc.taxRateBps)
+	if err != nil {
+		return nil, err
+	}
+	invoice.InvoiceId = uuid.New().String()
+	invoice.IssuedAt = time.Now()
+	invoice.CreatedAt = time.Now()
+
+	invoice, err = svc.repo.CreateInvoice(ctx, *invoice)
+	if errors.Is(err, domain.ErrAlreadyExists) {
+		// Another caller invoiced the request first.
+		return svc.repo.GetInvoiceByRequest(ctx, request_id)
+	}
+	return invoice, err
+}
+
+func (svc InvoiceServiceManagement) GetInvoiceById(ctx context.Context, invoice_id string) (*domain.Invoice, error) {
+	ctx, span := tracer.Start(ctx, "InvoiceService.GetInvoiceById")
+	defer span.End()
+
+	return svc.repo.GetInvoiceById(ctx, invoice_id)
+}
+
+func (svc InvoiceServiceManagement) GetInvoiceByRequest(ctx context.Context, request_id string) (*domain.Invoice, error) {
+	ctx, span := tracer.Start(ctx, "InvoiceService.GetInvoiceByRequest")
+	defer span.End()
+
+	return svc.repo.GetInvoiceByRequest(ctx, request_id)
+}
+
+func (svc InvoiceServiceManagement) GetInvoicesByClient(ctx context.Context, client_id string, filter domain.InvoiceFilter) (*domain.Page[domain.Invoice], error) {
+	ctx, span := tracer.Start(ctx, "InvoiceService.GetInvoicesByClient")
+	defer span.End()
+
+	return svc.repo.GetInvoicesByClient(ctx, client_id, filter)
+}
+
+func (svc InvoiceServiceManagement) RenderInvoicePDF(ctx context.Context, invoice_id string) ([]byte, error) {
+	ctx, span := tracer.Start(ctx, "InvoiceService.RenderInvoicePDF")
+	defer span.End()
+
+	invoice, err := svc.repo.GetInvoiceById(ctx, invoice_id)
+	if err != nil {
+		return nil, err
+	}
+	return svc.renderer.Render(*invoice)
+}
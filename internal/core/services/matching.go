@@ -0,0 +1,251 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/ports"
+)
+
+type MatchingServiceManagement struct {
+	repo         ports.CleanerProfileRepository
+	requestRepo  ports.RequestRepository
+	reviewRepo   ports.ReviewRepository
+	requests     ports.RequestService
+	availability ports.AvailabilityService
+	strategy     ports.MatchingStrategy
+	logger       ports.LoggerService
+}
+
+func NewMatchingServiceManagement(repo ports.CleanerProfileRepository, requestRepo ports.RequestRepository, reviewRepo ports.ReviewRepository, requests ports.RequestService, availability ports.AvailabilityService, strategy ports.MatchingStrategy, logger ports.LoggerService) *MatchingServiceManagement {
+	service := MatchingServiceManagement{
+		repo:         repo,
+		requestRepo:  requestRepo,
+		reviewRepo:   reviewRepo,
+		requests:     requests,
+		availability: availability,
+		strategy:     strategy,
+		logger:       logger,
+	}
+	return &service
+}
+
+func (svc MatchingServiceManagement) UpsertCleanerProfile(ctx context.Context, profile domain.CleanerProfile) (*domain.CleanerProfile, error) {
+	ctx, span := tracer.Start(ctx, "MatchingService.UpsertCleanerProfile")
+	defer span.End()
+
+	if err := profile.Validate(); err != nil {
+		return nil, err
+	}
+	profile.CreatedAt = time.Now()
+	profile.UpdatedAt = time.Now()
+	return svc.repo.UpsertCleanerProfile(ctx, profile)
+}
+
+func (svc MatchingServiceManagement) GetCleanerProfile(ctx context.Context, cleaner_id string) (*domain.CleanerProfile, error) {
+	ctx, span := tracer.Start(ctx, "MatchingService.GetCleanerProfile")
+	defer span.End()
+
+	return svc.repo.GetCleanerProfile(ctx, cleaner_id)
+}
+
+// RankCleaners lists the active cleaners that offer the request's service, are free for its slot and
+// within travel distance, ordered by the configured strategy.
+func (svc MatchingServiceManagement) RankCleaners(ctx context.Context, request_id string) (*[]domain.CleanerMatch, error) {
+	ctx, span := tracer.Start(ctx, "MatchingService.RankCleaners")
+	defer span.End()
+
+	request, err := svc.requestRepo.GetRequestById(ctx, request_id)
+	if err != nil {
+		return nil, err
+	}
+
+	profiles, err := svc.repo.GetActiveCleanersForService(ctx, request.ServiceId)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := []domain.CleanerCandidate{}
+	for _, profile := range *profiles {
+		candidate := domain.CleanerCandidate{Profile: profile}
+
+		if request.HasLocation() && (profile.Latitude != 0 || profile.Longitude != 0) {
+			candidate.DistanceKm = domain.DistanceKm(profile.Latitude, profile.Longitude, request.Latitude, request.Longitude)
+			candidate.HasDistance = true
+			if profile.MaxDistanceKm > 0 && candidate.DistanceKm > profile.MaxDistanceKm {
+				continue
+			}
+		}
+
+		err := svc.availability.CheckAvailability(ctx, profile.CleanerId, request.Slot(), request.RequestId)
+		if errors.Is(err, domain.ErrCleanerUnavailable) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		candidate.AverageRating, candidate.ReviewCount, err = svc.averageRating(ctx, profile.CleanerId)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	matches := svc.strategy.Rank(*request, candidates)
+	return &matches, nil
+}
+
+// AutoAssign assigns a pending request to the best ranked cleaner, falling through to the next one
+// if a cleaner was booked in the meantime.
+func (svc MatchingServiceManagement) AutoAssign(ctx context.Context, request_id string) (*domain.Request, error) {
+	ctx, span := tracer.Start(ctx, "MatchingService.AutoAssign")
+	defer span.End()
+
+	request, err := svc.requestRepo.GetRequestById(ctx, request_id)
+	if err != nil {
+		return nil, err
+	}
+	if request.Status != domain.RequestPending {
+		return nil, domain.TransitionError{From: request.Status, To: domain.RequestAssigned}
+	}
+
+	matches, err := svc.RankCleaners(ctx, request_id)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, match := range *matches {
+		err := svc.requests.AssignCleaner(ctx, request_id, match.CleanerId)
+		if errors.Is(err, domain.ErrCleanerUnavailable) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		svc.logger.WithContext(ctx).Info(fmt.Sprintf("request %s auto assigned to cleaner %s", request_id, match.CleanerId))
+		return svc.requestRepo.GetRequestById(ctx, request_id)
+	}
+	return nil, fmt.Errorf("%w: request %s", domain.ErrNoEligibleCleaner, request_id)
+}
+
+// averageRating reads the cleaner's mean rating and review count from their rating aggregate
+func (svc MatchingServiceManagement) averageRating(ctx context.Context, cleaner_id string) (float64, int, error) {
+	aggregate, err := svc.reviewRepo.GetRatingAggregate(ctx, domain.RatingSubjectCleaner, cleaner_id)
+	if err != nil {
+		return 0, 0, err
+	}
+	if aggregate.Rating.Count == 0 {
+		return 0, 0, nil
+	}
+	return float64(aggregate.Rating.Sum) / float64(aggregate.Rating.Count), aggregate.Rating.Count, nil
+}
+
+// WeightedMatchingStrategy scores candidates on a blend of their average rating and how close they are to the job
+type WeightedMatchingStrategy struct {
+	RatingWeight   float64
+	DistanceWeight float64
+	// ReferenceDistanceKm is the distance at which the distance score halves
+	ReferenceDistanceKm float64
+}
+
+func NewWeightedMatchingStrategy() WeightedMatchingStrategy {
+	return WeightedMatchingStrategy{
+		RatingWeight:        0.6,
+		DistanceWeight:      0.4,
+		ReferenceDistanceKm: 20,
+	}
+}
+
+func (s WeightedMatchingStrategy) Rank(request domain.Request, candidates []domain.CleanerCandidate) []domain.CleanerMatch {
+	matches := make([]domain.CleanerMatch, 0, len(candidates))
+	for _, candidate := range candidates {
+		// Cleaners without reviews or a known distance get a neutral score rather than the worst one.
+		ratingScore := 0.6
+		if candidate.ReviewCount > 0 {
+			ratingScore = candidate.AverageRating / 5
+		}
+		distanceScore := 0.5
+		if candidate.HasDistance {
+			distanceScore = 1 / (1 + candidate.DistanceKm/s.ReferenceDistanceKm)
+		}
+
+		matches = append(matches, domain.CleanerMatch{
+			CleanerId:     candidate.Profile.CleanerId,
+			Score:         s.RatingWeight*ratingScore + s.DistanceWeight*distanceScore,
+			DistanceKm:    candidate.DistanceKm,
+			AverageRating: candidate.AverageRating,
+			ReviewCount:   candidate.ReviewCount,
+		})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].CleanerId < matches[j].CleanerId
+	})
+	return matches
+}
+
+// AutoAssignWorker periodically auto assigns upcoming pending requests
+type AutoAssignWorker struct {
+	matching    ports.MatchingService
+	requestRepo ports.RequestRepository
+	interval    time.Duration
+	logger      ports.LoggerService
+}
+
+func NewAutoAssignWorker(matching ports.MatchingService, requestRepo ports.RequestRepository, interval time.Duration, logger ports.LoggerService) *AutoAssignWorker {
+	worker := AutoAssignWorker{
+		matching:    matching,
+		requestRepo: requestRepo,
+		interval:    interval,
+		logger:      logger,
+	}
+	return &worker
+}
+
+// Run assigns pending requests every interval until ctx is cancelled
+func (w AutoAssignWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.assignPending(ctx)
+		}
+	}
+}
+
+func (w AutoAssignWorker) assignPending(ctx context.Context) {
+	requests, err := collectRequests(ctx, w.requestRepo.GetRequests, domain.RequestFilter{
+		Status:        domain.RequestPending,
+		RequestedFrom: timePtr(time.Now()),
+	})
+	if err != nil {
+		w.logger.Error(err.Error())
+		return
+	}
+
+	for _, request := range requests {
+		// Leave the rest for the next run once shutdown has started
+		if ctx.Err() != nil {
+			return
+		}
+		_, err := w.matching.AutoAssign(ctx, request.RequestId)
+		if errors.Is(err, domain.ErrNoEligibleCleaner) || errors.Is(err, domain.ErrInvalidTransition) {
+			continue
+		}
+		if err != nil {
+			w.logger.Error(err.Error())
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/ports"
+	"github.com/google/uuid"
+)
+
+type PaymentServiceManagement struct {
+	repo        ports.PaymentRepository
+	requestRepo ports.RequestRepository
+	invoiceRepo ports.InvoiceRepository
+	uow         ports.UnitOfWork
+	provider    ports.PaymentProvider
+	logger      ports.LoggerService
+}
+
+func NewPaymentServiceManagement(repo ports.PaymentRepository, requestRepo ports.RequestRepository, invoiceRepo ports.InvoiceRepository, uow ports.UnitOfWork, provider ports.PaymentProvider, logger ports.LoggerService) *PaymentServiceManagement {
+	service := PaymentServiceManagement{
+		repo:        repo,
+		requestRepo: requestRepo,
+		invoiceRepo: invoiceRepo,
+		uow:         uow,
+		provider:    provider,
+		logger:      logger,
+	}
+	return &service
+}
+
+// InitiatePayment asks the provider to charge the client for the request's invoice. The payment
+// stays pending until the provider's callback arrives, and while it is pending or once it succeeded
+// the request is not charged again. The payment is recorded before the charge is started, so two
+// charges started at once cannot both reach the client.
+func (svc PaymentServiceManagement) InitiatePayment(ctx context.Context, request_id, phone_number string) (*domain.Payment, error) {
+	ctx, span := tracer.Start(ctx, "PaymentService.InitiatePayment")
+	defer span.End()
+
+	phone, err := domain.NormalizePhoneNumber(phone_number)
+	if err != nil {
+		return nil, err
+	}
+
+	invoice, err := svc.invoiceRepo.GetInvoiceByRequest(ctx, request_id)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, fmt.Errorf("%w: request %s has not been invoiced yet", domain.ErrInvalidInput, request_id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	payments, err := svc.repo.GetPaymentsByRequest(ctx, request_id)
+	if err != nil {
+		return nil, err
+	}
+	for _, payment := range *payments {
+		switch payment.Status {
+		case domain.PaymentSucceeded:
+			return nil, fmt.Errorf("%w: request %s is already paid", domain.ErrAlreadyExists, request_id)
+		case domain.PaymentPending:
+			return nil, fmt.Errorf("%w: request %s has a payment pending", domain.ErrAlreadyExists, request_id)
+		}
+	}
+
+	payment := domain.Payment{
+		PaymentId:   uuid.New().String(),
+		RequestId:   request_id,
+		InvoiceId:   invoice.InvoiceId,
+		Reference:   invoice.InvoiceNumber,
+		ClientId:    invoice.ClientId,
+		Amount:      invoice.Total,
+		PhoneNumber: phone,
+		Provider:    svc.provider.Name(),
+		Status:      domain.PaymentPending,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+
+	if _, err := svc.repo.CreatePayment(ctx, payment); err != nil {
+		if errors.Is(err, domain.ErrAlreadyExists) {
+			return nil, fmt.Errorf("%w: request %s has a payment pending", domain.ErrAlreadyExists, request_id)
+		}
+		return nil, err
+	}
+
+	payment.ProviderReference, err = svc.provider.InitiateCharge(ctx, payment)
+	if err != nil {
+		// The request can be charged again once this attempt is out of the way
+		payment.Status = domain.PaymentFailed
+		payment.FailureReason = "the charge could not be started"
+		payment.UpdatedAt = time.Now()
+		if _, updateErr := svc.repo.UpdatePayment(ctx, payment); updateErr != nil {
+			svc.logger.WithContext(ctx).Error("payment " + payment.PaymentId + " left pending: " + updateErr.Error())
+		}
+		return nil, err
+	}
+	payment.UpdatedAt = time.Now()
+	return svc.repo.UpdatePayment(ctx, payment)
+}
+
+// HandleCallback settles the payment a provider callback refers to. Providers retry callbacks,
+// so a callback for a payment that is already settled, or settled by another delivery of the same
+// callback meanwhile, is acknowledged without changing it.
+func (svc PaymentServiceManagement) HandleCallback(ctx context.Context, body []byte) (*domain.Payment, error) {
+	ctx, span := tracer.Start(ctx, "PaymentService.HandleCallback")
+	defer span.End()
+
+	callback, err := svc.provider.ParseCallback(body)
+	if err != nil {
+		return nil, err
+	}
+
+	payment, err := svc.repo.GetPaymentByReference(ctx, svc.provider.Name(), callback.ProviderReference)
+	if err != nil {
+		return nil, err
+	}
+	if !payment.Settle(*callback) {
+		return payment, nil
+	}
+	payment.UpdatedAt = time.Now()
+
+	// The payment and its request are settled together, so a failure leaves the callback to be retried
+	err = svc.uow.Do(ctx, func(ctx context.Context) error {
+		payment, err = svc.repo.UpdatePayment(ctx, *payment)
+		if err != nil {
+			return err
+		}
+		if payment.Status == domain.PaymentSucceeded {
+			return svc.requestRepo.MarkRequestPaid(ctx, payment.RequestId, payment.UpdatedAt)
+		}
+		return nil
+	})
+	if errors.Is(err, domain.ErrPaymentSettled) {
+		return svc.repo.GetPaymentByReference(ctx, svc.provider.Name(), callback.ProviderReference)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case payment.Status == domain.PaymentSucceeded:
+		svc.logger.WithContext(ctx).Info(fmt.Sprintf("request %s paid, receipt %s", payment.RequestId, payment.Receipt))
+	case callback.Succeeded:
+		svc.logger.WithContext(ctx).Error(fmt.Sprintf("payment %s for request %s not accepted, %s, receipt %s", payment.PaymentId, payment.RequestId, payment.FailureReason, payment.Receipt))
+	}
+	return payment, nil
+}
+
+func (svc PaymentServiceManagement) GetPaymentById(ctx context.Context, payment_id string) (*domain.Payment, error) {
+	ctx, span := tracer.Start(ctx, "PaymentService.GetPaymentById")
+	defer span.End()
+
+	return svc.repo.GetPaymentById(ctx, payment_id)
+}
+
+func (svc PaymentServiceManagement) GetPaymentsByRequest(ctx context.Context, request_id string) (*[]domain.Payment, error) {
+	ctx, span := tracer.Start(ctx, "PaymentService.GetPaymentsByRequest")
+	defer span.End()
+
+	return svc.repo.GetPaymentsByRequest(ctx, request_id)
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/ports"
+)
+
+type PricingServiceManagement struct {
+	serviceRepo ports.ServiceRepository
+	rules       domain.PricingRules
+	location    *time.Location
+	logger      ports.LoggerService
+}
+
+func NewPricingServiceManagement(serviceRepo ports.ServiceRepository, rules domain.PricingRules, location *time.Location, logger ports.LoggerService) *PricingServiceManagement {
+	service := PricingServiceManagement{
+		serviceRepo: serviceRepo,
+		rules:       rules,
+		location:    location,
+		logger:      logger,
+	}
+	return &service
+}
+
+// QuoteRequest prices a request against the current rates of its service without saving anything
+func (svc PricingServiceManagement) QuoteRequest(ctx context.Context, request domain.Request) (*domain.Quote, error) {
+	ctx, span := tracer.Start(ctx, "PricingService.QuoteRequest")
+	defer span.End()
+
+	service, err := resolveService(ctx, svc.serviceRepo, request.ServiceId)
+	if err != nil {
+		return nil, err
+	}
+	request.DurationMinutes = service.DurationMinutes
+	return svc.rules.Quote(*service, request, request.RequestedDate.In(svc.location))
+}
+
+// QuoteChangeFee takes the fee from the booked quote, requests booked before pricing existed are
+// priced at today's rates
+func (svc PricingServiceManagement) QuoteChangeFee(ctx context.Context, request domain.Request, kind domain.BookingChangeKind, initiator domain.ChangeInitiator, at time.Time) (*domain.Money, error) {
+	ctx, span := tracer.Start(ctx, "PricingService.QuoteChangeFee")
+	defer span.End()
+
+	quote := request.Quote
+	if quote == nil {
+		var err error
+		quote, err = svc.QuoteRequest(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+	}
+	fee := svc.rules.Cancellation.ChangeFee(*quote, kind, initiator, request.RequestedDate, at)
+	return &fee, nil
+}
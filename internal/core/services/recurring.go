@@ -0,0 +1,376 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/ports"
+	"github.com/google/uuid"
+)
+
+type RecurringBookingServiceManagement struct {
+	repo        ports.RecurringBookingRepository
+	requestRepo ports.RequestRepository
+	serviceRepo ports.ServiceRepository
+	requests    ports.RequestService
+	uow         ports.UnitOfWork
+	// horizon is how far ahead occurrences are materialised as requests
+	horizon  time.Duration
+	location *time.Location
+	logger   ports.LoggerService
+}
+
+func NewRecurringBookingServiceManagement(repo ports.RecurringBookingRepository, requestRepo ports.RequestRepository, serviceRepo ports.ServiceRepository, requests ports.RequestService, uow ports.UnitOfWork, horizon time.Duration, location *time.Location, logger ports.LoggerService) *RecurringBookingServiceManagement {
+	service := RecurringBookingServiceManagement{
+		repo:        repo,
+		requestRepo: requestRepo,
+		serviceRepo: serviceRepo,
+		requests:    requests,
+		uow:         uow,
+		horizon:     horizon,
+		location:    location,
+		logger:      logger,
+	}
+	return &service
+}
+
+// CreateRecurringBooking stores the series and books the occurrences already within the horizon.
+// The series keeps its time of day in the business timezone, across daylight saving changes too.
+func (svc RecurringBookingServiceManagement) CreateRecurringBooking(ctx context.Context, booking domain.RecurringBooking) (*domain.RecurringBooking, error) {
+	ctx, span := tracer.Start(ctx, "RecurringBookingService.CreateRecurringBooking")
+	defer span.End()
+
+	now := time.Now()
+	booking.StartsAt = booking.StartsAt.In(svc.location)
+	if err := booking.ValidateNew(now); err != nil {
+		return nil, err
+	}
+	if _, err := resolveService(ctx, svc.serviceRepo, booking.ServiceId); err != nil {
+		return nil, err
+	}
+
+	booking.RecurringBookingId = uuid.New().String()
+	booking.Status = domain.RecurringActive
+	booking.Exceptions = nil
+	booking.GeneratedUntil = booking.StartsAt
+	booking.CancelledAt = nil
+	booking.CreatedAt = now
+	booking.UpdatedAt = now
+	created, err := svc.repo.CreateRecurringBooking(ctx, booking)
+	if err != nil {
+		return nil, err
+	}
+
+	// The series stands even if booking its first occurrences fails, the generator retries them
+	generated, err := svc.generate(ctx, created.RecurringBookingId, now)
+	if err != nil {
+		svc.logger.WithContext(ctx).Error("generating occurrences of recurring booking " + created.RecurringBookingId + " failed: " + err.Error())
+		return created, nil
+	}
+	return generated, nil
+}
+
+func (svc RecurringBookingServiceManagement) GetRecurringBookingById(ctx context.Context, recurring_booking_id string) (*domain.RecurringBooking, error) {
+	ctx, span := tracer.Start(ctx, "RecurringBookingService.GetRecurringBookingById")
+	defer span.End()
+
+	return svc.getRecurringBooking(ctx, recurring_booking_id)
+}
+
+func (svc RecurringBookingServiceManagement) GetRecurringBookingsByClient(ctx context.Context, client_id string) (*[]domain.RecurringBooking, error) {
+	ctx, span := tracer.Start(ctx, "RecurringBookingService.GetRecurringBookingsByClient")
+	defer span.End()
+
+	bookings, err := svc.repo.GetRecurringBookingsByClient(ctx, client_id)
+	if err != nil {
+		return nil, err
+	}
+	for i := range *bookings {
+		(*bookings)[i].StartsAt = (*bookings)[i].StartsAt.In(svc.location)
+	}
+	return bookings, nil
+}
+
+// GetSchedule lists the series' occurrences due in [from, to), skipped ones included
+func (svc RecurringBookingServiceManagement) GetSchedule(ctx context.Context, recurring_booking_id string, from, to time.Time) (*[]domain.Occurrence, error) {
+	ctx, span := tracer.Start(ctx, "RecurringBookingService.GetSchedule")
+	defer span.End()
+
+	if !to.After(from) {
+		return nil, fmt.Errorf("%w: to must be after from", domain.ErrInvalidInput)
+	}
+	booking, err := svc.getRecurringBooking(ctx, recurring_booking_id)
+	if err != nil {
+		return nil, err
+	}
+	occurrences := booking.Schedule(from, to)
+	if occurrences == nil {
+		occurrences = []domain.Occurrence{}
+	}
+	return &occurrences, nil
+}
+
+// SkipOccurrence skips a single occurrence, cancelling its request under the cancellation policy
+// if it was already booked
+func (svc RecurringBookingServiceManagement) SkipOccurrence(ctx context.Context, recurring_booking_id string, occurrence_at time.Time) (*domain.RecurringBooking, error) {
+	ctx, span := tracer.Start(ctx, "RecurringBookingService.SkipOccurrence")
+	defer span.End()
+
+	return svc.changeOccurrence(ctx, recurring_booking_id, domain.OccurrenceException{OccurrenceAt: occurrence_at, Skipped: true}, func(ctx context.Context, request domain.Request) error {
+		if request.Status == domain.RequestCancelled {
+			return nil
+		}
+		_, err := svc.requests.CancelRequest(ctx, request.RequestId, seriesChange(request, "Occurrence of a recurring booking skipped"))
+		return err
+	})
+}
+
+// RescheduleOccurrence moves a single occurrence, rescheduling its request too if it was already booked
+func (svc RecurringBookingServiceManagement) RescheduleOccurrence(ctx context.Context, recurring_booking_id string, occurrence_at, requested_date time.Time) (*domain.RecurringBooking, error) {
+	ctx, span := tracer.Start(ctx, "RecurringBookingService.RescheduleOccurrence")
+	defer span.End()
+
+	return svc.changeOccurrence(ctx, recurring_booking_id, domain.OccurrenceException{OccurrenceAt: occurrence_at, RescheduledTo: &requested_date}, func(ctx context.Context, request domain.Request) error {
+		if request.Status.IsFinal() {
+			return fmt.Errorf("%w: the request for this occurrence is already %s", domain.ErrInvalidInput, request.Status)
+		}
+		_, err := svc.requests.RescheduleRequest(ctx, request.RequestId, requested_date, seriesChange(request, "Occurrence of a recurring booking rescheduled"))
+		return err
+	})
+}
+
+// changeOccurrence records the exception and applies it to the occurrence's request, if there is one,
+// in the same unit of work
+func (svc RecurringBookingServiceManagement) changeOccurrence(ctx context.Context, recurring_booking_id string, exception domain.OccurrenceException, apply func(ctx context.Context, request domain.Request) error) (*domain.RecurringBooking, error) {
+	var updated *domain.RecurringBooking
+	err := svc.uow.Do(ctx, func(ctx context.Context) error {
+		booking, err := svc.lockRecurringBooking(ctx, recurring_booking_id)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if err := booking.SetException(exception, now); err != nil {
+			return err
+		}
+		booking.UpdatedAt = now
+
+		requests, err := svc.occurrenceRequests(ctx, booking.RecurringBookingId)
+		if err != nil {
+			return err
+		}
+		if request, ok := requests[exception.OccurrenceAt.Unix()]; ok {
+			if err := apply(ctx, request); err != nil {
+				return err
+			}
+		}
+		updated, err = svc.repo.UpdateRecurringBooking(ctx, *booking)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	updated.StartsAt = updated.StartsAt.In(svc.location)
+	return updated, nil
+}
+
+// CancelRecurringBooking ends the series from now on and cancels its upcoming requests that no
+// cleaner has set off for yet
+func (svc RecurringBookingServiceManagement) CancelRecurringBooking(ctx context.Context, recurring_booking_id string) (*domain.RecurringBooking, error) {
+	ctx, span := tracer.Start(ctx, "RecurringBookingService.CancelRecurringBooking")
+	defer span.End()
+
+	var updated *domain.RecurringBooking
+	err := svc.uow.Do(ctx, func(ctx context.Context) error {
+		booking, err := svc.lockRecurringBooking(ctx, recurring_booking_id)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if err := booking.Cancel(now); err != nil {
+			return err
+		}
+		booking.UpdatedAt = now
+
+		requests, err := collectRequests(ctx, svc.requestRepo.GetRequests, domain.RequestFilter{
+			RecurringBookingId: booking.RecurringBookingId,
+			RequestedFrom:      &now,
+		})
+		if err != nil {
+			return err
+		}
+		for _, request := range requests {
+			if request.Status != domain.RequestPending && request.Status != domain.RequestAssigned {
+				continue
+			}
+			if _, err := svc.requests.CancelRequest(ctx, request.RequestId, seriesChange(request, "Recurring booking cancelled")); err != nil {
+				return err
+			}
+		}
+		updated, err = svc.repo.UpdateRecurringBooking(ctx, *booking)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	updated.StartsAt = updated.StartsAt.In(svc.location)
+	return updated, nil
+}
+
+// GenerateOccurrences books the occurrences of every active series that fall within the horizon.
+// A series that fails is retried on the next run, the others carry on.
+func (svc RecurringBookingServiceManagement) GenerateOccurrences(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "RecurringBookingService.GenerateOccurrences")
+	defer span.End()
+
+	now := time.Now()
+	bookings, err := svc.repo.GetActiveRecurringBookings(ctx, now.Add(svc.horizon))
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, booking := range *bookings {
+		// Leave the rest for the next run once shutdown has started
+		if ctx.Err() != nil {
+			break
+		}
+		if _, err := svc.generate(ctx, booking.RecurringBookingId, now); err != nil {
+			errs = append(errs, fmt.Errorf("recurring booking %s: %w", booking.RecurringBookingId, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// generate books the occurrences between what was generated before, or now if that has passed,
+// and the horizon. It works on the series as it is once locked rather than as it was listed, so a
+// skip or a cancellation made in the meantime holds, and two runs never book the same occurrence.
+// Occurrences already booked are left alone, so a run that failed halfway can be repeated.
+func (svc RecurringBookingServiceManagement) generate(ctx context.Context, recurring_booking_id string, now time.Time) (*domain.RecurringBooking, error) {
+	var generated *domain.RecurringBooking
+	err := svc.uow.Do(ctx, func(ctx context.Context) error {
+		booking, err := svc.lockRecurringBooking(ctx, recurring_booking_id)
+		if err != nil {
+			return err
+		}
+		generated = booking
+
+		from := booking.GeneratedUntil
+		if from.Before(now) {
+			from = now
+		}
+		until := now.Add(svc.horizon)
+		if booking.Status != domain.RecurringActive || !until.After(booking.GeneratedUntil) {
+			return nil
+		}
+
+		booked, err := svc.occurrenceRequests(ctx, booking.RecurringBookingId)
+		if err != nil {
+			return err
+		}
+		for _, occurrence := range booking.Schedule(from, until) {
+			if _, ok := booked[occurrence.OccurrenceAt.Unix()]; ok || occurrence.Skipped {
+				continue
+			}
+			if err := svc.bookOccurrence(ctx, *booking, occurrence); err != nil {
+				return err
+			}
+		}
+
+		booking.GeneratedUntil = until
+		booking.UpdatedAt = time.Now()
+		return svc.repo.SetGeneratedUntil(ctx, booking.RecurringBookingId, booking.GeneratedUntil, booking.UpdatedAt)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return generated, nil
+}
+
+// bookOccurrence books the occurrence with the series' cleaner, or leaves it for dispatch when they
+// are not free then
+func (svc RecurringBookingServiceManagement) bookOccurrence(ctx context.Context, booking domain.RecurringBooking, occurrence domain.Occurrence) error {
+	request := booking.RequestFor(occurrence)
+	_, err := svc.requests.CreateRequest(ctx, request)
+	if errors.Is(err, domain.ErrCleanerUnavailable) && request.CleanerId != "" {
+		svc.logger.WithContext(ctx).Warning(fmt.Sprintf("cleaner %s is not free for the occurrence of recurring booking %s at %s, leaving it for dispatch", request.CleanerId, booking.RecurringBookingId, occurrence.ScheduledAt.Format(time.RFC3339)))
+		request.CleanerId = ""
+		_, err = svc.requests.CreateRequest(ctx, request)
+	}
+	return err
+}
+
+// occurrenceRequests maps the requests booked for a series by the Unix time of their occurrence
+func (svc RecurringBookingServiceManagement) occurrenceRequests(ctx context.Context, recurring_booking_id string) (map[int64]domain.Request, error) {
+	requests, err := collectRequests(ctx, svc.requestRepo.GetRequests, domain.RequestFilter{RecurringBookingId: recurring_booking_id})
+	if err != nil {
+		return nil, err
+	}
+	booked := map[int64]domain.Request{}
+	for _, request := range requests {
+		if request.OccurrenceAt != nil {
+			booked[request.OccurrenceAt.Unix()] = request
+		}
+	}
+	return booked, nil
+}
+
+// seriesChange is a change the client makes to an occurrence through its series, the cancellation
+// policy applies to it as to any other booking
+func seriesChange(request domain.Request, reason string) domain.BookingChange {
+	return domain.BookingChange{Initiator: domain.InitiatorClient, By: request.ClientId, Reason: reason}
+}
+
+func (svc RecurringBookingServiceManagement) getRecurringBooking(ctx context.Context, recurring_booking_id string) (*domain.RecurringBooking, error) {
+	booking, err := svc.repo.GetRecurringBookingById(ctx, recurring_booking_id)
+	if err != nil {
+		return nil, err
+	}
+	booking.StartsAt = booking.StartsAt.In(svc.location)
+	return booking, nil
+}
+
+// lockRecurringBooking is getRecurringBooking for a unit of work that goes on to change the series
+func (svc RecurringBookingServiceManagement) lockRecurringBooking(ctx context.Context, recurring_booking_id string) (*domain.RecurringBooking, error) {
+	booking, err := svc.repo.LockRecurringBookingById(ctx, recurring_booking_id)
+	if err != nil {
+		return nil, err
+	}
+	booking.StartsAt = booking.StartsAt.In(svc.location)
+	return booking, nil
+}
+
+// RecurringBookingWorker periodically books the upcoming occurrences of recurring bookings
+type RecurringBookingWorker struct {
+	recurring ports.RecurringBookingService
+	interval  time.Duration
+	logger    ports.LoggerService
+}
+
+func NewRecurringBookingWorker(recurring ports.RecurringBookingService, interval time.Duration, logger ports.LoggerService) *RecurringBookingWorker {
+	worker := RecurringBookingWorker{
+		recurring: recurring,
+		interval:  interval,
+		logger:    logger,
+	}
+	return &worker
+}
+
+// Run generates occurrences every interval until ctx is cancelled
+func (w RecurringBookingWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := w.recurring.GenerateOccurrences(ctx); err != nil {
+				w.logger.Error(err.Error())
+			}
+		}
+	}
+}
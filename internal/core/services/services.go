@@ -1,6 +1,10 @@
 package services
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
@@ -14,13 +18,23 @@ type ServiceServiceManagement struct {
 }
 
 type RequestServiceManagement struct {
-	repo   ports.RequestRepository
-	logger ports.LoggerService
+	repo         ports.RequestRepository
+	serviceRepo  ports.ServiceRepository
+	uow          ports.UnitOfWork
+	availability ports.AvailabilityService
+	pricing      ports.PricingService
+	invoices     ports.InvoiceService
+	logger       ports.LoggerService
 }
 
 type ReviewServiceManagement struct {
-	repo   ports.ReviewRepository
-	logger ports.LoggerService
+	repo        ports.ReviewRepository
+	requestRepo ports.RequestRepository
+	uow         ports.UnitOfWork
+	filter      ports.ContentFilter
+	// reviewWindow is how long after completion a request can be reviewed
+	reviewWindow time.Duration
+	logger       ports.LoggerService
 }
 
 func NewServiceServiceManagement(repo ports.ServiceRepository, logger ports.LoggerService) *ServiceServiceManagement {
@@ -32,107 +46,712 @@ func NewServiceServiceManagement(repo ports.ServiceRepository, logger ports.Logg
 	return &service
 }
 
-func NewRequestServiceManagement(repo ports.RequestRepository, logger ports.LoggerService) *RequestServiceManagement {
+func NewRequestServiceManagement(repo ports.RequestRepository, serviceRepo ports.ServiceRepository, uow ports.UnitOfWork, availability ports.AvailabilityService, pricing ports.PricingService, invoices ports.InvoiceService, logger ports.LoggerService) *RequestServiceManagement {
 	service := RequestServiceManagement{
-		repo:   repo,
-		logger: logger,
+		repo:         repo,
+		serviceRepo:  serviceRepo,
+		uow:          uow,
+		availability: availability,
+		pricing:      pricing,
+		invoices:     invoices,
+		logger:       logger,
 	}
 	return &service
 }
 
-func NewReviewServiceManagement(repo ports.ReviewRepository, logger ports.LoggerService) *ReviewServiceManagement {
+func NewReviewServiceManagement(repo ports.ReviewRepository, requestRepo ports.RequestRepository, uow ports.UnitOfWork, filter ports.ContentFilter, reviewWindow time.Duration, logger ports.LoggerService) *ReviewServiceManagement {
 	service := ReviewServiceManagement{
-		repo:   repo,
-		logger: logger,
+		repo:         repo,
+		requestRepo:  requestRepo,
+		uow:          uow,
+		filter:       filter,
+		reviewWindow: reviewWindow,
+		logger:       logger,
 	}
 	return &service
 }
 
 // Service Methods
-func (svc ServiceServiceManagement) CreateService(service domain.Service) (*domain.Service, error) {
+func (svc ServiceServiceManagement) CreateService(ctx context.Context, service domain.Service) (*domain.Service, error) {
+	ctx, span := tracer.Start(ctx, "ServiceService.CreateService")
+	defer span.End()
+
+	if err := normalizeService(&service); err != nil {
+		return nil, err
+	}
 	service.ServiceId = uuid.New().String()
 	service.CreatedAt = time.Now()
 	service.UpdatedAt = time.Now()
-	return svc.repo.CreateService(service)
+	return svc.repo.CreateService(ctx, service)
 }
 
-func (svc ServiceServiceManagement) GetServiceById(service_id string) (*domain.Service, error) {
-	return svc.repo.GetServiceById(service_id)
+func (svc ServiceServiceManagement) GetServiceById(ctx context.Context, service_id string) (*domain.Service, error) {
+	ctx, span := tracer.Start(ctx, "ServiceService.GetServiceById")
+	defer span.End()
+
+	return svc.repo.GetServiceById(ctx, service_id)
 }
 
-func (svc ServiceServiceManagement) GetServices() (*[]domain.Service, error) {
-	return svc.repo.GetServices()
+func (svc ServiceServiceManagement) GetServices(ctx context.Context, filter domain.ServiceFilter) (*domain.Page[domain.Service], error) {
+	ctx, span := tracer.Start(ctx, "ServiceService.GetServices")
+	defer span.End()
+
+	return svc.repo.GetServices(ctx, filter)
 }
 
-func (svc ServiceServiceManagement) UpdateService(service domain.Service) (*domain.Service, error) {
+// UpdateService changes a service's rates for new bookings only, existing requests keep their quote
+func (svc ServiceServiceManagement) UpdateService(ctx context.Context, service domain.Service) (*domain.Service, error) {
+	ctx, span := tracer.Start(ctx, "ServiceService.UpdateService")
+	defer span.End()
+
+	if err := normalizeService(&service); err != nil {
+		return nil, err
+	}
 	service.UpdatedAt = time.Now()
-	return svc.repo.UpdateService(service)
+	return svc.repo.UpdateService(ctx, service)
+}
+
+func (svc ServiceServiceManagement) DeleteService(ctx context.Context, service_id string) error {
+	ctx, span := tracer.Start(ctx, "ServiceService.DeleteService")
+	defer span.End()
+
+	return svc.repo.DeleteService(ctx, service_id)
 }
 
-func (svc ServiceServiceManagement) DeleteService(service_id string) error {
-	return svc.repo.DeleteService(service_id)
+// normalizeService fills in the default duration and currency before validating the rates
+func normalizeService(service *domain.Service) error {
+	if service.DurationMinutes <= 0 {
+		service.DurationMinutes = domain.DefaultDurationMinutes
+	}
+	if service.HourlyRate.Currency == "" {
+		service.HourlyRate.Currency = domain.DefaultCurrency
+	}
+	for i := range service.AddOns {
+		if service.AddOns[i].Price.Currency == "" {
+			service.AddOns[i].Price.Currency = service.HourlyRate.Currency
+		}
+	}
+	return service.Validate()
 }
 
 // Request Methods
-func (svc RequestServiceManagement) CreateRequest(request domain.Request) (*domain.Request, error) {
+func (svc RequestServiceManagement) CreateRequest(ctx context.Context, request domain.Request) (*domain.Request, error) {
+	ctx, span := tracer.Start(ctx, "RequestService.CreateRequest")
+	defer span.End()
+
+	if err := request.ValidateLocation(); err != nil {
+		return nil, err
+	}
+	service, err := resolveService(ctx, svc.serviceRepo, request.ServiceId)
+	if err != nil {
+		return nil, err
+	}
+
+	request.RequestId = uuid.New().String()
+	request.DurationMinutes = service.DurationMinutes
+	request.Quote, err = svc.pricing.QuoteRequest(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	request.Status = domain.RequestPending
+	if request.CleanerId != "" {
+		request.Status = domain.RequestAssigned
+	}
 	request.CreatedAt = time.Now()
 	request.UpdatedAt = time.Now()
-	return svc.repo.CreateRequest(request)
+
+	var created *domain.Request
+	err = svc.uow.Do(ctx, func(ctx context.Context) error {
+		if request.CleanerId != "" {
+			if err := svc.availability.CheckAvailability(ctx, request.CleanerId, request.Slot(), ""); err != nil {
+				return err
+			}
+		}
+		created, err = svc.repo.CreateRequest(ctx, request)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
 }
 
-func (svc RequestServiceManagement) GetRequestById(request_id string) (*domain.Request, error) {
-	return svc.repo.GetRequestById(request_id)
+func (svc RequestServiceManagement) GetRequestById(ctx context.Context, request_id string) (*domain.Request, error) {
+	ctx, span := tracer.Start(ctx, "RequestService.GetRequestById")
+	defer span.End()
+
+	return svc.repo.GetRequestById(ctx, request_id)
 }
 
-func (svc RequestServiceManagement) GetRequests() (*[]domain.Request, error) {
-	return svc.repo.GetRequests()
+func (svc RequestServiceManagement) GetRequests(ctx context.Context, filter domain.RequestFilter) (*domain.Page[domain.Request], error) {
+	ctx, span := tracer.Start(ctx, "RequestService.GetRequests")
+	defer span.End()
+
+	return svc.repo.GetRequests(ctx, filter)
 }
 
-func (svc RequestServiceManagement) UpdateRequest(request domain.Request) (*domain.Request, error) {
+// UpdateRequest changes what was booked. The status only changes through TransitionRequest and
+// AssignCleaner, which invoice completed requests, the cleaner only through AssignCleaner, and cancelling and moving the booking go through
+// CancelRequest and RescheduleRequest, so the cancellation policy applies and the change is recorded.
+func (svc RequestServiceManagement) UpdateRequest(ctx context.Context, request domain.Request) (*domain.Request, error) {
+	ctx, span := tracer.Start(ctx, "RequestService.UpdateRequest")
+	defer span.End()
+
+	if err := request.ValidateLocation(); err != nil {
+		return nil, err
+	}
+	dbRequest, err := svc.repo.GetRequestById(ctx, request.RequestId)
+	if err != nil {
+		return nil, err
+	}
+	from := dbRequest.Status
+	if !request.RequestedDate.Equal(dbRequest.RequestedDate) {
+		return nil, fmt.Errorf("%w: requested_date is changed by rescheduling the request", domain.ErrInvalidInput)
+	}
+	request.Changes = dbRequest.Changes
+
+	if request.Status != "" && request.Status != dbRequest.Status {
+		return nil, fmt.Errorf("%w: status is changed through the request's lifecycle operations", domain.ErrInvalidInput)
+	}
+	request.Status = dbRequest.Status
+	if request.CleanerId != "" && request.CleanerId != dbRequest.CleanerId {
+		return nil, fmt.Errorf("%w: the cleaner is changed by assigning the request", domain.ErrInvalidInput)
+	}
+	request.CleanerId = dbRequest.CleanerId
+
+	request.DurationMinutes = dbRequest.DurationMinutes
+	if request.ServiceId != dbRequest.ServiceId {
+		service, err := resolveService(ctx, svc.serviceRepo, request.ServiceId)
+		if err != nil {
+			return nil, err
+		}
+		request.DurationMinutes = service.DurationMinutes
+	}
+
+	// The booked price only changes when what was booked changes, not when the service's rates do.
+	request.Quote = dbRequest.Quote
+	if request.ServiceId != dbRequest.ServiceId || request.DiscountCode != dbRequest.DiscountCode || !equalStrings(request.AddOns, dbRequest.AddOns) {
+		if err := svc.requote(ctx, &request); err != nil {
+			return nil, err
+		}
+	}
+
 	request.UpdatedAt = time.Now()
-	return svc.repo.UpdateRequest(request)
+	var updated *domain.Request
+	err = svc.uow.Do(ctx, func(ctx context.Context) error {
+		// The cleaner and the date are fixed here, a longer service is all that can run into their next booking
+		if request.DurationMinutes != dbRequest.DurationMinutes && request.OccupiesCleaner() {
+			if err := svc.availability.CheckAvailability(ctx, request.CleanerId, request.Slot(), request.RequestId); err != nil {
+				return err
+			}
+		}
+		updated, err = svc.repo.UpdateRequest(ctx, request, from)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
 }
 
-func (svc RequestServiceManagement) DeleteRequest(request_id string) error {
-	return svc.repo.DeleteRequest(request_id)
+func (svc RequestServiceManagement) AssignCleaner(ctx context.Context, request_id, cleaner_id string) error {
+	ctx, span := tracer.Start(ctx, "RequestService.AssignCleaner")
+	defer span.End()
+
+	request, err := svc.repo.GetRequestById(ctx, request_id)
+	if err != nil {
+		return err
+	}
+
+	// Reassigning an already assigned request is allowed, it only swaps the cleaner.
+	from := request.Status
+	if request.Status != domain.RequestAssigned {
+		if err := request.Transition(domain.RequestAssigned); err != nil {
+			svc.logger.WithContext(ctx).Warning(err.Error())
+			return err
+		}
+	}
+
+	return svc.uow.Do(ctx, func(ctx context.Context) error {
+		if err := svc.availability.CheckAvailability(ctx, cleaner_id, request.Slot(), request_id); err != nil {
+			return err
+		}
+		if err := svc.repo.UpdateRequestStatus(ctx, request_id, from, request.Status); err != nil {
+			return err
+		}
+		return svc.repo.AssignCleaner(ctx, request_id, cleaner_id)
+	})
 }
 
-func (svc RequestServiceManagement) AssignCleaner(request_id, cleaner_id string) error {
-	return svc.repo.AssignCleaner(request_id, cleaner_id)
+// TransitionRequest moves a request along its lifecycle. Assignment goes through AssignCleaner since it needs a cleaner.
+// The status is only written if nobody changed it since it was read.
+func (svc RequestServiceManagement) TransitionRequest(ctx context.Context, request_id string, status domain.RequestStatus) (*domain.Request, error) {
+	ctx, span := tracer.Start(ctx, "RequestService.TransitionRequest")
+	defer span.End()
+
+	request, err := svc.repo.GetRequestById(ctx, request_id)
+	if err != nil {
+		return nil, err
+	}
+
+	if status == domain.RequestAssigned {
+		return nil, domain.TransitionError{From: request.Status, To: status}
+	}
+	// Cancellation goes through CancelRequest, which applies the cancellation policy
+	if status == domain.RequestCancelled {
+		return nil, fmt.Errorf("%w: requests are cancelled through the cancel operation", domain.ErrInvalidInput)
+	}
+
+	from := request.Status
+	if err := request.Transition(status); err != nil {
+		svc.logger.WithContext(ctx).Warning(err.Error())
+		return nil, err
+	}
+
+	// A completed request is invoiced in the same transaction, so if the invoice fails the request
+	// stays in progress and completing it can be retried.
+	err = svc.uow.Do(ctx, func(ctx context.Context) error {
+		if err := svc.repo.UpdateRequestStatus(ctx, request_id, from, request.Status); err != nil {
+			return err
+		}
+		if request.Status == domain.RequestCompleted {
+			if _, err := svc.invoices.GenerateInvoice(ctx, request_id); err != nil {
+				svc.logger.WithContext(ctx).Error("invoice for request " + request_id + " failed: " + err.Error())
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return svc.repo.GetRequestById(ctx, request_id)
 }
 
-func (svc RequestServiceManagement) GetRequestByClient(client_id string) (*[]domain.Request, error) {
-	return svc.repo.GetRequestByClient(client_id)
+// CancelRequest cancels the request, charging the client the policy's fee when it is late. A
+// late fee is invoiced in the same transaction. When the request's cleaner cancels, the client is
+// not charged and the job goes back to dispatch instead.
+func (svc RequestServiceManagement) CancelRequest(ctx context.Context, request_id string, change domain.BookingChange) (*domain.Request, error) {
+	ctx, span := tracer.Start(ctx, "RequestService.CancelRequest")
+	defer span.End()
+
+	change.At = time.Now()
+	if err := change.Validate(); err != nil {
+		return nil, err
+	}
+	request, err := svc.repo.GetRequestById(ctx, request_id)
+	if err != nil {
+		return nil, err
+	}
+	from := request.Status
+
+	if change.Initiator == domain.InitiatorCleaner {
+		if err := request.Release(change); err != nil {
+			svc.logger.WithContext(ctx).Warning(err.Error())
+			return nil, err
+		}
+		request.UpdatedAt = change.At
+		return svc.repo.UpdateRequest(ctx, *request, from)
+	}
+
+	if err := svc.chargeChange(ctx, *request, domain.ChangeCancelled, &change); err != nil {
+		return nil, err
+	}
+	if err := request.Cancel(change); err != nil {
+		svc.logger.WithContext(ctx).Warning(err.Error())
+		return nil, err
+	}
+	request.UpdatedAt = change.At
+
+	err = svc.uow.Do(ctx, func(ctx context.Context) error {
+		if _, err := svc.repo.UpdateRequest(ctx, *request, from); err != nil {
+			return err
+		}
+		if request.CancellationQuote() != nil {
+			if _, err := svc.invoices.GenerateInvoice(ctx, request_id); err != nil {
+				svc.logger.WithContext(ctx).Error("cancellation fee invoice for request " + request_id + " failed: " + err.Error())
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return svc.repo.GetRequestById(ctx, request_id)
 }
 
-func (svc RequestServiceManagement) GetRequestByCleaner(cleaner_id string) (*[]domain.Request, error) {
-	return svc.repo.GetRequestByCleaner(cleaner_id)
+// RescheduleRequest moves the request to requested_date. The booking is priced again for its new
+// time and a late reschedule adds the policy's fee on top. The cleaner keeps the job if they are
+// free at the new time.
+func (svc RequestServiceManagement) RescheduleRequest(ctx context.Context, request_id string, requested_date time.Time, change domain.BookingChange) (*domain.Request, error) {
+	ctx, span := tracer.Start(ctx, "RequestService.RescheduleRequest")
+	defer span.End()
+
+	change.At = time.Now()
+	if err := change.Validate(); err != nil {
+		return nil, err
+	}
+	if change.Initiator == domain.InitiatorCleaner {
+		return nil, fmt.Errorf("%w: cleaners cancel a job they cannot do rather than move it", domain.ErrForbidden)
+	}
+	request, err := svc.repo.GetRequestById(ctx, request_id)
+	if err != nil {
+		return nil, err
+	}
+	from := request.Status
+
+	if err := svc.chargeChange(ctx, *request, domain.ChangeRescheduled, &change); err != nil {
+		return nil, err
+	}
+	if err := request.Reschedule(requested_date, change); err != nil {
+		svc.logger.WithContext(ctx).Warning(err.Error())
+		return nil, err
+	}
+	if err := svc.requote(ctx, request); err != nil {
+		return nil, err
+	}
+	request.UpdatedAt = change.At
+	var rescheduled *domain.Request
+	err = svc.uow.Do(ctx, func(ctx context.Context) error {
+		if request.OccupiesCleaner() {
+			if err := svc.availability.CheckAvailability(ctx, request.CleanerId, request.Slot(), request.RequestId); err != nil {
+				return err
+			}
+		}
+		rescheduled, err = svc.repo.UpdateRequest(ctx, *request, from)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rescheduled, nil
+}
+
+// chargeChange sets the fee the cancellation policy charges for the change, or records it as
+// waived when staff waive it
+func (svc RequestServiceManagement) chargeChange(ctx context.Context, request domain.Request, kind domain.BookingChangeKind, change *domain.BookingChange) error {
+	fee, err := svc.pricing.QuoteChangeFee(ctx, request, kind, change.Initiator, change.At)
+	if err != nil {
+		return err
+	}
+	change.Fee = *fee
+	if change.FeeWaived {
+		change.Fee = domain.NewMoney(0, fee.Currency)
+	}
+	return nil
+}
+
+// requote prices the request at the current rates, keeping the fees charged for earlier reschedules
+func (svc RequestServiceManagement) requote(ctx context.Context, request *domain.Request) error {
+	quote, err := svc.pricing.QuoteRequest(ctx, *request)
+	if err != nil {
+		return err
+	}
+	request.Quote, err = request.WithFees(quote)
+	if err != nil {
+		svc.logger.WithContext(ctx).Warning(err.Error())
+		return err
+	}
+	return nil
+}
+
+func (svc RequestServiceManagement) GetRequestByClient(ctx context.Context, client_id string, filter domain.RequestFilter) (*domain.Page[domain.Request], error) {
+	ctx, span := tracer.Start(ctx, "RequestService.GetRequestByClient")
+	defer span.End()
+
+	return svc.repo.GetRequestByClient(ctx, client_id, filter)
+}
+
+func (svc RequestServiceManagement) GetRequestByCleaner(ctx context.Context, cleaner_id string, filter domain.RequestFilter) (*domain.Page[domain.Request], error) {
+	ctx, span := tracer.Start(ctx, "RequestService.GetRequestByCleaner")
+	defer span.End()
+
+	return svc.repo.GetRequestByCleaner(ctx, cleaner_id, filter)
 }
 
 // Review Methods
-func (svc ReviewServiceManagement) CreateReview(review domain.Reviews) (*domain.Reviews, error) {
+
+// CreateReview records the review of review.ClientId, who must be the client of the completed
+// request reviewed. Everything else about the request is taken from the request itself. The
+// review is published straight away unless the content filter holds it for moderation.
+func (svc ReviewServiceManagement) CreateReview(ctx context.Context, review domain.Reviews) (*domain.Reviews, error) {
+	ctx, span := tracer.Start(ctx, "ReviewService.CreateReview")
+	defer span.End()
+
+	if err := review.ValidateRating(); err != nil {
+		return nil, err
+	}
+	request, err := svc.requestRepo.GetRequestById(ctx, review.RequestId)
+	if err != nil {
+		return nil, err
+	}
+	if err := request.CheckReviewable(review.ClientId, time.Now(), svc.reviewWindow); err != nil {
+		svc.logger.WithContext(ctx).Warning(err.Error())
+		return nil, err
+	}
+	review.ReviewId = uuid.New().String()
+	review.CleanerId = request.CleanerId
+	review.ServiceId = request.ServiceId
+	review.Status, review.ModerationNote, review.ModeratedBy, review.ModeratedAt = "", "", "", nil
+	review.Flags, review.Response = nil, nil
+	if err := svc.screen(ctx, &review); err != nil {
+		return nil, err
+	}
 	review.CreatedAt = time.Now()
 	review.UpdatedAt = time.Now()
-	return svc.repo.CreateReview(review)
+
+	var created *domain.Reviews
+	err = svc.uow.Do(ctx, func(ctx context.Context) error {
+		var err error
+		if created, err = svc.repo.CreateReview(ctx, review); err != nil {
+			if errors.Is(err, domain.ErrAlreadyExists) {
+				return fmt.Errorf("%w: request %s has already been reviewed", domain.ErrAlreadyExists, review.RequestId)
+			}
+			return err
+		}
+		return svc.addRatings(ctx, *created, 1)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
 }
 
-func (svc ReviewServiceManagement) GetReviewById(review_id string) (*domain.Reviews, error) {
-	return svc.repo.GetReviewById(review_id)
+func (svc ReviewServiceManagement) GetReviewById(ctx context.Context, review_id string) (*domain.Reviews, error) {
+	ctx, span := tracer.Start(ctx, "ReviewService.GetReviewById")
+	defer span.End()
+
+	return svc.repo.GetReviewById(ctx, review_id)
 }
 
-func (svc ReviewServiceManagement) UpdateReview(review domain.Reviews) (*domain.Reviews, error) {
+// UpdateReview changes the rating and comment of a review, what was reviewed stays the same
+func (svc ReviewServiceManagement) UpdateReview(ctx context.Context, review domain.Reviews) (*domain.Reviews, error) {
+	ctx, span := tracer.Start(ctx, "ReviewService.UpdateReview")
+	defer span.End()
+
+	if err := review.ValidateRating(); err != nil {
+		return nil, err
+	}
+	existing, err := svc.repo.GetReviewById(ctx, review.ReviewId)
+	if err != nil {
+		return nil, err
+	}
+	review.RequestId = existing.RequestId
+	review.ClientId = existing.ClientId
+	review.CleanerId = existing.CleanerId
+	review.ServiceId = existing.ServiceId
+	review.Status, review.ModerationNote, review.ModeratedBy, review.ModeratedAt = existing.Status, existing.ModerationNote, existing.ModeratedBy, existing.ModeratedAt
+	review.Flags, review.Response = existing.Flags, existing.Response
+	if err := svc.screen(ctx, &review); err != nil {
+		return nil, err
+	}
+	return svc.replaceReview(ctx, *existing, review)
+}
+
+// screen runs the review's comment through the content filter
+func (svc ReviewServiceManagement) screen(ctx context.Context, review *domain.Reviews) error {
+	verdict, err := svc.filter.Screen(ctx, review.Comment)
+	if err != nil {
+		return err
+	}
+	review.Screen(*verdict)
+	if !verdict.Allowed {
+		svc.logger.WithContext(ctx).Info(fmt.Sprintf("review of request %s held for moderation", review.RequestId))
+	}
+	return nil
+}
+
+// replaceReview stores the changed review, moving its rating in or out of the aggregates as it
+// changes or goes live or stops being live
+func (svc ReviewServiceManagement) replaceReview(ctx context.Context, existing, review domain.Reviews) (*domain.Reviews, error) {
 	review.UpdatedAt = time.Now()
-	return svc.repo.UpdateReview(review)
+
+	var updated *domain.Reviews
+	err := svc.uow.Do(ctx, func(ctx context.Context) error {
+		var err error
+		if updated, err = svc.repo.UpdateReview(ctx, review); err != nil {
+			return err
+		}
+		if err := svc.addRatings(ctx, existing, -1); err != nil {
+			return err
+		}
+		return svc.addRatings(ctx, *updated, 1)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// GetReviews lists reviews regardless of who wrote them or is reviewed, the moderation queue
+func (svc ReviewServiceManagement) GetReviews(ctx context.Context, filter domain.ReviewFilter) (*domain.Page[domain.Reviews], error) {
+	ctx, span := tracer.Start(ctx, "ReviewService.GetReviews")
+	defer span.End()
+
+	return svc.repo.GetReviews(ctx, filter)
+}
+
+func (svc ReviewServiceManagement) ApproveReview(ctx context.Context, review_id, moderator_id, note string) (*domain.Reviews, error) {
+	ctx, span := tracer.Start(ctx, "ReviewService.ApproveReview")
+	defer span.End()
+
+	return svc.moderate(ctx, review_id, domain.ReviewPublished, moderator_id, note)
+}
+
+func (svc ReviewServiceManagement) RejectReview(ctx context.Context, review_id, moderator_id, note string) (*domain.Reviews, error) {
+	ctx, span := tracer.Start(ctx, "ReviewService.RejectReview")
+	defer span.End()
+
+	return svc.moderate(ctx, review_id, domain.ReviewRejected, moderator_id, note)
 }
 
-func (svc ReviewServiceManagement) DeleteReview(review_id string) error {
-	return svc.repo.DeleteReview(review_id)
+func (svc ReviewServiceManagement) moderate(ctx context.Context, review_id string, status domain.ReviewStatus, moderator_id, note string) (*domain.Reviews, error) {
+	existing, err := svc.repo.GetReviewById(ctx, review_id)
+	if err != nil {
+		return nil, err
+	}
+	review := *existing
+	if err := review.Moderate(status, moderator_id, strings.TrimSpace(note), time.Now()); err != nil {
+		return nil, err
+	}
+	svc.logger.WithContext(ctx).Info(fmt.Sprintf("review %s %s by %s", review_id, status, moderator_id))
+	return svc.replaceReview(ctx, *existing, review)
+}
+
+// FlagReview reports a live review to the moderators. The review stays up until they decide.
+func (svc ReviewServiceManagement) FlagReview(ctx context.Context, review_id, flagged_by, reason string) (*domain.Reviews, error) {
+	ctx, span := tracer.Start(ctx, "ReviewService.FlagReview")
+	defer span.End()
+
+	existing, err := svc.repo.GetReviewById(ctx, review_id)
+	if err != nil {
+		return nil, err
+	}
+	review := *existing
+	review.Flags = append([]domain.ReviewFlag(nil), existing.Flags...)
+	if err := review.Flag(domain.ReviewFlag{FlaggedBy: flagged_by, Reason: strings.TrimSpace(reason), FlaggedAt: time.Now()}); err != nil {
+		return nil, err
+	}
+	return svc.replaceReview(ctx, *existing, review)
+}
+
+// RespondToReview sets the reviewed cleaner's public reply. Replies the content filter objects to
+// are refused rather than held, the cleaner can reword them straight away.
+func (svc ReviewServiceManagement) RespondToReview(ctx context.Context, review_id, cleaner_id, body string) (*domain.Reviews, error) {
+	ctx, span := tracer.Start(ctx, "ReviewService.RespondToReview")
+	defer span.End()
+
+	existing, err := svc.repo.GetReviewById(ctx, review_id)
+	if err != nil {
+		return nil, err
+	}
+	review := *existing
+	if err := review.Respond(cleaner_id, body, time.Now()); err != nil {
+		return nil, err
+	}
+	verdict, err := svc.filter.Screen(ctx, review.Response.Body)
+	if err != nil {
+		return nil, err
+	}
+	if !verdict.Allowed {
+		return nil, fmt.Errorf("%w: the response contains %s", domain.ErrInvalidInput, strings.Join(verdict.Matches, ", "))
+	}
+	return svc.replaceReview(ctx, *existing, review)
 }
 
-func (svc ReviewServiceManagement) GetReviewByClient(client_id string) (*[]domain.Reviews, error) {
-	return svc.repo.GetReviewByClient(client_id)
+func (svc ReviewServiceManagement) DeleteReview(ctx context.Context, review_id string) error {
+	ctx, span := tracer.Start(ctx, "ReviewService.DeleteReview")
+	defer span.End()
+
+	existing, err := svc.repo.GetReviewById(ctx, review_id)
+	if err != nil {
+		return err
+	}
+	return svc.uow.Do(ctx, func(ctx context.Context) error {
+		if err := svc.repo.DeleteReview(ctx, review_id); err != nil {
+			return err
+		}
+		return svc.addRatings(ctx, *existing, -1)
+	})
 }
 
-func (svc ReviewServiceManagement) GetReviewByCleaner(cleaner_id string) (*[]domain.Reviews, error) {
-	return svc.repo.GetReviewByCleaner(cleaner_id)
+// addRatings adds the review to (sign 1) or takes it out of (sign -1) the rating aggregates of its
+// cleaner, its service and the platform
+func (svc ReviewServiceManagement) addRatings(ctx context.Context, review domain.Reviews, sign int) error {
+	delta := review.RatingDelta(sign)
+	if delta.IsZero() {
+		return nil
+	}
+	subjects := []struct {
+		kind domain.RatingSubject
+		id   string
+	}{
+		{domain.RatingSubjectCleaner, review.CleanerId},
+		{domain.RatingSubjectService, review.ServiceId},
+		{domain.RatingSubjectPlatform, ""},
+	}
+	for _, subject := range subjects {
+		if subject.id == "" && subject.kind != domain.RatingSubjectPlatform {
+			continue
+		}
+		delta.SubjectType, delta.SubjectId = subject.kind, subject.id
+		if err := svc.repo.AddRatings(ctx, delta); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (svc ReviewServiceManagement) GetCleanerRatingSummary(ctx context.Context, cleaner_id string) (*domain.RatingSummary, error) {
+	ctx, span := tracer.Start(ctx, "ReviewService.GetCleanerRatingSummary")
+	defer span.End()
+
+	return svc.ratingSummary(ctx, domain.RatingSubjectCleaner, cleaner_id)
+}
+
+func (svc ReviewServiceManagement) GetServiceRatingSummary(ctx context.Context, service_id string) (*domain.RatingSummary, error) {
+	ctx, span := tracer.Start(ctx, "ReviewService.GetServiceRatingSummary")
+	defer span.End()
+
+	return svc.ratingSummary(ctx, domain.RatingSubjectService, service_id)
+}
+
+func (svc ReviewServiceManagement) ratingSummary(ctx context.Context, subject_type domain.RatingSubject, subject_id string) (*domain.RatingSummary, error) {
+	aggregate, err := svc.repo.GetRatingAggregate(ctx, subject_type, subject_id)
+	if err != nil {
+		return nil, err
+	}
+	platform, err := svc.repo.GetRatingAggregate(ctx, domain.RatingSubjectPlatform, "")
+	if err != nil {
+		return nil, err
+	}
+	summary := aggregate.Summary(*platform)
+	return &summary, nil
+}
+
+func (svc ReviewServiceManagement) GetReviewByClient(ctx context.Context, client_id string, filter domain.ReviewFilter) (*domain.Page[domain.Reviews], error) {
+	ctx, span := tracer.Start(ctx, "ReviewService.GetReviewByClient")
+	defer span.End()
+
+	return svc.repo.GetReviewByClient(ctx, client_id, filter)
+}
+
+func (svc ReviewServiceManagement) GetReviewByCleaner(ctx context.Context, cleaner_id string, filter domain.ReviewFilter) (*domain.Page[domain.Reviews], error) {
+	ctx, span := tracer.Start(ctx, "ReviewService.GetReviewByCleaner")
+	defer span.End()
+
+	return svc.repo.GetReviewByCleaner(ctx, cleaner_id, filter)
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
 }
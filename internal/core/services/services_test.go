@@ -1 +1,1331 @@
-package services
\ No newline at end of file
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/adapter/moderation"
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/adapter/payment"
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/adapter/pdf"
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/adapter/repository"
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/domain"
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/ports"
+)
+
+type testLogger struct{}
+
+func (testLogger) Debug(message string)   {}
+func (testLogger) Info(message string)    {}
+func (testLogger) Warning(message string) {}
+func (testLogger) Error(message string)   {}
+
+func (l testLogger) With(fields map[string]interface{}) ports.LoggerService { return l }
+func (l testLogger) WithContext(ctx context.Context) ports.LoggerService    { return l }
+
+// newTestServices wires the services over one memory repository holding a two hour
+// "service-1" and a "cleaner-1" who works 08:00 to 18:00 every day.
+func newTestServices(t *testing.T) (*RequestServiceManagement, *AvailabilityServiceManagement) {
+	ctx := context.Background()
+	repo := repository.NewMemoryClient()
+	if _, err := repo.CreateService(ctx, domain.Service{ServiceId: "service-1", Name: "Deep clean", DurationMinutes: 120}); err != nil {
+		t.Fatal(err)
+	}
+
+	requests, availability, _ := newRequestStack(repo)
+	week := []domain.WorkingHours{}
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		week = append(week, domain.WorkingHours{Weekday: day, StartTime: "08:00", EndTime: "18:00"})
+	}
+	if _, err := availability.SetWorkingHours(ctx, "cleaner-1", week); err != nil {
+		t.Fatal(err)
+	}
+
+	return requests, availability
+}
+
+// testRepository is the set of ports the memory client provides to the services under test
+type testRepository interface {
+	ports.ServiceRepository
+	ports.RequestRepository
+	ports.AvailabilityRepository
+	ports.InvoiceRepository
+	ports.UnitOfWork
+}
+
+// newRequestStack wires the request service and its collaborators over repo, pricing without
+// surcharges and taxing invoices at 16%
+func newRequestStack(repo testRepository) (*RequestServiceManagement, *AvailabilityServiceManagement, *InvoiceServiceManagement) {
+	availability := NewAvailabilityServiceManagement(repo, repo, repo, time.UTC, testLogger{})
+	pricing := NewPricingServiceManagement(repo, domain.PricingRules{}, time.UTC, testLogger{})
+	invoices := NewInvoiceServiceManagement(repo, repo, pricing, pdf.NewInvoiceRenderer("Usafi Hub", time.UTC), 1600, testLogger{})
+	return NewRequestServiceManagement(repo, repo, repo, availability, pricing, invoices, testLogger{}), availability, invoices
+}
+
+func newTestRequestService(t *testing.T) *RequestServiceManagement {
+	svc, _ := newTestServices(t)
+	return svc
+}
+
+// nextMonday returns 00:00 UTC of a Monday at least a day in the future
+func nextMonday() time.Time {
+	day := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	for day.Weekday() != time.Monday {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day
+}
+
+func TestCreateRequestStartsPending(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestRequestService(t)
+
+	request, err := svc.CreateRequest(ctx, domain.Request{ClientId: "client-1", ServiceId: "service-1", RequestedDate: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request.RequestId == "" {
+		t.Error("expected a request id to be generated")
+	}
+	if request.Status != domain.RequestPending {
+		t.Errorf("expected status %s, got %s", domain.RequestPending, request.Status)
+	}
+}
+
+func TestRequestLifecycle(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestRequestService(t)
+
+	request, err := svc.CreateRequest(ctx, domain.Request{ClientId: "client-1", ServiceId: "service-1", RequestedDate: nextMonday().Add(9 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := svc.TransitionRequest(ctx, request.RequestId, domain.RequestInProgress); !errors.Is(err, domain.ErrInvalidTransition) {
+		t.Fatalf("expected ErrInvalidTransition starting an unassigned request, got %v", err)
+	}
+
+	if err := svc.AssignCleaner(ctx, request.RequestId, "cleaner-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, status := range []domain.RequestStatus{domain.RequestEnRoute, domain.RequestInProgress, domain.RequestCompleted} {
+		updated, err := svc.TransitionRequest(ctx, request.RequestId, status)
+		if err != nil {
+			t.Fatalf("transition to %s: %v", status, err)
+		}
+		if updated.Status != status {
+			t.Fatalf("expected status %s, got %s", status, updated.Status)
+		}
+	}
+
+	completed, err := svc.GetRequestById(ctx, request.RequestId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if completed.CleanerId != "cleaner-1" {
+		t.Errorf("expected cleaner-1 to be assigned, got %q", completed.CleanerId)
+	}
+
+	if _, err := svc.TransitionRequest(ctx, request.RequestId, domain.RequestPending); !errors.Is(err, domain.ErrInvalidTransition) {
+		t.Errorf("expected ErrInvalidTransition reopening a completed request, got %v", err)
+	}
+	completed.Status = domain.RequestPending
+	if _, err := svc.UpdateRequest(ctx, *completed); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("expected an update not to reopen a completed request, got %v", err)
+	}
+}
+
+func TestUpdateRequestLeavesStatusToLifecycle(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestRequestService(t)
+
+	request, err := svc.CreateRequest(ctx, domain.Request{ClientId: "client-1", ServiceId: "service-1", CleanerId: "cleaner-1", RequestedDate: nextMonday().Add(9 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range []domain.RequestStatus{domain.RequestEnRoute, domain.RequestInProgress} {
+		if _, err := svc.TransitionRequest(ctx, request.RequestId, status); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	started, err := svc.GetRequestById(ctx, request.RequestId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	started.Status = domain.RequestCompleted
+	if _, err := svc.UpdateRequest(ctx, *started); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Fatalf("expected an update not to complete the request, got %v", err)
+	}
+	stored, err := svc.GetRequestById(ctx, request.RequestId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != domain.RequestInProgress || stored.CompletedAt != nil {
+		t.Errorf("expected the request to stay in progress, got %s completed at %v", stored.Status, stored.CompletedAt)
+	}
+
+	started.Status = ""
+	started.Latitude, started.Longitude = -1.2921, 36.8219
+	updated, err := svc.UpdateRequest(ctx, *started)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Status != domain.RequestInProgress || updated.Latitude != started.Latitude {
+		t.Errorf("expected the update to keep status %s, got %+v", domain.RequestInProgress, updated)
+	}
+
+	// Nor does an update hand the job to someone else, that goes through AssignCleaner
+	started.CleanerId = "cleaner-2"
+	if _, err := svc.UpdateRequest(ctx, *started); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("expected an update not to change the cleaner, got %v", err)
+	}
+	started.CleanerId = ""
+	updated, err = svc.UpdateRequest(ctx, *started)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.CleanerId != "cleaner-1" {
+		t.Errorf("expected the update to keep cleaner-1, got %q", updated.CleanerId)
+	}
+}
+
+func TestGetRequestByIdNotFound(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestRequestService(t)
+
+	if _, err := svc.GetRequestById(ctx, "missing"); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestGetRequestsPagination(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestRequestService(t)
+
+	start := nextMonday().Add(9 * time.Hour)
+	for i := 0; i < 5; i++ {
+		request := domain.Request{ClientId: "client-1", ServiceId: "service-1", RequestedDate: start.Add(time.Duration(i) * time.Hour)}
+		if i%2 == 0 {
+			request.CleanerId = "cleaner-1"
+		}
+		if _, err := svc.CreateRequest(ctx, request); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	filter := domain.RequestFilter{ListOptions: domain.ListOptions{Limit: 2, SortBy: "requested_date", SortDesc: true}}
+	var seen []time.Time
+	for {
+		page, err := svc.GetRequestByClient(ctx, "client-1", filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.Total != 5 {
+			t.Fatalf("expected a total of 5, got %d", page.Total)
+		}
+		for _, request := range page.Items {
+			seen = append(seen, request.RequestedDate)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+
+	if len(seen) != 5 {
+		t.Fatalf("expected 5 requests across pages, got %d", len(seen))
+	}
+	for i := 1; i < len(seen); i++ {
+		if !seen[i].Before(seen[i-1]) {
+			t.Fatalf("requests are not sorted by requested_date descending: %v", seen)
+		}
+	}
+
+	assigned, err := svc.GetRequests(ctx, domain.RequestFilter{Status: domain.RequestAssigned})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assigned.Total != 3 {
+		t.Errorf("expected 3 assigned requests, got %d", assigned.Total)
+	}
+
+	if _, err := svc.GetRequests(ctx, domain.RequestFilter{ListOptions: domain.ListOptions{SortBy: "name"}}); !errors.Is(err, domain.ErrInvalidListOptions) {
+		t.Errorf("expected ErrInvalidListOptions for an unknown sort key, got %v", err)
+	}
+}
+
+func TestAssignCleanerRejectsDoubleBooking(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestRequestService(t)
+	monday := nextMonday()
+
+	first, err := svc.CreateRequest(ctx, domain.Request{ClientId: "client-1", ServiceId: "service-1", CleanerId: "cleaner-1", RequestedDate: monday.Add(10 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.DurationMinutes != 120 {
+		t.Errorf("expected the service duration to be copied onto the request, got %d", first.DurationMinutes)
+	}
+
+	overlapping, err := svc.CreateRequest(ctx, domain.Request{ClientId: "client-2", ServiceId: "service-1", RequestedDate: monday.Add(11 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.AssignCleaner(ctx, overlapping.RequestId, "cleaner-1"); !errors.Is(err, domain.ErrCleanerUnavailable) {
+		t.Errorf("expected ErrCleanerUnavailable for an overlapping booking, got %v", err)
+	}
+
+	evening, err := svc.CreateRequest(ctx, domain.Request{ClientId: "client-2", ServiceId: "service-1", RequestedDate: monday.Add(17 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.AssignCleaner(ctx, evening.RequestId, "cleaner-1"); !errors.Is(err, domain.ErrCleanerUnavailable) {
+		t.Errorf("expected ErrCleanerUnavailable for a booking running past working hours, got %v", err)
+	}
+
+	if _, err := svc.CancelRequest(ctx, first.RequestId, domain.BookingChange{Initiator: domain.InitiatorClient, By: "client-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.AssignCleaner(ctx, overlapping.RequestId, "cleaner-1"); err != nil {
+		t.Errorf("expected the cancelled booking to free the cleaner, got %v", err)
+	}
+}
+
+func TestGetSlots(t *testing.T) {
+	ctx := context.Background()
+	svc, availability := newTestServices(t)
+	monday := nextMonday()
+
+	if _, err := svc.CreateRequest(ctx, domain.Request{ClientId: "client-1", ServiceId: "service-1", CleanerId: "cleaner-1", RequestedDate: monday.Add(10 * time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := availability.CreateTimeOff(ctx, domain.TimeOff{CleanerId: "cleaner-1", StartsAt: monday.Add(14 * time.Hour), EndsAt: monday.Add(18 * time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	slots, err := availability.GetSlots(ctx, "cleaner-1", monday, "service-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Free windows are 08:00-10:00 and 12:00-14:00, each fits exactly one two hour slot.
+	expected := []time.Time{monday.Add(8 * time.Hour), monday.Add(12 * time.Hour)}
+	if len(*slots) != len(expected) {
+		t.Fatalf("expected %d slots, got %v", len(expected), *slots)
+	}
+	for i, slot := range *slots {
+		if !slot.StartsAt.Equal(expected[i]) {
+			t.Errorf("slot %d starts at %s, expected %s", i, slot.StartsAt, expected[i])
+		}
+	}
+
+	if _, err := availability.CreateException(ctx, domain.AvailabilityException{CleanerId: "cleaner-1", Date: monday.Format(domain.DateLayout)}); err != nil {
+		t.Fatal(err)
+	}
+	slots, err = availability.GetSlots(ctx, "cleaner-1", monday, "service-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*slots) != 0 {
+		t.Errorf("expected no slots on a day off, got %v", *slots)
+	}
+}
+
+func TestAutoAssignRanksEligibleCleaners(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryClient()
+	for _, service := range []domain.Service{{ServiceId: "service-1", DurationMinutes: 120}, {ServiceId: "service-2", DurationMinutes: 60}} {
+		if _, err := repo.CreateService(ctx, service); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	requests, availability, _ := newRequestStack(repo)
+	matching := NewMatchingServiceManagement(repo, repo, repo, requests, availability, NewWeightedMatchingStrategy(), testLogger{})
+
+	profiles := []domain.CleanerProfile{
+		{CleanerId: "near", ServiceIds: []string{"service-1"}, Latitude: -1.2921, Longitude: 36.8219, Active: true},
+		{CleanerId: "rated", ServiceIds: []string{"service-1"}, Latitude: -1.2021, Longitude: 36.8219, Active: true},
+		{CleanerId: "unskilled", ServiceIds: []string{"service-2"}, Latitude: -1.2921, Longitude: 36.8219, Active: true},
+		{CleanerId: "too-far", ServiceIds: []string{"service-1"}, Latitude: -1.2021, Longitude: 36.8219, MaxDistanceKm: 5, Active: true},
+		{CleanerId: "inactive", ServiceIds: []string{"service-1"}, Latitude: -1.2921, Longitude: 36.8219},
+	}
+	for _, profile := range profiles {
+		if _, err := matching.UpsertCleanerProfile(ctx, profile); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := availability.SetWorkingHours(ctx, profile.CleanerId, []domain.WorkingHours{{Weekday: time.Monday, StartTime: "08:00", EndTime: "18:00"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, review := range []domain.Reviews{{ReviewId: "review-1", RequestId: "past-1", CleanerId: "near", Rating: 3, Status: domain.ReviewPublished}, {ReviewId: "review-2", RequestId: "past-2", CleanerId: "rated", Rating: 5, Status: domain.ReviewPublished}} {
+		if _, err := repo.CreateReview(ctx, review); err != nil {
+			t.Fatal(err)
+		}
+		delta := review.RatingDelta(1)
+		delta.SubjectType, delta.SubjectId = domain.RatingSubjectCleaner, review.CleanerId
+		if err := repo.AddRatings(ctx, delta); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	slot := nextMonday().Add(10 * time.Hour)
+	newRequest := func() *domain.Request {
+		request, err := requests.CreateRequest(ctx, domain.Request{ClientId: "client-1", ServiceId: "service-1", RequestedDate: slot, Latitude: -1.2921, Longitude: 36.8219})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return request
+	}
+
+	first := newRequest()
+	matches, err := matching.RankCleaners(ctx, first.RequestId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*matches) != 2 || (*matches)[0].CleanerId != "rated" || (*matches)[1].CleanerId != "near" {
+		t.Fatalf("expected rated then near, got %+v", *matches)
+	}
+
+	assigned, err := matching.AutoAssign(ctx, first.RequestId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assigned.CleanerId != "rated" || assigned.Status != domain.RequestAssigned {
+		t.Errorf("expected the request assigned to rated, got %s (%s)", assigned.CleanerId, assigned.Status)
+	}
+
+	second, err := matching.AutoAssign(ctx, newRequest().RequestId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.CleanerId != "near" {
+		t.Errorf("expected the next free cleaner to be picked, got %s", second.CleanerId)
+	}
+
+	if _, err := matching.AutoAssign(ctx, newRequest().RequestId); !errors.Is(err, domain.ErrNoEligibleCleaner) {
+		t.Errorf("expected ErrNoEligibleCleaner once every cleaner is booked, got %v", err)
+	}
+}
+
+func TestRequestQuoteSurvivesRateChange(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryClient()
+	catalogue := NewServiceServiceManagement(repo, testLogger{})
+	requests, _, _ := newRequestStack(repo)
+
+	service, err := catalogue.CreateService(ctx, domain.Service{Name: "Standard clean", HourlyRate: domain.NewMoney(80000, ""), DurationMinutes: 90})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if service.HourlyRate.Currency != domain.DefaultCurrency {
+		t.Errorf("expected the default currency, got %q", service.HourlyRate.Currency)
+	}
+
+	request, err := requests.CreateRequest(ctx, domain.Request{ClientId: "client-1", ServiceId: service.ServiceId, RequestedDate: nextMonday().Add(9 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request.Quote == nil || request.Quote.Total != domain.NewMoney(120000, domain.DefaultCurrency) {
+		t.Fatalf("expected a quote of KES 1200.00, got %+v", request.Quote)
+	}
+
+	service.HourlyRate = domain.NewMoney(100000, domain.DefaultCurrency)
+	if _, err := catalogue.UpdateService(ctx, *service); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := requests.GetRequestById(ctx, request.RequestId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Quote.Total != request.Quote.Total {
+		t.Errorf("expected the booked quote to be kept after a rate change, got %s", stored.Quote.Total)
+	}
+}
+
+func TestCompletingRequestIssuesInvoice(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryClient()
+	if _, err := repo.CreateService(ctx, domain.Service{ServiceId: "service-1", Name: "Deep clean", HourlyRate: domain.NewMoney(100000, "KES"), DurationMinutes: 120}); err != nil {
+		t.Fatal(err)
+	}
+	requests, availability, invoices := newRequestStack(repo)
+	if _, err := availability.SetWorkingHours(ctx, "cleaner-1", []domain.WorkingHours{{Weekday: time.Monday, StartTime: "08:00", EndTime: "18:00"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	complete := func(start time.Time) *domain.Invoice {
+		request, err := requests.CreateRequest(ctx, domain.Request{ClientId: "client-1", CleanerId: "cleaner-1", ServiceId: "service-1", RequestedDate: start})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := invoices.GenerateInvoice(ctx, request.RequestId); !errors.Is(err, domain.ErrInvalidInput) {
+			t.Errorf("expected an open request not to be invoiced, got %v", err)
+		}
+		for _, status := range []domain.RequestStatus{domain.RequestEnRoute, domain.RequestInProgress, domain.RequestCompleted} {
+			if _, err := requests.TransitionRequest(ctx, request.RequestId, status); err != nil {
+				t.Fatal(err)
+			}
+		}
+		invoice, err := invoices.GetInvoiceByRequest(ctx, request.RequestId)
+		if err != nil {
+			t.Fatalf("expected completion to issue an invoice: %v", err)
+		}
+		return invoice
+	}
+
+	first := complete(nextMonday().Add(9 * time.Hour))
+	if first.InvoiceNumber != "INV-000001" {
+		t.Errorf("expected the first invoice number, got %s", first.InvoiceNumber)
+	}
+	if first.Subtotal.Amount != 200000 || first.Tax.Amount != 32000 || first.Total.Amount != 232000 {
+		t.Errorf("unexpected totals: subtotal %s, tax %s, total %s", first.Subtotal, first.Tax, first.Total)
+	}
+
+	second := complete(nextMonday().Add(13 * time.Hour))
+	if second.InvoiceNumber != "INV-000002" {
+		t.Errorf("expected invoice numbers to be sequential, got %s", second.InvoiceNumber)
+	}
+
+	again, err := invoices.GenerateInvoice(ctx, first.RequestId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.InvoiceId != first.InvoiceId {
+		t.Error("expected generating an invoice twice to return the existing one")
+	}
+
+	page, err := invoices.GetInvoicesByClient(ctx, "client-1", domain.InvoiceFilter{ListOptions: domain.ListOptions{SortBy: "invoice_number", SortDesc: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 2 || page.Items[0].InvoiceId != second.InvoiceId {
+		t.Errorf("expected both invoices newest first, got %+v", page.Items)
+	}
+
+	document, err := invoices.RenderInvoicePDF(ctx, first.InvoiceId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(document, []byte("%PDF-")) || !bytes.HasSuffix(document, []byte("%%EOF\n")) {
+		t.Error("expected a PDF document")
+	}
+}
+
+func TestCancellationPolicyCharges(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryClient()
+	if _, err := repo.CreateService(ctx, domain.Service{ServiceId: "service-1", Name: "Deep clean", HourlyRate: domain.NewMoney(100000, "KES"), DurationMinutes: 120}); err != nil {
+		t.Fatal(err)
+	}
+	rules := domain.PricingRules{Cancellation: domain.CancellationPolicy{
+		FreeCancellationWindow: 24 * time.Hour,
+		LateCancellationFeeBps: 5000,
+		FreeRescheduleWindow:   24 * time.Hour,
+		LateRescheduleFeeBps:   1000,
+	}}
+	availability := NewAvailabilityServiceManagement(repo, repo, repo, time.UTC, testLogger{})
+	pricing := NewPricingServiceManagement(repo, rules, time.UTC, testLogger{})
+	invoices := NewInvoiceServiceManagement(repo, repo, pricing, pdf.NewInvoiceRenderer("Usafi Hub", time.UTC), 1600, testLogger{})
+	requests := NewRequestServiceManagement(repo, repo, repo, availability, pricing, invoices, testLogger{})
+
+	book := func(start time.Time) *domain.Request {
+		request, err := requests.CreateRequest(ctx, domain.Request{ClientId: "client-1", ServiceId: "service-1", RequestedDate: start})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return request
+	}
+	client := domain.BookingChange{Initiator: domain.InitiatorClient, By: "client-1", Reason: "travelling"}
+	soon := time.Now().Add(6 * time.Hour).Truncate(time.Minute)
+
+	early, err := requests.CancelRequest(ctx, book(nextMonday().Add(7*24*time.Hour)).RequestId, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if early.Status != domain.RequestCancelled || len(early.Changes) != 1 || !early.Changes[0].Fee.IsZero() {
+		t.Errorf("expected an early cancellation to be free and recorded, got %+v", early.Changes)
+	}
+	if _, err := invoices.GetInvoiceByRequest(ctx, early.RequestId); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("expected no invoice for a free cancellation, got %v", err)
+	}
+
+	late, err := requests.CancelRequest(ctx, book(soon).RequestId, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if late.Changes[0].Fee != domain.NewMoney(100000, "KES") || late.Changes[0].Reason != "travelling" {
+		t.Errorf("expected half the booking as the late cancellation fee, got %+v", late.Changes[0])
+	}
+	invoice, err := invoices.GetInvoiceByRequest(ctx, late.RequestId)
+	if err != nil {
+		t.Fatalf("expected the late cancellation fee to be invoiced: %v", err)
+	}
+	if invoice.Subtotal.Amount != 100000 {
+		t.Errorf("expected the invoice to bill only the fee, got %s", invoice.Subtotal)
+	}
+	if _, err := requests.CancelRequest(ctx, late.RequestId, client); !errors.Is(err, domain.ErrInvalidTransition) {
+		t.Errorf("expected a cancelled request not to be cancelled again, got %v", err)
+	}
+
+	waived := domain.BookingChange{Initiator: domain.InitiatorClient, By: "client-1", FeeWaived: true}
+	if _, err := requests.CancelRequest(ctx, book(soon).RequestId, waived); !errors.Is(err, domain.ErrForbidden) {
+		t.Errorf("expected only staff to waive a fee, got %v", err)
+	}
+	waived.Initiator, waived.By = domain.InitiatorStaff, "staff-1"
+	free, err := requests.CancelRequest(ctx, book(soon).RequestId, waived)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !free.Changes[0].Fee.IsZero() || !free.Changes[0].FeeWaived {
+		t.Errorf("expected the waived fee to be recorded as waived, got %+v", free.Changes[0])
+	}
+
+	moved := book(soon)
+	moved, err = requests.RescheduleRequest(ctx, moved.RequestId, soon.Add(48*time.Hour), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !moved.RequestedDate.Equal(soon.Add(48*time.Hour)) || moved.Changes[0].PreviousDate == nil || !moved.Changes[0].PreviousDate.Equal(soon) {
+		t.Errorf("expected the reschedule to move the booking and record where from, got %+v", moved.Changes)
+	}
+	if moved.Quote.Total != domain.NewMoney(220000, "KES") || moved.Quote.BookingTotal() != domain.NewMoney(200000, "KES") {
+		t.Errorf("expected a 10%% late reschedule fee on top of the booking, got %s", moved.Quote.Total)
+	}
+	if _, err := requests.UpdateRequest(ctx, domain.Request{RequestId: moved.RequestId, RequestedDate: soon}); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("expected the date to be changed only by rescheduling, got %v", err)
+	}
+
+	// The booking's fee is in shillings, the service is now priced in dollars
+	service, err := repo.GetServiceById(ctx, "service-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.HourlyRate = domain.NewMoney(1000, "USD")
+	if _, err := repo.UpdateService(ctx, *service); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := requests.RescheduleRequest(ctx, moved.RequestId, soon.Add(72*time.Hour), client); !errors.Is(err, domain.ErrCurrencyMismatch) {
+		t.Errorf("expected a reschedule across currencies to be refused, got %v", err)
+	}
+	stored, err := requests.GetRequestById(ctx, moved.RequestId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stored.RequestedDate.Equal(moved.RequestedDate) || stored.Quote.Total != moved.Quote.Total {
+		t.Errorf("expected the refused reschedule to leave the booking alone, got %+v", stored)
+	}
+}
+
+func TestCleanerCancellingReleasesRequest(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestRequestService(t)
+
+	request, err := svc.CreateRequest(ctx, domain.Request{ClientId: "client-1", ServiceId: "service-1", CleanerId: "cleaner-1", RequestedDate: nextMonday().Add(9 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cleaner := domain.BookingChange{Initiator: domain.InitiatorCleaner, By: "cleaner-1", Reason: "sick"}
+	if _, err := svc.RescheduleRequest(ctx, request.RequestId, request.RequestedDate.Add(time.Hour), cleaner); !errors.Is(err, domain.ErrForbidden) {
+		t.Errorf("expected cleaners not to reschedule, got %v", err)
+	}
+
+	released, err := svc.CancelRequest(ctx, request.RequestId, cleaner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if released.Status != domain.RequestPending || released.CleanerId != "" {
+		t.Errorf("expected the request back in dispatch, got %s with cleaner %q", released.Status, released.CleanerId)
+	}
+	if len(released.Changes) != 1 || released.Changes[0].Kind != domain.ChangeReleased || released.Changes[0].PreviousCleanerId != "cleaner-1" {
+		t.Errorf("expected the release to be recorded, got %+v", released.Changes)
+	}
+	if _, err := svc.CancelRequest(ctx, request.RequestId, cleaner); !errors.Is(err, domain.ErrInvalidTransition) {
+		t.Errorf("expected an unassigned request not to be released, got %v", err)
+	}
+}
+
+// failingInvoiceRepository fails every new invoice, as a database outage would
+type failingInvoiceRepository struct {
+	testRepository
+}
+
+func (failingInvoiceRepository) CreateInvoice(ctx context.Context, invoice domain.Invoice) (*domain.Invoice, error) {
+	return nil, domain.ErrUnavailable
+}
+
+func TestCompletionRolledBackWhenInvoiceFails(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryClient()
+	if _, err := repo.CreateService(ctx, domain.Service{ServiceId: "service-1", Name: "Deep clean", HourlyRate: domain.NewMoney(100000, "KES"), DurationMinutes: 120}); err != nil {
+		t.Fatal(err)
+	}
+	requests, availability, _ := newRequestStack(failingInvoiceRepository{repo})
+	if _, err := availability.SetWorkingHours(ctx, "cleaner-1", []domain.WorkingHours{{Weekday: time.Monday, StartTime: "08:00", EndTime: "18:00"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	request, err := requests.CreateRequest(ctx, domain.Request{ClientId: "client-1", CleanerId: "cleaner-1", ServiceId: "service-1", RequestedDate: nextMonday().Add(9 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range []domain.RequestStatus{domain.RequestEnRoute, domain.RequestInProgress} {
+		if _, err := requests.TransitionRequest(ctx, request.RequestId, status); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := requests.TransitionRequest(ctx, request.RequestId, domain.RequestCompleted); !errors.Is(err, domain.ErrUnavailable) {
+		t.Fatalf("expected the invoice failure to fail completion, got %v", err)
+	}
+	stored, err := repo.GetRequestById(ctx, request.RequestId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != domain.RequestInProgress {
+		t.Errorf("expected the request to stay in progress, got %s", stored.Status)
+	}
+}
+
+// interleavingRequestRepository runs interleave once a request has been read, as another caller
+// changing the request before the reader writes it back would
+type interleavingRequestRepository struct {
+	testRepository
+	interleave func()
+}
+
+func (r *interleavingRequestRepository) GetRequestById(ctx context.Context, requestId string) (*domain.Request, error) {
+	request, err := r.testRepository.GetRequestById(ctx, requestId)
+	if r.interleave != nil {
+		interleave := r.interleave
+		r.interleave = nil
+		interleave()
+	}
+	return request, err
+}
+
+func TestConcurrentTransitionsOnlyOneWins(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryClient()
+	if _, err := repo.CreateService(ctx, domain.Service{ServiceId: "service-1", Name: "Deep clean", HourlyRate: domain.NewMoney(100000, "KES"), DurationMinutes: 120}); err != nil {
+		t.Fatal(err)
+	}
+	other, availability, invoices := newRequestStack(repo)
+	interleaving := &interleavingRequestRepository{testRepository: repo}
+	requests, _, _ := newRequestStack(interleaving)
+	if _, err := availability.SetWorkingHours(ctx, "cleaner-1", []domain.WorkingHours{{Weekday: time.Monday, StartTime: "08:00", EndTime: "18:00"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	started, err := requests.CreateRequest(ctx, domain.Request{ClientId: "client-1", CleanerId: "cleaner-1", ServiceId: "service-1", RequestedDate: nextMonday().Add(9 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range []domain.RequestStatus{domain.RequestEnRoute, domain.RequestInProgress} {
+		if _, err := requests.TransitionRequest(ctx, started.RequestId, status); err != nil {
+			t.Fatal(err)
+		}
+	}
+	interleaving.interleave = func() {
+		if _, err := other.TransitionRequest(ctx, started.RequestId, domain.RequestCompleted); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := requests.TransitionRequest(ctx, started.RequestId, domain.RequestCompleted); !errors.Is(err, domain.ErrInvalidTransition) {
+		t.Errorf("expected the second completion to be refused, got %v", err)
+	}
+	page, err := invoices.GetInvoicesByClient(ctx, "client-1", domain.InvoiceFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 1 {
+		t.Errorf("expected a single invoice, got %d", page.Total)
+	}
+
+	pending, err := requests.CreateRequest(ctx, domain.Request{ClientId: "client-1", ServiceId: "service-1", RequestedDate: nextMonday().Add(13 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	interleaving.interleave = func() {
+		if _, err := other.CancelRequest(ctx, pending.RequestId, domain.BookingChange{Initiator: domain.InitiatorClient, By: "client-1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := requests.AssignCleaner(ctx, pending.RequestId, "cleaner-1"); !errors.Is(err, domain.ErrInvalidTransition) {
+		t.Errorf("expected assigning a request cancelled meanwhile to be refused, got %v", err)
+	}
+	stored, err := repo.GetRequestById(ctx, pending.RequestId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != domain.RequestCancelled || stored.CleanerId != "" {
+		t.Errorf("expected the request to stay cancelled without a cleaner, got %s with %q", stored.Status, stored.CleanerId)
+	}
+
+	assigned, err := requests.CreateRequest(ctx, domain.Request{ClientId: "client-1", CleanerId: "cleaner-1", ServiceId: "service-1", RequestedDate: nextMonday().Add(15 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	interleaving.interleave = func() {
+		if _, err := other.CancelRequest(ctx, assigned.RequestId, domain.BookingChange{Initiator: domain.InitiatorCleaner, By: "cleaner-1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := requests.CancelRequest(ctx, assigned.RequestId, domain.BookingChange{Initiator: domain.InitiatorClient, By: "client-1"}); !errors.Is(err, domain.ErrInvalidTransition) {
+		t.Errorf("expected a cancellation racing a release to be refused, got %v", err)
+	}
+	stored, err = repo.GetRequestById(ctx, assigned.RequestId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != domain.RequestPending || len(stored.Changes) != 1 {
+		t.Errorf("expected only the release to be recorded, got %s with %+v", stored.Status, stored.Changes)
+	}
+}
+
+// slowCalendarRepository takes its time reading a cleaner's bookings, leaving room for another
+// booking to be checked against the same calendar
+type slowCalendarRepository struct {
+	testRepository
+}
+
+func (r slowCalendarRepository) GetRequestByCleaner(ctx context.Context, cleanerId string, filter domain.RequestFilter) (*domain.Page[domain.Request], error) {
+	page, err := r.testRepository.GetRequestByCleaner(ctx, cleanerId, filter)
+	time.Sleep(10 * time.Millisecond)
+	return page, err
+}
+
+func TestConcurrentBookingsOfOneSlot(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryClient()
+	if _, err := repo.CreateService(ctx, domain.Service{ServiceId: "service-1", Name: "Deep clean", HourlyRate: domain.NewMoney(100000, "KES"), DurationMinutes: 120}); err != nil {
+		t.Fatal(err)
+	}
+	requests, availability, _ := newRequestStack(slowCalendarRepository{repo})
+	if _, err := availability.SetWorkingHours(ctx, "cleaner-1", []domain.WorkingHours{{Weekday: time.Monday, StartTime: "08:00", EndTime: "18:00"}}); err != nil {
+		t.Fatal(err)
+	}
+	pending, err := requests.CreateRequest(ctx, domain.Request{ClientId: "client-2", ServiceId: "service-1", RequestedDate: nextMonday().Add(10 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := make([]error, 2)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		_, errs[0] = requests.CreateRequest(ctx, domain.Request{ClientId: "client-1", CleanerId: "cleaner-1", ServiceId: "service-1", RequestedDate: nextMonday().Add(9 * time.Hour)})
+	}()
+	go func() {
+		defer wg.Done()
+		errs[1] = requests.AssignCleaner(ctx, pending.RequestId, "cleaner-1")
+	}()
+	wg.Wait()
+
+	if (errs[0] == nil) == (errs[1] == nil) {
+		t.Fatalf("expected exactly one booking of the overlapping slots, got %v and %v", errs[0], errs[1])
+	}
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, domain.ErrCleanerUnavailable) {
+			t.Errorf("expected the other booking to find the cleaner unavailable, got %v", err)
+		}
+	}
+}
+
+func TestPaymentSettlesThroughProviderCallback(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryClient()
+	if _, err := repo.CreateService(ctx, domain.Service{ServiceId: "service-1", Name: "Deep clean", HourlyRate: domain.NewMoney(100000, "KES"), DurationMinutes: 120}); err != nil {
+		t.Fatal(err)
+	}
+	requests, availability, _ := newRequestStack(repo)
+	if _, err := availability.SetWorkingHours(ctx, "cleaner-1", []domain.WorkingHours{{Weekday: time.Monday, StartTime: "08:00", EndTime: "18:00"}}); err != nil {
+		t.Fatal(err)
+	}
+	payments := NewPaymentServiceManagement(repo, repo, repo, repo, payment.NewSimulator(), testLogger{})
+
+	request, err := requests.CreateRequest(ctx, domain.Request{ClientId: "client-1", CleanerId: "cleaner-1", ServiceId: "service-1", RequestedDate: nextMonday().Add(9 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := payments.InitiatePayment(ctx, request.RequestId, "0712345678"); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("expected a request without an invoice not to be payable, got %v", err)
+	}
+	for _, status := range []domain.RequestStatus{domain.RequestEnRoute, domain.RequestInProgress, domain.RequestCompleted} {
+		if _, err := requests.TransitionRequest(ctx, request.RequestId, status); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := payments.InitiatePayment(ctx, request.RequestId, "12345"); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("expected an invalid phone number to be rejected, got %v", err)
+	}
+
+	failed, err := payments.InitiatePayment(ctx, request.RequestId, "0712 345 678")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed.PhoneNumber != "254712345678" || failed.Amount.Amount != 232000 || failed.Status != domain.PaymentPending {
+		t.Errorf("unexpected payment %+v", failed)
+	}
+	if _, err := payments.HandleCallback(ctx, payment.SimulatorCallback(failed.ProviderReference, failed.Amount, false)); err != nil {
+		t.Fatal(err)
+	}
+
+	paid, err := payments.InitiatePayment(ctx, request.RequestId, "+254712345678")
+	if err != nil {
+		t.Fatalf("expected a failed payment to be retryable: %v", err)
+	}
+	if _, err := payments.InitiatePayment(ctx, request.RequestId, "+254712345678"); !errors.Is(err, domain.ErrAlreadyExists) {
+		t.Errorf("expected a second charge to wait for the pending one, got %v", err)
+	}
+	callback := payment.SimulatorCallback(paid.ProviderReference, paid.Amount, true)
+	settled, err := payments.HandleCallback(ctx, callback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settled.Status != domain.PaymentSucceeded || settled.Receipt == "" {
+		t.Errorf("expected the payment to succeed with a receipt, got %+v", settled)
+	}
+
+	// Providers deliver callbacks more than once
+	if _, err := payments.HandleCallback(ctx, callback); err != nil {
+		t.Errorf("expected a repeated callback to be acknowledged, got %v", err)
+	}
+
+	dbRequest, err := requests.GetRequestById(ctx, request.RequestId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dbRequest.PaidAt == nil {
+		t.Error("expected the request to be marked paid")
+	}
+
+	if _, err := payments.InitiatePayment(ctx, request.RequestId, "0712345678"); !errors.Is(err, domain.ErrAlreadyExists) {
+		t.Errorf("expected a paid request not to be charged again, got %v", err)
+	}
+
+	history, err := payments.GetPaymentsByRequest(ctx, request.RequestId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*history) != 2 || (*history)[0].Status != domain.PaymentFailed {
+		t.Errorf("expected the failed and the successful payment, got %+v", *history)
+	}
+}
+
+func TestReviewRatingsKeepAggregatesCurrent(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryClient()
+	if _, err := repo.CreateService(ctx, domain.Service{ServiceId: "service-1", DurationMinutes: 120}); err != nil {
+		t.Fatal(err)
+	}
+	reviews := NewReviewServiceManagement(repo, repo, repo, moderation.NewWordlistFilter(nil), 14*24*time.Hour, testLogger{})
+	completeRequest(t, repo, domain.Request{RequestId: "request-1", ClientId: "client-1", CleanerId: "cleaner-1", ServiceId: "service-1"})
+	completeRequest(t, repo, domain.Request{RequestId: "request-2", ClientId: "client-1", CleanerId: "cleaner-1", ServiceId: "service-1"})
+
+	if _, err := reviews.CreateReview(ctx, domain.Reviews{RequestId: "request-1", ClientId: "client-1", Rating: 7}); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("expected a rating of 7 to be rejected, got %v", err)
+	}
+	punctual := 5
+	var ids []string
+	for _, review := range []domain.Reviews{
+		{RequestId: "request-1", ClientId: "client-1", Rating: 5, SubScores: domain.SubScores{Punctuality: &punctual}},
+		{RequestId: "request-2", ClientId: "client-1", Rating: 3},
+	} {
+		created, err := reviews.CreateReview(ctx, review)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if created.ServiceId != "service-1" {
+			t.Errorf("expected the review to take the request's service, got %q", created.ServiceId)
+		}
+		ids = append(ids, created.ReviewId)
+	}
+
+	if _, err := reviews.UpdateReview(ctx, domain.Reviews{ReviewId: ids[1], Rating: 4}); err != nil {
+		t.Fatal(err)
+	}
+	summary, err := reviews.GetCleanerRatingSummary(ctx, "cleaner-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Count != 2 || summary.Mean != 4.5 || summary.Histogram["3"] != 0 || summary.Histogram["4"] != 1 {
+		t.Errorf("expected the update to replace the 3 with a 4, got %+v", summary)
+	}
+	if summary.Punctuality == nil || *summary.Punctuality != 5 {
+		t.Errorf("expected a punctuality of 5, got %v", summary.Punctuality)
+	}
+
+	if err := reviews.DeleteReview(ctx, ids[0]); err != nil {
+		t.Fatal(err)
+	}
+	summary, err = reviews.GetServiceRatingSummary(ctx, "service-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Count != 1 || summary.Mean != 4 || summary.Punctuality != nil {
+		t.Errorf("expected only the 4 left, got %+v", summary)
+	}
+	// A lone review is weighed against five at the platform mean, which is that same review
+	if summary.WeightedScore != 4 {
+		t.Errorf("expected a weighted score of 4, got %v", summary.WeightedScore)
+	}
+}
+
+// completeRequest stores request as completed just now
+func completeRequest(t *testing.T, repo ports.RequestRepository, request domain.Request) {
+	ctx := context.Background()
+	if _, err := repo.CreateRequest(ctx, request); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.UpdateRequestStatus(ctx, request.RequestId, request.Status, domain.RequestCompleted); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReviewsOnlyForCompletedRequestsOfTheirClient(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryClient()
+	reviews := NewReviewServiceManagement(repo, repo, repo, moderation.NewWordlistFilter(nil), time.Hour, testLogger{})
+	completeRequest(t, repo, domain.Request{RequestId: "done", ClientId: "client-1", CleanerId: "cleaner-1", ServiceId: "service-1"})
+	if _, err := repo.CreateRequest(ctx, domain.Request{RequestId: "pending", ClientId: "client-1", Status: domain.RequestPending}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		review domain.Reviews
+		want   error
+	}{
+		{"unknown request", domain.Reviews{RequestId: "missing", ClientId: "client-1", Rating: 5}, domain.ErrNotFound},
+		{"another client", domain.Reviews{RequestId: "done", ClientId: "client-2", Rating: 5}, domain.ErrForbidden},
+		{"not completed", domain.Reviews{RequestId: "pending", ClientId: "client-1", Rating: 5}, domain.ErrNotReviewable},
+	}
+	for _, tt := range tests {
+		if _, err := reviews.CreateReview(ctx, tt.review); !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+	}
+
+	created, err := reviews.CreateReview(ctx, domain.Reviews{ReviewId: "chosen", RequestId: "done", ClientId: "client-1", CleanerId: "someone-else", Rating: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.ReviewId == "" || created.ReviewId == "chosen" || created.CleanerId != "cleaner-1" {
+		t.Errorf("expected a generated id and the request's cleaner, got %+v", created)
+	}
+	if _, err := reviews.CreateReview(ctx, domain.Reviews{RequestId: "done", ClientId: "client-1", Rating: 5}); !errors.Is(err, domain.ErrAlreadyExists) {
+		t.Errorf("expected a second review of the request to be rejected, got %v", err)
+	}
+
+	updated, err := reviews.UpdateReview(ctx, domain.Reviews{ReviewId: created.ReviewId, RequestId: "pending", ClientId: "client-2", Rating: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.RequestId != "done" || updated.ClientId != "client-1" || updated.Rating != 2 {
+		t.Errorf("expected only the rating to change, got %+v", updated)
+	}
+}
+
+func TestReviewModeration(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryClient()
+	reviews := NewReviewServiceManagement(repo, repo, repo, moderation.NewWordlistFilter([]string{"scam", "rip off"}), time.Hour, testLogger{})
+	completeRequest(t, repo, domain.Request{RequestId: "request-1", ClientId: "client-1", CleanerId: "cleaner-1", ServiceId: "service-1"})
+	completeRequest(t, repo, domain.Request{RequestId: "request-2", ClientId: "client-1", CleanerId: "cleaner-1", ServiceId: "service-1"})
+
+	held, err := reviews.CreateReview(ctx, domain.Reviews{RequestId: "request-1", ClientId: "client-1", Rating: 1, Comment: "A total R1P-OFF!"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if held.Status != domain.ReviewPending {
+		t.Errorf("expected the filter to hold the review, got %s", held.Status)
+	}
+	published, err := reviews.CreateReview(ctx, domain.Reviews{RequestId: "request-2", ClientId: "client-1", Rating: 5, Comment: "Spotless"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if published.Status != domain.ReviewPublished {
+		t.Errorf("expected a clean review to be published, got %s", published.Status)
+	}
+	summary, _ := reviews.GetCleanerRatingSummary(ctx, "cleaner-1")
+	if summary.Count != 1 {
+		t.Errorf("expected only the published review to count, got %d", summary.Count)
+	}
+
+	if _, err := reviews.RespondToReview(ctx, held.ReviewId, "cleaner-1", "Sorry to hear that"); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("expected no response to a held review, got %v", err)
+	}
+	if _, err := reviews.ApproveReview(ctx, held.ReviewId, "admin-1", "fair criticism"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reviews.ApproveReview(ctx, held.ReviewId, "admin-1", ""); !errors.Is(err, domain.ErrInvalidTransition) {
+		t.Errorf("expected approving twice to be refused, got %v", err)
+	}
+
+	flagged, err := reviews.FlagReview(ctx, held.ReviewId, "cleaner-1", "not true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flagged.Status != domain.ReviewFlagged || len(flagged.Flags) != 1 {
+		t.Errorf("expected the review flagged once, got %+v", flagged)
+	}
+	if _, err := reviews.FlagReview(ctx, held.ReviewId, "cleaner-1", "still not true"); !errors.Is(err, domain.ErrAlreadyExists) {
+		t.Errorf("expected a second flag from the same user to be refused, got %v", err)
+	}
+	queue, err := reviews.GetReviews(ctx, domain.ReviewFilter{Statuses: []domain.ReviewStatus{domain.ReviewPending, domain.ReviewFlagged}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queue.Items) != 1 || queue.Items[0].ReviewId != held.ReviewId {
+		t.Errorf("expected the flagged review in the queue, got %+v", queue.Items)
+	}
+
+	if _, err := reviews.RespondToReview(ctx, held.ReviewId, "cleaner-2", "Not my job"); !errors.Is(err, domain.ErrForbidden) {
+		t.Errorf("expected another cleaner not to respond, got %v", err)
+	}
+	if _, err := reviews.RespondToReview(ctx, held.ReviewId, "cleaner-1", "This is a scam review"); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("expected the filter to refuse the response, got %v", err)
+	}
+	responded, err := reviews.RespondToReview(ctx, held.ReviewId, "cleaner-1", "  We have refunded the visit.  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if responded.Response == nil || responded.Response.Body != "We have refunded the visit." {
+		t.Errorf("unexpected response %+v", responded.Response)
+	}
+
+	if _, err := reviews.RejectReview(ctx, held.ReviewId, "admin-1", "abusive"); err != nil {
+		t.Fatal(err)
+	}
+	summary, _ = reviews.GetCleanerRatingSummary(ctx, "cleaner-1")
+	if summary.Count != 1 || summary.Mean != 5 {
+		t.Errorf("expected the rejected review out of the ratings, got %+v", summary)
+	}
+
+	edited, err := reviews.UpdateReview(ctx, domain.Reviews{ReviewId: held.ReviewId, Rating: 2, Comment: "Late and careless"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edited.Status != domain.ReviewPending || edited.Response == nil {
+		t.Errorf("expected the edited review back in moderation with its response, got %+v", edited)
+	}
+}
+
+func TestRecurringBookingGeneratesOccurrences(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryClient()
+	if _, err := repo.CreateService(ctx, domain.Service{ServiceId: "service-1", Name: "Deep clean", DurationMinutes: 120}); err != nil {
+		t.Fatal(err)
+	}
+	requests, availability, _ := newRequestStack(repo)
+	week := []domain.WorkingHours{}
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		week = append(week, domain.WorkingHours{Weekday: day, StartTime: "08:00", EndTime: "18:00"})
+	}
+	if _, err := availability.SetWorkingHours(ctx, "cleaner-1", week); err != nil {
+		t.Fatal(err)
+	}
+
+	start := nextMonday().Add(10 * time.Hour)
+	second, third := start.AddDate(0, 0, 7), start.AddDate(0, 0, 14)
+	// The cleaner is already booked for the second occurrence
+	if _, err := requests.CreateRequest(ctx, domain.Request{ClientId: "client-2", ServiceId: "service-1", CleanerId: "cleaner-1", RequestedDate: second}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The horizon reaches the first two occurrences only
+	recurring := NewRecurringBookingServiceManagement(repo, repo, repo, requests, repo, time.Until(start)+8*24*time.Hour, time.UTC, testLogger{})
+	rule, _ := domain.ParseRecurrenceRule("FREQ=WEEKLY;COUNT=6")
+	booking, err := recurring.CreateRecurringBooking(ctx, domain.RecurringBooking{ClientId: "client-1", CleanerId: "cleaner-1", ServiceId: "service-1", StartsAt: start, Rule: rule})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if booking.RecurringBookingId == "" || booking.Status != domain.RecurringActive || !booking.GeneratedUntil.After(second) {
+		t.Errorf("expected an active series generated past its second occurrence, got %+v", booking)
+	}
+
+	booked := func() map[int64]domain.Request {
+		occurrences, err := recurring.occurrenceRequests(ctx, booking.RecurringBookingId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return occurrences
+	}
+	occurrences := booked()
+	if len(occurrences) != 2 {
+		t.Fatalf("expected the two occurrences within the horizon to be booked, got %d", len(occurrences))
+	}
+	if first := occurrences[start.Unix()]; first.CleanerId != "cleaner-1" || first.Status != domain.RequestAssigned || !first.RequestedDate.Equal(start) {
+		t.Errorf("expected the first occurrence booked with the series' cleaner, got %+v", first)
+	}
+	if clash := occurrences[second.Unix()]; clash.CleanerId != "" || clash.Status != domain.RequestPending {
+		t.Errorf("expected the occurrence the cleaner is busy for to be left for dispatch, got %+v", clash)
+	}
+	if err := recurring.GenerateOccurrences(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(booked()) != 2 {
+		t.Error("expected generating again to book nothing new")
+	}
+
+	if _, err := recurring.SkipOccurrence(ctx, booking.RecurringBookingId, start); err != nil {
+		t.Fatal(err)
+	}
+	moved := second.AddDate(0, 0, 1).Add(2 * time.Hour)
+	if _, err := recurring.RescheduleOccurrence(ctx, booking.RecurringBookingId, second, moved); err != nil {
+		t.Fatal(err)
+	}
+	// Not booked yet, the generator books it at the new time
+	later := third.Add(3 * time.Hour)
+	if _, err := recurring.RescheduleOccurrence(ctx, booking.RecurringBookingId, third, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := recurring.SkipOccurrence(ctx, booking.RecurringBookingId, start.Add(time.Hour)); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a time that is not an occurrence, got %v", err)
+	}
+
+	occurrences = booked()
+	if occurrences[start.Unix()].Status != domain.RequestCancelled {
+		t.Errorf("expected the skipped occurrence's request to be cancelled, got %s", occurrences[start.Unix()].Status)
+	}
+	if !occurrences[second.Unix()].RequestedDate.Equal(moved) {
+		t.Errorf("expected the rescheduled occurrence's request to move to %s, got %s", moved, occurrences[second.Unix()].RequestedDate)
+	}
+
+	wider := NewRecurringBookingServiceManagement(repo, repo, repo, requests, repo, time.Until(start)+15*24*time.Hour, time.UTC, testLogger{})
+	if err := wider.GenerateOccurrences(ctx); err != nil {
+		t.Fatal(err)
+	}
+	occurrences = booked()
+	if len(occurrences) != 3 || !occurrences[third.Unix()].RequestedDate.Equal(later) {
+		t.Errorf("expected the third occurrence booked at its new time, got %+v", occurrences[third.Unix()])
+	}
+
+	schedule, err := recurring.GetSchedule(ctx, booking.RecurringBookingId, start, start.AddDate(0, 2, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*schedule) != 6 || !(*schedule)[0].Skipped || !(*schedule)[1].ScheduledAt.Equal(moved) {
+		t.Errorf("expected six occurrences with the exceptions applied, got %+v", *schedule)
+	}
+
+	cancelled, err := recurring.CancelRecurringBooking(ctx, booking.RecurringBookingId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cancelled.Status != domain.RecurringCancelled || cancelled.CancelledAt == nil {
+		t.Errorf("expected the series to be cancelled, got %+v", cancelled)
+	}
+	for _, request := range booked() {
+		if request.Status != domain.RequestCancelled {
+			t.Errorf("expected every upcoming occurrence to be cancelled, got %s for %s", request.Status, request.RequestedDate)
+		}
+	}
+	if _, err := recurring.SkipOccurrence(ctx, booking.RecurringBookingId, third); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("expected a cancelled series to refuse changes, got %v", err)
+	}
+}
+
+// interleavingRecurringRepository runs interleave once the generator has listed the series to
+// generate, as a client changing a series while a run is under way would
+type interleavingRecurringRepository struct {
+	recurringTestRepository
+	interleave func()
+}
+
+type recurringTestRepository interface {
+	testRepository
+	ports.RecurringBookingRepository
+}
+
+func (r *interleavingRecurringRepository) GetActiveRecurringBookings(ctx context.Context, until time.Time) (*[]domain.RecurringBooking, error) {
+	bookings, err := r.recurringTestRepository.GetActiveRecurringBookings(ctx, until)
+	if r.interleave != nil {
+		r.interleave()
+		r.interleave = nil
+	}
+	return bookings, err
+}
+
+func TestRecurringGeneratorKeepsConcurrentChanges(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryClient()
+	if _, err := repo.CreateService(ctx, domain.Service{ServiceId: "service-1", Name: "Deep clean", DurationMinutes: 120}); err != nil {
+		t.Fatal(err)
+	}
+	requests, _, _ := newRequestStack(repo)
+	interleaving := &interleavingRecurringRepository{recurringTestRepository: repo}
+
+	start := nextMonday().Add(10 * time.Hour)
+	rule, _ := domain.ParseRecurrenceRule("FREQ=WEEKLY;COUNT=6")
+	// The client's service books the first occurrence, the generator's horizon reaches the third
+	recurring := NewRecurringBookingServiceManagement(repo, repo, repo, requests, repo, time.Until(start)+24*time.Hour, time.UTC, testLogger{})
+	generator := NewRecurringBookingServiceManagement(interleaving, repo, repo, requests, repo, time.Until(start)+15*24*time.Hour, time.UTC, testLogger{})
+	create := func() *domain.RecurringBooking {
+		booking, err := recurring.CreateRecurringBooking(ctx, domain.RecurringBooking{ClientId: "client-1", ServiceId: "service-1", StartsAt: start, Rule: rule})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return booking
+	}
+
+	skipped := create()
+	second := start.AddDate(0, 0, 7)
+	interleaving.interleave = func() {
+		if _, err := recurring.SkipOccurrence(ctx, skipped.RecurringBookingId, second); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cancelled := create()
+	if err := generator.GenerateOccurrences(ctx); err != nil {
+		t.Fatal(err)
+	}
+	booking, err := recurring.GetRecurringBookingById(ctx, skipped.RecurringBookingId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(booking.Exceptions) != 1 || !booking.GeneratedUntil.After(start.AddDate(0, 0, 14)) {
+		t.Errorf("expected the skip made during the run to be kept, got %+v", booking)
+	}
+	occurrences, err := recurring.occurrenceRequests(ctx, skipped.RecurringBookingId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := occurrences[second.Unix()]; ok || len(occurrences) != 2 {
+		t.Errorf("expected the first and third occurrences booked without the skipped one, got %d", len(occurrences))
+	}
+
+	interleaving.interleave = func() {
+		if _, err := recurring.CancelRecurringBooking(ctx, cancelled.RecurringBookingId); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := generator.GenerateOccurrences(ctx); err != nil {
+		t.Fatal(err)
+	}
+	booking, err = recurring.GetRecurringBookingById(ctx, cancelled.RecurringBookingId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if booking.Status != domain.RecurringCancelled {
+		t.Errorf("expected the series cancelled during the run to stay cancelled, got %s", booking.Status)
+	}
+	occurrences, err = recurring.occurrenceRequests(ctx, cancelled.RecurringBookingId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, request := range occurrences {
+		if request.Status != domain.RequestCancelled {
+			t.Errorf("expected nothing booked after the cancellation, got %s for %s", request.Status, request.RequestedDate)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package services
+
+import (
+	"context"
+
+	"github.com/AntonyIS/usafi-hub-cleaning-service/internal/core/ports"
+)
+
+// tracer starts a span for every service call. Tracing is off until SetTracer is called.
+var tracer ports.Tracer = noopTracer{}
+
+func SetTracer(t ports.Tracer) {
+	tracer = t
+}
+
+type noopTracer struct{}
+
+func (noopTracer) Start(ctx context.Context, name string) (context.Context, ports.Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(key string, value interface{}) {}
+func (noopSpan) RecordError(err error)                      {}
+func (noopSpan) End()                                       {}
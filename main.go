@@ -1,8 +1,11 @@
 package main
 
-import "github.com/AntonyIS/usafi-hub-cleaning-service/cmd"
+import (
+	"os"
 
+	"github.com/AntonyIS/usafi-hub-cleaning-service/cmd"
+)
 
-func main(){
-	cmd.RunService()
-}
\ No newline at end of file
+func main() {
+	cmd.Execute(os.Args[1:])
+}